@@ -0,0 +1,449 @@
+// account.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/lib/pq" // postgresql driver
+)
+
+// ERRORS
+var errEmailTaken = errors.New("email already belongs to another account")
+
+// HELPERS
+// the signed in user as the api shows them
+func (apiCfg *apiConfig) userResponse(user database.User) JsonUserResponse {
+	resp := JsonUserResponse{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		PendingEmail:  user.PendingEmail.String,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarURL:     apiCfg.avatarURL(user.ID, user.AvatarUrl),
+		IsChirpyRed:   user.IsChirpyRed,
+	}
+	if user.DeleteAfter.Valid {
+		resp.DeleteAfter = &user.DeleteAfter.Time
+	}
+	return resp
+}
+
+// check the caller's current password before a credential change, throttled like a login
+// writes the error response and returns false on failure
+func (apiCfg *apiConfig) checkCurrentPassword(w http.ResponseWriter, req *http.Request, user database.User, password string) bool {
+	// brute force check, guessing the current password counts as a login attempt
+	ip := clientIP(req)
+	wait := apiCfg.loginWait(req.Context(), user.Email, ip)
+
+	// throttled check
+	if wait > 0 {
+		log.Printf("Password check throttled for %s from %s: retry in %s", user.Email, ip, wait) // log msg
+		// helper to insert error msg + 429 too many requests status code
+		WriteTooManyRequests(w, "Too many attempts, try again later", wait)
+		return false
+	}
+
+	// compare the current password
+	_, err := apiCfg.passwordHasher.Verify(user.HashedPassword, password)
+
+	// current password check
+	if err != nil {
+		log.Printf("Error invalid current password for user %s: %s", user.ID, err) // log msg with err
+		apiCfg.loginFailed(req.Context(), user.Email, ip)                          // count the failure
+		// helper to insert error msg + 401 unauthorised error status code
+		WriteJSONError(w, "Incorrect current password", http.StatusUnauthorized)
+		return false
+	}
+
+	// correct password, clear the account's failures
+	apiCfg.loginSucceeded(req.Context(), user.Email)
+	return true
+}
+
+// park a new address and email it a confirmation link, the change lands once the new inbox confirms
+// asking for the current address cancels a pending change, returns what is now pending
+func (apiCfg *apiConfig) requestEmailChange(ctx context.Context, user database.User, newEmail string) (sql.NullString, error) {
+	// back to the current address, cancel anything pending
+	if newEmail == user.Email {
+		if !user.PendingEmail.Valid {
+			return sql.NullString{}, nil // nothing to cancel
+		}
+		return sql.NullString{}, apiCfg.db.SetUserPendingEmail(ctx, database.SetUserPendingEmailParams{
+			ID: user.ID,
+		})
+	}
+
+	// taken check (the unique index catches races at confirm time)
+	owner, err := apiCfg.db.GetUserByEmail(ctx, newEmail)
+	if err == nil && owner.ID != user.ID {
+		return sql.NullString{}, errEmailTaken
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return sql.NullString{}, err
+	}
+
+	// park it
+	pending := sql.NullString{String: newEmail, Valid: true}
+	err = apiCfg.db.SetUserPendingEmail(ctx, database.SetUserPendingEmailParams{
+		ID:           user.ID,
+		PendingEmail: pending,
+	})
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	// email the new address a confirmation link and warn the old one (best effort)
+	err = apiCfg.sendEmailChangeConfirmation(ctx, user, newEmail)
+	if err != nil {
+		log.Printf("Error sending email change confirmation: %s", err) // log msg with err
+	}
+	err = apiCfg.sendEmailChangeNotice(ctx, user, newEmail)
+	if err != nil {
+		log.Printf("Error sending email change notice: %s", err) // log msg with err
+	}
+
+	return pending, nil
+}
+
+// HANDLERS
+// GetMe handler that returns the caller's own account
+func (apiCfg *apiConfig) handlerGetMe(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the user
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, apiCfg.userResponse(user), http.StatusOK)
+}
+
+// PatchMe handler that updates only the account fields the caller sends
+func (apiCfg *apiConfig) handlerPatchMe(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqPatch JsonUserPatchRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqPatch)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqPatch is now successfully populated
+
+	// is a public profile field changing
+	profileChanging := reqPatch.Handle != nil || reqPatch.DisplayName != nil || reqPatch.Bio != nil || reqPatch.AvatarURL != nil
+
+	// nothing to do check
+	if reqPatch.Email == nil && !profileChanging {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "No fields to update", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the user
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// check the profile fields before anything is written
+	profile, problem := applyProfilePatch(user, reqPatch)
+	if problem != "" {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, problem, http.StatusBadRequest)
+		return // early return
+	}
+
+	// email change
+	if reqPatch.Email != nil {
+		// the address is how the account is recovered, tokens and apps can't move it
+		if !caller.isSession() {
+			// helper to insert error msg + 403 forbidden status code
+			WriteJSONError(w, "Changing email requires a login session", http.StatusForbidden)
+			return // early return
+		}
+
+		// syntax check and case fold
+		newEmail, err := normaliseEmail(*reqPatch.Email)
+		if err != nil {
+			log.Printf("Error invalid email: %s", err) // log msg with err
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Email is not a valid address", http.StatusBadRequest)
+			return // early return
+		}
+
+		// re-authentication check
+		if reqPatch.CurrentPassword == "" {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Current password is required", http.StatusBadRequest)
+			return // early return
+		}
+
+		// current password check (writes the error on failure)
+		if !apiCfg.checkCurrentPassword(w, req, user, reqPatch.CurrentPassword) {
+			return // early return
+		}
+
+		// park the new address until it's confirmed
+		user.PendingEmail, err = apiCfg.requestEmailChange(req.Context(), user, newEmail)
+
+		// taken check
+		if errors.Is(err, errEmailTaken) {
+			// helper to insert error msg + 409 conflict status code
+			WriteJSONError(w, "User email is already used", http.StatusConflict)
+			return // early return
+		}
+
+		// email change check (general)
+		if err != nil {
+			log.Printf("Error requesting email change: %s", err) // log msg with err
+			// helper to insert error msg + 500 internal error status code
+			WriteJSONError(w, "Error occurred updating user", http.StatusInternalServerError)
+			return // early return
+		}
+	}
+
+	// profile change
+	if profileChanging {
+		// keep what the email step parked
+		pendingEmail := user.PendingEmail
+
+		// store every profile field, changed or not
+		user, err = apiCfg.db.UpdateUserProfile(req.Context(), profile)
+
+		// taken check, handles are unique whatever the case
+		if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+			// helper to insert error msg + 409 conflict status code
+			WriteJSONError(w, "Handle is already taken", http.StatusConflict)
+			return // early return
+		}
+
+		// profile update check (general)
+		if err != nil {
+			log.Printf("Error updating profile for user %s: %s", caller.UserID, err) // log msg with err
+			// helper to insert error msg + 500 internal error status code
+			WriteJSONError(w, "Error occurred updating user", http.StatusInternalServerError)
+			return // early return
+		}
+		user.PendingEmail = pendingEmail
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, apiCfg.userResponse(user), http.StatusOK)
+}
+
+// ChangePassword handler that sets a new password given the old one
+// every other session is signed out, the caller gets a fresh token pair to stay signed in
+func (apiCfg *apiConfig) handlerChangePassword(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqChange JsonChangePasswordRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqChange)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqChange is now successfully populated
+
+	// re-authentication check
+	if reqChange.CurrentPassword == "" {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Current password is required", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the user
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// current password check (writes the error on failure)
+	if !apiCfg.checkCurrentPassword(w, req, user, reqChange.CurrentPassword) {
+		return // early return
+	}
+
+	// same password check, hashes are salted so verify rather than compare them
+	if _, err := apiCfg.passwordHasher.Verify(user.HashedPassword, reqChange.NewPassword); err == nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "New password must differ from the current one", http.StatusBadRequest)
+		return // early return
+	}
+
+	// password policy check (writes field errors on failure)
+	if !apiCfg.checkPasswordPolicy(w, reqChange.NewPassword, user.Email) {
+		return // early return
+	}
+
+	// hash the new password
+	hash, err := apiCfg.passwordHasher.Hash(reqChange.NewPassword)
+
+	// hash check
+	if err != nil {
+		log.Printf("Error hashing password: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// store it
+	err = apiCfg.db.UpdateUserPasswordHash(req.Context(), database.UpdateUserPasswordHashParams{
+		ID:             user.ID,
+		HashedPassword: hash,
+	})
+
+	// update password check
+	if err != nil {
+		log.Printf("Error updating password: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred changing password", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// sign out everywhere (access tokens run out within the hour)
+	err = apiCfg.db.RevokeAllRefreshTokensForUser(req.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error revoking refresh tokens after password change: %s", err) // log msg with err
+	}
+
+	// then sign this session back in with a fresh pair
+	respLogin, err := apiCfg.issueLoginTokens(req.Context(), user)
+
+	// issue tokens check
+	if err != nil {
+		log.Printf("Error issuing tokens after password change: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Password changed, please log in again", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	log.Printf("Password changed for user %s", user.ID) // log msg
+	WriteJSONResponse(w, respLogin, http.StatusOK)
+}
@@ -0,0 +1,83 @@
+// account_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test the account response shows verification and pending changes
+func TestUserResponse(t *testing.T) {
+	user := database.User{
+		ID:              uuid.New(),
+		Email:           "old@example.com",
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		PendingEmail:    sql.NullString{String: "new@example.com", Valid: true},
+	}
+	apiCfg := &apiConfig{baseURL: "https://chirpy.example"}
+	resp := apiCfg.userResponse(user)
+	if !resp.EmailVerified {
+		t.Errorf("EmailVerified = false, want true")
+	}
+	if resp.Email != "old@example.com" || resp.PendingEmail != "new@example.com" {
+		t.Errorf("email = %q pending = %q, want old@example.com and new@example.com", resp.Email, resp.PendingEmail)
+	}
+
+	// no pending change, nothing shown
+	user.PendingEmail = sql.NullString{}
+	if resp := apiCfg.userResponse(user); resp.PendingEmail != "" {
+		t.Errorf("PendingEmail = %q, want empty", resp.PendingEmail)
+	}
+
+	// no avatar link, the served avatar is shown
+	if want := "https://chirpy.example/api/users/" + user.ID.String() + "/avatar"; resp.AvatarURL != want {
+		t.Errorf("AvatarURL = %q, want %q", resp.AvatarURL, want)
+	}
+}
+
+// test patching needs at least one field
+func TestPatchMeNoFields(t *testing.T) {
+	apiCfg := &apiConfig{}
+
+	// build test cases
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{"empty body", ""},
+		{"no fields", `{}`},
+		{"only the password", `{"current_password": "hunter2"}`},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPatch, "/api/users/me", strings.NewReader(tc.body))
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerPatchMe(rec, req.WithContext(ctx))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// test changing the password needs the current one
+func TestChangePasswordNeedsCurrent(t *testing.T) {
+	apiCfg := &apiConfig{}
+	req := httptest.NewRequest(http.MethodPost, "/api/users/me/password", strings.NewReader(`{"new_password": "a much better password"}`))
+	ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+	rec := httptest.NewRecorder()
+	apiCfg.handlerChangePassword(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
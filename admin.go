@@ -0,0 +1,303 @@
+// admin.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// AdminSetUserRole handler that changes a user's role (admins only)
+func (apiCfg *apiConfig) handlerAdminSetUserRole(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// HTTP method check
+	if req.Method != "PUT" {
+		// helper to insert error msg + 405 invalid method status code
+		WriteJSONError(w, "User role must be PUTed", http.StatusMethodNotAllowed)
+		return // early return
+	}
+
+	// get user id from api endpoint path string
+	userUUID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		log.Printf("Error getting user ID: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// json request from client
+	var reqRole JsonRoleRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err = decoder.Decode(&reqRole)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Role is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// known role check
+	if !auth.ValidRole(reqRole.Role) {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Role must be user, moderator or admin", http.StatusBadRequest)
+		return // early return
+	}
+
+	// set the role
+	updated, err := apiCfg.db.SetUserRole(req.Context(), database.SetUserRoleParams{
+		ID:   userUUID,
+		Role: reqRole.Role,
+	})
+
+	// user doesn't exist check
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("User could not be found: ID = %s", userUUID) // msg to server admin
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// set role check
+	if err != nil {
+		log.Printf("Error setting user role: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// role lands in their next access token (at most an hour away)
+	log.Printf("User role changed: ID = %s, role = %s", updated.ID, updated.Role) // log msg
+
+	// json response payload
+	respRole := JsonRoleResponse{
+		ID:        updated.ID,
+		Role:      updated.Role,
+		UpdatedAt: updated.UpdatedAt,
+	}
+
+	// helper to insert body response + 200 OK status code
+	WriteJSONResponse(w, respRole, http.StatusOK)
+}
+
+// AdminUnlockUser handler that clears a user's failed logins and lockout (admins only)
+func (apiCfg *apiConfig) handlerAdminUnlockUser(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// HTTP method check
+	if req.Method != "POST" {
+		// helper to insert error msg + 405 invalid method status code
+		WriteJSONError(w, "User unlock must be POSTed", http.StatusMethodNotAllowed)
+		return // early return
+	}
+
+	// get user id from api endpoint path string
+	userUUID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		log.Printf("Error getting user ID: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the user, attempts are tracked by the email they log in with
+	user, err := apiCfg.db.GetUserByID(req.Context(), userUUID)
+
+	// user doesn't exist check
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("User could not be found: ID = %s", userUUID) // msg to server admin
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// clear the account's failures and lockout
+	err = apiCfg.accountLimiter.Reset(req.Context(), accountThrottleKey(user.Email))
+
+	// reset check
+	if err != nil {
+		log.Printf("Error unlocking user: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// write to server and client that user unlocked
+	log.Printf("User has been unlocked: ID = %s", user.ID) // log msg
+	w.WriteHeader(http.StatusNoContent)                    // status code 204 to client
+}
+
+// AdminRevokeAccessToken handler that denylists an access token before it expires (admins only)
+func (apiCfg *apiConfig) handlerAdminRevokeAccessToken(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// HTTP method check
+	if req.Method != "POST" {
+		// helper to insert error msg + 405 invalid method status code
+		WriteJSONError(w, "Token revocation must be POSTed", http.StatusMethodNotAllowed)
+		return // early return
+	}
+
+	// json request from client
+	var reqRevoke JsonRevokeAccessTokenRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqRevoke)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Token or jti is required", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// without the token we don't know the expiry, so assume the longest one
+	revoke := database.RevokeAccessTokenParams{
+		Jti:       reqRevoke.JTI,
+		ExpiresAt: time.Now().UTC().Add(accessTokenDuration),
+		Reason:    reqRevoke.Reason,
+	}
+
+	// with the token we know exactly who and until when
+	if reqRevoke.Token != "" {
+		// signature must still be ours (no denylist, it may already be denied)
+		claims, err := auth.ValidateAccessToken(reqRevoke.Token, apiCfg.serverKey, nil)
+
+		// validation check (expired tokens need no revoking)
+		if err != nil {
+			log.Printf("Error validating token to revoke: %s", err) // log msg with err
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Token is invalid or already expired", http.StatusBadRequest)
+			return // early return
+		}
+
+		revoke.Jti = claims.ID
+		revoke.UserID = uuid.NullUUID{UUID: claims.UserID(), Valid: true}
+		revoke.ExpiresAt = claims.ExpiresAt.Time.UTC()
+	}
+
+	// token id check
+	if revoke.Jti == "" {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Token or jti is required", http.StatusBadRequest)
+		return // early return
+	}
+
+	// persist so every instance (and restarts) pick it up
+	err = apiCfg.db.RevokeAccessToken(req.Context(), revoke)
+
+	// revoke check
+	if err != nil {
+		log.Printf("Error revoking access token: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// deny it here right away, other instances catch up on their next sync
+	apiCfg.denylist.Deny(revoke.Jti, revoke.ExpiresAt)
+
+	// write to server and client that token revoked
+	log.Printf("Access token has been revoked: jti = %s", revoke.Jti) // log msg
+	w.WriteHeader(http.StatusNoContent)                               // status code 204 to client
+}
+
+// HELPER FUNCS
+
+// promote an existing user to admin, so a fresh deployment has someone to grant roles
+func (apiCfg *apiConfig) bootstrapAdmin(ctx context.Context, email string) error {
+	// get the user by email
+	user, err := apiCfg.db.GetUserByEmail(ctx, email)
+
+	// get user check
+	if err != nil {
+		return err // early return
+	}
+
+	// already an admin check
+	if user.Role == auth.RoleAdmin {
+		return nil
+	}
+
+	// promote them
+	_, err = apiCfg.db.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: auth.RoleAdmin,
+	})
+	return err
+}
@@ -0,0 +1,331 @@
+// apitokens.go
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// personal access token limits
+const (
+	apiTokenDefaultDays = 30  // expiry when none is requested
+	apiTokenMaxDays     = 365 // longest expiry we allow
+	apiTokenMaxNameLen  = 100 // longest label we allow
+	apiTokenPrefixLen   = len(auth.APITokenPrefix) + 6
+)
+
+// CreateAPIToken handler that issues a scoped personal access token
+func (apiCfg *apiConfig) handlerCreateAPIToken(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// HTTP method check
+	if req.Method != "POST" {
+		// helper to insert error msg + 405 invalid method status code
+		WriteJSONError(w, "API token creation must be POSTed", http.StatusMethodNotAllowed)
+		return // early return
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqToken JsonAPITokenRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqToken)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqToken is now successfully populated
+
+	// check name
+	if len(reqToken.Name) == 0 || len(reqToken.Name) > apiTokenMaxNameLen {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Token name must be 1-100 characters", http.StatusBadRequest)
+		return // early return
+	}
+
+	// check scopes, at least one and all known
+	scopes, err := normaliseScopes(reqToken.Scopes)
+
+	// scopes check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid scopes: "+err.Error(), http.StatusBadRequest)
+		return // early return
+	}
+
+	// default the expiry when omitted
+	expiresInDays := apiTokenDefaultDays
+	if reqToken.ExpiresInDays != nil {
+		expiresInDays = *reqToken.ExpiresInDays
+	}
+
+	// check expiry range
+	if expiresInDays < 1 || expiresInDays > apiTokenMaxDays {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Token expiry must be 1-365 days", http.StatusBadRequest)
+		return // early return
+	}
+
+	// generate the token
+	tokenString, err := auth.MakeAPIToken()
+
+	// generate token check
+	if err != nil {
+		log.Printf("Error making api token: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Internal server token generation error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// store only the hash, the token itself is shown once
+	apiToken, err := apiCfg.db.CreateAPIToken(req.Context(), database.CreateAPITokenParams{
+		UserID:      caller.UserID,
+		Name:        reqToken.Name,
+		TokenHash:   auth.HashAPIToken(tokenString),
+		TokenPrefix: tokenString[:apiTokenPrefixLen],
+		Scopes:      scopes,
+		ExpiresAt:   time.Now().UTC().Add(time.Duration(expiresInDays) * 24 * time.Hour),
+	})
+
+	// create token check
+	if err != nil {
+		log.Printf("Error adding api token to database: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Error occurred creating API token", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload, only time the token is returned
+	respToken := apiTokenResponse(apiToken)
+	respToken.Token = tokenString
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, respToken, http.StatusCreated)
+}
+
+// ListAPITokens handler that lists the caller's active personal access tokens
+func (apiCfg *apiConfig) handlerListAPITokens(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// HTTP method check
+	if req.Method != http.MethodGet {
+		// helper to insert error msg + 405 invalid method status code
+		WriteJSONError(w, "API tokens must be GETted", http.StatusMethodNotAllowed)
+		return // early return
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the caller's tokens
+	apiTokens, err := apiCfg.db.ListAPITokensByUser(req.Context(), caller.UserID)
+
+	// list tokens check
+	if err != nil {
+		log.Printf("Error listing api tokens: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Failed to retrieve API tokens", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// transform database tokens into JSON response format
+	tokenResponses := make([]JsonAPITokenResponse, len(apiTokens))
+	for i, apiToken := range apiTokens {
+		tokenResponses[i] = apiTokenResponse(apiToken)
+	}
+
+	// helper to insert body response + 200 OK status code
+	WriteJSONResponse(w, tokenResponses, http.StatusOK)
+}
+
+// RevokeAPIToken handler that revokes one of the caller's personal access tokens
+func (apiCfg *apiConfig) handlerRevokeAPIToken(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// HTTP method check
+	if req.Method != http.MethodDelete {
+		// helper to insert error msg + 405 invalid method status code
+		WriteJSONError(w, "API token must be DELETEd", http.StatusMethodNotAllowed)
+		return // early return
+	}
+
+	// get token id from api endpoint path string
+	tokenUUID, err := uuid.Parse(req.PathValue("tokenID"))
+
+	// uuid conv check
+	if err != nil {
+		log.Printf("Error getting api token ID: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid API token ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// oauth clients have no business with the user's api tokens
+	if caller.isDelegated() {
+		log.Printf("Error oauth client %s tried to revoke api token %s", caller.ClientID, tokenUUID) // log msg
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "OAuth clients cannot manage API tokens", http.StatusForbidden)
+		return // early return
+	}
+
+	// a token may revoke itself (leaked token cleanup), but no other token
+	if caller.isAPIToken() && caller.APITokenID.UUID != tokenUUID {
+		log.Printf("Error api token %s tried to revoke api token %s", caller.APITokenID.UUID, tokenUUID) // log msg
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "API tokens cannot manage API tokens", http.StatusForbidden)
+		return // early return
+	}
+
+	// revoke it, only matches the caller's own tokens
+	_, err = apiCfg.db.RevokeAPIToken(req.Context(), database.RevokeAPITokenParams{
+		ID:     tokenUUID,
+		UserID: caller.UserID,
+	})
+
+	// not found (or not theirs, or already revoked) check
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error api token not found: %s", tokenUUID) // log msg
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "API token not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// revoke check
+	if err != nil {
+		log.Printf("Error revoking api token: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// write to server and client that token revoked
+	log.Printf("API token has been revoked: ID = %s", tokenUUID) // log msg
+	w.WriteHeader(http.StatusNoContent)                          // status code 204 to client
+}
+
+// HELPER FUNCS
+
+// check requested scopes are known, dropping duplicates
+func normaliseScopes(requested []string) ([]string, error) {
+	// at least one scope
+	if len(requested) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	// keep the first of each, in order
+	seen := make(map[string]bool, len(requested))
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		// unknown scope check
+		if !auth.ValidScope(scope) {
+			return nil, errors.New("unknown scope: " + scope)
+		}
+
+		// duplicate check
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+
+	return scopes, nil
+}
+
+// build a token response (never includes the token itself)
+func apiTokenResponse(apiToken database.ApiToken) JsonAPITokenResponse {
+	resp := JsonAPITokenResponse{
+		ID:        apiToken.ID,
+		CreatedAt: apiToken.CreatedAt,
+		Name:      apiToken.Name,
+		Prefix:    apiToken.TokenPrefix,
+		Scopes:    apiToken.Scopes,
+		ExpiresAt: apiToken.ExpiresAt,
+	}
+
+	// only set last used when it has been used
+	if apiToken.LastUsedAt.Valid {
+		resp.LastUsedAt = &apiToken.LastUsedAt.Time
+	}
+
+	return resp
+}
@@ -0,0 +1,40 @@
+// apitokens_test.go
+
+package main
+
+import (
+	"reflect"
+	"testing" // importing testing package for unit tests
+)
+
+// test normaliseScopes
+func TestNormaliseScopes(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		name      string
+		input     []string
+		expected  []string
+		expectErr bool
+	}{
+		{"Test case: Single Scope", []string{"chirps:read"}, []string{"chirps:read"}, false},
+		{"Test case: Duplicates Dropped", []string{"chirps:write", "chirps:read", "chirps:write"}, []string{"chirps:write", "chirps:read"}, false},
+		{"Test case: No Scopes", []string{}, nil, true},
+		{"Test case: Unknown Scope", []string{"chirps:read", "admin:everything"}, nil, true},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		actual, err := normaliseScopes(tc.input)
+
+		// check if err bool matches the expected err
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%s: error = %v, expectErr %v", tc.name, err, tc.expectErr)
+			continue
+		}
+
+		// check scopes match
+		if !tc.expectErr && !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: got = %v, want %v", tc.name, actual, tc.expected)
+		}
+	}
+}
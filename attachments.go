@@ -0,0 +1,401 @@
+// attachments.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/blobstore"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+// attachment limits
+const (
+	maxAttachmentsPerChirp = 4                                     // like a photo grid
+	freeUploadLimit        = 5 << 20                               // 5 MiB per image
+	chirpyRedUploadLimit   = 20 << 20                              // 20 MiB per image, premium perk
+	multipartOverhead      = 64 << 10                              // room for the form framing around the file
+	mediaCacheControl      = "public, max-age=31536000, immutable" // keys never change content
+	maxPendingUploads      = 20                                    // uploads a user may hold that no chirp uses yet
+	pendingUploadTTL       = 24 * time.Hour                        // after which an unused upload is swept
+)
+
+// build the blob store from the environment
+// MEDIA_STORE picks file (default, writes to MEDIA_DIR), the only kind so far
+func blobStoreFromEnv() (blobstore.BlobStore, error) {
+	switch kind := strings.TrimSpace(os.Getenv("MEDIA_STORE")); kind {
+	case "", "file":
+		// media dir
+		dir := strings.TrimSpace(os.Getenv("MEDIA_DIR"))
+		if dir == "" {
+			dir = "media"
+		}
+		return blobstore.NewFileStore(dir), nil
+
+	default:
+		return nil, fmt.Errorf("MEDIA_STORE must be file, not %q", kind)
+	}
+}
+
+// the largest image a plan may upload
+func uploadLimit(plan string) int64 {
+	if plan == auth.PlanChirpyRed {
+		return chirpyRedUploadLimit
+	}
+	return freeUploadLimit
+}
+
+// where a stored blob is served from
+func (apiCfg *apiConfig) mediaURL(key string) string {
+	return strings.TrimRight(apiCfg.baseURL, "/") + "/media/" + key
+}
+
+// an attachment as the api shows it
+func (apiCfg *apiConfig) attachmentResponse(attachment database.Attachment) JsonAttachmentResponse {
+	return JsonAttachmentResponse{
+		ID:           attachment.ID,
+		URL:          apiCfg.mediaURL(attachment.BlobKey),
+		ThumbnailURL: apiCfg.mediaURL(attachment.ThumbnailKey),
+		ContentType:  attachment.ContentType,
+		Width:        attachment.Width,
+		Height:       attachment.Height,
+	}
+}
+
+// look up the attachments of the given chirps, every chirp gets a (maybe empty) list
+func (apiCfg *apiConfig) chirpAttachments(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]JsonAttachmentResponse, error) {
+	// empty lists, never null in the json
+	byChirp := make(map[uuid.UUID][]JsonAttachmentResponse, len(chirpIDs))
+	for _, id := range chirpIDs {
+		byChirp[id] = []JsonAttachmentResponse{}
+	}
+	if len(chirpIDs) == 0 {
+		return byChirp, nil
+	}
+
+	// one query for the whole page, already in order
+	attachments, err := apiCfg.db.ListAttachmentsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		byChirp[attachment.ChirpID.UUID] = append(byChirp[attachment.ChirpID.UUID], apiCfg.attachmentResponse(attachment))
+	}
+	return byChirp, nil
+}
+
+// remove an attachment's blobs, best effort as the rows are already gone
+func (apiCfg *apiConfig) deleteAttachmentBlobs(ctx context.Context, attachments []database.Attachment) {
+	for _, attachment := range attachments {
+		for _, key := range []string{attachment.BlobKey, attachment.ThumbnailKey} {
+			err := apiCfg.blobStore.Delete(ctx, key)
+			if err != nil {
+				log.Printf("Error deleting blob %s: %s", key, err)
+			}
+		}
+	}
+}
+
+// ATTACHMENT SWEEP
+// delete uploads no chirp used within pendingUploadTTL, along with their blobs
+func (apiCfg *apiConfig) sweepStaleAttachments(ctx context.Context) error {
+	// forget the rows first, so a chirp can't pick one up while its blobs go
+	stale, err := apiCfg.db.DeleteStaleAttachments(ctx, time.Now().UTC().Add(-pendingUploadTTL))
+
+	// delete stale check
+	if err != nil {
+		return err // early return
+	}
+
+	// the blobs are unreachable now
+	apiCfg.deleteAttachmentBlobs(ctx, stale)
+	if len(stale) > 0 {
+		log.Printf("Swept %d unused uploads", len(stale))
+	}
+
+	return nil
+}
+
+// sweep unused uploads on an interval, blocks so run it in a goroutine
+func (apiCfg *apiConfig) runAttachmentSweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// sweep on each tick
+	for range ticker.C {
+		err := apiCfg.sweepStaleAttachments(context.Background())
+
+		// sweep check (whatever is left goes next tick)
+		if err != nil {
+			log.Printf("Error sweeping unused uploads: %s", err)
+		}
+	}
+}
+
+// read the image in the "file" field of a multipart upload, capped at limit bytes
+// writes the error response and returns false on failure
+func readUploadFile(w http.ResponseWriter, req *http.Request, limit int64) ([]byte, bool) {
+	// cap the whole body before reading any of it
+	req.Body = http.MaxBytesReader(w, req.Body, limit+multipartOverhead)
+	defer req.Body.Close()
+
+	// multipart check
+	reader, err := req.MultipartReader()
+	if err != nil {
+		log.Printf("Error reading multipart upload: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Upload must be multipart/form-data", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// find the file part, skipping anything else
+	for {
+		part, err := reader.NextPart()
+
+		// no file check
+		if err == io.EOF {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Missing file", http.StatusBadRequest)
+			return nil, false
+		}
+
+		// body too large check
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			// helper to insert error msg + 413 too large status code
+			WriteJSONError(w, "File is larger than your plan allows", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+
+		// part check (general)
+		if err != nil {
+			log.Printf("Error reading multipart part: %s", err) // log msg with err
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+			return nil, false
+		}
+
+		// not ours, skip it
+		if part.FormName() != "file" {
+			continue
+		}
+
+		// read one byte past the limit so an oversized file shows up
+		data, err := io.ReadAll(io.LimitReader(part, limit+1))
+		if errors.As(err, &tooLarge) || int64(len(data)) > limit {
+			// helper to insert error msg + 413 too large status code
+			WriteJSONError(w, "File is larger than your plan allows", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		if err != nil {
+			log.Printf("Error reading upload: %s", err) // log msg with err
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+			return nil, false
+		}
+		return data, true
+	}
+}
+
+// UploadAttachment handler that stores an image for a later chirp
+// multipart/form-data with the image in the "file" field
+func (apiCfg *apiConfig) handlerUploadAttachment(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// read the image
+	data, ok := readUploadFile(w, req, uploadLimit(caller.Plan))
+	if !ok {
+		return // early return
+	}
+
+	// pending cap check, before spending any processing on the upload
+	pending, err := apiCfg.db.CountPendingAttachmentsForUser(req.Context(), caller.UserID)
+
+	// count pending check
+	if err != nil {
+		log.Printf("Error counting pending uploads: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred uploading file", http.StatusInternalServerError)
+		return // early return
+	}
+	if pending >= maxPendingUploads {
+		// helper to insert error msg + 429 too many requests status code (no retry-after, posting a chirp frees a slot)
+		WriteJSONError(w, "Too many uploads waiting for a chirp, post or let some expire first", http.StatusTooManyRequests)
+		return // early return
+	}
+
+	// get the uploader, only verified addresses may post media
+	uploader, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get uploader check
+	if err != nil {
+		log.Printf("Error getting uploader: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred uploading file", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// verified check
+	if !uploader.EmailVerifiedAt.Valid {
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "Verify your email address before uploading", http.StatusForbidden)
+		return // early return
+	}
+
+	// sniff, strip and thumbnail
+	processed, err := media.Process(data)
+
+	// type check
+	if errors.Is(err, media.ErrUnsupportedType) {
+		// helper to insert error msg + 415 unsupported media type status code
+		WriteJSONError(w, "Only JPEG, PNG and GIF images are supported", http.StatusUnsupportedMediaType)
+		return // early return
+	}
+
+	// dimensions check
+	if errors.Is(err, media.ErrTooManyPixels) {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Image dimensions are too large", http.StatusBadRequest)
+		return // early return
+	}
+
+	// animation length check
+	if errors.Is(err, media.ErrTooManyFrames) {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Animation has too many frames", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check (general)
+	if err != nil {
+		log.Printf("Error processing upload: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Could not read image", http.StatusBadRequest)
+		return // early return
+	}
+
+	// the id names both blobs
+	id := uuid.New()
+	blobKey := id.String() + processed.Original.Ext
+	thumbnailKey := id.String() + "_thumb" + processed.Thumbnail.Ext
+
+	// store the original, then the thumbnail
+	err = apiCfg.blobStore.Put(req.Context(), blobKey, bytes.NewReader(processed.Original.Data))
+	if err == nil {
+		err = apiCfg.blobStore.Put(req.Context(), thumbnailKey, bytes.NewReader(processed.Thumbnail.Data))
+	}
+
+	// store check
+	if err != nil {
+		log.Printf("Error storing upload: %s", err) // log msg with err
+		apiCfg.deleteAttachmentBlobs(req.Context(), []database.Attachment{{BlobKey: blobKey, ThumbnailKey: thumbnailKey}})
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred uploading file", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// record it
+	attachment, err := apiCfg.db.CreateAttachment(req.Context(), database.CreateAttachmentParams{
+		ID:           id,
+		UserID:       caller.UserID,
+		ContentType:  processed.Original.ContentType,
+		SizeBytes:    int64(len(processed.Original.Data)),
+		Width:        int32(processed.Width),
+		Height:       int32(processed.Height),
+		BlobKey:      blobKey,
+		ThumbnailKey: thumbnailKey,
+	})
+
+	// create attachment check
+	if err != nil {
+		log.Printf("Error creating attachment: %s", err) // log msg with err
+		apiCfg.deleteAttachmentBlobs(req.Context(), []database.Attachment{{BlobKey: blobKey, ThumbnailKey: thumbnailKey}})
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred uploading file", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, apiCfg.attachmentResponse(attachment), http.StatusCreated)
+}
+
+// ServeMedia handler that serves stored media, cached for a year as keys never change
+func (apiCfg *apiConfig) handlerServeMedia(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get key from api endpoint path string
+	key := req.PathValue("key")
+
+	// only keys we could have written, with a type we serve
+	contentType := media.ContentTypeForExt(path.Ext(key))
+	if !blobstore.ValidKey(key) || contentType == "" {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Media not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// open the blob
+	blob, info, err := apiCfg.blobStore.Open(req.Context(), key)
+
+	// not found check
+	if errors.Is(err, blobstore.ErrNotFound) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Media not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// open check (general)
+	if err != nil {
+		log.Printf("Error opening blob %s: %s", key, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting media", http.StatusInternalServerError)
+		return // early return
+	}
+	defer blob.Close()
+
+	// our type, never sniffed by the browser
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", mediaCacheControl)
+	w.Header().Set("ETag", `"`+key+`"`)
+
+	// handles ranges and conditional requests
+	http.ServeContent(w, req, key, info.ModTime, blob)
+}
@@ -0,0 +1,116 @@
+// attachments_test.go
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/blobstore"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test media store config from the environment
+func TestBlobStoreFromEnv(t *testing.T) {
+	// file store by default
+	store, err := blobStoreFromEnv()
+	if err != nil {
+		t.Fatalf("blobStoreFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if _, ok := store.(*blobstore.FileStore); !ok {
+		t.Errorf("default store is %T, want *blobstore.FileStore", store)
+	}
+
+	// unknown kinds are rejected
+	t.Setenv("MEDIA_STORE", "floppy")
+	if _, err := blobStoreFromEnv(); err == nil {
+		t.Errorf("blobStoreFromEnv accepted an unknown store")
+	}
+}
+
+// test premium users get the bigger upload limit
+func TestUploadLimit(t *testing.T) {
+	if uploadLimit(auth.PlanFree) >= uploadLimit(auth.PlanChirpyRed) {
+		t.Errorf("free limit %d isn't below chirpy red limit %d", uploadLimit(auth.PlanFree), uploadLimit(auth.PlanChirpyRed))
+	}
+	if uploadLimit("") != freeUploadLimit {
+		t.Errorf("unknown plan limit = %d, want %d", uploadLimit(""), freeUploadLimit)
+	}
+}
+
+// test uploads must be multipart
+func TestUploadAttachmentNotMultipart(t *testing.T) {
+	apiCfg := &apiConfig{}
+	req := httptest.NewRequest(http.MethodPost, "/api/attachments", strings.NewReader(`{"file": "nope"}`))
+	req.Header.Set("Content-Type", "application/json")
+	ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+	rec := httptest.NewRecorder()
+	apiCfg.handlerUploadAttachment(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// test stored media is served with caching headers, and nothing else is
+func TestServeMedia(t *testing.T) {
+	store := blobstore.NewFileStore(t.TempDir())
+	apiCfg := &apiConfig{blobStore: store}
+	if err := store.Put(context.Background(), "abc.png", strings.NewReader("png bytes")); err != nil {
+		t.Fatalf("Put failed: %v", err) // fatal, don't continue
+	}
+
+	// serve through the mux so the path value is set
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /media/{key}", apiCfg.handlerServeMedia)
+
+	// stored blob
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/media/abc.png", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "png bytes" {
+		t.Fatalf("status = %d body = %q, want 200 and the blob", rec.Code, rec.Body.String()) // fatal, don't continue
+	}
+	for header, want := range map[string]string{
+		"Content-Type":           "image/png",
+		"Cache-Control":          mediaCacheControl,
+		"X-Content-Type-Options": "nosniff",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// revalidation is free
+	req := httptest.NewRequest(http.MethodGet, "/media/abc.png", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	// missing blobs and types we don't serve
+	for _, path := range []string{"/media/missing.png", "/media/abc.html", "/media/..png"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+	}
+}
+
+// test chirps always carry an attachments list
+func TestChirpResponseAttachments(t *testing.T) {
+	dat, err := json.Marshal(chirpResponse(database.Chirp{ID: uuid.New()}, "", nil))
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err) // fatal, don't continue
+	}
+	if !strings.Contains(string(dat), `"attachments":[]`) {
+		t.Errorf("chirp json %s has no empty attachments list", dat)
+	}
+}
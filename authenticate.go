@@ -0,0 +1,220 @@
+// authenticate.go
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// STRUCTS
+// the authenticated caller behind a request
+type principal struct {
+	UserID     uuid.UUID     // who is calling
+	Plan       string        // subscription plan
+	Role       string        // user role
+	Scopes     []string      // nil means a full session (access token)
+	APITokenID uuid.NullUUID // set when a personal access token was used
+	ClientID   string        // set when an oauth client is acting for the user
+}
+
+// check if the caller may act within a scope
+func (p principal) hasScope(scope string) bool {
+	// sessions (access tokens) can do anything the user can
+	if p.Scopes == nil {
+		return true
+	}
+
+	// personal access tokens and oauth clients only what they were granted
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// check if the caller is using a personal access token
+func (p principal) isAPIToken() bool {
+	return p.APITokenID.Valid
+}
+
+// check if the caller is an oauth client acting for the user
+func (p principal) isDelegated() bool {
+	return p.ClientID != ""
+}
+
+// check if the caller is the user's own login session (not a token or client acting for them)
+func (p principal) isSession() bool {
+	return !p.isAPIToken() && !p.isDelegated()
+}
+
+// check if the caller holds one of the roles
+func (p principal) hasRole(roles ...string) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AUTHENTICATION
+// resolve the bearer token (access token or personal access token) to a principal
+// errors are for the server log, clients should only ever see a generic 401
+func (apiCfg *apiConfig) authenticate(req *http.Request) (principal, error) {
+	// get the bearer's token
+	token, err := auth.GetBearerToken(req.Header)
+
+	// get token check
+	if err != nil {
+		return principal{}, err // early return
+	}
+
+	// personal access tokens are looked up by hash
+	if auth.IsAPIToken(token) {
+		return apiCfg.authenticateAPIToken(req, token)
+	}
+
+	// otherwise it must be a JWT access token
+	claims, err := auth.ValidateAccessToken(token, apiCfg.serverKey, apiCfg.denylist)
+
+	// jwt validation check
+	if err != nil {
+		return principal{}, err // early return
+	}
+
+	// principal from the claims, no db lookup needed
+	// delegated tokens are scoped to what the user consented to
+	return principal{
+		UserID:   claims.UserID(),
+		Plan:     claims.Plan,
+		Role:     claims.Role,
+		Scopes:   claims.Scopes(),
+		ClientID: claims.ClientID,
+	}, nil
+}
+
+// resolve a personal access token to a principal
+func (apiCfg *apiConfig) authenticateAPIToken(req *http.Request, token string) (principal, error) {
+	// get the token by its hash
+	apiToken, err := apiCfg.db.GetAPITokenByHash(req.Context(), auth.HashAPIToken(token))
+
+	// get token check
+	if err != nil {
+		return principal{}, err // early return
+	}
+
+	// token revoked check
+	if apiToken.RevokedAt.Valid {
+		return principal{}, errors.New("api token revoked")
+	}
+
+	// token expiration check
+	if time.Now().UTC().After(apiToken.ExpiresAt) {
+		return principal{}, errors.New("api token expired")
+	}
+
+	// suspended user check (the denylist is kept in sync with the db)
+	if apiCfg.denylist != nil && apiCfg.denylist.IsUserDenied(apiToken.UserID) {
+		return principal{}, errors.New("user is suspended")
+	}
+
+	// record the use (best effort, don't fail the request over it)
+	apiCfg.db.TouchAPIToken(req.Context(), apiToken.ID)
+
+	// premium users get the red plan
+	plan := auth.PlanFree
+	if apiToken.IsChirpyRed {
+		plan = auth.PlanChirpyRed
+	}
+
+	// scoped principal
+	return principal{
+		UserID:     apiToken.UserID,
+		Plan:       plan,
+		Role:       apiToken.Role,
+		Scopes:     apiToken.Scopes,
+		APITokenID: uuid.NullUUID{UUID: apiToken.ID, Valid: true},
+	}, nil
+}
+
+// REQUEST CONTEXT
+// unexported key type so no other package can clash with ours
+type contextKey string
+
+// context key the auth middleware stores the principal under
+const principalContextKey contextKey = "principal"
+
+// get the authenticated caller from the request context
+// ok is false on anonymous requests to optional-auth routes
+func principalFromContext(ctx context.Context) (principal, bool) {
+	caller, ok := ctx.Value(principalContextKey).(principal)
+	return caller, ok
+}
+
+// what a route demands of its caller, declared where the route is registered
+type authPolicy struct {
+	Optional    bool     // anonymous callers allowed (bad credentials are still rejected)
+	Scope       string   // scope a personal access token must hold
+	Roles       []string // caller must hold one of these roles (implies SessionOnly)
+	SessionOnly bool     // personal access tokens and oauth clients are refused
+}
+
+// MIDDLEWARE
+// auth middleware, validates the bearer once and puts the principal in the request context
+func (apiCfg *apiConfig) middlewareAuth(policy authPolicy, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc( // return a new func
+		func(w http.ResponseWriter, r *http.Request) { // http.Handler type func
+			// anonymous caller on an optional-auth route, pass along with no principal
+			if policy.Optional && r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r) // pass req to the next handler in the chain
+				return               // early return
+			}
+
+			// authenticate the caller
+			caller, err := apiCfg.authenticate(r)
+
+			// authentication check
+			if err != nil {
+				log.Printf("Error authenticating request: %s", err) // log msg with err
+				// helper to insert error msg + 401 unauthorized status code
+				WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+				return // early return
+			}
+
+			// staff routes and token management need a real login, never a personal access token or oauth client
+			if !caller.isSession() && (policy.SessionOnly || len(policy.Roles) > 0) {
+				log.Printf("Error token (api token %s, client %q) used on session only route %s %s", caller.APITokenID.UUID, caller.ClientID, r.Method, r.URL.Path) // log msg
+				// helper to insert error msg + 403 forbidden status code
+				WriteJSONError(w, "This endpoint requires a login session", http.StatusForbidden)
+				return // early return
+			}
+
+			// role check (roles come from the access token claims, no db lookup)
+			if len(policy.Roles) > 0 && !caller.hasRole(policy.Roles...) {
+				log.Printf("Error caller %s (role %s) denied %s %s", caller.UserID, caller.Role, r.Method, r.URL.Path) // log msg
+				// helper to insert error msg + 403 forbidden status code
+				WriteJSONError(w, "Forbidden", http.StatusForbidden)
+				return // early return
+			}
+
+			// scope check (personal access tokens and oauth clients only get what they were granted)
+			if policy.Scope != "" && !caller.hasScope(policy.Scope) {
+				log.Printf("Error caller %s lacks scope %s", caller.UserID, policy.Scope) // log msg
+				// helper to insert error msg + 403 forbidden status code
+				WriteJSONError(w, "Insufficient token scope", http.StatusForbidden)
+				return // early return
+			}
+
+			// hand the principal to the handler
+			ctx := context.WithValue(r.Context(), principalContextKey, caller)
+			next.ServeHTTP(w, r.WithContext(ctx)) // pass req to the next handler in the chain
+		}, // trailing comma required in last arg of multi-line call
+	)
+}
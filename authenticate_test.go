@@ -0,0 +1,126 @@
+// authenticate_test.go
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// test principal scope checks
+func TestPrincipalHasScope(t *testing.T) {
+	// a login session can do anything
+	session := principal{UserID: uuid.New()}
+	if !session.hasScope(auth.ScopeProfileWrite) {
+		t.Errorf("session principal denied a scope")
+	}
+
+	// an api token only what it was granted
+	apiToken := principal{
+		UserID:     uuid.New(),
+		Scopes:     []string{auth.ScopeChirpsRead},
+		APITokenID: uuid.NullUUID{UUID: uuid.New(), Valid: true},
+	}
+	if !apiToken.hasScope(auth.ScopeChirpsRead) {
+		t.Errorf("api token principal denied a granted scope")
+	}
+	if apiToken.hasScope(auth.ScopeChirpsWrite) {
+		t.Errorf("api token principal allowed an ungranted scope")
+	}
+
+	// an api token with no scopes can do nothing
+	empty := principal{UserID: uuid.New(), Scopes: []string{}}
+	if empty.hasScope(auth.ScopeChirpsRead) {
+		t.Errorf("api token principal with no scopes allowed a scope")
+	}
+}
+
+// test the auth middleware policies with access tokens
+func TestMiddlewareAuth(t *testing.T) {
+	// test config, access tokens need no db
+	apiCfg := &apiConfig{
+		serverKey: "AllYourBase",
+		denylist:  auth.NewMemoryDenylist(),
+	}
+
+	// make an access token for a role
+	tokenFor := func(role string) string {
+		token, err := auth.MakeAccessToken(uuid.New(), apiCfg.serverKey, time.Hour, auth.TokenOptions{Role: role})
+		if err != nil {
+			t.Fatalf("MakeAccessToken failed: %v", err) // fatal, don't continue
+		}
+		return token
+	}
+
+	// make a delegated token an oauth client holds
+	clientToken := func(scopes ...string) string {
+		token, err := auth.MakeAccessToken(uuid.New(), apiCfg.serverKey, time.Hour, auth.TokenOptions{
+			Role:     auth.RoleAdmin,
+			Scopes:   scopes,
+			ClientID: uuid.NewString(),
+		})
+		if err != nil {
+			t.Fatalf("MakeAccessToken failed: %v", err) // fatal, don't continue
+		}
+		return token
+	}
+
+	// handler reports whether it got a principal
+	echo := func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := principalFromContext(req.Context()); ok {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	// policies under test
+	required := authPolicy{Scope: auth.ScopeChirpsWrite}
+	optional := authPolicy{Optional: true}
+	adminOnly := authPolicy{Roles: []string{auth.RoleAdmin}}
+	session := authPolicy{SessionOnly: true}
+
+	// build test cases
+	testCases := []struct {
+		name       string
+		policy     authPolicy
+		authHeader string
+		expected   int
+	}{
+		{"Test case: Required No Token", required, "", http.StatusUnauthorized},
+		{"Test case: Required Garbage Token", required, "Bearer nope", http.StatusUnauthorized},
+		{"Test case: Required Valid Token", required, "Bearer " + tokenFor(auth.RoleUser), http.StatusOK},
+		{"Test case: Optional No Token", optional, "", http.StatusNoContent},
+		{"Test case: Optional Garbage Token", optional, "Bearer nope", http.StatusUnauthorized},
+		{"Test case: Optional Valid Token", optional, "Bearer " + tokenFor(auth.RoleUser), http.StatusOK},
+		{"Test case: Admin Route User Role", adminOnly, "Bearer " + tokenFor(auth.RoleUser), http.StatusForbidden},
+		{"Test case: Admin Route Moderator Role", adminOnly, "Bearer " + tokenFor(auth.RoleModerator), http.StatusForbidden},
+		{"Test case: Admin Route Admin Role", adminOnly, "Bearer " + tokenFor(auth.RoleAdmin), http.StatusOK},
+		{"Test case: Client Token Granted Scope", required, "Bearer " + clientToken(auth.ScopeChirpsWrite), http.StatusOK},
+		{"Test case: Client Token Missing Scope", required, "Bearer " + clientToken(auth.ScopeChirpsRead), http.StatusForbidden},
+		{"Test case: Client Token Admin Route", adminOnly, "Bearer " + clientToken(auth.ScopeChirpsWrite), http.StatusForbidden},
+		{"Test case: Client Token Session Route", session, "Bearer " + clientToken(auth.ScopeChirpsWrite), http.StatusForbidden},
+		{"Test case: Session Token Session Route", session, "Bearer " + tokenFor(auth.RoleUser), http.StatusOK},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/api/anything", nil)
+		if tc.authHeader != "" {
+			req.Header.Set("Authorization", tc.authHeader)
+		}
+		rec := httptest.NewRecorder()
+
+		apiCfg.middlewareAuth(tc.policy, echo).ServeHTTP(rec, req)
+
+		// status check
+		if rec.Code != tc.expected {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// avatars.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/blobstore"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+// avatars keep one url per user, so caches revalidate instead of keeping them forever
+const avatarCacheControl = "public, max-age=3600, must-revalidate"
+
+// HELPERS
+// where a user's avatar is shown from, an avatar link wins over the served avatar
+func (apiCfg *apiConfig) avatarURL(userID uuid.UUID, link sql.NullString) string {
+	if link.Valid {
+		return link.String
+	}
+	return strings.TrimRight(apiCfg.baseURL, "/") + "/api/users/" + userID.String() + "/avatar"
+}
+
+// the blob holding one size of an uploaded avatar
+func avatarBlobKey(avatarKey string, size int) string {
+	return fmt.Sprintf("%s_%d.png", avatarKey, size)
+}
+
+// remove every size of an uploaded avatar, best effort as the row no longer points at it
+func (apiCfg *apiConfig) deleteAvatarBlobs(ctx context.Context, avatarKey string) {
+	for _, size := range media.AvatarSizes {
+		key := avatarBlobKey(avatarKey, size)
+		err := apiCfg.blobStore.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Error deleting blob %s: %s", key, err)
+		}
+	}
+}
+
+// HANDLERS
+// UploadAvatar handler that crops and resizes an image into the caller's avatar
+// multipart/form-data with the image in the "file" field
+func (apiCfg *apiConfig) handlerUploadAvatar(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// read the image
+	data, ok := readUploadFile(w, req, uploadLimit(caller.Plan))
+	if !ok {
+		return // early return
+	}
+
+	// get the user, only verified addresses may post media
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred uploading avatar", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// verified check
+	if !user.EmailVerifiedAt.Valid {
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "Verify your email address before uploading", http.StatusForbidden)
+		return // early return
+	}
+
+	// sniff, crop and resize
+	avatars, err := media.Avatar(data)
+
+	// type check
+	if errors.Is(err, media.ErrUnsupportedType) {
+		// helper to insert error msg + 415 unsupported media type status code
+		WriteJSONError(w, "Only JPEG, PNG and GIF images are supported", http.StatusUnsupportedMediaType)
+		return // early return
+	}
+
+	// dimensions check
+	if errors.Is(err, media.ErrTooManyPixels) {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Image dimensions are too large", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check (general)
+	if err != nil {
+		log.Printf("Error processing avatar: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Could not read image", http.StatusBadRequest)
+		return // early return
+	}
+
+	// a fresh key per upload, so the etag changes with the picture
+	avatarKey := "avatar_" + uuid.New().String()
+
+	// store every size
+	for i, size := range media.AvatarSizes {
+		err = apiCfg.blobStore.Put(req.Context(), avatarBlobKey(avatarKey, size), bytes.NewReader(avatars[i].Data))
+		if err != nil {
+			break
+		}
+	}
+
+	// store check
+	if err != nil {
+		log.Printf("Error storing avatar: %s", err) // log msg with err
+		apiCfg.deleteAvatarBlobs(req.Context(), avatarKey)
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred uploading avatar", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// point the user at it
+	err = apiCfg.db.SetUserAvatar(req.Context(), database.SetUserAvatarParams{
+		ID:        user.ID,
+		AvatarKey: sql.NullString{String: avatarKey, Valid: true},
+	})
+
+	// set avatar check
+	if err != nil {
+		log.Printf("Error setting avatar for %s: %s", user.ID, err) // log msg with err
+		apiCfg.deleteAvatarBlobs(req.Context(), avatarKey)
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred uploading avatar", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// the old picture is unreachable now
+	if user.AvatarKey.Valid {
+		apiCfg.deleteAvatarBlobs(req.Context(), user.AvatarKey.String)
+	}
+
+	// json response payload
+	respAvatar := JsonAvatarResponse{
+		AvatarURL: apiCfg.avatarURL(user.ID, sql.NullString{}),
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, respAvatar, http.StatusCreated)
+}
+
+// DeleteAvatar handler that removes the caller's uploaded avatar, the identicon shows again
+func (apiCfg *apiConfig) handlerDeleteAvatar(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the user
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// clear the avatar and any avatar link
+	err = apiCfg.db.SetUserAvatar(req.Context(), database.SetUserAvatarParams{ID: user.ID})
+
+	// clear avatar check
+	if err != nil {
+		log.Printf("Error clearing avatar for %s: %s", user.ID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred removing avatar", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// the blobs are unreachable now
+	if user.AvatarKey.Valid {
+		apiCfg.deleteAvatarBlobs(req.Context(), user.AvatarKey.String)
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
+
+// GetAvatar handler that serves a user's avatar, the generated identicon if they haven't uploaded one
+// ?size= picks the smallest stored size that's big enough
+func (apiCfg *apiConfig) handlerGetAvatar(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get userID from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// parse check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID", http.StatusBadRequest)
+		return // early return
+	}
+
+	// size check (optional, largest by default)
+	size := media.AvatarSizes[len(media.AvatarSizes)-1]
+	if raw := req.URL.Query().Get("size"); raw != "" {
+		requested, err := strconv.Atoi(raw)
+		if err != nil || requested <= 0 {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Invalid size", http.StatusBadRequest)
+			return // early return
+		}
+		size = media.AvatarSize(requested)
+	}
+
+	// get the user
+	user, err := apiCfg.db.GetUserByID(req.Context(), userID)
+
+	// not found check
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting avatar", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// our type, never sniffed by the browser
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", avatarCacheControl)
+
+	// no upload, draw the identicon (the same user always gets the same one)
+	if !user.AvatarKey.Valid {
+		identicon, err := media.IdenticonPNG(user.ID[:], size)
+
+		// draw check
+		if err != nil {
+			log.Printf("Error drawing identicon for %s: %s", user.ID, err) // log msg with err
+			// helper to insert error msg + 500 internal error status code
+			WriteJSONError(w, "Error occurred getting avatar", http.StatusInternalServerError)
+			return // early return
+		}
+
+		// handles conditional requests
+		w.Header().Set("ETag", fmt.Sprintf(`"identicon_%s_%d"`, user.ID, size))
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(identicon))
+		return // early return
+	}
+
+	// open the uploaded size
+	key := avatarBlobKey(user.AvatarKey.String, size)
+	blob, info, err := apiCfg.blobStore.Open(req.Context(), key)
+
+	// not found check (deleted under us)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Avatar not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// open check (general)
+	if err != nil {
+		log.Printf("Error opening blob %s: %s", key, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting avatar", http.StatusInternalServerError)
+		return // early return
+	}
+	defer blob.Close()
+
+	// handles ranges and conditional requests
+	w.Header().Set("ETag", `"`+key+`"`)
+	http.ServeContent(w, req, key, info.ModTime, blob)
+}
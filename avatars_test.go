@@ -0,0 +1,57 @@
+// avatars_test.go
+
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing" // importing testing package for unit tests
+
+	"github.com/PietPadda/chirpy/internal/blobstore"
+	"github.com/PietPadda/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+// test avatar links win over the served avatar
+func TestAvatarURL(t *testing.T) {
+	apiCfg := &apiConfig{baseURL: "https://chirpy.example/"}
+	id := uuid.New()
+	if got, want := apiCfg.avatarURL(id, sql.NullString{}), "https://chirpy.example/api/users/"+id.String()+"/avatar"; got != want {
+		t.Errorf("avatarURL = %q, want %q", got, want)
+	}
+	link := sql.NullString{String: "https://img.example/me.png", Valid: true}
+	if got := apiCfg.avatarURL(id, link); got != link.String {
+		t.Errorf("avatarURL = %q, want the link %q", got, link.String)
+	}
+}
+
+// test every avatar blob key is one the media store accepts
+func TestAvatarBlobKey(t *testing.T) {
+	avatarKey := "avatar_" + uuid.New().String()
+	for _, size := range media.AvatarSizes {
+		if key := avatarBlobKey(avatarKey, size); !blobstore.ValidKey(key) {
+			t.Errorf("avatar blob key %q isn't a valid blob key", key)
+		}
+	}
+}
+
+// test bad avatar requests are refused before any lookup
+func TestGetAvatarBadRequest(t *testing.T) {
+	apiCfg := &apiConfig{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/{userID}/avatar", apiCfg.handlerGetAvatar)
+
+	// build test cases
+	for _, path := range []string{
+		"/api/users/not-a-uuid/avatar",
+		"/api/users/" + uuid.NewString() + "/avatar?size=big",
+		"/api/users/" + uuid.NewString() + "/avatar?size=-1",
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
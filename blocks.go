@@ -0,0 +1,222 @@
+// blocks.go
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// HELPERS
+// the authors a signed in caller must not see: everyone on either side of a block,
+// plus everyone they muted when it's one of their feeds, anonymous callers see everyone
+func (apiCfg *apiConfig) hiddenAuthors(ctx context.Context, includeMuted bool) (map[uuid.UUID]bool, error) {
+	// signed in check
+	hidden := make(map[uuid.UUID]bool)
+	caller, ok := principalFromContext(ctx)
+	if !ok {
+		return hidden, nil
+	}
+
+	// blocks work both ways
+	blockedIDs, err := apiCfg.db.GetBlockedUserIDs(ctx, caller.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range blockedIDs {
+		hidden[id] = true
+	}
+
+	// mutes only hide from the muter's feeds
+	if includeMuted {
+		mutedIDs, err := apiCfg.db.GetMutedUserIDs(ctx, caller.UserID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range mutedIDs {
+			hidden[id] = true
+		}
+	}
+	return hidden, nil
+}
+
+// whether a signed in caller and another user are on either side of a block
+func (apiCfg *apiConfig) blockedEitherWay(ctx context.Context, otherID uuid.UUID) (bool, error) {
+	// signed in check, nobody blocks themselves
+	caller, ok := principalFromContext(ctx)
+	if !ok || caller.UserID == otherID {
+		return false, nil
+	}
+
+	return apiCfg.db.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{
+		UserID:  caller.UserID,
+		OtherID: otherID,
+	})
+}
+
+// HANDLERS
+// BlockUser handler that blocks a user, neither sees the other's chirps or profile after
+func (apiCfg *apiConfig) handlerBlockUser(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get user id from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// self check
+	if userID == caller.UserID {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "You can't block yourself", http.StatusBadRequest)
+		return // early return
+	}
+
+	// block them
+	err = apiCfg.db.CreateBlock(req.Context(), database.CreateBlockParams{
+		BlockerID: caller.UserID,
+		BlockedID: userID,
+	})
+
+	// no such user check (foreign key violation)
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23503" {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// create block check
+	if err != nil {
+		log.Printf("Error blocking user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred blocking user", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
+
+// UnblockUser handler that lifts a block, lifting one that isn't there is fine
+func (apiCfg *apiConfig) handlerUnblockUser(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get user id from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// unblock them
+	err = apiCfg.db.DeleteBlock(req.Context(), database.DeleteBlockParams{
+		BlockerID: caller.UserID,
+		BlockedID: userID,
+	})
+
+	// delete block check
+	if err != nil {
+		log.Printf("Error unblocking user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred unblocking user", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
+
+// ListBlocks handler that returns the users the caller has blocked, newest first
+func (apiCfg *apiConfig) handlerListBlocks(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the blocks
+	rows, err := apiCfg.db.ListBlocks(req.Context(), caller.UserID)
+
+	// list blocks check
+	if err != nil {
+		log.Printf("Error listing blocks: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting blocked users", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload
+	respBlocks := make([]JsonRelatedUserResponse, 0, len(rows))
+	for _, row := range rows {
+		respBlocks = append(respBlocks, JsonRelatedUserResponse{
+			UserID:    row.BlockedID,
+			Handle:    row.Handle.String,
+			AvatarURL: apiCfg.avatarURL(row.BlockedID, row.AvatarUrl),
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respBlocks, http.StatusOK)
+}
@@ -0,0 +1,136 @@
+// blocks_test.go
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing" // importing testing package for unit tests
+
+	"github.com/google/uuid"
+)
+
+// test anonymous callers and the caller themselves are never behind a block
+func TestBlocksWithoutCaller(t *testing.T) {
+	apiCfg := &apiConfig{}
+
+	// anonymous
+	hidden, err := apiCfg.hiddenAuthors(context.Background(), true)
+	if err != nil || len(hidden) != 0 {
+		t.Errorf("hiddenAuthors() = %v, %v, want empty, nil", hidden, err)
+	}
+	blocked, err := apiCfg.blockedEitherWay(context.Background(), uuid.New())
+	if err != nil || blocked {
+		t.Errorf("blockedEitherWay() anonymous = %v, %v, want false, nil", blocked, err)
+	}
+
+	// self
+	userID := uuid.New()
+	ctx := context.WithValue(context.Background(), principalContextKey, principal{UserID: userID})
+	blocked, err = apiCfg.blockedEitherWay(ctx, userID)
+	if err != nil || blocked {
+		t.Errorf("blockedEitherWay() self = %v, %v, want false, nil", blocked, err)
+	}
+}
+
+// test bad targets are refused before the db is touched
+func TestBlockUserBadRequest(t *testing.T) {
+	apiCfg := &apiConfig{}
+	callerID := uuid.New()
+	for name, target := range map[string]string{"Invalid ID": "nope", "Self": callerID.String()} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/users/me/blocks/"+target, nil)
+			req.SetPathValue("userID", target)
+			ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: callerID})
+			rec := httptest.NewRecorder()
+			apiCfg.handlerBlockUser(rec, req.WithContext(ctx))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// test hiddenAuthors merges blocks both ways with the caller's mutes, and only when asked
+func TestHiddenAuthors(t *testing.T) {
+	callerID := uuid.New()
+	blockedID := uuid.New()
+	mutedID := uuid.New()
+	bothID := uuid.New() // blocked and muted
+	signedIn := context.WithValue(context.Background(), principalContextKey, principal{UserID: callerID})
+
+	// build test cases
+	testCases := []struct {
+		name            string          // name for test case
+		ctx             context.Context // who is asking
+		includeMuted    bool            // feeds hide mutes too
+		expected        []uuid.UUID     // authors we want hidden
+		expectedQueries []string        // what the db should be asked
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: anonymous", context.Background(), true, nil, nil},
+		{"Test case: blocks only", signedIn, false, []uuid.UUID{blockedID, bothID}, []string{"GetBlockedUserIDs"}},
+		{"Test case: blocks and mutes", signedIn, true, []uuid.UUID{blockedID, mutedID, bothID}, []string{"GetBlockedUserIDs", "GetMutedUserIDs"}},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		fake := newFakeQuerier()
+		fake.blocked = []uuid.UUID{blockedID, bothID}
+		fake.muted = []uuid.UUID{mutedID, bothID}
+		apiCfg := &apiConfig{db: fake}
+
+		hidden, err := apiCfg.hiddenAuthors(tc.ctx, tc.includeMuted)
+		if err != nil {
+			t.Fatalf("%s: hiddenAuthors failed: %v", tc.name, err) // fatal, don't continue
+		}
+
+		// merged, no duplicates
+		if len(hidden) != len(tc.expected) {
+			t.Errorf("%s: hiddenAuthors = %v, want %v", tc.name, hidden, tc.expected)
+		}
+		for _, id := range tc.expected {
+			if !hidden[id] {
+				t.Errorf("%s: %s not hidden", tc.name, id)
+			}
+		}
+
+		// mutes only looked up when they count
+		if !slices.Equal(fake.calls, tc.expectedQueries) {
+			t.Errorf("%s: queries = %v, want %v", tc.name, fake.calls, tc.expectedQueries)
+		}
+	}
+}
+
+// test blockedEitherWay never asks the db for anonymous callers or the caller themselves
+func TestBlockedEitherWay(t *testing.T) {
+	callerID := uuid.New()
+	blockedID := uuid.New()
+	signedIn := context.WithValue(context.Background(), principalContextKey, principal{UserID: callerID})
+
+	// build test cases
+	testCases := []struct {
+		name     string          // name for test case
+		ctx      context.Context // who is asking
+		otherID  uuid.UUID       // who they're looking at
+		expected bool            // blocked either way
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: anonymous", context.Background(), blockedID, false},
+		{"Test case: self", signedIn, callerID, false},
+		{"Test case: blocked", signedIn, blockedID, true},
+		{"Test case: not blocked", signedIn, uuid.New(), false},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		fake := newFakeQuerier()
+		fake.blocked = []uuid.UUID{blockedID, callerID} // a block touching the caller can't hide them from themselves
+		apiCfg := &apiConfig{db: fake}
+
+		blocked, err := apiCfg.blockedEitherWay(tc.ctx, tc.otherID)
+		if err != nil || blocked != tc.expected {
+			t.Errorf("%s: blockedEitherWay = %v, %v, want %v, nil", tc.name, blocked, err, tc.expected)
+		}
+	}
+}
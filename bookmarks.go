@@ -0,0 +1,773 @@
+// bookmarks.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq" // postgresql driver
+)
+
+// bookmark limits
+const (
+	defaultBookmarkPageSize = 20  // chirps per page unless ?limit= says otherwise
+	maxBookmarkPageSize     = 100 // largest page a caller may ask for
+	maxCollectionNameLength = 50  // in characters, not bytes
+)
+
+// ERRORS
+var errInvalidCursor = errors.New("invalid cursor")
+
+// HELPERS
+// an opaque cursor pointing just past the last bookmark of a page
+func encodeBookmarkCursor(bookmarkedAt time.Time, chirpID uuid.UUID) string {
+	raw := bookmarkedAt.UTC().Format(time.RFC3339Nano) + "," + chirpID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// read a cursor back into the bookmark it points past
+func decodeBookmarkCursor(cursor string) (time.Time, uuid.UUID, error) {
+	// decode check
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errInvalidCursor
+	}
+
+	// time, id
+	at, id, found := strings.Cut(string(raw), ",")
+	if !found {
+		return time.Time{}, uuid.Nil, errInvalidCursor
+	}
+	bookmarkedAt, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errInvalidCursor
+	}
+	chirpID, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errInvalidCursor
+	}
+	return bookmarkedAt, chirpID, nil
+}
+
+// checks a collection name, returns it trimmed
+func normaliseCollectionName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" {
+		return "", errors.New("collection name is required")
+	}
+	if utf8.RuneCountInString(name) > maxCollectionNameLength {
+		return "", errors.New("collection name is too long")
+	}
+	return name, nil
+}
+
+// set bookmarked_by_me on chirps for a signed in caller allowed to see their bookmarks
+// anonymous callers and tokens without bookmarks:read get no flag at all
+func (apiCfg *apiConfig) markBookmarked(ctx context.Context, chirps []JsonChirpResponse) error {
+	// signed in check
+	caller, ok := principalFromContext(ctx)
+	if !ok || !caller.hasScope(auth.ScopeBookmarksRead) || len(chirps) == 0 {
+		return nil
+	}
+
+	// one query for the whole page
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+	bookmarkedIDs, err := apiCfg.db.GetBookmarkedChirpIDs(ctx, database.GetBookmarkedChirpIDsParams{
+		UserID:   caller.UserID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+	bookmarked := make(map[uuid.UUID]bool, len(bookmarkedIDs))
+	for _, id := range bookmarkedIDs {
+		bookmarked[id] = true
+	}
+
+	// every chirp gets a flag, true or false
+	for i := range chirps {
+		flag := bookmarked[chirps[i].ID]
+		chirps[i].BookmarkedByMe = &flag
+	}
+	return nil
+}
+
+// a collection as the api shows it
+func collectionResponse(collection database.BookmarkCollection, count int64) JsonCollectionResponse {
+	return JsonCollectionResponse{
+		ID:            collection.ID,
+		CreatedAt:     collection.CreatedAt,
+		UpdatedAt:     collection.UpdatedAt,
+		Name:          collection.Name,
+		BookmarkCount: count,
+	}
+}
+
+// HANDLERS
+// BookmarkChirp handler that bookmarks a chirp, optionally into one of the caller's collections
+// bookmarking it again moves it to the collection given (or out of any)
+func (apiCfg *apiConfig) handlerBookmarkChirp(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get chirp id from api endpoint path string
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid chirp ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// json request from client
+	var reqBookmark JsonBookmarkRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body (an empty body is fine, it means no collection)
+	err = decoder.Decode(&reqBookmark)
+
+	// decode check
+	if err != nil && err != io.EOF {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the chirp
+	chirp, err := apiCfg.db.GetChirp(req.Context(), chirpID)
+
+	// not found check
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get chirp check
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred bookmarking chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// hidden by a moderator check, only the author still sees it
+	if hiddenFromCaller(req.Context(), chirp) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// block check, a chirp across a block can't be seen so can't be bookmarked
+	blocked, err := apiCfg.blockedEitherWay(req.Context(), chirp.UserID)
+
+	// get block check
+	if err != nil {
+		log.Printf("Error checking blocks: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred bookmarking chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// blocked check
+	if blocked {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// restricted author check, the suspended and shadow banned are only seen by themselves
+	restricted, err := apiCfg.restrictedFromCaller(req.Context(), chirp.UserID)
+
+	// get restriction check
+	if err != nil {
+		log.Printf("Error checking restrictions: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred bookmarking chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// restricted check
+	if restricted {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// collection check, only the caller's own
+	var collectionID uuid.NullUUID
+	if reqBookmark.CollectionID != nil {
+		_, err = apiCfg.db.GetBookmarkCollection(req.Context(), database.GetBookmarkCollectionParams{
+			ID:     *reqBookmark.CollectionID,
+			UserID: caller.UserID,
+		})
+
+		// not found check (someone else's looks the same as none)
+		if errors.Is(err, sql.ErrNoRows) {
+			// helper to insert error msg + 404 not found status code
+			WriteJSONError(w, "Collection not found", http.StatusNotFound)
+			return // early return
+		}
+
+		// get collection check
+		if err != nil {
+			log.Printf("Error getting collection: %s", err) // log msg with err
+			// helper to insert error msg + 500 internal error status code
+			WriteJSONError(w, "Error occurred bookmarking chirp", http.StatusInternalServerError)
+			return // early return
+		}
+		collectionID = uuid.NullUUID{UUID: *reqBookmark.CollectionID, Valid: true}
+	}
+
+	// bookmark it
+	err = apiCfg.db.CreateBookmark(req.Context(), database.CreateBookmarkParams{
+		UserID:       caller.UserID,
+		ChirpID:      chirpID,
+		CollectionID: collectionID,
+	})
+
+	// no such chirp check (deleted since, foreign key violation)
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23503" {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// create bookmark check
+	if err != nil {
+		log.Printf("Error creating bookmark: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred bookmarking chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
+
+// UnbookmarkChirp handler that removes a bookmark, removing one that isn't there is fine
+func (apiCfg *apiConfig) handlerUnbookmarkChirp(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get chirp id from api endpoint path string
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid chirp ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// remove it
+	err = apiCfg.db.DeleteBookmark(req.Context(), database.DeleteBookmarkParams{
+		UserID:  caller.UserID,
+		ChirpID: chirpID,
+	})
+
+	// delete bookmark check
+	if err != nil {
+		log.Printf("Error deleting bookmark: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred removing bookmark", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
+
+// GetBookmarks handler that returns a page of the caller's bookmarks, newest first
+// ?collection_id= narrows it to one collection, ?limit= sets the page size, ?cursor= continues a previous page
+func (apiCfg *apiConfig) handlerGetBookmarks(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// the page to fetch
+	query := req.URL.Query()
+	params := database.ListBookmarksParams{
+		UserID:   caller.UserID,
+		PageSize: defaultBookmarkPageSize,
+	}
+
+	// page size check
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxBookmarkPageSize {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Limit must be between 1 and 100", http.StatusBadRequest)
+			return // early return
+		}
+		params.PageSize = int32(limit)
+	}
+
+	// collection check
+	if raw := query.Get("collection_id"); raw != "" {
+		collectionID, err := uuid.Parse(raw)
+		if err != nil {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Invalid collection ID format", http.StatusBadRequest)
+			return // early return
+		}
+		params.CollectionID = uuid.NullUUID{UUID: collectionID, Valid: true}
+	}
+
+	// cursor check
+	if raw := query.Get("cursor"); raw != "" {
+		bookmarkedAt, chirpID, err := decodeBookmarkCursor(raw)
+		if err != nil {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Invalid cursor", http.StatusBadRequest)
+			return // early return
+		}
+		params.BeforeAt = sql.NullTime{Time: bookmarkedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: chirpID, Valid: true}
+	}
+
+	// one more than the page, so we know if there's another
+	pageSize := int(params.PageSize)
+	params.PageSize++
+	rows, err := apiCfg.db.ListBookmarks(req.Context(), params)
+
+	// list bookmarks check
+	if err != nil {
+		log.Printf("Error listing bookmarks: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting bookmarks", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// next page check
+	resp := JsonBookmarksResponse{Bookmarks: []JsonBookmarkResponse{}}
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		resp.NextCursor = encodeBookmarkCursor(last.BookmarkedAt, last.ID)
+	}
+
+	// authors and attachments for the page
+	authorIDs := make([]uuid.UUID, 0, len(rows))
+	chirpIDs := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		authorIDs = append(authorIDs, row.UserID)
+		chirpIDs = append(chirpIDs, row.ID)
+	}
+	handles, err := apiCfg.authorHandles(req.Context(), authorIDs)
+
+	// get handles check
+	if err != nil {
+		log.Printf("Error getting author handles: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting bookmarks", http.StatusInternalServerError)
+		return // early return
+	}
+
+	attachments, err := apiCfg.chirpAttachments(req.Context(), chirpIDs)
+
+	// get attachments check
+	if err != nil {
+		log.Printf("Error getting chirp attachments: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting bookmarks", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// every chirp on this page is bookmarked, by definition
+	bookmarked := true
+	for _, row := range rows {
+		chirp := chirpResponse(database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			HiddenAt:  row.HiddenAt,
+			HeldAt:    row.HeldAt,
+		}, handles[row.UserID], attachments[row.ID])
+		chirp.BookmarkedByMe = &bookmarked
+
+		bookmark := JsonBookmarkResponse{Chirp: chirp, BookmarkedAt: row.BookmarkedAt}
+		if row.CollectionID.Valid {
+			bookmark.CollectionID = &row.CollectionID.UUID
+		}
+		resp.Bookmarks = append(resp.Bookmarks, bookmark)
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, resp, http.StatusOK)
+}
+
+// CreateCollection handler that adds a named bookmark collection
+func (apiCfg *apiConfig) handlerCreateCollection(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqCollection JsonCollectionRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqCollection)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqCollection is now successfully populated
+
+	// name check
+	name, err := normaliseCollectionName(reqCollection.Name)
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid collection name: "+err.Error(), http.StatusBadRequest)
+		return // early return
+	}
+
+	// create it
+	collection, err := apiCfg.db.CreateBookmarkCollection(req.Context(), database.CreateBookmarkCollectionParams{
+		UserID: caller.UserID,
+		Name:   name,
+	})
+
+	// duplicate name check
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "You already have a collection with that name", http.StatusConflict)
+		return // early return
+	}
+
+	// create collection check
+	if err != nil {
+		log.Printf("Error creating collection: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred creating collection", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, collectionResponse(collection, 0), http.StatusCreated)
+}
+
+// ListCollections handler that returns the caller's bookmark collections by name
+func (apiCfg *apiConfig) handlerListCollections(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the collections
+	rows, err := apiCfg.db.ListBookmarkCollections(req.Context(), caller.UserID)
+
+	// list collections check
+	if err != nil {
+		log.Printf("Error listing collections: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting collections", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload
+	respCollections := make([]JsonCollectionResponse, 0, len(rows))
+	for _, row := range rows {
+		respCollections = append(respCollections, collectionResponse(database.BookmarkCollection{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			UserID:    row.UserID,
+			Name:      row.Name,
+		}, row.BookmarkCount))
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respCollections, http.StatusOK)
+}
+
+// RenameCollection handler that renames one of the caller's bookmark collections
+func (apiCfg *apiConfig) handlerRenameCollection(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get collection id from api endpoint path string
+	collectionID, err := uuid.Parse(req.PathValue("collectionID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid collection ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// json request from client
+	var reqCollection JsonCollectionRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err = decoder.Decode(&reqCollection)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqCollection is now successfully populated
+
+	// name check
+	name, err := normaliseCollectionName(reqCollection.Name)
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid collection name: "+err.Error(), http.StatusBadRequest)
+		return // early return
+	}
+
+	// rename it
+	collection, err := apiCfg.db.RenameBookmarkCollection(req.Context(), database.RenameBookmarkCollectionParams{
+		ID:     collectionID,
+		UserID: caller.UserID,
+		Name:   name,
+	})
+
+	// not found check (someone else's looks the same as none)
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Collection not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// duplicate name check
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "You already have a collection with that name", http.StatusConflict)
+		return // early return
+	}
+
+	// rename collection check
+	if err != nil {
+		log.Printf("Error renaming collection: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred renaming collection", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, collectionResponse(database.BookmarkCollection{
+		ID:        collection.ID,
+		CreatedAt: collection.CreatedAt,
+		UpdatedAt: collection.UpdatedAt,
+		UserID:    collection.UserID,
+		Name:      collection.Name,
+	}, collection.BookmarkCount), http.StatusOK)
+}
+
+// DeleteCollection handler that deletes one of the caller's bookmark collections, its bookmarks are kept
+func (apiCfg *apiConfig) handlerDeleteCollection(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get collection id from api endpoint path string
+	collectionID, err := uuid.Parse(req.PathValue("collectionID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid collection ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// delete it
+	deleted, err := apiCfg.db.DeleteBookmarkCollection(req.Context(), database.DeleteBookmarkCollectionParams{
+		ID:     collectionID,
+		UserID: caller.UserID,
+	})
+
+	// delete collection check
+	if err != nil {
+		log.Printf("Error deleting collection: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred deleting collection", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// not found check (someone else's looks the same as none)
+	if deleted == 0 {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Collection not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
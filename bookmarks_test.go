@@ -0,0 +1,167 @@
+// bookmarks_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test a cursor reads back to the bookmark it was made from
+func TestBookmarkCursor(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	id := uuid.New()
+
+	gotAt, gotID, err := decodeBookmarkCursor(encodeBookmarkCursor(at, id))
+	if err != nil {
+		t.Fatalf("decodeBookmarkCursor() error = %v", err)
+	}
+	if !gotAt.Equal(at) || gotID != id {
+		t.Errorf("decodeBookmarkCursor() = %v, %v, want %v, %v", gotAt, gotID, at, id)
+	}
+
+	for _, cursor := range []string{"!!!", "bm90LWEtY3Vyc29y", "eCx5"} {
+		if _, _, err := decodeBookmarkCursor(cursor); err != errInvalidCursor {
+			t.Errorf("decodeBookmarkCursor(%q) error = %v, want %v", cursor, err, errInvalidCursor)
+		}
+	}
+}
+
+// test collection names are trimmed and checked
+func TestNormaliseCollectionName(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"Trimmed", "  Recipes ", "Recipes", false},
+		{"Empty", "   ", "", true},
+		{"Longest", strings.Repeat("é", maxCollectionNameLength), strings.Repeat("é", maxCollectionNameLength), false},
+		{"Too long", strings.Repeat("a", maxCollectionNameLength+1), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normaliseCollectionName(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normaliseCollectionName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normaliseCollectionName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// test anonymous callers and tokens without bookmarks:read get no flag
+func TestMarkBookmarkedSkipped(t *testing.T) {
+	apiCfg := &apiConfig{}
+	contexts := map[string]context.Context{
+		"Anonymous": context.Background(),
+		"No scope":  context.WithValue(context.Background(), principalContextKey, principal{UserID: uuid.New(), Scopes: []string{auth.ScopeChirpsRead}}),
+	}
+
+	for name, ctx := range contexts {
+		t.Run(name, func(t *testing.T) {
+			chirps := []JsonChirpResponse{{ID: uuid.New()}}
+			if err := apiCfg.markBookmarked(ctx, chirps); err != nil {
+				t.Fatalf("markBookmarked() error = %v", err)
+			}
+			if chirps[0].BookmarkedByMe != nil {
+				t.Errorf("BookmarkedByMe = %v, want nil", *chirps[0].BookmarkedByMe)
+			}
+		})
+	}
+}
+
+// test bad paging input is refused before the db is touched
+func TestGetBookmarksBadRequest(t *testing.T) {
+	apiCfg := &apiConfig{}
+	for _, query := range []string{"limit=0", "limit=101", "limit=ten", "collection_id=nope", "cursor=!!!"} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/bookmarks?"+query, nil)
+			ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+			rec := httptest.NewRecorder()
+			apiCfg.handlerGetBookmarks(rec, req.WithContext(ctx))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// test a malformed chirp id is refused
+func TestBookmarkChirpInvalidID(t *testing.T) {
+	apiCfg := &apiConfig{}
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps/nope/bookmark", nil)
+	req.SetPathValue("chirpID", "nope")
+	ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+	rec := httptest.NewRecorder()
+	apiCfg.handlerBookmarkChirp(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// test only chirps the caller can see can be bookmarked
+func TestBookmarkChirpVisibility(t *testing.T) {
+	callerID := uuid.New()
+	otherID := uuid.New()
+	blockedID := uuid.New()
+	restrictedID := uuid.New()
+	now := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	// build test cases
+	testCases := []struct {
+		name           string         // name for test case
+		chirp          database.Chirp // the chirp, id filled in
+		exists         bool           // whether it's in the db
+		expectedStatus int            // status code we want
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: visible", database.Chirp{UserID: otherID}, true, http.StatusNoContent},
+		{"Test case: missing", database.Chirp{UserID: otherID}, false, http.StatusNotFound},
+		{"Test case: hidden by a moderator", database.Chirp{UserID: otherID, HiddenAt: now}, true, http.StatusNotFound},
+		{"Test case: held by the spam filter", database.Chirp{UserID: otherID, HeldAt: now}, true, http.StatusNotFound},
+		{"Test case: own held chirp", database.Chirp{UserID: callerID, HeldAt: now}, true, http.StatusNoContent},
+		{"Test case: blocked author", database.Chirp{UserID: blockedID}, true, http.StatusNotFound},
+		{"Test case: restricted author", database.Chirp{UserID: restrictedID}, true, http.StatusNotFound},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		fake := newFakeQuerier()
+		fake.blocked = []uuid.UUID{blockedID}
+		fake.restricted = []uuid.UUID{restrictedID}
+		chirp := tc.chirp
+		chirp.ID = uuid.New()
+		if tc.exists {
+			fake.chirps[chirp.ID] = chirp
+		}
+		apiCfg := &apiConfig{db: fake}
+
+		// bookmark it
+		req := httptest.NewRequest(http.MethodPost, "/api/chirps/"+chirp.ID.String()+"/bookmark", nil)
+		req.SetPathValue("chirpID", chirp.ID.String())
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: callerID})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerBookmarkChirp(rec, req.WithContext(ctx))
+
+		// check the outcome
+		if rec.Code != tc.expectedStatus {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.expectedStatus)
+		}
+		if fake.called("CreateBookmark") != (tc.expectedStatus == http.StatusNoContent) {
+			t.Errorf("%s: bookmarked = %v with status %d", tc.name, fake.called("CreateBookmark"), rec.Code)
+		}
+	}
+}
@@ -8,19 +8,27 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
 
-	"github.com/PietPadda/chirpy/internal/auth"
 	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/spam"
 	"github.com/google/uuid"
 )
 
+// longest chirp body, in bytes
+const maxChirpLength = 140
+
+// ERRORS
+var (
+	errChirpEmpty             = errors.New("chirp is empty")
+	errChirpTooLong           = errors.New("chirp is too long")
+	errAttachmentsUnavailable = errors.New("attachment unknown or already used")
+)
+
 // CreateChirp handler that creates a chirp (keep ValidateChirp logic)
 func (apiCfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Request) {
-	// consts
-	const maxMessageLimit = 140
-
 	// HTTP method check
 	if req.Method != "POST" {
 		// helper to insert error msg + 405 invalid method status code
@@ -31,27 +39,19 @@ func (apiCfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Req
 	// json request from client
 	var reqBody JsonChirpRequest
 
-	// authenticate before decoding request
-	token, err := auth.GetBearerToken(req.Header) // get the bearer's token
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
 
-	// get token check
-	if err != nil {
-		log.Printf("Error getting bearer token: %s", err) // log msg with err
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
 		// helper to insert error msg + 401 unauthorized status code
 		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
 		return // early return
 	}
 
-	// validate the JWT token after getting bearer's token
-	uuidJWTValidated, err := auth.ValidateJWT(token, apiCfg.serverKey) // pass in tokenstring and server secret
-
-	// jwt validation check
-	if err != nil {
-		log.Printf("Error validating JWT token: %s", err) // log msg with err
-		// helper to insert error msg + 401 unauthorized status code
-		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
-		return // early return
-	}
+	// get the validated user id
+	uuidJWTValidated := caller.UserID
 
 	// create json req body decoder
 	decoder := json.NewDecoder(req.Body)
@@ -60,7 +60,7 @@ func (apiCfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Req
 	defer req.Body.Close()
 
 	// decode the req body
-	err = decoder.Decode(&reqBody)
+	err := decoder.Decode(&reqBody)
 
 	// request body missing edge case check (before general error check)
 	if err == io.EOF { // end of file
@@ -83,48 +83,174 @@ func (apiCfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Req
 
 	// reqBody is now successfully populated
 
+	// check and clean the chirp body
+	bodyClean, err := moderateChirpBody(reqBody.Body)
+
 	// check chirp empty
-	if len(reqBody.Body) == 0 {
+	if errors.Is(err, errChirpEmpty) {
 		// helper to insert error msg + 400 bad req status code
 		WriteJSONError(w, "Chirp is empty", http.StatusBadRequest)
 		return // early return
 	}
 
 	// check chirp too long
-	if len(reqBody.Body) > maxMessageLimit {
+	if errors.Is(err, errChirpTooLong) {
 		// helper to insert error msg + 400 bad req status code
 		WriteJSONError(w, "Chirp is too long", http.StatusBadRequest)
 		return // early return
 	}
 
-	// clean the request body
-	bodyClean := cleanProfanity(reqBody.Body)
+	// check attachment count
+	if len(reqBody.AttachmentIDs) > maxAttachmentsPerChirp {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Too many attachments", http.StatusBadRequest)
+		return // early return
+	}
+
+	// check attachment duplicates (each must match a row below)
+	seenAttachments := make(map[uuid.UUID]bool, len(reqBody.AttachmentIDs))
+	for _, id := range reqBody.AttachmentIDs {
+		if seenAttachments[id] {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Duplicate attachment", http.StatusBadRequest)
+			return // early return
+		}
+		seenAttachments[id] = true
+	}
+
+	// get the author, only verified addresses may chirp
+	author, err := apiCfg.db.GetUserByID(req.Context(), uuidJWTValidated)
+
+	// get author check
+	if err != nil {
+		log.Printf("Error getting chirp author: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred creating new chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// verified check
+	if !author.EmailVerifiedAt.Valid {
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "Verify your email address before chirping", http.StatusForbidden)
+		return // early return
+	}
+
+	// suspended check (a token issued before the suspension may not be denylisted here yet)
+	if apiCfg.refuseSuspended(w, req, uuidJWTValidated) {
+		return // early return
+	}
+
+	// check, create, attach and hold in one transaction, holding the author's lock
+	// so two chirps sent at once can't both pass the spam check against the same history
+	var verdict spam.Verdict
+	var newChirp database.Chirp
+	err = apiCfg.db.inTx(req.Context(), func(q database.Querier) error {
+		// wait for the author's other chirps
+		err := q.LockChirpAuthor(req.Context(), uuidJWTValidated)
+		if err != nil {
+			return err
+		}
+
+		// spam check
+		verdict, err = apiCfg.checkSpam(req.Context(), q, author, bodyClean, nil)
+		if err != nil || verdict.RetryAfter > 0 || verdict.Outcome == spam.OutcomeReject {
+			return err // nothing to write
+		}
+
+		// create chirp
+		newChirp, err = q.CreateChirp(req.Context(), database.CreateChirpParams{
+			Body:   bodyClean,        // add the profanity cleaned chirp body
+			UserID: uuidJWTValidated, // get user_id from the VALIDATED JWT!
+		}) // we ignore the request's userid and ONLY use the VALIDATED userid!
+		if err != nil {
+			return err
+		}
+
+		// hand over the attachments (the uploader's own, unused ones only)
+		if len(reqBody.AttachmentIDs) > 0 {
+			attached, err := q.AttachToChirp(req.Context(), database.AttachToChirpParams{
+				ChirpID: uuid.NullUUID{UUID: newChirp.ID, Valid: true},
+				Ids:     reqBody.AttachmentIDs,
+				UserID:  uuidJWTValidated,
+			})
+			if err != nil {
+				return err
+			}
+
+			// all or nothing, a miss rolls back the chirp too
+			if attached != int64(len(reqBody.AttachmentIDs)) {
+				log.Printf("Error attaching %d attachments: only %d attached", len(reqBody.AttachmentIDs), attached) // log msg
+				return errAttachmentsUnavailable
+			}
+		}
 
-	// create chirp
-	newChirp, err := apiCfg.db.CreateChirp(req.Context(), database.CreateChirpParams{
-		Body:   bodyClean,        // add the profanity cleaned chirp body
-		UserID: uuidJWTValidated, // get user_id from the VALIDATED JWT!
-	}) // we ignore the request's userid and ONLY use the VALIDATED userid!
+		// held check, posted but only the author sees it until a moderator has looked
+		if verdict.Outcome == spam.OutcomeHold {
+			log.Printf("Chirp %s held for review: %v (score %d)", newChirp.ID, verdict.Signals, verdict.Score) // log msg
+			return holdChirp(req.Context(), q, &newChirp, verdict)
+		}
+		return nil
+	})
+
+	// attach check
+	if errors.Is(err, errAttachmentsUnavailable) {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Unknown or already used attachment", http.StatusBadRequest)
+		return // early return
+	}
 
 	// create chirp check
 	if err != nil {
 		log.Printf("Error creating chirp: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred creating new chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// throttled check, new accounts posting too fast are told when to try again
+	if verdict.RetryAfter > 0 {
+		log.Printf("Chirp throttled for new account %s: retry in %s", author.ID, verdict.RetryAfter) // log msg
+		// helper to insert error msg + 429 too many requests status code
+		WriteTooManyRequests(w, "New accounts can only chirp so often, try again later", verdict.RetryAfter)
+		return // early return
+	}
+
+	// rejected check
+	if verdict.Outcome == spam.OutcomeReject {
+		log.Printf("Chirp rejected as spam for %s: %v (score %d)", author.ID, verdict.Signals, verdict.Score) // log msg
 		// helper to insert error msg + 400 bad req status code
-		WriteJSONError(w, "Error occurred creating new chirp", http.StatusBadRequest)
+		if slices.Contains(verdict.Signals, spam.SignalDuplicate) {
+			WriteJSONError(w, "You already chirped that", http.StatusBadRequest)
+		} else {
+			WriteJSONError(w, "Chirp looks like spam", http.StatusBadRequest)
+		}
 		return // early return
 	}
 
-	// json response payload
-	respChirp := JsonChirpResponse{
-		ID:        newChirp.ID,
-		CreatedAt: newChirp.CreatedAt,
-		UpdatedAt: newChirp.UpdatedAt,
-		Body:      newChirp.Body,
-		UserID:    newChirp.UserID,
+	// read the attachments back in order
+	attachments := []JsonAttachmentResponse{}
+	if len(reqBody.AttachmentIDs) > 0 {
+		byChirp, err := apiCfg.chirpAttachments(req.Context(), []uuid.UUID{newChirp.ID})
+		if err != nil {
+			log.Printf("Error getting attachments: %s", err) // log msg with err
+		}
+		if byChirp != nil {
+			attachments = byChirp[newChirp.ID]
+		}
 	}
 
-	// helper to insert body response + 201 created status code
-	WriteJSONResponse(w, respChirp, http.StatusCreated)
+	// held chirps are accepted rather than created
+	status := http.StatusCreated
+	if newChirp.HeldAt.Valid {
+		status = http.StatusAccepted
+	}
+
+	// json response payload
+	respChirp := chirpResponse(newChirp, author.Handle.String, attachments)
+
+	// helper to insert body response + 201 created (or 202 accepted when held) status code
+	WriteJSONResponse(w, respChirp, status)
 }
 
 // DeleteChirp handler that deletes a chirp
@@ -169,27 +295,19 @@ func (apiCfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Req
 		return // early return
 	}
 
-	// authenticate before decoding request
-	token, err := auth.GetBearerToken(req.Header) // get the bearer's token
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
 
-	// get token check
-	if err != nil {
-		log.Printf("Error getting bearer token: %s", err) // log msg with err
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
 		// helper to insert error msg + 401 unauthorized status code
 		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
 		return // early return
 	}
 
-	// validate the JWT token after getting bearer's token
-	uuidJWTValidated, err := auth.ValidateJWT(token, apiCfg.serverKey) // pass in tokenstring and server secret
-
-	// jwt validation check
-	if err != nil {
-		log.Printf("Error validating JWT token: %s", err) // log msg with err
-		// helper to insert error msg + 401 unauthorized status code
-		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
-		return // early return
-	}
+	// get the validated user id
+	uuidJWTValidated := caller.UserID
 
 	// get chirp author id
 	uuidChirpAuthor, err := apiCfg.db.GetUserIDByChirpID(req.Context(), chirpUUID)
@@ -210,6 +328,12 @@ func (apiCfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Req
 		return // early return
 	}
 
+	// note the attachments, their rows go with the chirp but the blobs don't
+	attachments, err := apiCfg.db.ListAttachmentsForChirps(req.Context(), []uuid.UUID{chirpUUID})
+	if err != nil {
+		log.Printf("Error listing chirp attachments: %s", err) // log msg with err
+	}
+
 	// proceed to delete the chirp
 	deletedChirp, err := apiCfg.db.DeleteChirp(req.Context(), chirpUUID)
 
@@ -222,6 +346,9 @@ func (apiCfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Req
 		return // stop processing req
 	}
 
+	// then the blobs (best effort)
+	apiCfg.deleteAttachmentBlobs(req.Context(), attachments)
+
 	// write to server and client that chirp deleted
 	log.Printf("Chirp has been deleted: ID = %s", deletedChirp.ID) // log msg with err
 	w.WriteHeader(http.StatusNoContent)                            // status code 204 to client
@@ -288,6 +415,33 @@ func (apiCfg *apiConfig) handlerGetChirps(w http.ResponseWriter, req *http.Reque
 		}
 	}
 
+	// Drop authors the caller must not see: blocks always, mutes on the all chirps feed only
+	hidden, err := apiCfg.hiddenAuthors(req.Context(), len(authorIDStr) == 0)
+	if err != nil {
+		log.Printf("Error getting blocked and muted users: %s", err)
+		WriteJSONError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
+	}
+
+	// Then the suspended and shadow banned, who only see their own
+	restricted, err := apiCfg.restrictedAuthors(req.Context())
+	if err != nil {
+		log.Printf("Error getting restricted users: %s", err)
+		WriteJSONError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
+	}
+	for id := range restricted {
+		hidden[id] = true
+	}
+
+	visibleChirps := dbChirps[:0] // filter in place, chirps hidden by a moderator go too
+	for _, dbChirp := range dbChirps {
+		if !hidden[dbChirp.UserID] && !hiddenFromCaller(req.Context(), dbChirp) {
+			visibleChirps = append(visibleChirps, dbChirp)
+		}
+	}
+	dbChirps = visibleChirps
+
 	// handle optional SORT param
 	sortChirps := req.URL.Query().Get("sort") // Get optional query parameter
 
@@ -302,16 +456,41 @@ func (apiCfg *apiConfig) handlerGetChirps(w http.ResponseWriter, req *http.Reque
 	// sort the slice per optional parameter IN MEMORY
 	sort.Slice(dbChirps, sortFunc) // use our switch case anonymous func
 
+	// Collect the authors so their handles come back in one query
+	authorIDs := make([]uuid.UUID, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		authorIDs = append(authorIDs, dbChirp.UserID)
+	}
+	handles, err := apiCfg.authorHandles(req.Context(), authorIDs)
+	if err != nil {
+		log.Printf("Error getting author handles: %s", err)
+		WriteJSONError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
+	}
+
+	// Same for the attachments
+	chirpIDs := make([]uuid.UUID, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		chirpIDs = append(chirpIDs, dbChirp.ID)
+	}
+	attachments, err := apiCfg.chirpAttachments(req.Context(), chirpIDs)
+	if err != nil {
+		log.Printf("Error getting chirp attachments: %s", err)
+		WriteJSONError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
+	}
+
 	// Transform database chirps into JSON response format
 	chirpResponses := make([]JsonChirpResponse, len(dbChirps))
 	for i, dbChirp := range dbChirps { // loop through field in each chirp
-		chirpResponses[i] = JsonChirpResponse{ // then populate the response
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			Body:      dbChirp.Body,
-			UserID:    dbChirp.UserID,
-		}
+		chirpResponses[i] = chirpResponse(dbChirp, handles[dbChirp.UserID], attachments[dbChirp.ID]) // then populate the response
+	}
+
+	// Flag the ones the caller has bookmarked (signed in callers only)
+	if err := apiCfg.markBookmarked(req.Context(), chirpResponses); err != nil {
+		log.Printf("Error getting bookmarks: %s", err)
+		WriteJSONError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
 	}
 
 	// Send successful response
@@ -369,21 +548,127 @@ func (apiCfg *apiConfig) handlerGetChirp(w http.ResponseWriter, req *http.Reques
 		return // early return
 	}
 
+	// hidden by a moderator check, only the author still sees it
+	if hiddenFromCaller(req.Context(), dbChirp) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// block check, either side of a block sees no chirp at all
+	blocked, err := apiCfg.blockedEitherWay(req.Context(), dbChirp.UserID)
+
+	// get block check
+	if err != nil {
+		log.Printf("Error checking blocks: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// blocked check
+	if blocked {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// restricted author check, the suspended and shadow banned are only seen by themselves
+	restricted, err := apiCfg.restrictedFromCaller(req.Context(), dbChirp.UserID)
+
+	// get restriction check
+	if err != nil {
+		log.Printf("Error checking restrictions: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// restricted check
+	if restricted {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get the author's handle
+	handles, err := apiCfg.authorHandles(req.Context(), []uuid.UUID{dbChirp.UserID})
+
+	// get handle check
+	if err != nil {
+		log.Printf("Error getting author handle: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// get the attachments
+	attachments, err := apiCfg.chirpAttachments(req.Context(), []uuid.UUID{dbChirp.ID})
+
+	// get attachments check
+	if err != nil {
+		log.Printf("Error getting chirp attachments: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
 	// build the chirp response
-	chirpResp := JsonChirpResponse{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
+	chirpResps := []JsonChirpResponse{chirpResponse(dbChirp, handles[dbChirp.UserID], attachments[dbChirp.ID])}
+
+	// flag it if the caller has bookmarked it (signed in callers only)
+	err = apiCfg.markBookmarked(req.Context(), chirpResps)
+
+	// get bookmarks check
+	if err != nil {
+		log.Printf("Error getting bookmarks: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting chirp", http.StatusInternalServerError)
+		return // early return
 	}
 
 	// helper to insert body response + 200 OK status code
-	WriteJSONResponse(w, chirpResp, http.StatusOK)
+	WriteJSONResponse(w, chirpResps[0], http.StatusOK)
 }
 
 // HELPER FUNCS
 
+// RESPONSE helper to build a chirp response, handle is "" when the author has none
+func chirpResponse(chirp database.Chirp, authorHandle string, attachments []JsonAttachmentResponse) JsonChirpResponse {
+	// always a list in the json, never null
+	if attachments == nil {
+		attachments = []JsonAttachmentResponse{}
+	}
+
+	return JsonChirpResponse{
+		ID:           chirp.ID,
+		CreatedAt:    chirp.CreatedAt,
+		UpdatedAt:    chirp.UpdatedAt,
+		Body:         chirp.Body,
+		UserID:       chirp.UserID,
+		AuthorHandle: authorHandle,
+		Attachments:  attachments,
+		Hidden:       chirp.HiddenAt.Valid,
+		Held:         chirp.HeldAt.Valid,
+	}
+}
+
+// the moderation every chirp body goes through, returns the body to store
+func moderateChirpBody(body string) (string, error) {
+	// check chirp empty
+	if len(body) == 0 {
+		return "", errChirpEmpty
+	}
+
+	// check chirp too long
+	if len(body) > maxChirpLength {
+		return "", errChirpTooLong
+	}
+
+	// clean the body
+	return cleanProfanity(body), nil
+}
+
 // RESPONSE helper to clean profanity before passing payload to response
 func cleanProfanity(body string) string {
 	// split the body
@@ -3,7 +3,18 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
 )
 
 // test cleanProfanity
@@ -34,3 +45,101 @@ func TestCleanProfanity(t *testing.T) {
 		// %s doesn't, meaning we can test EXACTLY
 	}
 }
+
+// test moderateChirpBody
+func TestModerateChirpBody(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		input    string
+		expected string
+		err      error
+	}{
+		{"a fine chirp", "a fine chirp", nil},
+		{"what a kerfuffle", "what a ****", nil},
+		{"", "", errChirpEmpty},
+		{strings.Repeat("x", maxChirpLength+1), "", errChirpTooLong},
+	}
+
+	// run test cases
+	for _, tc := range testCases {
+		got, err := moderateChirpBody(tc.input)
+		if got != tc.expected || !errors.Is(err, tc.err) {
+			t.Errorf("moderateChirpBody(%.20q) = %q, %v, want %q, %v", tc.input, got, err, tc.expected, tc.err)
+		}
+	}
+}
+
+// test the chirps feed drops what the caller mustn't see, and keeps their own
+func TestGetChirpsHidesFromCaller(t *testing.T) {
+	callerID := uuid.New()
+	otherID := uuid.New()
+	blockedID := uuid.New()
+	mutedID := uuid.New()
+	restrictedID := uuid.New()
+	now := time.Now().UTC()
+	hiddenAt := sql.NullTime{Time: now, Valid: true}
+
+	// one chirp per way of being hidden, plus the caller's own
+	fake := newFakeQuerier()
+	fake.blocked = []uuid.UUID{blockedID}
+	fake.muted = []uuid.UUID{mutedID}
+	fake.restricted = []uuid.UUID{restrictedID, callerID} // the caller is shadow banned too
+	chirps := map[string]database.Chirp{
+		"plain":            {ID: uuid.New(), UserID: otherID},
+		"blocked":          {ID: uuid.New(), UserID: blockedID},
+		"muted":            {ID: uuid.New(), UserID: mutedID},
+		"restricted":       {ID: uuid.New(), UserID: restrictedID},
+		"hidden":           {ID: uuid.New(), UserID: otherID, HiddenAt: hiddenAt},
+		"held":             {ID: uuid.New(), UserID: otherID, HeldAt: hiddenAt},
+		"own held":         {ID: uuid.New(), UserID: callerID, HeldAt: hiddenAt},
+		"own hidden":       {ID: uuid.New(), UserID: callerID, HiddenAt: hiddenAt},
+		"muted, by author": {ID: uuid.New(), UserID: mutedID},
+	}
+	for _, chirp := range chirps {
+		chirp.CreatedAt = now
+		fake.chirps[chirp.ID] = chirp
+	}
+	apiCfg := &apiConfig{db: fake}
+
+	// build test cases
+	testCases := []struct {
+		name     string   // name for test case
+		query    string   // feed filters
+		expected []string // chirps we want back
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: all chirps", "", []string{"plain", "own held", "own hidden"}},
+		{"Test case: muted author's page", "?author_id=" + mutedID.String(), []string{"muted", "muted, by author"}},
+		{"Test case: blocked author's page", "?author_id=" + blockedID.String(), nil},
+		{"Test case: restricted author's page", "?author_id=" + restrictedID.String(), nil},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/api/chirps"+tc.query, nil)
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: callerID})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerGetChirps(rec, req.WithContext(ctx))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", tc.name, rec.Code, http.StatusOK) // fatal, don't continue
+		}
+
+		var resp []JsonChirpResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: decoding response failed: %v", tc.name, err) // fatal, don't continue
+		}
+		got := make(map[uuid.UUID]bool, len(resp))
+		for _, chirp := range resp {
+			got[chirp.ID] = true
+		}
+
+		// exactly the expected ones
+		if len(resp) != len(tc.expected) {
+			t.Errorf("%s: got %d chirps, want %d", tc.name, len(resp), len(tc.expected))
+		}
+		for _, name := range tc.expected {
+			if !got[chirps[name].ID] {
+				t.Errorf("%s: %q chirp missing", tc.name, name)
+			}
+		}
+	}
+}
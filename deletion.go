@@ -0,0 +1,253 @@
+// deletion.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+)
+
+// how long a requested deletion can still be cancelled
+const accountDeletionGrace = 30 * 24 * time.Hour
+
+// ACCOUNT PURGE
+// delete the accounts whose grace period is over, along with their stored media
+func (apiCfg *apiConfig) purgeDeletedAccounts(ctx context.Context) error {
+	// get the accounts due
+	due, err := apiCfg.db.GetUsersDueForDeletion(ctx)
+
+	// get due check
+	if err != nil {
+		return err // early return
+	}
+
+	// one at a time, a failure leaves the rest for the next run
+	for _, user := range due {
+		// note the uploads, their rows go with the account but the blobs don't
+		attachments, err := apiCfg.db.ListAttachmentsForUser(ctx, user.ID)
+		if err != nil {
+			return err // early return
+		}
+
+		// delete, unless they cancelled since
+		deleted, err := apiCfg.db.DeleteUserIfDue(ctx, user.ID)
+		if err != nil {
+			return err // early return
+		}
+		if deleted == 0 {
+			continue
+		}
+
+		// the blobs are unreachable now
+		apiCfg.deleteAttachmentBlobs(ctx, attachments)
+		if user.AvatarKey.Valid {
+			apiCfg.deleteAvatarBlobs(ctx, user.AvatarKey.String)
+		}
+		log.Printf("Deleted account %s after its grace period", user.ID)
+	}
+
+	return nil
+}
+
+// purge deleted accounts on an interval, blocks so run it in a goroutine
+func (apiCfg *apiConfig) runAccountPurge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// purge on each tick
+	for range ticker.C {
+		err := apiCfg.purgeDeletedAccounts(context.Background())
+
+		// purge check (whatever is left goes next tick)
+		if err != nil {
+			log.Printf("Error purging deleted accounts: %s", err)
+		}
+	}
+}
+
+// HANDLERS
+// DeleteMe handler that schedules the caller's account for deletion after a grace period
+// the password is asked again and every session, api token and app grant is revoked
+func (apiCfg *apiConfig) handlerDeleteMe(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqDelete JsonDeleteAccountRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqDelete)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqDelete is now successfully populated
+
+	// re-authentication check
+	if reqDelete.CurrentPassword == "" {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Current password is required", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the user
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// current password check (writes the error on failure)
+	if !apiCfg.checkCurrentPassword(w, req, user, reqDelete.CurrentPassword) {
+		return // early return
+	}
+
+	// already pending, asking again doesn't move the date
+	if user.DeleteAfter.Valid {
+		// helper to insert body response + 202 accepted status code
+		WriteJSONResponse(w, JsonDeletionResponse{DeleteAfter: user.DeleteAfter.Time}, http.StatusAccepted)
+		return // early return
+	}
+
+	// start the grace period
+	deleteAfter := time.Now().UTC().Add(accountDeletionGrace)
+	err = apiCfg.db.ScheduleUserDeletion(req.Context(), database.ScheduleUserDeletionParams{
+		ID:          user.ID,
+		DeleteAfter: sql.NullTime{Time: deleteAfter, Valid: true},
+	})
+
+	// schedule check
+	if err != nil {
+		log.Printf("Error scheduling deletion for %s: %s", user.ID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred deleting account", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// sign out everywhere, access tokens already out expire on their own
+	err = apiCfg.db.RevokeAllRefreshTokensForUser(req.Context(), user.ID)
+
+	// revoke sessions check (the deletion stands, log it)
+	if err != nil {
+		log.Printf("Error revoking sessions for %s: %s", user.ID, err) // log msg with err
+	}
+
+	// api tokens go too, they'd otherwise keep working for the whole grace period
+	err = apiCfg.db.RevokeAllAPITokensForUser(req.Context(), user.ID)
+
+	// revoke api tokens check (the deletion stands, log it)
+	if err != nil {
+		log.Printf("Error revoking api tokens for %s: %s", user.ID, err) // log msg with err
+	}
+
+	// and so do the apps the user authorized, codes not yet exchanged included
+	err = apiCfg.db.RevokeAllOAuthGrantsForUser(req.Context(), user.ID)
+	if err == nil {
+		err = apiCfg.db.DeleteOAuthCodesForUser(req.Context(), user.ID)
+	}
+
+	// revoke grants check (the deletion stands, log it)
+	if err != nil {
+		log.Printf("Error revoking oauth grants for %s: %s", user.ID, err) // log msg with err
+	}
+
+	// tell the account's inbox (best effort)
+	err = apiCfg.sendDeletionNotice(req.Context(), user, deleteAfter)
+	if err != nil {
+		log.Printf("Error sending deletion notice: %s", err) // log msg with err
+	}
+
+	// helper to insert body response + 202 accepted status code
+	WriteJSONResponse(w, JsonDeletionResponse{DeleteAfter: deleteAfter}, http.StatusAccepted)
+}
+
+// CancelDeletion handler that keeps an account scheduled for deletion
+// revoked sessions, api tokens and app grants stay revoked, the user signs in and re-creates them
+func (apiCfg *apiConfig) handlerCancelDeletion(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// cancel it
+	cancelled, err := apiCfg.db.CancelUserDeletion(req.Context(), caller.UserID)
+
+	// cancel check
+	if err != nil {
+		log.Printf("Error cancelling deletion for %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred cancelling deletion", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// nothing pending check
+	if cancelled == 0 {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "No deletion is pending", http.StatusNotFound)
+		return // early return
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
@@ -0,0 +1,47 @@
+// deletion_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test deleting an account needs the password again
+func TestDeleteMeNeedsPassword(t *testing.T) {
+	apiCfg := &apiConfig{}
+
+	// build test cases
+	for _, body := range []string{"", `{}`, `{"current_password": ""}`} {
+		req := httptest.NewRequest(http.MethodDelete, "/api/users/me", strings.NewReader(body))
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerDeleteMe(rec, req.WithContext(ctx))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %q: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// test a pending deletion shows on the account
+func TestUserResponseDeleteAfter(t *testing.T) {
+	apiCfg := &apiConfig{}
+	user := database.User{ID: uuid.New()}
+	if resp := apiCfg.userResponse(user); resp.DeleteAfter != nil {
+		t.Errorf("DeleteAfter = %v, want nil", resp.DeleteAfter)
+	}
+
+	deleteAfter := time.Now().Add(accountDeletionGrace)
+	user.DeleteAfter = sql.NullTime{Time: deleteAfter, Valid: true}
+	if resp := apiCfg.userResponse(user); resp.DeleteAfter == nil || !resp.DeleteAfter.Equal(deleteAfter) {
+		t.Errorf("DeleteAfter = %v, want %v", resp.DeleteAfter, deleteAfter)
+	}
+}
@@ -0,0 +1,75 @@
+// denylist.go
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DENYLIST SYNC
+// load revoked access token ids and suspended users from the db into the in-memory denylist
+// rows are inserted by operators (or other instances) for emergency revocation
+func (apiCfg *apiConfig) syncDenylist(ctx context.Context) error {
+	// drop rows for tokens that have expired anyway
+	err := apiCfg.db.DeleteExpiredRevokedAccessTokens(ctx)
+
+	// cleanup check
+	if err != nil {
+		return err // early return
+	}
+
+	// get the still active revocations
+	revoked, err := apiCfg.db.GetActiveRevokedAccessTokens(ctx)
+
+	// get revocations check
+	if err != nil {
+		return err // early return
+	}
+
+	// deny each of them in memory
+	for _, token := range revoked {
+		apiCfg.denylist.Deny(token.Jti, token.ExpiresAt)
+	}
+
+	// get the suspensions in force
+	suspensions, err := apiCfg.db.GetActiveSuspensions(ctx)
+
+	// get suspensions check
+	if err != nil {
+		return err // early return
+	}
+
+	// deny each suspended user until their last suspension ends
+	// replacing the set picks up suspensions lifted on other instances
+	suspendedUntil := make(map[uuid.UUID]time.Time, len(suspensions))
+	for _, suspension := range suspensions {
+		if suspension.EndsAt.After(suspendedUntil[suspension.UserID]) {
+			suspendedUntil[suspension.UserID] = suspension.EndsAt
+		}
+	}
+	apiCfg.denylist.SetDeniedUsers(suspendedUntil)
+
+	// forget the expired ones in memory too
+	apiCfg.denylist.Prune(time.Now().UTC())
+
+	return nil
+}
+
+// keep the denylist in sync on an interval, blocks so run it in a goroutine
+func (apiCfg *apiConfig) runDenylistSync(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// sync on each tick
+	for range ticker.C {
+		err := apiCfg.syncDenylist(context.Background())
+
+		// sync check (keep the old entries and try again next tick)
+		if err != nil {
+			log.Printf("Error syncing access token denylist: %s", err)
+		}
+	}
+}
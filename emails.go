@@ -0,0 +1,648 @@
+// emails.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/mailer"
+	"github.com/google/uuid"
+	"github.com/lib/pq" // postgresql driver
+)
+
+// emailed token lifetimes
+const (
+	verifyEmailTokenDuration   = 48 * time.Hour // time to find the mail and click
+	resetPasswordTokenDuration = time.Hour      // short, a reset link is a password
+	changeEmailTokenDuration   = 48 * time.Hour // same as verifying a new signup
+)
+
+// build the mailer from the environment
+// MAILER picks smtp, file (default, writes .eml files to MAIL_OUTBOX_DIR) or memory
+func mailerFromEnv() (mailer.Mailer, error) {
+	// sender address
+	from := strings.TrimSpace(os.Getenv("MAIL_FROM"))
+	if from == "" {
+		from = "Chirpy <noreply@localhost>"
+	}
+
+	switch kind := strings.TrimSpace(os.Getenv("MAILER")); kind {
+	case "", "file":
+		// outbox dir
+		dir := strings.TrimSpace(os.Getenv("MAIL_OUTBOX_DIR"))
+		if dir == "" {
+			dir = "outbox"
+		}
+		return mailer.NewFileMailer(dir, from), nil
+
+	case "memory":
+		return mailer.NewMemoryMailer(), nil
+
+	case "smtp":
+		// server address check
+		addr := strings.TrimSpace(os.Getenv("SMTP_ADDR"))
+		if addr == "" {
+			return nil, errors.New("SMTP_ADDR is not set")
+		}
+		return &mailer.SMTPMailer{
+			Addr:     addr,
+			From:     from,
+			Username: strings.TrimSpace(os.Getenv("SMTP_USERNAME")),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("MAILER must be smtp, file or memory, not %q", kind)
+	}
+}
+
+// make, store and return a fresh emailed token, retiring older ones for the same purpose
+func (apiCfg *apiConfig) issueEmailToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	// only the newest link works
+	err := apiCfg.db.InvalidateEmailTokens(ctx, database.InvalidateEmailTokensParams{
+		UserID:  userID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// generate the token
+	token, err := auth.MakeEmailToken()
+	if err != nil {
+		return "", err
+	}
+
+	// store only the signed hash
+	_, err = apiCfg.db.CreateEmailToken(ctx, database.CreateEmailTokenParams{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: auth.HashEmailToken(token, purpose, apiCfg.serverKey),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// spend an emailed token, returning who it was issued to
+func (apiCfg *apiConfig) consumeEmailToken(ctx context.Context, token, purpose string) (uuid.UUID, error) {
+	return apiCfg.db.ConsumeEmailToken(ctx, database.ConsumeEmailTokenParams{
+		TokenHash: auth.HashEmailToken(strings.TrimSpace(token), purpose, apiCfg.serverKey),
+		Purpose:   purpose,
+	})
+}
+
+// a link into the web app carrying a token
+func (apiCfg *apiConfig) appLink(path, token string) string {
+	return strings.TrimRight(apiCfg.baseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// email a user a link to verify their address
+func (apiCfg *apiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	// make the token
+	token, err := apiCfg.issueEmailToken(ctx, user.ID, auth.EmailPurposeVerify, verifyEmailTokenDuration)
+	if err != nil {
+		return err
+	}
+
+	// send it
+	return apiCfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: "Welcome to Chirpy!\n\n" +
+			"Confirm this is your email address by opening the link below:\n\n" +
+			apiCfg.appLink("/app/verify-email", token) + "\n\n" +
+			"The link expires in 48 hours. If you didn't sign up, you can ignore this email.\n",
+	})
+}
+
+// email a user a link to choose a new password
+func (apiCfg *apiConfig) sendPasswordResetEmail(ctx context.Context, user database.User) error {
+	// make the token
+	token, err := apiCfg.issueEmailToken(ctx, user.ID, auth.EmailPurposeReset, resetPasswordTokenDuration)
+	if err != nil {
+		return err
+	}
+
+	// send it
+	return apiCfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: "Someone asked to reset the password for your Chirpy account.\n\n" +
+			"Choose a new password by opening the link below:\n\n" +
+			apiCfg.appLink("/app/reset-password", token) + "\n\n" +
+			"The link expires in 1 hour and works once. If this wasn't you, you can ignore this email.\n",
+	})
+}
+
+// email the requested new address a link to confirm the change
+func (apiCfg *apiConfig) sendEmailChangeConfirmation(ctx context.Context, user database.User, newEmail string) error {
+	// make the token
+	token, err := apiCfg.issueEmailToken(ctx, user.ID, auth.EmailPurposeChange, changeEmailTokenDuration)
+	if err != nil {
+		return err
+	}
+
+	// send it to the new address, only its owner can finish the change
+	return apiCfg.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new Chirpy email address",
+		Body: "Someone asked to move a Chirpy account to this email address.\n\n" +
+			"Confirm the change by opening the link below:\n\n" +
+			apiCfg.appLink("/app/confirm-email", token) + "\n\n" +
+			"The link expires in 48 hours. If this wasn't you, you can ignore this email.\n",
+	})
+}
+
+// tell the current address a change was asked for, so a hijacked session doesn't go unnoticed
+func (apiCfg *apiConfig) sendEmailChangeNotice(ctx context.Context, user database.User, newEmail string) error {
+	return apiCfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy email address is changing",
+		Body: "Someone asked to change the email address on your Chirpy account to " + newEmail + ".\n\n" +
+			"Nothing changes until the new address is confirmed. " +
+			"If this wasn't you, reset your password straight away.\n",
+	})
+}
+
+// confirm a requested account deletion, and how to stop it
+func (apiCfg *apiConfig) sendDeletionNotice(ctx context.Context, user database.User, deleteAfter time.Time) error {
+	return apiCfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy account will be deleted",
+		Body: "Your Chirpy account is scheduled for deletion on " + deleteAfter.Format("2 January 2006") + ".\n\n" +
+			"You have been signed out everywhere, and your API tokens and app authorizations are revoked. To keep your account, sign in before then and cancel the deletion.\n\n" +
+			"If this wasn't you, sign in, cancel the deletion and reset your password straight away.\n",
+	})
+}
+
+// tell an author a moderator hid or removed one of their chirps, and why
+func (apiCfg *apiConfig) sendChirpActionedNotice(ctx context.Context, user database.User, chirpBody, action, reason string) error {
+	// what happened to it
+	outcome := "hidden from everyone but you"
+	if action == moderationActionDeleteChirp {
+		outcome = "removed"
+	}
+
+	return apiCfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "One of your chirps was " + outcome,
+		Body: "A moderator reviewed a report about this chirp of yours:\n\n" +
+			"    " + chirpBody + "\n\n" +
+			"It broke the rules (reason: " + reportReasonLabel(reason) + ") and has been " + outcome + ".\n",
+	})
+}
+
+// tell a user their account was suspended, why, and until when
+func (apiCfg *apiConfig) sendSuspensionNotice(ctx context.Context, user database.User, reason string, endsAt time.Time) error {
+	return apiCfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy account has been suspended",
+		Body: "A moderator suspended your Chirpy account until " + endsAt.Format("2 January 2006 15:04 MST") + ".\n\n" +
+			"Reason: " + reportReasonLabel(reason) + ".\n\n" +
+			"You have been signed out everywhere.\n",
+	})
+}
+
+// VerifyEmail handler that marks the address verified when the emailed token checks out
+func (apiCfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// json request from client
+	var reqToken JsonEmailTokenRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqToken)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqToken is now successfully populated
+
+	// spend the token
+	userID, err := apiCfg.consumeEmailToken(req.Context(), reqToken.Token, auth.EmailPurposeVerify)
+
+	// token check (unknown, used, superseded or expired)
+	if err != nil {
+		log.Printf("Error consuming verify email token: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid or expired verification link", http.StatusBadRequest)
+		return // early return
+	}
+
+	// mark the address verified
+	err = apiCfg.db.MarkUserEmailVerified(req.Context(), userID)
+
+	// mark verified check
+	if err != nil {
+		log.Printf("Error marking email verified: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred verifying email", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// write to server and client that email verified
+	log.Printf("Email verified for user %s", userID) // log msg
+	w.WriteHeader(http.StatusNoContent)              // status code 204 to client
+}
+
+// ConfirmEmailChange handler that swaps in the pending address when the emailed token checks out
+// the token is the credential, whoever can read the new inbox finishes the change
+func (apiCfg *apiConfig) handlerConfirmEmailChange(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// json request from client
+	var reqToken JsonEmailTokenRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqToken)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqToken is now successfully populated
+
+	// spend the token
+	userID, err := apiCfg.consumeEmailToken(req.Context(), reqToken.Token, auth.EmailPurposeChange)
+
+	// token check (unknown, used, superseded or expired)
+	if err != nil {
+		log.Printf("Error consuming change email token: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid or expired confirmation link", http.StatusBadRequest)
+		return // early return
+	}
+
+	// swap in the new address
+	user, err := apiCfg.db.ConfirmUserEmailChange(req.Context(), userID)
+
+	// nothing pending check (cancelled since the link was sent)
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid or expired confirmation link", http.StatusBadRequest)
+		return // early return
+	}
+
+	// taken check, someone registered the address while the link sat in the inbox
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+		log.Printf("Error confirming email change for user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "User email is already used", http.StatusConflict)
+		return // early return
+	}
+
+	// confirm check (general)
+	if err != nil {
+		log.Printf("Error confirming email change for user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred changing email", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload
+	respUser := JsonUserUpdatedResponse{
+		ID:          user.ID,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+
+	// helper to insert body response + 200 ok status code
+	log.Printf("Email changed for user %s", user.ID) // log msg
+	WriteJSONResponse(w, respUser, http.StatusOK)
+}
+
+// ResendVerification handler that emails the caller a fresh verification link
+func (apiCfg *apiConfig) handlerResendVerification(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the user
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// already verified check
+	if user.EmailVerifiedAt.Valid {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "Email is already verified", http.StatusConflict)
+		return // early return
+	}
+
+	// send the link
+	err = apiCfg.sendVerificationEmail(req.Context(), user)
+
+	// send check
+	if err != nil {
+		log.Printf("Error sending verification email: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred sending verification email", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// 202 accepted, the rest happens in the user's inbox
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ForgotPassword handler that emails a reset link
+// always answers 202 so it can't be used to find out who has an account
+func (apiCfg *apiConfig) handlerForgotPassword(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// json request from client
+	var reqForgot JsonForgotPasswordRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqForgot)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqForgot is now successfully populated
+
+	// mail bombing check, per email whether or not it has an account
+	throttleKey := passwordResetThrottleKey(reqForgot.Email)
+	wait, err := apiCfg.magicLinkLimiter.Check(req.Context(), throttleKey)
+	if err != nil {
+		log.Printf("Error checking password reset throttle: %s", err) // log msg with err
+	}
+
+	// throttled check
+	if wait > 0 {
+		log.Printf("Password reset throttled for %s: retry in %s", reqForgot.Email, wait) // log msg
+		// helper to insert error msg + 429 too many requests status code
+		WriteTooManyRequests(w, "Too many reset links requested, try again later", wait)
+		return // early return
+	}
+
+	// count this request
+	_, err = apiCfg.magicLinkLimiter.Fail(req.Context(), throttleKey)
+	if err != nil {
+		log.Printf("Error recording password reset request: %s", err) // log msg with err
+	}
+
+	// get the user by email
+	user, err := apiCfg.db.GetUserByEmail(req.Context(), reqForgot.Email)
+
+	// unknown email, same answer as a known one
+	if err != nil {
+		log.Printf("Password reset requested for unknown email: %s", err) // log msg with err
+		w.WriteHeader(http.StatusAccepted)                                // status code 202 to client
+		return                                                            // early return
+	}
+
+	// send the link (failures are logged, the answer stays the same)
+	err = apiCfg.sendPasswordResetEmail(req.Context(), user)
+	if err != nil {
+		log.Printf("Error sending password reset email: %s", err) // log msg with err
+	}
+
+	// 202 accepted, the rest happens in the user's inbox
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword handler that sets a new password from an emailed reset token
+// signs the user out everywhere, whoever knew the old password loses their sessions
+func (apiCfg *apiConfig) handlerResetPassword(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// json request from client
+	var reqReset JsonResetPasswordRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqReset)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqReset is now successfully populated
+
+	// password policy check before spending the token (the email rule is checked below)
+	if !apiCfg.checkPasswordPolicy(w, reqReset.Password, "") {
+		return // early return
+	}
+
+	// spend the token
+	userID, err := apiCfg.consumeEmailToken(req.Context(), reqReset.Token, auth.EmailPurposeReset)
+
+	// token check (unknown, used, superseded or expired)
+	if err != nil {
+		log.Printf("Error consuming reset password token: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid or expired reset link", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the user, the policy needs their email
+	user, err := apiCfg.db.GetUserByID(req.Context(), userID)
+
+	// get user check
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid or expired reset link", http.StatusBadRequest)
+		return // early return
+	}
+	if err != nil {
+		log.Printf("Error getting user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// password policy check again, now the email is known (writes field errors on failure)
+	if !apiCfg.checkPasswordPolicy(w, reqReset.Password, user.Email) {
+		return // early return
+	}
+
+	// hash the new password
+	hash, err := apiCfg.passwordHasher.Hash(reqReset.Password)
+
+	// hash check
+	if err != nil {
+		log.Printf("Error hashing password: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// store it
+	err = apiCfg.db.UpdateUserPasswordHash(req.Context(), database.UpdateUserPasswordHashParams{
+		ID:             user.ID,
+		HashedPassword: hash,
+	})
+
+	// update password check
+	if err != nil {
+		log.Printf("Error updating password: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred resetting password", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// sign out everywhere (access tokens run out within the hour)
+	err = apiCfg.db.RevokeAllRefreshTokensForUser(req.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error revoking refresh tokens after password reset: %s", err) // log msg with err
+	}
+
+	// reading the reset mail proves the address too
+	err = apiCfg.db.MarkUserEmailVerified(req.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error marking email verified after password reset: %s", err) // log msg with err
+	}
+
+	// a locked out owner can get straight back in
+	apiCfg.loginSucceeded(req.Context(), user.Email)
+
+	// the link worked, they can ask for another straight away
+	err = apiCfg.magicLinkLimiter.Reset(req.Context(), passwordResetThrottleKey(user.Email))
+	if err != nil {
+		log.Printf("Error resetting password reset throttle: %s", err) // log msg with err
+	}
+
+	// write to server and client that password reset
+	log.Printf("Password reset for user %s", user.ID) // log msg
+	w.WriteHeader(http.StatusNoContent)               // status code 204 to client
+}
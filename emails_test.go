@@ -0,0 +1,93 @@
+// emails_test.go
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+
+	"github.com/PietPadda/chirpy/internal/lockout"
+	"github.com/PietPadda/chirpy/internal/mailer"
+)
+
+// test mailer config from the environment
+func TestMailerFromEnv(t *testing.T) {
+	// file outbox by default
+	m, err := mailerFromEnv()
+	if err != nil {
+		t.Fatalf("mailerFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if _, ok := m.(*mailer.FileMailer); !ok {
+		t.Errorf("default mailer is %T, want *mailer.FileMailer", m)
+	}
+
+	// smtp needs a server
+	t.Setenv("MAILER", "smtp")
+	if _, err := mailerFromEnv(); err == nil {
+		t.Errorf("mailerFromEnv accepted smtp without SMTP_ADDR")
+	}
+	t.Setenv("SMTP_ADDR", "smtp.example.com:587")
+	m, err = mailerFromEnv()
+	if err != nil {
+		t.Fatalf("mailerFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if _, ok := m.(*mailer.SMTPMailer); !ok {
+		t.Errorf("mailer is %T, want *mailer.SMTPMailer", m)
+	}
+
+	// unknown kinds are rejected
+	t.Setenv("MAILER", "pigeon")
+	if _, err := mailerFromEnv(); err == nil {
+		t.Errorf("mailerFromEnv accepted an unknown mailer")
+	}
+}
+
+// test emailed links
+func TestAppLink(t *testing.T) {
+	apiCfg := &apiConfig{baseURL: "https://chirpy.example/"}
+	got := apiCfg.appLink("/app/verify-email", "a b")
+	want := "https://chirpy.example/app/verify-email?token=a+b"
+	if got != want {
+		t.Errorf("appLink = %q, want %q", got, want)
+	}
+}
+
+// test confirming an email change needs a token
+func TestConfirmEmailChangeEmptyBody(t *testing.T) {
+	apiCfg := &apiConfig{}
+	req := httptest.NewRequest(http.MethodPost, "/api/users/email/confirm", strings.NewReader(""))
+	rec := httptest.NewRecorder()
+	apiCfg.handlerConfirmEmailChange(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// test reset links are throttled per email before any lookup
+func TestForgotPasswordThrottled(t *testing.T) {
+	apiCfg := &apiConfig{
+		magicLinkLimiter: lockout.NewLimiter(lockout.NewMemoryStore(), magicLinkPolicy),
+	}
+
+	// use up the free sends and the first delayed one, case shouldn't dodge the limit
+	for i := 0; i <= magicLinkPolicy.FreeAttempts; i++ {
+		if _, err := apiCfg.magicLinkLimiter.Fail(context.Background(), passwordResetThrottleKey("Walt@Example.com")); err != nil {
+			t.Fatalf("Fail failed: %v", err) // fatal, don't continue
+		}
+	}
+
+	// next request is refused without touching the db
+	body := strings.NewReader(`{"email":" walt@example.com"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/password/forgot", body)
+	rec := httptest.NewRecorder()
+	apiCfg.handlerForgotPassword(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("missing Retry-After header")
+	}
+}
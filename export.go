@@ -0,0 +1,168 @@
+// export.go
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// one json file in a data export
+type exportFile struct {
+	Name string
+	Data any
+}
+
+// write the export files into a zip, streamed as it goes
+func writeExportZip(w io.Writer, files []exportFile) error {
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		// new entry
+		entry, err := archive.Create(file.Name)
+		if err != nil {
+			return err
+		}
+
+		// indented, people read these
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(file.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ExportMe handler that streams a zip of everything the caller's account holds, as json
+func (apiCfg *apiConfig) handlerExportMe(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// gather everything before the first byte goes out, so a failure is still a clean 500
+
+	// get the user
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get their chirps
+	dbChirps, err := apiCfg.db.GetChirpsByAuthorID(req.Context(), user.ID)
+
+	// get chirps check
+	if err != nil {
+		log.Printf("Error getting chirps for export: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred exporting account", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// with their attachments
+	chirpIDs := make([]uuid.UUID, 0, len(dbChirps))
+	for _, chirp := range dbChirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+	attachments, err := apiCfg.chirpAttachments(req.Context(), chirpIDs)
+
+	// get attachments check
+	if err != nil {
+		log.Printf("Error getting attachments for export: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred exporting account", http.StatusInternalServerError)
+		return // early return
+	}
+
+	chirps := make([]JsonChirpResponse, 0, len(dbChirps))
+	for _, chirp := range dbChirps {
+		chirps = append(chirps, chirpResponse(chirp, user.Handle.String, attachments[chirp.ID]))
+	}
+
+	// get their sessions
+	tokens, err := apiCfg.db.ListRefreshTokensForUser(req.Context(), user.ID)
+
+	// get sessions check
+	if err != nil {
+		log.Printf("Error getting sessions for export: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred exporting account", http.StatusInternalServerError)
+		return // early return
+	}
+
+	sessions := make([]JsonExportSession, 0, len(tokens))
+	for _, token := range tokens {
+		session := JsonExportSession{CreatedAt: token.CreatedAt, ExpiresAt: token.ExpiresAt}
+		if token.RevokedAt.Valid {
+			session.RevokedAt = &token.RevokedAt.Time
+		}
+		sessions = append(sessions, session)
+	}
+
+	// get their subscription history
+	events, err := apiCfg.db.ListSubscriptionEventsForUser(req.Context(), user.ID)
+
+	// get subscription history check
+	if err != nil {
+		log.Printf("Error getting subscription history for export: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred exporting account", http.StatusInternalServerError)
+		return // early return
+	}
+
+	subscriptions := make([]JsonExportSubscriptionEvent, 0, len(events))
+	for _, event := range events {
+		subscriptions = append(subscriptions, JsonExportSubscriptionEvent{
+			CreatedAt: event.CreatedAt,
+			Event:     event.Event,
+			Plan:      event.Plan,
+		})
+	}
+
+	// a download, never cached
+	filename := fmt.Sprintf("chirpy-export-%s.zip", time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	// stream it
+	err = writeExportZip(w, []exportFile{
+		{Name: "profile.json", Data: apiCfg.userResponse(user)},
+		{Name: "chirps.json", Data: chirps},
+		{Name: "sessions.json", Data: sessions},
+		{Name: "subscriptions.json", Data: subscriptions},
+	})
+
+	// stream check (headers are out, all we can do is log it)
+	if err != nil {
+		log.Printf("Error writing export for %s: %s", user.ID, err) // log msg with err
+	}
+}
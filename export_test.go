@@ -0,0 +1,44 @@
+// export_test.go
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"testing" // importing testing package for unit tests
+	"time"
+)
+
+// test the export zip holds one readable json file per section
+func TestWriteExportZip(t *testing.T) {
+	var buf bytes.Buffer
+	sessions := []JsonExportSession{{CreatedAt: time.Now().UTC(), ExpiresAt: time.Now().UTC()}}
+	err := writeExportZip(&buf, []exportFile{
+		{Name: "chirps.json", Data: []JsonChirpResponse{}},
+		{Name: "sessions.json", Data: sessions},
+	})
+	if err != nil {
+		t.Fatalf("writeExportZip failed: %v", err) // fatal, don't continue
+	}
+
+	// read it back
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader failed: %v", err) // fatal, don't continue
+	}
+	if len(archive.File) != 2 || archive.File[0].Name != "chirps.json" || archive.File[1].Name != "sessions.json" {
+		t.Fatalf("unexpected zip entries %v", archive.File) // fatal, don't continue
+	}
+
+	// empty sections are empty lists, not null
+	entry, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatalf("open chirps.json failed: %v", err) // fatal, don't continue
+	}
+	defer entry.Close()
+	var chirps []JsonChirpResponse
+	if err := json.NewDecoder(entry).Decode(&chirps); err != nil || chirps == nil {
+		t.Errorf("chirps.json = %v (err %v), want an empty list", chirps, err)
+	}
+}
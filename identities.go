@@ -0,0 +1,636 @@
+// identities.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/oidc"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// how long a sign in at the provider may take
+const oidcLoginTimeout = 10 * time.Minute
+
+// provider names end up in urls and env var names
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// build the identity providers from the environment
+// OIDC_PROVIDERS is a comma separated list of names, each configured by
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and optional OIDC_<NAME>_SCOPES
+func oidcProvidersFromEnv(baseURL string) (map[string]*oidc.Provider, error) {
+	providers := make(map[string]*oidc.Provider)
+
+	// none configured is fine, social login is just off
+	raw := strings.TrimSpace(os.Getenv("OIDC_PROVIDERS"))
+	if raw == "" {
+		return providers, nil
+	}
+
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		// name check
+		if !oidcProviderName.MatchString(name) {
+			return nil, fmt.Errorf("invalid provider name %q", name)
+		}
+
+		// duplicate check
+		if _, ok := providers[name]; ok {
+			return nil, fmt.Errorf("provider %q listed twice", name)
+		}
+
+		// per provider settings
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		cfg := oidc.Config{
+			Name:         name,
+			Issuer:       strings.TrimSpace(os.Getenv(prefix + "ISSUER")),
+			ClientID:     strings.TrimSpace(os.Getenv(prefix + "CLIENT_ID")),
+			ClientSecret: strings.TrimSpace(os.Getenv(prefix + "CLIENT_SECRET")),
+			RedirectURL:  strings.TrimRight(baseURL, "/") + "/api/oidc/" + name + "/callback",
+			Scopes:       []string{"email", "profile"},
+		}
+		if scopes := strings.Fields(os.Getenv(prefix + "SCOPES")); len(scopes) > 0 {
+			cfg.Scopes = scopes
+		}
+
+		// required settings check
+		if cfg.Issuer == "" || cfg.ClientID == "" || cfg.ClientSecret == "" {
+			return nil, fmt.Errorf("provider %q needs %sISSUER, %sCLIENT_ID and %sCLIENT_SECRET", name, prefix, prefix, prefix)
+		}
+
+		providers[name] = oidc.NewProvider(cfg, nil)
+	}
+
+	return providers, nil
+}
+
+// store a new flow and return the provider url to send the browser to
+// userID is set when linking to an existing account
+func (apiCfg *apiConfig) startOIDCLogin(ctx context.Context, provider *oidc.Provider, userID uuid.NullUUID) (string, time.Time, error) {
+	// tidy abandoned flows (best effort)
+	err := apiCfg.db.DeleteExpiredOIDCLogins(ctx)
+	if err != nil {
+		log.Printf("Error deleting expired oidc logins: %s", err) // log msg with err
+	}
+
+	// state, nonce and pkce verifier
+	state, err := oidc.NewRandomString()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	nonce, err := oidc.NewRandomString()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	verifier, err := oidc.NewRandomString()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// provider url (may fetch discovery)
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// store the flow
+	expiresAt := time.Now().UTC().Add(oidcLoginTimeout)
+	err = apiCfg.db.CreateOIDCLogin(ctx, database.CreateOIDCLoginParams{
+		State:        state,
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       userID,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return authURL, expiresAt, nil
+}
+
+// ListOIDCProviders handler that lists the configured identity providers
+func (apiCfg *apiConfig) handlerListOIDCProviders(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// stable order
+	names := make([]string, 0, len(apiCfg.oidcProviders))
+	for name := range apiCfg.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// transform names into JSON response format
+	providerResponses := make([]JsonOIDCProviderResponse, len(names))
+	for i, name := range names {
+		providerResponses[i] = JsonOIDCProviderResponse{
+			Name:     name,
+			LoginURL: "/api/oidc/" + name + "/login",
+		}
+	}
+
+	// helper to insert body response + 200 OK status code
+	WriteJSONResponse(w, providerResponses, http.StatusOK)
+}
+
+// OIDCLogin handler that starts "sign in with <provider>" by redirecting the browser
+func (apiCfg *apiConfig) handlerOIDCLogin(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get provider from api endpoint path string
+	provider, ok := apiCfg.oidcProviders[req.PathValue("provider")]
+
+	// provider check
+	if !ok {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Unknown identity provider", http.StatusNotFound)
+		return // early return
+	}
+
+	// store the flow, not tied to anyone yet
+	authURL, _, err := apiCfg.startOIDCLogin(req.Context(), provider, uuid.NullUUID{})
+
+	// start check
+	if err != nil {
+		log.Printf("Error starting %s sign in: %s", provider.Name(), err) // log msg with err
+		// helper to insert error msg + 502 bad gateway status code
+		WriteJSONError(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return // early return
+	}
+
+	// off to the provider
+	http.Redirect(w, req, authURL, http.StatusFound)
+}
+
+// LinkIdentity handler that starts linking a provider to the caller's account
+// answers with the url instead of redirecting, the browser can't send the bearer token on a redirect
+func (apiCfg *apiConfig) handlerLinkIdentity(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get provider from api endpoint path string
+	provider, ok := apiCfg.oidcProviders[req.PathValue("provider")]
+
+	// provider check
+	if !ok {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Unknown identity provider", http.StatusNotFound)
+		return // early return
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// store the flow for the caller
+	authURL, expiresAt, err := apiCfg.startOIDCLogin(req.Context(), provider, uuid.NullUUID{UUID: caller.UserID, Valid: true})
+
+	// start check
+	if err != nil {
+		log.Printf("Error starting %s link: %s", provider.Name(), err) // log msg with err
+		// helper to insert error msg + 502 bad gateway status code
+		WriteJSONError(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return // early return
+	}
+
+	// json response payload
+	respLink := JsonOIDCLinkResponse{
+		AuthorizationURL: authURL,
+		ExpiresAt:        expiresAt,
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respLink, http.StatusOK)
+}
+
+// OIDCCallback handler the provider sends the browser back to
+// finishes a link, or signs in (creating the account on first use)
+func (apiCfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get provider from api endpoint path string
+	provider, ok := apiCfg.oidcProviders[req.PathValue("provider")]
+
+	// provider check
+	if !ok {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Unknown identity provider", http.StatusNotFound)
+		return // early return
+	}
+
+	// the provider's answer
+	query := req.URL.Query()
+
+	// user refused or provider failed check
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("Identity provider %s returned error: %s", provider.Name(), providerErr) // log msg
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Sign in was cancelled or refused", http.StatusBadRequest)
+		return // early return
+	}
+
+	// parameters check
+	if query.Get("state") == "" || query.Get("code") == "" {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Missing code or state", http.StatusBadRequest)
+		return // early return
+	}
+
+	// take the flow, single use (stops csrf and replayed callbacks)
+	login, err := apiCfg.db.ConsumeOIDCLogin(req.Context(), database.ConsumeOIDCLoginParams{
+		State:    query.Get("state"),
+		Provider: provider.Name(),
+	})
+
+	// state check
+	if err != nil {
+		log.Printf("Error consuming oidc login: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid or expired sign in attempt", http.StatusBadRequest)
+		return // early return
+	}
+
+	// swap the code, proving we started the flow
+	token, err := provider.Exchange(req.Context(), query.Get("code"), login.CodeVerifier)
+
+	// exchange check
+	if err != nil {
+		log.Printf("Error exchanging %s code: %s", provider.Name(), err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Sign in with identity provider failed", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// verify who the provider says it is
+	claims, err := provider.VerifyIDToken(req.Context(), token.IDToken, login.Nonce)
+
+	// id token check
+	if err != nil {
+		log.Printf("Error verifying %s id token: %s", provider.Name(), err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Sign in with identity provider failed", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// linking, the flow belongs to a signed in user
+	if login.UserID.Valid {
+		apiCfg.finishLinkIdentity(w, req, login.UserID.UUID, provider.Name(), claims)
+		return // early return
+	}
+
+	// signing in
+	apiCfg.finishOIDCLogin(w, req, provider.Name(), claims)
+}
+
+// links a verified external identity to a user
+func (apiCfg *apiConfig) finishLinkIdentity(w http.ResponseWriter, req *http.Request, userID uuid.UUID, providerName string, claims oidc.Claims) {
+	// store the link
+	identity, err := apiCfg.db.CreateUserIdentity(req.Context(), database.CreateUserIdentityParams{
+		UserID:   userID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+
+	// already linked check (to this account, or the identity to another)
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+		log.Printf("Error identity already linked: %s", err) // log msg with err
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "This identity or provider is already linked", http.StatusConflict)
+		return // early return
+	}
+
+	// create identity check
+	if err != nil {
+		log.Printf("Error linking identity: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred linking identity", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, identityResponse(identity), http.StatusCreated)
+}
+
+// signs in with a verified external identity, creating the account on first use
+func (apiCfg *apiConfig) finishOIDCLogin(w http.ResponseWriter, req *http.Request, providerName string, claims oidc.Claims) {
+	// known identity?
+	identity, err := apiCfg.db.GetUserIdentity(req.Context(), database.GetUserIdentityParams{
+		Provider: providerName,
+		Subject:  claims.Subject,
+	})
+
+	var loginUser database.User
+	switch {
+	case err == nil:
+		// returning user
+		err = apiCfg.db.RecordUserIdentityLogin(req.Context(), database.RecordUserIdentityLoginParams{
+			ID:    identity.ID,
+			Email: claims.Email,
+		})
+		if err != nil {
+			log.Printf("Error recording identity login: %s", err) // log msg with err
+		}
+		loginUser, err = apiCfg.db.GetUserByID(req.Context(), identity.UserID)
+
+		// get user check
+		if err != nil {
+			log.Printf("Error getting user %s: %s", identity.UserID, err) // log msg with err
+			// helper to insert error msg + 401 unauthorised status code
+			WriteJSONError(w, "Sign in with identity provider failed", http.StatusUnauthorized)
+			return // early return
+		}
+
+	case errors.Is(err, sql.ErrNoRows):
+		// first sign in, make the account
+		loginUser, err = apiCfg.createUserFromIdentity(req.Context(), providerName, claims)
+
+		// an account already uses this email, never link it silently (that would hand it to whoever controls the provider account)
+		if errors.Is(err, errIdentityEmailTaken) {
+			// helper to insert error msg + 409 conflict status code
+			WriteJSONError(w, "An account with this email already exists, sign in and link "+providerName+" from your settings", http.StatusConflict)
+			return // early return
+		}
+
+		// no email check
+		if errors.Is(err, errIdentityNoEmail) {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Identity provider did not share an email address", http.StatusBadRequest)
+			return // early return
+		}
+
+		// unverified email check
+		if errors.Is(err, errIdentityEmailUnverified) {
+			// helper to insert error msg + 403 forbidden status code
+			WriteJSONError(w, providerName+" has not verified your email address, verify it there or sign up with a password", http.StatusForbidden)
+			return // early return
+		}
+
+		// create user check
+		if err != nil {
+			log.Printf("Error creating user from identity: %s", err) // log msg with err
+			// helper to insert error msg + 500 internal error status code
+			WriteJSONError(w, "Error occurred during login", http.StatusInternalServerError)
+			return // early return
+		}
+
+	default:
+		log.Printf("Error getting identity: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred during login", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// look up the second factor
+	mfaEnabled, err := apiCfg.totpEnabled(req.Context(), loginUser.ID)
+
+	// 2fa lookup check (fail closed)
+	if err != nil {
+		log.Printf("Error getting totp for user %s: %s", loginUser.ID, err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Error occurred during login", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// suspended check
+	if apiCfg.refuseSuspended(w, req, loginUser.ID) {
+		return // early return
+	}
+
+	// 2fa on, hand back a challenge instead of tokens
+	if mfaEnabled {
+		apiCfg.writeMFAChallenge(w, loginUser.ID)
+		return // early return
+	}
+
+	// make the access and refresh tokens
+	respLogin, err := apiCfg.issueLoginTokens(req.Context(), loginUser)
+
+	// issue tokens check
+	if err != nil {
+		log.Printf("Error issuing login tokens: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Internal server token generation error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respLogin, http.StatusOK)
+}
+
+// first sign in outcomes the caller must explain
+var (
+	errIdentityEmailTaken      = errors.New("email already belongs to an account")
+	errIdentityNoEmail         = errors.New("identity has no email")
+	errIdentityEmailUnverified = errors.New("identity email is not verified")
+)
+
+// creates a passwordless user and links the identity to it
+func (apiCfg *apiConfig) createUserFromIdentity(ctx context.Context, providerName string, claims oidc.Claims) (database.User, error) {
+	// we need a usable address to reach the user
+	email, err := normaliseEmail(claims.Email)
+	if err != nil {
+		return database.User{}, errIdentityNoEmail
+	}
+
+	// an unverified address could be anyone's, claiming it would let them take the account over once the owner signs up
+	if !claims.EmailVerified {
+		return database.User{}, errIdentityEmailUnverified
+	}
+
+	// the user and their identity go in together, a half made account could never sign in with this provider again
+	var user database.User
+	err = apiCfg.db.inTx(ctx, func(q database.Querier) error {
+		// create the user, password stays "unset" (magic links and resets still work)
+		user, err = q.CreateUserWithoutPassword(ctx, database.CreateUserWithoutPasswordParams{
+			Email:           email,
+			EmailVerifiedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true}, // trust the provider's verification
+		})
+
+		// duplicate email check
+		if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+			return errIdentityEmailTaken
+		}
+		if err != nil {
+			return err
+		}
+
+		// link the identity
+		_, err = q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+			UserID:   user.ID,
+			Provider: providerName,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		})
+		return err
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+// ListIdentities handler that lists the caller's linked identities
+func (apiCfg *apiConfig) handlerListIdentities(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the caller's identities
+	identities, err := apiCfg.db.ListUserIdentities(req.Context(), caller.UserID)
+
+	// list identities check
+	if err != nil {
+		log.Printf("Error listing identities: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Failed to retrieve linked identities", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// transform database identities into JSON response format
+	identityResponses := make([]JsonIdentityResponse, len(identities))
+	for i, identity := range identities {
+		identityResponses[i] = identityResponse(identity)
+	}
+
+	// helper to insert body response + 200 OK status code
+	WriteJSONResponse(w, identityResponses, http.StatusOK)
+}
+
+// UnlinkIdentity handler that removes a provider from the caller's account
+// always allowed, an account can still be reached by email (magic link or password reset)
+func (apiCfg *apiConfig) handlerUnlinkIdentity(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get provider from api endpoint path string (may no longer be configured)
+	providerName := req.PathValue("provider")
+
+	// delete it, only matches the caller's own identities
+	_, err := apiCfg.db.DeleteUserIdentity(req.Context(), database.DeleteUserIdentityParams{
+		UserID:   caller.UserID,
+		Provider: providerName,
+	})
+
+	// not linked check
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Identity not linked", http.StatusNotFound)
+		return // early return
+	}
+
+	// delete check
+	if err != nil {
+		log.Printf("Error unlinking identity: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// write to server and client that identity unlinked
+	log.Printf("Identity %s unlinked from user %s", providerName, caller.UserID) // log msg
+	w.WriteHeader(http.StatusNoContent)                                          // status code 204 to client
+}
+
+// HELPER FUNCS
+
+// build an identity response (never includes the provider subject)
+func identityResponse(identity database.UserIdentity) JsonIdentityResponse {
+	resp := JsonIdentityResponse{
+		ID:        identity.ID,
+		CreatedAt: identity.CreatedAt,
+		Provider:  identity.Provider,
+		Email:     identity.Email,
+	}
+
+	// only set last login when it has been used
+	if identity.LastLoginAt.Valid {
+		resp.LastLoginAt = &identity.LastLoginAt.Time
+	}
+
+	return resp
+}
@@ -0,0 +1,128 @@
+// identities_test.go
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing" // importing testing package for unit tests
+
+	"github.com/PietPadda/chirpy/internal/oidc"
+)
+
+// test identity providers config from the environment
+func TestOIDCProvidersFromEnv(t *testing.T) {
+	// none by default
+	providers, err := oidcProvidersFromEnv("http://localhost:8080")
+	if err != nil {
+		t.Fatalf("oidcProvidersFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if len(providers) != 0 {
+		t.Errorf("got %d providers, want none", len(providers))
+	}
+
+	// a listed provider needs its settings
+	t.Setenv("OIDC_PROVIDERS", "Acme-ID")
+	if _, err := oidcProvidersFromEnv("http://localhost:8080"); err == nil {
+		t.Errorf("oidcProvidersFromEnv accepted a provider without settings")
+	}
+	t.Setenv("OIDC_ACME_ID_ISSUER", "https://id.acme.example")
+	t.Setenv("OIDC_ACME_ID_CLIENT_ID", "chirpy")
+	t.Setenv("OIDC_ACME_ID_CLIENT_SECRET", "shh")
+	providers, err = oidcProvidersFromEnv("http://localhost:8080")
+	if err != nil {
+		t.Fatalf("oidcProvidersFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if providers["acme-id"] == nil {
+		t.Errorf("provider acme-id not configured, got %v", providers)
+	}
+
+	// names end up in urls
+	t.Setenv("OIDC_PROVIDERS", "acme/../admin")
+	if _, err := oidcProvidersFromEnv("http://localhost:8080"); err == nil {
+		t.Errorf("oidcProvidersFromEnv accepted an unsafe provider name")
+	}
+}
+
+// test the provider listing
+func TestListOIDCProviders(t *testing.T) {
+	apiCfg := &apiConfig{oidcProviders: map[string]*oidc.Provider{
+		"zeta":  oidc.NewProvider(oidc.Config{Name: "zeta"}, nil),
+		"alpha": oidc.NewProvider(oidc.Config{Name: "alpha"}, nil),
+	}}
+	rec := httptest.NewRecorder()
+	apiCfg.handlerListOIDCProviders(rec, httptest.NewRequest(http.MethodGet, "/api/oidc/providers", nil))
+
+	var got []JsonOIDCProviderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode failed: %v", err) // fatal, don't continue
+	}
+	if len(got) != 2 || got[0].Name != "alpha" || got[1].LoginURL != "/api/oidc/zeta/login" {
+		t.Errorf("providers = %+v, want alpha then zeta", got)
+	}
+}
+
+// test the callback rejects what it can without touching the db
+func TestOIDCCallbackRejects(t *testing.T) {
+	apiCfg := &apiConfig{oidcProviders: map[string]*oidc.Provider{
+		"acme": oidc.NewProvider(oidc.Config{Name: "acme"}, nil),
+	}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/oidc/{provider}/callback", apiCfg.handlerOIDCCallback)
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{name: "unknown provider", url: "/api/oidc/nope/callback?code=c&state=s", want: http.StatusNotFound},
+		{name: "provider error", url: "/api/oidc/acme/callback?error=access_denied&state=s", want: http.StatusBadRequest},
+		{name: "missing state", url: "/api/oidc/acme/callback?code=c", want: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+// test first sign in refuses what it can't trust before making an account
+func TestCreateUserFromIdentityRejects(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		name        string      // name for test case
+		claims      oidc.Claims // input to func
+		expectedErr error       // error we want our func to return
+	}{ // }{ -- inits the test values for input vs expected
+		{
+			name:        "Test case: No Email",
+			claims:      oidc.Claims{},
+			expectedErr: errIdentityNoEmail,
+		},
+		{
+			name:        "Test case: Unverified Email",
+			claims:      oidc.Claims{Email: "alice@example.com"},
+			expectedErr: errIdentityEmailUnverified,
+		},
+	}
+
+	// no db, a rejected identity never gets that far
+	apiCfg := &apiConfig{}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		_, err := apiCfg.createUserFromIdentity(context.Background(), "acme", tc.claims)
+
+		// check result
+		if !errors.Is(err, tc.expectedErr) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.expectedErr)
+		}
+	}
+}
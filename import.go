@@ -0,0 +1,200 @@
+// import.go
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/chirpimport"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/spam"
+)
+
+// import limits
+const (
+	maxImportSize   = 10 << 20        // 10 MiB archive
+	importClockSkew = 5 * time.Minute // how far ahead of us an archive's clock may run
+)
+
+// ImportChirps handler that brings chirps over from an archive, keeping their original times
+// the body is our export zip, a json list of chirps or a csv with a header row
+func (apiCfg *apiConfig) handlerImportChirps(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// read the archive, capped
+	req.Body = http.MaxBytesReader(w, req.Body, maxImportSize)
+	defer req.Body.Close()
+	data, err := io.ReadAll(req.Body)
+
+	// body too large check
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		// helper to insert error msg + 413 too large status code
+		WriteJSONError(w, "Archive is too large", http.StatusRequestEntityTooLarge)
+		return // early return
+	}
+
+	// read check (general)
+	if err != nil {
+		log.Printf("Error reading import: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the importer, only verified addresses may chirp
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting importer: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred importing chirps", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// verified check
+	if !user.EmailVerifiedAt.Valid {
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "Verify your email address before chirping", http.StatusForbidden)
+		return // early return
+	}
+
+	// suspended check (a token issued before the suspension may not be denylisted here yet)
+	if apiCfg.refuseSuspended(w, req, caller.UserID) {
+		return // early return
+	}
+
+	// new account check, an archive would get round the new account chirp limit
+	if time.Since(user.CreatedAt) < apiCfg.spamPolicy.NewAccountAge {
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "New accounts can't import chirps yet, try again later", http.StatusForbidden)
+		return // early return
+	}
+
+	// read the chirps out
+	items, failures, err := chirpimport.Parse(data)
+
+	// parse check
+	if err != nil {
+		log.Printf("Error parsing import: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Could not read archive: "+err.Error(), http.StatusBadRequest)
+		return // early return
+	}
+
+	// report what couldn't be read
+	report := JsonImportResponse{Failed: []JsonImportFailure{}}
+	for _, failure := range failures {
+		report.Failed = append(report.Failed, JsonImportFailure{Line: failure.Line, Error: failure.Reason})
+	}
+
+	// moderate, spam check and store each chirp, one bad chirp doesn't stop the rest
+	now := time.Now().UTC()
+	var imported []spam.Post // this archive's chirps so far at their own times, so only recent ones count as duplicates
+	for _, item := range items {
+		// same moderation as a new chirp
+		body, err := moderateChirpBody(item.Body)
+		if err != nil {
+			report.Failed = append(report.Failed, JsonImportFailure{Line: item.Line, Error: err.Error()})
+			continue
+		}
+
+		// original time, or now when the archive doesn't say
+		createdAt := item.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		if createdAt.After(now.Add(importClockSkew)) {
+			report.Failed = append(report.Failed, JsonImportFailure{Line: item.Line, Error: "timestamp is in the future"})
+			continue
+		}
+
+		// check and store it holding the author's lock, like a new chirp
+		var verdict spam.Verdict
+		var chirp database.Chirp
+		err = apiCfg.db.inTx(req.Context(), func(q database.Querier) error {
+			// wait for the author's other chirps
+			err := q.LockChirpAuthor(req.Context(), user.ID)
+			if err != nil {
+				return err
+			}
+
+			// spam check, as if posted now next to the archive's earlier chirps
+			verdict, err = apiCfg.checkSpam(req.Context(), q, user, body, imported)
+			if err != nil || verdict.Outcome == spam.OutcomeReject {
+				return err // nothing to write
+			}
+
+			// store it
+			chirp, err = q.CreateImportedChirp(req.Context(), database.CreateImportedChirpParams{
+				CreatedAt: createdAt,
+				Body:      body,
+				UserID:    user.ID,
+			})
+			if err != nil {
+				return err
+			}
+
+			// held check, imported but only the author sees it until a moderator has looked
+			if verdict.Outcome == spam.OutcomeHold {
+				return holdChirp(req.Context(), q, &chirp, verdict)
+			}
+			return nil
+		})
+
+		// store check
+		if err != nil {
+			log.Printf("Error importing chirp: %s", err) // log msg with err
+			report.Failed = append(report.Failed, JsonImportFailure{Line: item.Line, Error: "could not be saved"})
+			continue
+		}
+
+		// rejected check
+		if verdict.Outcome == spam.OutcomeReject {
+			reason := "looks like spam"
+			if slices.Contains(verdict.Signals, spam.SignalDuplicate) {
+				reason = "duplicate of another chirp"
+			}
+			report.Failed = append(report.Failed, JsonImportFailure{Line: item.Line, Error: reason})
+			continue
+		}
+
+		imported = append(imported, spam.Post{Body: body, CreatedAt: createdAt})
+		report.Imported++
+		if chirp.HeldAt.Valid {
+			report.Held++
+		}
+	}
+
+	// failures from parsing and storing, in archive order
+	sort.SliceStable(report.Failed, func(i, j int) bool {
+		return report.Failed[i].Line < report.Failed[j].Line
+	})
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, report, http.StatusOK)
+}
@@ -0,0 +1,101 @@
+// import_test.go
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/spam"
+	"github.com/google/uuid"
+)
+
+// test oversized archives are refused before anything is read into the db
+func TestImportChirpsTooLarge(t *testing.T) {
+	apiCfg := &apiConfig{}
+	body := bytes.Repeat([]byte("x"), maxImportSize+1)
+	req := httptest.NewRequest(http.MethodPost, "/api/users/me/import", bytes.NewReader(body))
+	ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+	rec := httptest.NewRecorder()
+	apiCfg.handlerImportChirps(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+// test imported chirps go through the spam filter, archive duplicates included, and new accounts can't import
+func TestImportChirpsSpam(t *testing.T) {
+	now := time.Now().UTC()
+	verified := sql.NullTime{Time: now, Valid: true}
+	twoWeeksAgo := now.Add(-14 * 24 * time.Hour).Format(time.RFC3339)
+	lastWeek := now.Add(-7 * 24 * time.Hour).Format(time.RFC3339)
+
+	// build test cases
+	testCases := []struct {
+		name             string        // name for test case
+		accountAge       time.Duration // how old the importer's account is
+		archive          string        // json list of chirps
+		expectedStatus   int           // status code we want
+		expectedImported int           // chirps stored
+		expectedHeld     int           // of those, held for review
+		expectedFailed   []int         // archive positions refused
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: clean archive", 30 * 24 * time.Hour, `[{"body":"first"},{"body":"second one"}]`, http.StatusOK, 2, 0, nil},
+		{"Test case: duplicates in the archive", 30 * 24 * time.Hour, `[{"body":"buy now"},{"body":"BUY NOW"},{"body":"something else"}]`, http.StatusOK, 2, 0, []int{2}},
+		{"Test case: same chirp a week apart", 30 * 24 * time.Hour, `[{"body":"weekly update","created_at":"` + twoWeeksAgo + `"},{"body":"weekly update","created_at":"` + lastWeek + `"}]`, http.StatusOK, 2, 0, nil},
+		{"Test case: duplicate of a recent chirp", 30 * 24 * time.Hour, `[{"body":"already chirped"}]`, http.StatusOK, 0, 0, []int{1}},
+		{"Test case: mention spam", 30 * 24 * time.Hour, `[{"body":"@a @b @c @d @e @f look"}]`, http.StatusOK, 1, 1, nil},
+		{"Test case: new account", time.Hour, `[{"body":"first"}]`, http.StatusForbidden, 0, 0, nil},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		// an importer with one recent chirp
+		fake := newFakeQuerier()
+		user := database.User{ID: uuid.New(), CreatedAt: now.Add(-tc.accountAge), EmailVerifiedAt: verified}
+		fake.users[user.ID] = user
+		recent := database.Chirp{ID: uuid.New(), CreatedAt: now.Add(-time.Minute), Body: "already chirped", UserID: user.ID}
+		fake.chirps[recent.ID] = recent
+		apiCfg := &apiConfig{db: fake, spamPolicy: spam.DefaultPolicy}
+
+		// import
+		req := httptest.NewRequest(http.MethodPost, "/api/users/me/import", strings.NewReader(tc.archive))
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: user.ID})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerImportChirps(rec, req.WithContext(ctx))
+		if rec.Code != tc.expectedStatus {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.expectedStatus)
+			continue
+		}
+
+		// refused before anything was stored
+		if rec.Code != http.StatusOK {
+			if fake.called("CreateImportedChirp") {
+				t.Errorf("%s: chirps imported despite the refusal", tc.name)
+			}
+			continue
+		}
+
+		// check the report
+		var report JsonImportResponse
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatalf("%s: decoding response failed: %v", tc.name, err) // fatal, don't continue
+		}
+		var failed []int
+		for _, failure := range report.Failed {
+			failed = append(failed, failure.Line)
+		}
+		if report.Imported != tc.expectedImported || report.Held != tc.expectedHeld || !slices.Equal(failed, tc.expectedFailed) {
+			t.Errorf("%s: imported %d, held %d, failed %v, want %d, %d, %v", tc.name, report.Imported, report.Held, failed, tc.expectedImported, tc.expectedHeld, tc.expectedFailed)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// apitokens.go
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SCOPES
+// what a personal access token is allowed to do
+const (
+	ScopeChirpsRead     = "chirps:read"     // read chirps as the user
+	ScopeChirpsWrite    = "chirps:write"    // create and delete the user's chirps
+	ScopeProfileRead    = "profile:read"    // read the user's account, email included
+	ScopeProfileWrite   = "profile:write"   // change the user's profile
+	ScopeBookmarksRead  = "bookmarks:read"  // read the user's private bookmarks
+	ScopeBookmarksWrite = "bookmarks:write" // add, remove and organise the user's bookmarks
+	ScopeReportsWrite   = "reports:write"   // report chirps and users to moderators
+)
+
+// every scope a token may be granted
+var AllScopes = []string{
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeProfileRead,
+	ScopeProfileWrite,
+	ScopeBookmarksRead,
+	ScopeBookmarksWrite,
+	ScopeReportsWrite,
+}
+
+// check if a scope is one we know about
+func ValidScope(scope string) bool {
+	for _, known := range AllScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// PERSONAL ACCESS TOKENS
+// prefix on every personal access token, tells them apart from JWTs
+const APITokenPrefix = "chirpy_pat_"
+
+// makes a personal access token for scripts and bots
+func MakeAPIToken() (string, error) {
+	// make zero'd slice with 32 bytes (which is 256 bits)
+	key := make([]byte, 32)
+
+	// fill the slice with random raw bytes 0-255
+	_, err := rand.Read(key)
+
+	// random check
+	if err != nil {
+		return "", err // early return
+	}
+
+	// prefix the hex encoded key
+	return APITokenPrefix + hex.EncodeToString(key), nil
+}
+
+// check if a bearer token is a personal access token (and not a JWT)
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// hash a personal access token for storage and lookup
+// tokens are 256 bits of randomness, so a fast unsalted hash is enough
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
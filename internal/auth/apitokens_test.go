@@ -0,0 +1,59 @@
+// apitokens_test.go
+
+package auth
+
+import (
+	"strings"
+	"testing" // importing testing package for unit tests
+)
+
+// test personal access token generation and hashing
+func TestMakeAPIToken(t *testing.T) {
+	// gen token
+	token, err := MakeAPIToken()
+
+	// gen token check
+	if err != nil {
+		t.Fatalf("MakeAPIToken failed: %v", err) // fatal, don't continue
+	}
+
+	// prefix check
+	if !strings.HasPrefix(token, APITokenPrefix) || !IsAPIToken(token) {
+		t.Errorf("MakeAPIToken returned token without prefix: %s", token)
+	}
+
+	// jwt aren't api tokens
+	if IsAPIToken("header.payload.signature") {
+		t.Errorf("IsAPIToken accepted a JWT")
+	}
+
+	// duplicate check
+	token2, _ := MakeAPIToken()
+	if token == token2 {
+		t.Errorf("MakeAPIToken produced duplicated tokens")
+	}
+
+	// hash is stable and never the token
+	hash := HashAPIToken(token)
+	if hash != HashAPIToken(token) {
+		t.Errorf("HashAPIToken is not deterministic")
+	}
+	if hash == token || hash == HashAPIToken(token2) {
+		t.Errorf("HashAPIToken returned a weak hash")
+	}
+}
+
+// test scope validation
+func TestValidScope(t *testing.T) {
+	// every known scope is valid
+	for _, scope := range AllScopes {
+		if !ValidScope(scope) {
+			t.Errorf("ValidScope rejected %q", scope)
+		}
+	}
+
+	// unknown scopes aren't
+	if ValidScope("chirps:*") {
+		t.Errorf("ValidScope accepted an unknown scope")
+	}
+}
@@ -11,57 +11,146 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // PASSWORD HASHING
-// func to hash a user's password
+// func to hash a user's password (argon2id by default, see password.go)
 func HashPassword(password string) (string, error) {
-	// handle empty password
-	if password == "" {
-		return "", errors.New("empty password") // early return
-	}
-
-	// use bcrypt's pw gen -- accepts a byte (max 72) and cost (1-31)
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-
-	// hash pw string check
-	if err != nil {
-		return "", err // early return
-	}
-	// low-level funcs shouldn't log or send client responses
-
-	// successfully hash the pw
-	return string(hashedPassword), nil
+	return DefaultPasswordHasher.Hash(password)
 }
 
-// compare hashed pw with user login string
+// compare hashed pw with user login string, any supported algorithm
 func CheckPasswordHash(hash, password string) error {
-	// use bcrypt's compare func
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-
 	// we return the resulting error (and let the func caller handle it)
+	_, err := DefaultPasswordHasher.Verify(hash, password)
 	return err
 }
 
 // GENERATE AND VERIFY JWT TOKENS
+// issuer and audience stamped on (and demanded from) every access token
+const (
+	TokenIssuer   = "chirpy"     // our application
+	TokenAudience = "chirpy-api" // the api that accepts the token
+)
+
+// plans embedded in access tokens
+const (
+	PlanFree      = "free"       // default plan
+	PlanChirpyRed = "chirpy_red" // premium plan
+)
+
+// roles embedded in access tokens
+const (
+	RoleUser      = "user"      // default role
+	RoleModerator = "moderator" // staff, moderates content
+	RoleAdmin     = "admin"     // staff, runs the service
+)
+
+// check if a role is one we know about
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
+// access token claims, our own claims alongside the registered ones
+type Claims struct {
+	Plan     string `json:"plan,omitempty"`      // subscription plan, lets handlers skip a db lookup
+	Role     string `json:"role,omitempty"`      // user role, lets handlers skip a db lookup
+	Scope    string `json:"scope,omitempty"`     // space separated scopes, only on delegated (oauth) tokens
+	ClientID string `json:"client_id,omitempty"` // oauth client acting for the user, only on delegated tokens
+	jwt.RegisteredClaims
+}
+
+// optional extras to embed when making an access token
+type TokenOptions struct {
+	Plan     string   // defaults to PlanFree
+	Role     string   // defaults to RoleUser
+	Scopes   []string // limits a delegated token, needs ClientID
+	ClientID string   // oauth client the token is issued to, empty for a login session
+}
+
+// get the validated subject as a user id
+func (c *Claims) UserID() uuid.UUID {
+	// subject is checked to be a uuid during validation, so the err is moot
+	userID, _ := uuid.Parse(c.Subject)
+	return userID
+}
+
+// check if the token was issued to an oauth client rather than a login session
+func (c *Claims) IsDelegated() bool {
+	return c.ClientID != ""
+}
+
+// get the delegated token's scopes, nil for a login session
+func (c *Claims) Scopes() []string {
+	// sessions are unscoped
+	if !c.IsDelegated() {
+		return nil
+	}
+
+	// never nil for a delegated token, no scopes means no access
+	scopes := strings.Fields(c.Scope)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return scopes
+}
+
 // generate jwt token on server to send to user
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	// default plan and role
+	return MakeAccessToken(userID, tokenSecret, expiresIn, TokenOptions{})
+}
+
+// generate jwt access token with plan and role claims
+func MakeAccessToken(userID uuid.UUID, tokenSecret string, expiresIn time.Duration, opts TokenOptions) (string, error) {
 	// the signing key
 	signingKey := []byte(tokenSecret)
 
 	// HMAC HS256 signing method
 	signingMethod := jwt.SigningMethodHS256
 
-	// create registered claims
-	claims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy",                                      // issuer = our application
-		IssuedAt:  jwt.NewNumericDate(time.Now()),                // current time
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)), // current time + expiration time
-		Subject:   userID.String(),                               // stringified version of user id
+	// unique token id so a single token can be denylisted
+	jti, err := uuid.NewRandom()
+
+	// token id check
+	if err != nil {
+		return "", err // early return
+	}
+
+	// default plan and role
+	if opts.Plan == "" {
+		opts.Plan = PlanFree
+	}
+	if opts.Role == "" {
+		opts.Role = RoleUser
+	}
+
+	// scopes only mean something on a delegated token
+	if len(opts.Scopes) > 0 && opts.ClientID == "" {
+		return "", errors.New("scoped access tokens need a client id")
 	}
 
-	// create JWT token using signing method and registered claim
+	// single timestamp so iat and nbf agree
+	now := time.Now()
+
+	// create our claims with the registered claims embedded
+	claims := &Claims{
+		Plan:     opts.Plan,
+		Role:     opts.Role,
+		Scope:    strings.Join(opts.Scopes, " "),
+		ClientID: opts.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    TokenIssuer,                            // issuer = our application
+			Audience:  jwt.ClaimStrings{TokenAudience},        // audience = our api
+			IssuedAt:  jwt.NewNumericDate(now),                // current time
+			NotBefore: jwt.NewNumericDate(now),                // usable from now
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)), // current time + expiration time
+			Subject:   userID.String(),                        // stringified version of user id
+			ID:        jti.String(),                           // jti for revocation
+		},
+	}
+
+	// create JWT token using signing method and claims
 	token := jwt.NewWithClaims(signingMethod, claims)
 
 	// sign the token with secret key
@@ -79,45 +168,81 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 
 // validate jwt token returned from user
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	// no denylist, signature and claims only
+	claims, err := ValidateAccessToken(tokenString, tokenSecret, nil)
+
+	// validation check
+	if err != nil {
+		return uuid.Nil, err // nil id
+	}
+
+	// return userid (subject) as uuid from the populate claims
+	// validation confirms which user this JWT belongs to
+	return claims.UserID(), nil
+}
+
+// validate jwt access token and return its claims
+// denylist is optional, pass nil to skip the revocation check
+func ValidateAccessToken(tokenString, tokenSecret string, denylist Denylist) (*Claims, error) {
 	// create empty claims struct to be populated
-	claims := &jwt.RegisteredClaims{} // ptr because ParseWithClaims requires it
+	claims := &Claims{} // ptr because ParseWithClaims requires it
 
 	// parse the user's jwt token claims, validates it, then returns the parsed token
 	tokenParse, err := jwt.ParseWithClaims(tokenString, claims, func(tokenParse *jwt.Token) (interface{}, error) {
 		// return secret key a byte slice
 		return []byte(tokenSecret), nil
-	}) // anon func takes token from ParseWithClaims and returns the secret key as byte slice
+	}, // anon func takes token from ParseWithClaims and returns the secret key as byte slice
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), // reject alg swapping
+		jwt.WithIssuer(TokenIssuer),                                  // must be ours
+		jwt.WithAudience(TokenAudience),                              // must be meant for the api
+		jwt.WithExpirationRequired(),                                 // exp must be present (and valid)
+		jwt.WithIssuedAt(),                                           // iat must not be in the future
+	) // nbf is checked by the parser whenever it is present
 
 	// check token claims parse
 	if err != nil {
-		return uuid.Nil, err // nil id
+		return nil, err
 	}
 
-	// Use a type assertion to get the claims as *jwt.RegisteredClaims
-	token, ok := tokenParse.Claims.(*jwt.RegisteredClaims)
+	// Use a type assertion to get the claims as *Claims
+	token, ok := tokenParse.Claims.(*Claims)
 	// tokenParse.Claims is of type jwt.Claims (interface)
 
 	// type assertion check
 	if !ok {
-		return uuid.Nil, errors.New("invalid token claims") // nil id
+		return nil, errors.New("invalid token claims")
 	} // use custom err, not just "err" (from previous check...)
 
-	// token expiration check
-	if time.Now().After(token.ExpiresAt.Time) {
-		return uuid.Nil, errors.New("token has expired") // nil id
-	} // use custom err, not just "err" (from previous check...)
+	// not before presence check (we always set it)
+	if token.NotBefore == nil {
+		return nil, errors.New("token is missing not before")
+	}
+
+	// token id presence check (needed for revocation)
+	if token.ID == "" {
+		return nil, errors.New("token is missing token id")
+	}
 
 	// convert userid (subject) to uuid
 	userID, err := uuid.Parse(token.Subject)
 
 	// uuid parse check
 	if err != nil {
-		return uuid.Nil, err // nil id
+		return nil, err
 	}
 
-	// return userid (subject) as uuid from the populate claims
-	// validation confirms which user this JWT belongs to
-	return userID, nil
+	// revoked token check
+	if denylist != nil && denylist.IsDenied(token.ID) {
+		return nil, errors.New("token has been revoked")
+	}
+
+	// suspended user check
+	if denylist != nil && denylist.IsUserDenied(userID) {
+		return nil, errors.New("user is suspended")
+	}
+
+	// return the validated claims
+	return token, nil
 }
 
 // BEARER TOKEN
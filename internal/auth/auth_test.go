@@ -8,6 +8,7 @@ import (
 	"testing" // importing testing package for unit tests
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -170,6 +171,216 @@ func TestValidateJWTExpiredToken(t *testing.T) {
 	}
 }
 
+// test access token claims survive the round trip
+func TestValidateAccessTokenClaims(t *testing.T) {
+	// test case
+	userUUID := uuid.New()
+	tokenSecret := "AllYourBase"
+
+	// gen access token with plan and role
+	tokenString, err := MakeAccessToken(userUUID, tokenSecret, time.Hour, TokenOptions{
+		Plan: PlanChirpyRed,
+		Role: RoleUser,
+	})
+
+	// gen token check
+	if err != nil {
+		t.Fatalf("MakeAccessToken failed: %v", err) // fatal, don't continue
+	}
+
+	// validate token
+	claims, err := ValidateAccessToken(tokenString, tokenSecret, nil)
+
+	// validate token check
+	if err != nil {
+		t.Fatalf("ValidateAccessToken failed: %v", err) // fatal, don't continue
+	}
+
+	// check each claim
+	if claims.UserID() != userUUID {
+		t.Errorf("ValidateAccessToken returned invalid user ID: %s", claims.UserID())
+	}
+	if claims.Plan != PlanChirpyRed {
+		t.Errorf("ValidateAccessToken returned plan %q, want %q", claims.Plan, PlanChirpyRed)
+	}
+	if claims.Role != RoleUser {
+		t.Errorf("ValidateAccessToken returned role %q, want %q", claims.Role, RoleUser)
+	}
+	if claims.ID == "" {
+		t.Errorf("ValidateAccessToken returned empty token id")
+	}
+}
+
+// test delegated (oauth) access tokens carry their client and scopes
+func TestValidateAccessTokenDelegated(t *testing.T) {
+	// test case
+	userUUID := uuid.New()
+	tokenSecret := "AllYourBase"
+
+	// a session token is unscoped
+	sessionToken, err := MakeAccessToken(userUUID, tokenSecret, time.Hour, TokenOptions{})
+	if err != nil {
+		t.Fatalf("MakeAccessToken failed: %v", err) // fatal, don't continue
+	}
+	claims, err := ValidateAccessToken(sessionToken, tokenSecret, nil)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken failed: %v", err) // fatal, don't continue
+	}
+	if claims.IsDelegated() || claims.Scopes() != nil {
+		t.Errorf("session token is delegated or scoped: %+v", claims)
+	}
+
+	// a delegated token is limited to its scopes
+	delegatedToken, err := MakeAccessToken(userUUID, tokenSecret, time.Hour, TokenOptions{
+		Scopes:   []string{ScopeChirpsRead, ScopeChirpsWrite},
+		ClientID: "client-1",
+	})
+	if err != nil {
+		t.Fatalf("MakeAccessToken failed: %v", err) // fatal, don't continue
+	}
+	claims, err = ValidateAccessToken(delegatedToken, tokenSecret, nil)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken failed: %v", err) // fatal, don't continue
+	}
+	if !claims.IsDelegated() || claims.ClientID != "client-1" {
+		t.Errorf("delegated token client = %q, want client-1", claims.ClientID)
+	}
+	if got := claims.Scopes(); len(got) != 2 || got[0] != ScopeChirpsRead || got[1] != ScopeChirpsWrite {
+		t.Errorf("delegated token scopes = %v", got)
+	}
+
+	// a delegated token with no scopes can do nothing, not everything
+	emptyToken, _ := MakeAccessToken(userUUID, tokenSecret, time.Hour, TokenOptions{ClientID: "client-1"})
+	claims, _ = ValidateAccessToken(emptyToken, tokenSecret, nil)
+	if got := claims.Scopes(); got == nil || len(got) != 0 {
+		t.Errorf("unscoped delegated token scopes = %#v, want empty", got)
+	}
+
+	// scopes without a client are refused
+	if _, err := MakeAccessToken(userUUID, tokenSecret, time.Hour, TokenOptions{Scopes: []string{ScopeChirpsRead}}); err == nil {
+		t.Errorf("MakeAccessToken allowed scopes without a client id")
+	}
+}
+
+// test access token claim validation rejects foreign or incomplete tokens
+func TestValidateAccessTokenRejectsBadClaims(t *testing.T) {
+	// test case
+	userID := "123e4567-e89b-12d3-a456-426614174000" // fixed stringified uuid
+	tokenSecret := "AllYourBase"
+	now := time.Now()
+
+	// valid claims, each test case breaks one
+	validClaims := func() *Claims {
+		return &Claims{
+			Plan: PlanFree,
+			Role: RoleUser,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    TokenIssuer,
+				Audience:  jwt.ClaimStrings{TokenAudience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				NotBefore: jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				Subject:   userID,
+				ID:        "jti-1",
+			},
+		}
+	}
+
+	// build test cases
+	testCases := []struct {
+		name   string        // name for test case
+		mutate func(*Claims) // breaks the valid claims
+	}{
+		{"Test case: Wrong Issuer", func(c *Claims) { c.Issuer = "not-chirpy" }},
+		{"Test case: Wrong Audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"someone-else"} }},
+		{"Test case: Missing Audience", func(c *Claims) { c.Audience = nil }},
+		{"Test case: Not Yet Valid", func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Hour)) }},
+		{"Test case: Missing Not Before", func(c *Claims) { c.NotBefore = nil }},
+		{"Test case: Missing Token ID", func(c *Claims) { c.ID = "" }},
+		{"Test case: Missing Expiry", func(c *Claims) { c.ExpiresAt = nil }},
+		{"Test case: Subject Not UUID", func(c *Claims) { c.Subject = "bobaggins" }},
+	}
+
+	// sanity check the valid claims pass first
+	validToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte(tokenSecret))
+	if _, err := ValidateAccessToken(validToken, tokenSecret, nil); err != nil {
+		t.Fatalf("ValidateAccessToken rejected valid claims: %v", err) // fatal, cases are meaningless
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		// break the claims and sign them
+		claims := validClaims()
+		tc.mutate(claims)
+		tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
+
+		// validated token (or attempt to...)
+		_, err := ValidateAccessToken(tokenString, tokenSecret, nil)
+
+		// validate token check
+		if err == nil {
+			t.Errorf("%s: ValidateAccessToken accepted bad claims", tc.name)
+		}
+	}
+}
+
+// test access token validation with a denylisted token id
+func TestValidateAccessTokenDenied(t *testing.T) {
+	// test case
+	userUUID := uuid.New()
+	tokenSecret := "AllYourBase"
+	denylist := NewMemoryDenylist()
+
+	// gen jwt token
+	tokenString, _ := MakeJWT(userUUID, tokenSecret, time.Hour) // err checked in other test
+
+	// validates before revocation
+	claims, err := ValidateAccessToken(tokenString, tokenSecret, denylist)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken failed: %v", err) // fatal, don't continue
+	}
+
+	// revoke it
+	denylist.Deny(claims.ID, claims.ExpiresAt.Time)
+
+	// validated token (or attempt to...)
+	_, err = ValidateAccessToken(tokenString, tokenSecret, denylist)
+
+	// validate token check
+	if err == nil {
+		t.Fatalf("ValidateAccessToken failed to reject denylisted token") // fatal, don't continue
+	}
+}
+
+// test access token validation for a suspended user
+func TestValidateAccessTokenUserDenied(t *testing.T) {
+	// test case
+	userUUID := uuid.New()
+	tokenSecret := "AllYourBase"
+	denylist := NewMemoryDenylist()
+
+	// gen jwt token
+	tokenString, _ := MakeJWT(userUUID, tokenSecret, time.Hour) // err checked in other test
+
+	// suspend the user
+	denylist.DenyUser(userUUID, time.Now().Add(time.Hour))
+
+	// validated token (or attempt to...)
+	_, err := ValidateAccessToken(tokenString, tokenSecret, denylist)
+
+	// validate token check
+	if err == nil {
+		t.Fatalf("ValidateAccessToken failed to reject a suspended user's token") // fatal, don't continue
+	}
+
+	// lift the suspension, the same token works again
+	denylist.AllowUser(userUUID)
+	_, err = ValidateAccessToken(tokenString, tokenSecret, denylist)
+	if err != nil {
+		t.Errorf("ValidateAccessToken rejected a token after the suspension was lifted: %v", err)
+	}
+}
+
 // JSON WEB TOKEN BEARER GET
 // test GetBearerToken
 func TestGetBearerToken(t *testing.T) {
@@ -0,0 +1,104 @@
+// denylist.go
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ACCESS TOKEN DENYLIST
+// reports if an access token id (jti) was revoked before it expired,
+// or if every token of a user is refused for now (a suspended account)
+type Denylist interface {
+	IsDenied(jti string) bool
+	IsUserDenied(userID uuid.UUID) bool
+}
+
+// in-memory denylist, safe for concurrent use
+type MemoryDenylist struct {
+	mu      sync.RWMutex            // guards entries and users
+	entries map[string]time.Time    // jti -> token expiry (entry is useless after)
+	users   map[uuid.UUID]time.Time // user id -> denied until
+}
+
+// create an empty in-memory denylist
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{
+		entries: make(map[string]time.Time),
+		users:   make(map[uuid.UUID]time.Time),
+	}
+}
+
+// deny a token id until the token's own expiry
+func (d *MemoryDenylist) Deny(jti string, expiresAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[jti] = expiresAt
+}
+
+// check if a token id is denied
+func (d *MemoryDenylist) IsDenied(jti string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, denied := d.entries[jti]
+	return denied
+}
+
+// deny every token of a user until a time, a later time wins
+func (d *MemoryDenylist) DenyUser(userID uuid.UUID, until time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if until.After(d.users[userID]) {
+		d.users[userID] = until
+	}
+}
+
+// stop denying a user's tokens
+func (d *MemoryDenylist) AllowUser(userID uuid.UUID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.users, userID)
+}
+
+// replace every denied user at once, for syncing with the db
+func (d *MemoryDenylist) SetDeniedUsers(users map[uuid.UUID]time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.users = make(map[uuid.UUID]time.Time, len(users))
+	for userID, until := range users {
+		d.users[userID] = until
+	}
+}
+
+// check if a user's tokens are denied right now
+func (d *MemoryDenylist) IsUserDenied(userID uuid.UUID) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	until, denied := d.users[userID]
+	return denied && time.Now().Before(until)
+}
+
+// drop entries for tokens that have expired anyway, and users no longer denied, returns number dropped
+func (d *MemoryDenylist) Prune(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// loop through and delete the stale ones
+	pruned := 0
+	for jti, expiresAt := range d.entries {
+		if now.After(expiresAt) {
+			delete(d.entries, jti)
+			pruned++
+		}
+	}
+	for userID, until := range d.users {
+		if now.After(until) {
+			delete(d.users, userID)
+			pruned++
+		}
+	}
+
+	return pruned
+}
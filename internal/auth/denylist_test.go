@@ -0,0 +1,81 @@
+// denylist_test.go
+
+package auth
+
+import (
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// test denying and pruning token ids
+func TestMemoryDenylist(t *testing.T) {
+	// test case
+	denylist := NewMemoryDenylist()
+	now := time.Now()
+
+	// deny one live and one already expired token
+	denylist.Deny("live", now.Add(time.Hour))
+	denylist.Deny("stale", now.Add(-time.Hour))
+
+	// both denied before pruning
+	if !denylist.IsDenied("live") || !denylist.IsDenied("stale") {
+		t.Fatalf("MemoryDenylist failed to deny token ids") // fatal, don't continue
+	}
+
+	// unknown ids aren't denied
+	if denylist.IsDenied("unknown") {
+		t.Errorf("MemoryDenylist denied an unknown token id")
+	}
+
+	// prune the expired one
+	pruned := denylist.Prune(now)
+
+	// prune count check
+	if pruned != 1 {
+		t.Errorf("MemoryDenylist pruned %d entries, want 1", pruned)
+	}
+
+	// live one must survive the prune
+	if !denylist.IsDenied("live") {
+		t.Errorf("MemoryDenylist pruned a live token id")
+	}
+
+	// stale one is gone
+	if denylist.IsDenied("stale") {
+		t.Errorf("MemoryDenylist kept an expired token id")
+	}
+}
+
+// test denying, replacing and pruning users
+func TestMemoryDenylistUsers(t *testing.T) {
+	// test case
+	denylist := NewMemoryDenylist()
+	now := time.Now()
+	suspended, lapsed, other := uuid.New(), uuid.New(), uuid.New()
+
+	// deny one user for a while, and one whose suspension is already over
+	denylist.DenyUser(suspended, now.Add(time.Hour))
+	denylist.DenyUser(suspended, now.Add(time.Minute)) // an earlier end doesn't shorten it
+	denylist.DenyUser(lapsed, now.Add(-time.Hour))
+
+	// only the live one is denied
+	if !denylist.IsUserDenied(suspended) {
+		t.Errorf("MemoryDenylist failed to deny a user")
+	}
+	if denylist.IsUserDenied(lapsed) || denylist.IsUserDenied(other) {
+		t.Errorf("MemoryDenylist denied a user it shouldn't")
+	}
+
+	// prune the lapsed one
+	if pruned := denylist.Prune(now.Add(2 * time.Minute)); pruned != 1 {
+		t.Errorf("MemoryDenylist pruned %d entries, want 1", pruned)
+	}
+
+	// a sync replaces the whole set
+	denylist.SetDeniedUsers(map[uuid.UUID]time.Time{other: now.Add(time.Hour)})
+	if denylist.IsUserDenied(suspended) || !denylist.IsUserDenied(other) {
+		t.Errorf("MemoryDenylist did not replace its denied users")
+	}
+}
@@ -0,0 +1,44 @@
+// emailtokens.go
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// EMAIL TOKENS
+// what an emailed link is for, bound into the token hash
+const (
+	EmailPurposeVerify = "verify_email"   // confirm the address belongs to the user
+	EmailPurposeReset  = "reset_password" // set a new password without the old one
+	EmailPurposeMagic  = "magic_login"    // sign in without a password
+	EmailPurposeChange = "change_email"   // prove the user owns a new address
+)
+
+// makes a token to email to a user
+func MakeEmailToken() (string, error) {
+	// make zero'd slice with 32 bytes (which is 256 bits)
+	key := make([]byte, 32)
+
+	// fill the slice with random raw bytes 0-255
+	_, err := rand.Read(key)
+
+	// random check
+	if err != nil {
+		return "", err // early return
+	}
+
+	// hex travels well in a url
+	return hex.EncodeToString(key), nil
+}
+
+// signs an email token for storage and lookup
+// keyed with the server secret and bound to a purpose, so a leaked table can't be
+// replayed and a verify link can't be used as a reset link
+func HashEmailToken(token, purpose, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,36 @@
+// emailtokens_test.go
+
+package auth
+
+import (
+	"testing" // importing testing package for unit tests
+)
+
+// test email tokens are random and their hashes keyed and purpose bound
+func TestEmailTokens(t *testing.T) {
+	first, err := MakeEmailToken()
+	if err != nil {
+		t.Fatalf("MakeEmailToken failed: %v", err) // fatal, don't continue
+	}
+	second, _ := MakeEmailToken()
+	if len(first) != 64 || first == second {
+		t.Errorf("unexpected tokens %q and %q", first, second)
+	}
+
+	// stable for the same inputs
+	hash := HashEmailToken(first, EmailPurposeVerify, "secret")
+	if hash != HashEmailToken(first, EmailPurposeVerify, "secret") {
+		t.Errorf("hash is not deterministic")
+	}
+
+	// different purpose, key or token all change it
+	if hash == HashEmailToken(first, EmailPurposeReset, "secret") {
+		t.Errorf("hash ignores the purpose")
+	}
+	if hash == HashEmailToken(first, EmailPurposeVerify, "other") {
+		t.Errorf("hash ignores the key")
+	}
+	if hash == HashEmailToken(second, EmailPurposeVerify, "secret") {
+		t.Errorf("hash ignores the token")
+	}
+}
@@ -0,0 +1,74 @@
+// mfa.go
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MFA CHALLENGE TOKENS
+// audience of the short lived token handed out between password and second factor
+// the api audience differs, so a challenge token can never be used as an access token
+const MFATokenAudience = "chirpy-mfa"
+
+// makes a challenge token proving the password step passed for a user
+func MakeMFAToken(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	// unique token id, for log correlation
+	jti, err := uuid.NewRandom()
+
+	// token id check
+	if err != nil {
+		return "", err // early return
+	}
+
+	// single timestamp so iat and nbf agree
+	now := time.Now()
+
+	// registered claims only, no plan or role until the login completes
+	claims := jwt.RegisteredClaims{
+		Issuer:    TokenIssuer,                            // issuer = our application
+		Audience:  jwt.ClaimStrings{MFATokenAudience},     // audience = the second factor step
+		IssuedAt:  jwt.NewNumericDate(now),                // current time
+		NotBefore: jwt.NewNumericDate(now),                // usable from now
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)), // current time + expiration time
+		Subject:   userID.String(),                        // stringified version of user id
+		ID:        jti.String(),                           // jti
+	}
+
+	// sign with HS256 and the secret key
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
+}
+
+// validates a challenge token and returns the user it was issued for
+func ValidateMFAToken(tokenString, tokenSecret string) (uuid.UUID, error) {
+	// create empty claims struct to be populated
+	claims := &jwt.RegisteredClaims{}
+
+	// parse and validate, same rules as access tokens but the mfa audience
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(tokenParse *jwt.Token) (interface{}, error) {
+		// return secret key a byte slice
+		return []byte(tokenSecret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), // reject alg swapping
+		jwt.WithIssuer(TokenIssuer),                                  // must be ours
+		jwt.WithAudience(MFATokenAudience),                           // must be a challenge token
+		jwt.WithExpirationRequired(),                                 // exp must be present (and valid)
+		jwt.WithIssuedAt(),                                           // iat must not be in the future
+	)
+
+	// check token claims parse
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// not before presence check (we always set it)
+	if claims.NotBefore == nil {
+		return uuid.Nil, errors.New("token is missing not before")
+	}
+
+	// convert userid (subject) to uuid
+	return uuid.Parse(claims.Subject)
+}
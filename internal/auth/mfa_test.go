@@ -0,0 +1,56 @@
+// mfa_test.go
+
+package auth
+
+import (
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// test challenge tokens round trip and never pass as access tokens
+func TestMFAToken(t *testing.T) {
+	userID := uuid.New()
+	secret := "test-secret"
+
+	// make a challenge token
+	token, err := MakeMFAToken(userID, secret, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMFAToken failed: %v", err) // fatal, don't continue
+	}
+
+	// valid challenge token
+	got, err := ValidateMFAToken(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateMFAToken failed: %v", err) // fatal, don't continue
+	}
+	if got != userID {
+		t.Errorf("ValidateMFAToken returned %s, want %s", got, userID)
+	}
+
+	// not an access token
+	if _, err := ValidateJWT(token, secret); err == nil {
+		t.Errorf("ValidateJWT accepted an mfa challenge token")
+	}
+
+	// an access token is not a challenge token
+	access, err := MakeJWT(userID, secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err) // fatal, don't continue
+	}
+	if _, err := ValidateMFAToken(access, secret); err == nil {
+		t.Errorf("ValidateMFAToken accepted an access token")
+	}
+
+	// wrong key
+	if _, err := ValidateMFAToken(token, "other-secret"); err == nil {
+		t.Errorf("ValidateMFAToken accepted a token signed with another key")
+	}
+
+	// expired
+	expired, _ := MakeMFAToken(userID, secret, -time.Minute)
+	if _, err := ValidateMFAToken(expired, secret); err == nil {
+		t.Errorf("ValidateMFAToken accepted an expired token")
+	}
+}
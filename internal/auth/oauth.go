@@ -0,0 +1,78 @@
+// oauth.go
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// OAUTH AUTHORIZATION SERVER
+// prefixes on the opaque oauth credentials, so a leaked one is recognisable
+const (
+	OAuthClientSecretPrefix = "chirpy_cs_"  // client secrets, shown once at registration
+	OAuthRefreshTokenPrefix = "chirpy_ort_" // refresh tokens handed to clients
+)
+
+// makes a client secret for a confidential oauth client
+func MakeOAuthClientSecret() (string, error) {
+	return makeOpaqueToken(OAuthClientSecretPrefix)
+}
+
+// makes a single use authorization code
+func MakeOAuthCode() (string, error) {
+	return makeOpaqueToken("")
+}
+
+// makes a refresh token for an oauth client
+func MakeOAuthRefreshToken() (string, error) {
+	return makeOpaqueToken(OAuthRefreshTokenPrefix)
+}
+
+// hash an oauth secret, code or refresh token for storage and lookup
+// all are 256 bits of randomness, so a fast unsalted hash is enough
+func HashOAuthToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PKCE
+// check a pkce code verifier has the form rfc 7636 demands (43-128 unreserved chars)
+func ValidCodeVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// check a code verifier against the S256 challenge sent with the authorization request
+func VerifyPKCE(verifier, challenge string) bool {
+	// verifier form check
+	if !ValidCodeVerifier(verifier) {
+		return false
+	}
+
+	// S256: base64url(sha256(verifier))
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// HELPER FUNCS
+
+// 32 random bytes, hex encoded behind a prefix
+func makeOpaqueToken(prefix string) (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(key), nil
+}
@@ -0,0 +1,55 @@
+// oauth_test.go
+
+package auth
+
+import (
+	"strings"
+	"testing" // importing testing package for unit tests
+)
+
+// test oauth credentials are prefixed and unique
+func TestMakeOAuthTokens(t *testing.T) {
+	secret, err := MakeOAuthClientSecret()
+	if err != nil {
+		t.Fatalf("MakeOAuthClientSecret failed: %v", err) // fatal, don't continue
+	}
+	if !strings.HasPrefix(secret, OAuthClientSecretPrefix) {
+		t.Errorf("client secret %q missing prefix", secret)
+	}
+
+	refresh, err := MakeOAuthRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeOAuthRefreshToken failed: %v", err) // fatal, don't continue
+	}
+	if !strings.HasPrefix(refresh, OAuthRefreshTokenPrefix) {
+		t.Errorf("refresh token %q missing prefix", refresh)
+	}
+
+	code1, _ := MakeOAuthCode()
+	code2, _ := MakeOAuthCode()
+	if code1 == code2 || len(code1) != 64 {
+		t.Errorf("codes %q and %q are not unique 64 char values", code1, code2)
+	}
+	if HashOAuthToken(code1) == code1 || HashOAuthToken(code1) != HashOAuthToken(code1) {
+		t.Errorf("HashOAuthToken is not a stable hash")
+	}
+}
+
+// test pkce verification (rfc 7636 appendix b example)
+func TestVerifyPKCE(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !VerifyPKCE(verifier, challenge) {
+		t.Errorf("VerifyPKCE rejected the rfc example")
+	}
+	if VerifyPKCE(verifier+"x", challenge) {
+		t.Errorf("VerifyPKCE accepted the wrong verifier")
+	}
+	if VerifyPKCE("short", "short") {
+		t.Errorf("VerifyPKCE accepted a too short verifier")
+	}
+	if ValidCodeVerifier(strings.Repeat("a", 42) + "!") {
+		t.Errorf("ValidCodeVerifier accepted a reserved character")
+	}
+}
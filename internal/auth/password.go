@@ -0,0 +1,248 @@
+// password.go
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// password hash algorithms, identified by their prefix in the stored hash
+const (
+	AlgorithmArgon2id = "argon2id" // $argon2id$v=19$m=...,t=...,p=...$salt$key
+	AlgorithmBcrypt   = "bcrypt"   // $2a$10$... (legacy, truncates past 72 bytes)
+)
+
+// bcrypt silently ignores anything past this many bytes
+const bcryptMaxPasswordBytes = 72
+
+// argon2id cost parameters
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32 // passes over memory
+	Parallelism uint8  // lanes (threads)
+	SaltLength  uint32 // bytes of random salt
+	KeyLength   uint32 // bytes of derived key
+}
+
+// RFC 9106 style defaults, ~64MiB per hash
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// hashes new passwords with one algorithm, verifies any supported one
+type PasswordHasher struct {
+	Algorithm  string        // algorithm for new hashes
+	Argon2     Argon2Params  // argon2id cost
+	BcryptCost int           // bcrypt cost (4-31)
+	Slots      chan struct{} // optional, caps hashes running at once (argon2id holds Memory KiB each), nil for no cap
+}
+
+// make a semaphore allowing n hashes at once, for PasswordHasher.Slots
+func NewHashSlots(n int) chan struct{} {
+	return make(chan struct{}, n)
+}
+
+// what the package level HashPassword and CheckPasswordHash use
+var DefaultPasswordHasher = PasswordHasher{
+	Algorithm:  AlgorithmArgon2id,
+	Argon2:     DefaultArgon2Params,
+	BcryptCost: bcrypt.DefaultCost,
+}
+
+// check the hasher is usable before handing it any passwords
+func (h PasswordHasher) Validate() error {
+	switch h.Algorithm {
+	case AlgorithmArgon2id:
+		// all params must be non zero
+		p := h.Argon2
+		if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 || p.SaltLength == 0 || p.KeyLength == 0 {
+			return errors.New("argon2id parameters must all be positive")
+		}
+		return nil
+	case AlgorithmBcrypt:
+		// bcrypt's own cost bounds
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be %d-%d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+}
+
+// hash a password with the configured algorithm
+func (h PasswordHasher) Hash(password string) (string, error) {
+	// handle empty password
+	if password == "" {
+		return "", errors.New("empty password") // early return
+	}
+
+	// wait for a slot, a burst of hashes mustn't exhaust memory
+	defer h.acquire()()
+
+	// pick the algorithm
+	switch h.Algorithm {
+	case AlgorithmArgon2id:
+		return hashArgon2id(password, h.Argon2)
+	case AlgorithmBcrypt:
+		return hashBcrypt(password, h.BcryptCost)
+	default:
+		return "", fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+}
+
+// compare a stored hash with a login password
+// needsRehash is true when the password matched but the hash is outdated
+// (other algorithm or weaker parameters), so the caller should store a fresh one
+func (h PasswordHasher) Verify(hash, password string) (needsRehash bool, err error) {
+	// wait for a slot, a burst of logins mustn't exhaust memory
+	defer h.acquire()()
+
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		// parse the stored params, salt and key
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+
+		// derive with the stored params and compare in constant time
+		derived := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(derived, key) != 1 {
+			return false, errors.New("password does not match hash")
+		}
+
+		// outdated if we've moved algorithm or params
+		stale := h.Algorithm != AlgorithmArgon2id ||
+			params.Memory != h.Argon2.Memory ||
+			params.Iterations != h.Argon2.Iterations ||
+			params.Parallelism != h.Argon2.Parallelism ||
+			uint32(len(salt)) != h.Argon2.SaltLength ||
+			uint32(len(key)) != h.Argon2.KeyLength
+		return stale, nil
+
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		// bcrypt would truncate, so a long password could match on its first 72 bytes
+		if len(password) > bcryptMaxPasswordBytes {
+			return false, bcrypt.ErrPasswordTooLong
+		}
+
+		// use bcrypt's compare func
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err != nil {
+			return false, err
+		}
+
+		// outdated if we've moved algorithm or cost
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, err
+		}
+		return h.Algorithm != AlgorithmBcrypt || cost != h.BcryptCost, nil
+
+	default:
+		return false, errors.New("unrecognised password hash format")
+	}
+}
+
+// HELPER FUNCS
+
+// argon2id hash in the PHC string format
+func hashArgon2id(password string, p Argon2Params) (string, error) {
+	// random salt per password
+	salt := make([]byte, p.SaltLength)
+	_, err := rand.Read(salt)
+
+	// random check
+	if err != nil {
+		return "", err // early return
+	}
+
+	// derive the key
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	// params travel with the hash so they can change later
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// parse an argon2id PHC string into its params, salt and key
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, errors.New("malformed argon2id hash")
+	}
+
+	// version check
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	// cost params
+	var p Argon2Params
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	// salt and key
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
+
+// legacy bcrypt hash, refusing what it would truncate
+func hashBcrypt(password string, cost int) (string, error) {
+	// bcrypt truncation check
+	if len(password) > bcryptMaxPasswordBytes {
+		return "", bcrypt.ErrPasswordTooLong
+	}
+
+	// use bcrypt's pw gen -- accepts a byte (max 72) and cost (4-31)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+
+	// hash pw string check
+	if err != nil {
+		return "", err // early return
+	}
+
+	return string(hashedPassword), nil
+}
+
+// take a hashing slot, returns the func that gives it back
+func (h PasswordHasher) acquire() func() {
+	if h.Slots == nil {
+		return func() {}
+	}
+	h.Slots <- struct{}{}
+	return func() { <-h.Slots }
+}
@@ -0,0 +1,157 @@
+// password_test.go
+
+package auth
+
+import (
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap argon2id params keep the tests quick
+var testArgon2Params = Argon2Params{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// test hashers for both algorithms
+var (
+	testArgon2Hasher = PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2: testArgon2Params, BcryptCost: bcrypt.MinCost}
+	testBcryptHasher = PasswordHasher{Algorithm: AlgorithmBcrypt, Argon2: testArgon2Params, BcryptCost: bcrypt.MinCost}
+)
+
+// test argon2id round trip
+func TestPasswordHasherArgon2id(t *testing.T) {
+	// hash pw
+	hash, err := testArgon2Hasher.Hash("password123")
+
+	// hash fail check
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err) // fatal, don't continue
+	}
+
+	// format check
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Hash returned unexpected format: %s", hash)
+	}
+
+	// correct password, current params
+	needsRehash, err := testArgon2Hasher.Verify(hash, "password123")
+	if err != nil || needsRehash {
+		t.Errorf("Verify = (%v, %v), want (false, nil)", needsRehash, err)
+	}
+
+	// wrong password
+	if _, err := testArgon2Hasher.Verify(hash, "password1234"); err == nil {
+		t.Errorf("Verify failed to detect incorrect password")
+	}
+}
+
+// test argon2id doesn't truncate long passwords like bcrypt does
+func TestPasswordHasherLongPassword(t *testing.T) {
+	// two passwords equal for the first 72 bytes
+	prefix := strings.Repeat("a", 72)
+	hash, _ := testArgon2Hasher.Hash(prefix + "one")
+
+	// the tail must matter
+	if _, err := testArgon2Hasher.Verify(hash, prefix+"two"); err == nil {
+		t.Errorf("Verify matched a password differing after 72 bytes")
+	}
+
+	// bcrypt refuses rather than truncating
+	if _, err := testBcryptHasher.Hash(prefix + "one"); err == nil {
+		t.Errorf("bcrypt Hash accepted a password over 72 bytes")
+	}
+}
+
+// test outdated hashes are flagged for rehash
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	// legacy bcrypt hash, argon2id hasher
+	legacy, _ := testBcryptHasher.Hash("password123")
+	needsRehash, err := testArgon2Hasher.Verify(legacy, "password123")
+	if err != nil || !needsRehash {
+		t.Errorf("Verify(bcrypt) = (%v, %v), want (true, nil)", needsRehash, err)
+	}
+
+	// same bcrypt hash, bcrypt hasher at the same cost
+	needsRehash, err = testBcryptHasher.Verify(legacy, "password123")
+	if err != nil || needsRehash {
+		t.Errorf("Verify(bcrypt, same cost) = (%v, %v), want (false, nil)", needsRehash, err)
+	}
+
+	// argon2id hash, hasher with stronger params
+	hash, _ := testArgon2Hasher.Hash("password123")
+	stronger := testArgon2Hasher
+	stronger.Argon2.Iterations = 2
+	needsRehash, err = stronger.Verify(hash, "password123")
+	if err != nil || !needsRehash {
+		t.Errorf("Verify(stronger params) = (%v, %v), want (true, nil)", needsRehash, err)
+	}
+
+	// wrong password never asks for a rehash
+	needsRehash, err = stronger.Verify(hash, "wrong")
+	if err == nil || needsRehash {
+		t.Errorf("Verify(wrong password) = (%v, %v), want (false, err)", needsRehash, err)
+	}
+}
+
+// test unknown formats and bad configs are rejected
+func TestPasswordHasherInvalid(t *testing.T) {
+	// unknown hash format (e.g. the users table default)
+	if _, err := testArgon2Hasher.Verify("unset", "password123"); err == nil {
+		t.Errorf("Verify accepted an unrecognised hash")
+	}
+
+	// malformed argon2id hash
+	if _, err := testArgon2Hasher.Verify("$argon2id$v=19$nope", "password123"); err == nil {
+		t.Errorf("Verify accepted a malformed argon2id hash")
+	}
+
+	// config validation
+	if err := (PasswordHasher{Algorithm: "md5"}).Validate(); err == nil {
+		t.Errorf("Validate accepted an unknown algorithm")
+	}
+	if err := (PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: 99}).Validate(); err == nil {
+		t.Errorf("Validate accepted an out of range bcrypt cost")
+	}
+	if err := testArgon2Hasher.Validate(); err != nil {
+		t.Errorf("Validate rejected a good config: %v", err)
+	}
+}
+
+// test hashes queue for a slot and give it back when done
+func TestPasswordHasherSlots(t *testing.T) {
+	hasher := testArgon2Hasher
+	hasher.Slots = NewHashSlots(1)
+
+	// the only slot is free, so hashing goes through and hands it back
+	hash, err := hasher.Hash("password123")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err) // fatal, don't continue
+	}
+	if len(hasher.Slots) != 0 {
+		t.Errorf("Hash kept its slot, %d in use", len(hasher.Slots))
+	}
+
+	// a held slot blocks Verify until it's released
+	hasher.Slots <- struct{}{}
+	done := make(chan error, 1)
+	go func() {
+		_, err := hasher.Verify(hash, "password123")
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatalf("Verify ran without a free slot") // fatal, don't continue
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-hasher.Slots
+	if err := <-done; err != nil {
+		t.Errorf("Verify failed after the slot freed up: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+// blobstore.go
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ERRORS
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// STRUCTS
+// what the store knows about a blob without reading it
+type Info struct {
+	Size    int64     // bytes
+	ModTime time.Time // when it was written, for conditional requests
+}
+
+// anything that can keep uploaded files, the local filesystem first, object storage later
+// keys are flat names like "0f8f...e1.jpg", see ValidKey
+type BlobStore interface {
+	// write a blob, replacing any blob with the same key
+	Put(ctx context.Context, key string, data io.Reader) error
+	// read a blob, the caller closes it
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error)
+	// remove a blob, removing a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// check a key is safe to hand to any store
+// lowercase letters, digits, dots, dashes and underscores only, so no paths or traversal
+func ValidKey(key string) bool {
+	// length check
+	if key == "" || len(key) > 128 {
+		return false
+	}
+
+	// hidden files and "." / ".." check
+	if strings.HasPrefix(key, ".") {
+		return false
+	}
+
+	// character check
+	for _, c := range key {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '.' && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+// filestore.go
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// keeps blobs as files in one directory, for a single server or a shared volume
+type FileStore struct {
+	Dir string // blob directory, created on first write
+}
+
+// new store in a directory
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+// write a blob, readers never see a half written file
+func (s *FileStore) Put(ctx context.Context, key string, data io.Reader) error {
+	// key check
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	// blob dir
+	err := os.MkdirAll(s.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	// write to a temp file next to the target
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	// copy check
+	_, err = io.Copy(tmp, data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	// media is public, anyone may read it
+	err = os.Chmod(tmp.Name(), 0o644)
+	if err != nil {
+		return err
+	}
+
+	// then swap it in
+	return os.Rename(tmp.Name(), filepath.Join(s.Dir, key))
+}
+
+// read a blob
+func (s *FileStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error) {
+	// key check
+	if !ValidKey(key) {
+		return nil, Info{}, ErrInvalidKey
+	}
+
+	// open check
+	f, err := os.Open(filepath.Join(s.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	// size and time for the response headers
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Info{}, err
+	}
+	return f, Info{Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// remove a blob
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	// key check
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	// already gone is fine
+	err := os.Remove(filepath.Join(s.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+// filestore_test.go
+
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing" // importing testing package for unit tests
+)
+
+// test a blob round trips and can be deleted
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir())
+
+	// write
+	err := store.Put(ctx, "abc.jpg", strings.NewReader("image bytes"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err) // fatal, don't continue
+	}
+
+	// read
+	f, info, err := store.Open(ctx, "abc.jpg")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err) // fatal, don't continue
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("read failed: %v", err) // fatal, don't continue
+	}
+	if string(data) != "image bytes" || info.Size != int64(len(data)) {
+		t.Errorf("Open = %q (size %d), want %q", data, info.Size, "image bytes")
+	}
+
+	// delete, twice is fine
+	for range 2 {
+		if err := store.Delete(ctx, "abc.jpg"); err != nil {
+			t.Errorf("Delete failed: %v", err)
+		}
+	}
+	if _, _, err := store.Open(ctx, "abc.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after delete err = %v, want ErrNotFound", err)
+	}
+}
+
+// test keys can't escape the store
+func TestValidKey(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		key      string
+		expected bool
+	}{
+		{"0f8f6c1e_thumb.jpg", true},
+		{"a-b.png", true},
+		{"", false},
+		{".hidden", false},
+		{"..", false},
+		{"../etc/passwd", false},
+		{"dir/file.jpg", false},
+		{"UPPER.jpg", false},
+		{strings.Repeat("a", 129), false},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		if actual := ValidKey(tc.key); actual != tc.expected {
+			t.Errorf("ValidKey(%q) = %v, want %v", tc.key, actual, tc.expected)
+		}
+	}
+
+	// the store refuses them too
+	store := NewFileStore(t.TempDir())
+	if err := store.Put(context.Background(), "../x", strings.NewReader("")); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put(../x) err = %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,261 @@
+// chirpimport.go
+package chirpimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// archive limits
+const (
+	MaxItems      = 5000     // most chirps one archive may hold
+	MaxEntryBytes = 16 << 20 // largest chirps.json an export may unpack to, zips compress far past the upload cap
+)
+
+// ERRORS
+var (
+	ErrUnknownFormat = errors.New("archive is not a chirpy export, json or csv")
+	ErrNoChirps      = errors.New("export has no chirps.json")
+	ErrTooManyItems  = fmt.Errorf("archive holds more than %d chirps", MaxItems)
+	ErrEntryTooLarge = fmt.Errorf("chirps.json unpacks to more than %d MiB", MaxEntryBytes>>20)
+)
+
+// timestamp layouts we read, the first is what our own export writes
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// STRUCTS
+// one chirp read from an archive
+type Item struct {
+	Line      int       // where it came from, the csv line or json list position (from 1)
+	Body      string    // as written, not yet moderated
+	CreatedAt time.Time // zero when the archive doesn't say
+}
+
+// one entry that couldn't be read
+type Failure struct {
+	Line   int
+	Reason string
+}
+
+// a json chirp, in our export's shape or the common alternatives
+type jsonChirp struct {
+	Body      *string `json:"body"`
+	Text      *string `json:"text"`
+	CreatedAt string  `json:"created_at"`
+	Timestamp string  `json:"timestamp"`
+}
+
+// PARSING
+// read the chirps from an archive, the format is worked out from the bytes
+// a chirpy export zip (its chirps.json), a json list of chirps or a csv with a header row
+func Parse(data []byte) ([]Item, []Failure, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return parseExport(data)
+	case bytes.HasPrefix(trimmed, []byte("[")), bytes.HasPrefix(trimmed, []byte("{")):
+		return parseJSON(trimmed)
+	case len(trimmed) > 0:
+		return parseCSV(trimmed)
+	default:
+		return nil, nil, ErrUnknownFormat
+	}
+}
+
+// our own export, only its chirps are imported
+func parseExport(data []byte) ([]Item, []Failure, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// find the chirps
+	for _, file := range archive.File {
+		if file.Name != "chirps.json" {
+			continue
+		}
+
+		// size check, the header is the archive's claim so refuse early on it
+		if file.UncompressedSize64 > MaxEntryBytes {
+			return nil, nil, ErrEntryTooLarge
+		}
+		entry, err := file.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer entry.Close()
+
+		// and never read past the cap, whatever the header said
+		chirps, err := io.ReadAll(io.LimitReader(entry, MaxEntryBytes+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(chirps) > MaxEntryBytes {
+			return nil, nil, ErrEntryTooLarge
+		}
+		return parseJSON(chirps)
+	}
+	return nil, nil, ErrNoChirps
+}
+
+// a json list of chirps, or an object holding one under "chirps"
+func parseJSON(data []byte) ([]Item, []Failure, error) {
+	// the list, decoded lazily so one bad chirp doesn't sink the rest
+	var list []json.RawMessage
+	if bytes.HasPrefix(data, []byte("{")) {
+		var wrapper struct {
+			Chirps []json.RawMessage `json:"chirps"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, nil, err
+		}
+		list = wrapper.Chirps
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, nil, err
+	}
+
+	// size check
+	if len(list) > MaxItems {
+		return nil, nil, ErrTooManyItems
+	}
+
+	// each chirp
+	var items []Item
+	var failures []Failure
+	for i, raw := range list {
+		line := i + 1
+
+		// shape check
+		var chirp jsonChirp
+		if err := json.Unmarshal(raw, &chirp); err != nil {
+			failures = append(failures, Failure{Line: line, Reason: "not a chirp object"})
+			continue
+		}
+
+		// body, under either name
+		body := chirp.Body
+		if body == nil {
+			body = chirp.Text
+		}
+		if body == nil {
+			failures = append(failures, Failure{Line: line, Reason: "missing body"})
+			continue
+		}
+
+		// timestamp, under either name
+		createdAt, err := parseTime(firstNonEmpty(chirp.CreatedAt, chirp.Timestamp))
+		if err != nil {
+			failures = append(failures, Failure{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		items = append(items, Item{Line: line, Body: *body, CreatedAt: createdAt})
+	}
+	return items, failures, nil
+}
+
+// a csv with a header row naming a body (or text) column and optionally created_at (or timestamp)
+func parseCSV(data []byte) ([]Item, []Failure, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1 // short rows are per-row failures, not fatal
+
+	// header check
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, ErrUnknownFormat
+	}
+	bodyCol, timeCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "body", "text":
+			bodyCol = i
+		case "created_at", "timestamp":
+			timeCol = i
+		}
+	}
+	if bodyCol < 0 {
+		return nil, nil, ErrUnknownFormat
+	}
+
+	// each row
+	var items []Item
+	var failures []Failure
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		// row check (quoting errors end the file, there's no telling where the next row starts)
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			failures = append(failures, Failure{Line: parseErr.StartLine, Reason: "malformed csv row"})
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		// size check
+		if len(items)+len(failures) >= MaxItems {
+			return nil, nil, ErrTooManyItems
+		}
+
+		// short row check
+		if bodyCol >= len(record) {
+			failures = append(failures, Failure{Line: line, Reason: "missing body"})
+			continue
+		}
+
+		// timestamp, when there's a column for it
+		var createdAt time.Time
+		if timeCol >= 0 && timeCol < len(record) {
+			createdAt, err = parseTime(record[timeCol])
+			if err != nil {
+				failures = append(failures, Failure{Line: line, Reason: err.Error()})
+				continue
+			}
+		}
+
+		items = append(items, Item{Line: line, Body: record[bodyCol], CreatedAt: createdAt})
+	}
+	return items, failures, nil
+}
+
+// HELPERS
+// read a timestamp in any layout we know, "" is the zero time
+func parseTime(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unreadable timestamp %q", raw)
+}
+
+// the first of the values that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
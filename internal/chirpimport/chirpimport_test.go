@@ -0,0 +1,151 @@
+// chirpimport_test.go
+
+package chirpimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+)
+
+// test our own export round trips, timestamps and all
+func TestParseExport(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	profile, _ := archive.Create("profile.json")
+	profile.Write([]byte(`{"email": "me@example.com"}`))
+	chirps, _ := archive.Create("chirps.json")
+	chirps.Write([]byte(`[{"id": "x", "body": "hello", "created_at": "2021-03-04T05:06:07Z", "attachments": []}]`))
+	archive.Close()
+
+	items, failures, err := Parse(buf.Bytes())
+	if err != nil || len(failures) != 0 {
+		t.Fatalf("Parse failed: %v %v", err, failures) // fatal, don't continue
+	}
+	want := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	if len(items) != 1 || items[0].Body != "hello" || !items[0].CreatedAt.Equal(want) {
+		t.Errorf("items = %+v, want hello at %s", items, want)
+	}
+}
+
+// test a generic json list reports bad entries and keeps the rest
+func TestParseJSON(t *testing.T) {
+	data := []byte(`[
+		{"text": "from elsewhere", "timestamp": "2020-01-02 03:04:05"},
+		{"created_at": "2020-01-02T03:04:05Z"},
+		{"body": "bad date", "created_at": "yesterday"},
+		"not an object",
+		{"body": "no date"}
+	]`)
+	items, failures, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err) // fatal, don't continue
+	}
+	if len(items) != 2 || items[0].Body != "from elsewhere" || !items[1].CreatedAt.IsZero() {
+		t.Errorf("items = %+v", items)
+	}
+	if len(failures) != 3 || failures[0].Line != 2 || failures[1].Line != 3 || failures[2].Line != 4 {
+		t.Errorf("failures = %+v, want lines 2, 3 and 4", failures)
+	}
+
+	// wrapped in an object works too
+	items, _, err = Parse([]byte(`{"chirps": [{"body": "wrapped"}]}`))
+	if err != nil || len(items) != 1 {
+		t.Errorf("wrapped list: items = %+v err = %v", items, err)
+	}
+}
+
+// test csv columns are found by name and rows report their line
+func TestParseCSV(t *testing.T) {
+	data := []byte("\ufeffcreated_at,Body\n" +
+		"2019-05-06,\"hello, world\"\n" +
+		"not a date,oops\n" +
+		"2019-05-07\n")
+	items, failures, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err) // fatal, don't continue
+	}
+	if len(items) != 1 || items[0].Body != "hello, world" || items[0].Line != 2 {
+		t.Errorf("items = %+v", items)
+	}
+	if len(failures) != 2 || failures[0].Line != 3 || failures[1].Line != 4 {
+		t.Errorf("failures = %+v, want lines 3 and 4", failures)
+	}
+}
+
+// test archives we can't read are refused outright
+func TestParseRejects(t *testing.T) {
+	cases := map[string]error{
+		"":                  ErrUnknownFormat,
+		"just some words\n": ErrUnknownFormat,
+		"[" + strings.Repeat(`{"body": "x"},`, MaxItems) + `{"body": "x"}]`: ErrTooManyItems,
+	}
+	for data, want := range cases {
+		if _, _, err := Parse([]byte(data)); !errors.Is(err, want) {
+			t.Errorf("Parse(%.20q) err = %v, want %v", data, err, want)
+		}
+	}
+}
+
+// an export whose chirps.json unpacks to size bytes, claiming claimed bytes in its header
+func exportUnpackingTo(t *testing.T, size, claimed uint64) []byte {
+	t.Helper()
+	data := bytes.Repeat([]byte(" "), int(size))
+
+	// deflate it ourselves, so the header can say whatever we like
+	var compressed bytes.Buffer
+	deflater, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	deflater.Write(data)
+	deflater.Close()
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	entry, err := archive.CreateRaw(&zip.FileHeader{
+		Name:               "chirps.json",
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: claimed,
+	})
+	if err != nil {
+		t.Fatalf("CreateRaw failed: %v", err) // fatal, don't continue
+	}
+	entry.Write(compressed.Bytes())
+	archive.Close()
+	return buf.Bytes()
+}
+
+// test a zip bomb is refused, whether or not its header owns up to the size
+func TestParseExportTooLarge(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		name        string // name for test case
+		data        []byte // input to func
+		expectedErr error  // error we want our func to return, nil for any error
+	}{ // }{ -- inits the test values for input vs expected
+		{
+			name:        "Test case: Honest Header",
+			data:        exportUnpackingTo(t, MaxEntryBytes+1, MaxEntryBytes+1),
+			expectedErr: ErrEntryTooLarge,
+		},
+		{
+			name: "Test case: Lying Header",
+			data: exportUnpackingTo(t, MaxEntryBytes+1, 2),
+		},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		_, _, err := Parse(tc.data)
+
+		// check result
+		if err == nil || (tc.expectedErr != nil && !errors.Is(err, tc.expectedErr)) {
+			t.Errorf("%s: Parse err = %v, want %v", tc.name, err, tc.expectedErr)
+		}
+	}
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -21,7 +22,7 @@ VALUES (
     $1,                -- gen code will input body
     $2                 -- gen code will input user_id
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, held_at
 `
 
 type CreateChirpParams struct {
@@ -41,6 +42,43 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.HeldAt,
+	)
+	return i, err
+}
+
+const createImportedChirp = `-- name: CreateImportedChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    $1,                -- insert original time
+    $1,                -- never edited since
+    $2,                -- insert moderated body
+    $3                 -- insert importing user id fk
+)
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, held_at
+`
+
+type CreateImportedChirpParams struct {
+	CreatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+}
+
+// add "one" chirp brought over from an archive, keeping its original time
+// the spam filter may still hold it
+func (q *Queries) CreateImportedChirp(ctx context.Context, arg CreateImportedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createImportedChirp, arg.CreatedAt, arg.Body, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.HeldAt,
 	)
 	return i, err
 }
@@ -48,7 +86,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 const deleteChirp = `-- name: DeleteChirp :one
 DELETE FROM chirps
 WHERE id = $1        -- matches chirp_id 
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, held_at
 `
 
 // delete chirp by id
@@ -62,12 +100,14 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.HeldAt,
 	)
 	return i, err
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at, held_at FROM chirps
 WHERE id = $1
 `
 
@@ -82,12 +122,14 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.HeldAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at, held_at FROM chirps
 ORDER BY created_at ASC
 `
 
@@ -108,6 +150,8 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.HeldAt,
 		); err != nil {
 			return nil, err
 		}
@@ -123,7 +167,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByAuthorID = `-- name: GetChirpsByAuthorID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at, held_at FROM chirps
 WHERE user_id = $1 -- our input
 ORDER BY created_at ASC
 `
@@ -146,6 +190,8 @@ func (q *Queries) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.HeldAt,
 		); err != nil {
 			return nil, err
 		}
@@ -160,6 +206,49 @@ func (q *Queries) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]
 	return items, nil
 }
 
+const getRecentChirpsForUser = `-- name: GetRecentChirpsForUser :many
+SELECT body, created_at FROM chirps
+WHERE user_id = $1
+  AND created_at > $2
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type GetRecentChirpsForUserParams struct {
+	UserID    uuid.UUID
+	Since     time.Time
+	MaxChirps int32
+}
+
+type GetRecentChirpsForUserRow struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+// a user's latest chirps since a time, newest first, for the spam filter
+func (q *Queries) GetRecentChirpsForUser(ctx context.Context, arg GetRecentChirpsForUserParams) ([]GetRecentChirpsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpsForUser, arg.UserID, arg.Since, arg.MaxChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRecentChirpsForUserRow
+	for rows.Next() {
+		var i GetRecentChirpsForUserRow
+		if err := rows.Scan(&i.Body, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserIDByChirpID = `-- name: GetUserIDByChirpID :one
 
 SELECT user_id FROM chirps
@@ -176,3 +265,67 @@ func (q *Queries) GetUserIDByChirpID(ctx context.Context, id uuid.UUID) (uuid.UU
 	err := row.Scan(&user_id)
 	return user_id, err
 }
+
+const hideChirp = `-- name: HideChirp :execrows
+UPDATE chirps
+SET
+  hidden_at = NOW(), -- hidden now
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+  AND hidden_at IS NULL
+`
+
+// hide a chirp from everyone but its author, hiding it again is a no-op
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hideChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const holdChirp = `-- name: HoldChirp :execrows
+UPDATE chirps
+SET
+  held_at = NOW(),   -- held now
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+  AND held_at IS NULL
+`
+
+// hold a chirp for review, only its author sees it until a moderator releases it
+func (q *Queries) HoldChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, holdChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const lockChirpAuthor = `-- name: LockChirpAuthor :exec
+SELECT pg_advisory_xact_lock(hashtext($1::uuid::text))
+`
+
+// take a user's chirping lock until the transaction ends, so their spam check and insert can't interleave
+func (q *Queries) LockChirpAuthor(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockChirpAuthor, userID)
+	return err
+}
+
+const releaseChirp = `-- name: ReleaseChirp :execrows
+UPDATE chirps
+SET
+  held_at = NULL,    -- review done
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+  AND held_at IS NOT NULL
+`
+
+// release a held chirp, 0 rows when it wasn't held so a moderator's hide stays put
+func (q *Queries) ReleaseChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, releaseChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -11,12 +11,138 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Name        string
+	TokenHash   string
+	TokenPrefix string
+	Scopes      []string
+	ExpiresAt   time.Time
+	LastUsedAt  sql.NullTime
+	RevokedAt   sql.NullTime
+}
+
+type Attachment struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	SizeBytes    int64
+	Width        int32
+	Height       int32
+	BlobKey      string
+	ThumbnailKey string
+}
+
+type Bookmark struct {
+	UserID       uuid.UUID
+	ChirpID      uuid.UUID
+	CreatedAt    time.Time
+	CollectionID uuid.NullUUID
+}
+
+type BookmarkCollection struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	HiddenAt  sql.NullTime
+	HeldAt    sql.NullTime
+}
+
+type EmailToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type LoginAttempt struct {
+	Key           string
+	UpdatedAt     time.Time
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	ReportID    uuid.UUID
+	ModeratorID uuid.NullUUID
+	Action      string
+	Notes       string
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	RedirectUris []string
+	Scopes       []string
+	SecretHash   sql.NullString
+}
+
+type OauthCode struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthRefreshToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	TokenHash string
+	GrantID   uuid.UUID
+	ClientID  uuid.UUID
+	UserID    uuid.UUID
+	Scopes    []string
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+type OidcLogin struct {
+	State        string
+	CreatedAt    time.Time
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	UserID       uuid.NullUUID
+	ExpiresAt    time.Time
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
 }
 
 type RefreshToken struct {
@@ -28,11 +154,124 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Kind       string
+	ReporterID uuid.NullUUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	ChirpBody  sql.NullString
+	Reason     string
+	Details    string
+	Status     string
+	ResolvedAt sql.NullTime
+}
+
+type RevokedAccessToken struct {
+	Jti       string
+	CreatedAt time.Time
+	UserID    uuid.NullUUID
+	ExpiresAt time.Time
+	Reason    string
+}
+
+type ShadowBan struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	ModeratorID uuid.NullUUID
+	Reason      string
+	EndsAt      time.Time
+	LiftedAt    sql.NullTime
+}
+
+type SubscriptionEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Event     string
+	Plan      string
+}
+
+type Suspension struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	ModeratorID uuid.NullUUID
+	Reason      string
+	EndsAt      time.Time
+	LiftedAt    sql.NullTime
+}
+
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Role            string
+	EmailVerifiedAt sql.NullTime
+	PendingEmail    sql.NullString
+	Handle          sql.NullString
+	DisplayName     string
+	Bio             string
+	AvatarUrl       sql.NullString
+	AvatarKey       sql.NullString
+	DeleteAfter     sql.NullTime
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserIdentity struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Provider    string
+	Subject     string
+	Email       string
+	LastLoginAt sql.NullTime
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Secret       string
+	ConfirmedAt  sql.NullTime
+	LastUsedStep sql.NullInt64
+}
+
+type WebauthnChallenge struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.NullUUID
+	Ceremony  string
+	Challenge string
+	ExpiresAt time.Time
+}
+
+type WebauthnCredential struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    int64
+	Name         string
+	LastUsedAt   sql.NullTime
 }
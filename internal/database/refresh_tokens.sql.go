@@ -71,6 +71,57 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 	return i, err
 }
 
+const listRefreshTokensForUser = `-- name: ListRefreshTokensForUser :many
+SELECT created_at, expires_at, revoked_at FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+type ListRefreshTokensForUserRow struct {
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+// a user's sessions for their data export, never the token itself
+func (q *Queries) ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]ListRefreshTokensForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listRefreshTokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRefreshTokensForUserRow
+	for rows.Next() {
+		var i ListRefreshTokensForUserRow
+		if err := rows.Scan(&i.CreatedAt, &i.ExpiresAt, &i.RevokedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAllRefreshTokensForUser = `-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL
+`
+
+// sign a user out everywhere (password reset or change)
+func (q *Queries) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllRefreshTokensForUser, userID)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens 
 SET 
@@ -7,11 +7,68 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :execrows
+UPDATE users
+SET
+  delete_after = NULL, -- keep the account
+  updated_at = NOW()   -- audit trail
+WHERE id = $1
+  AND delete_after IS NOT NULL
+`
+
+// stop a pending deletion, no rows when none was pending
+// by user id as input
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const confirmUserEmailChange = `-- name: ConfirmUserEmailChange :one
+UPDATE users
+SET
+  email = pending_email,     -- the confirmed address
+  pending_email = NULL,      -- nothing awaits confirmation
+  email_verified_at = NOW(), -- the link reached the new inbox
+  updated_at = NOW()         -- audit trail
+WHERE id = $1
+  AND pending_email IS NOT NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, email_verified_at, pending_email, handle, display_name, bio, avatar_url, avatar_key, delete_after
+`
+
+// swap in the confirmed address, it was just proven so it's verified too
+// by user id as input
+func (q *Queries) ConfirmUserEmailChange(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, confirmUserEmailChange, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.AvatarKey,
+		&i.DeleteAfter,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
@@ -22,7 +79,7 @@ VALUES (
     $1,                -- gen code will input email
     $2                 -- insert hashed pw via handler
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, email_verified_at, pending_email, handle, display_name, bio, avatar_url, avatar_key, delete_after
 `
 
 type CreateUserParams struct {
@@ -43,17 +100,83 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.AvatarKey,
+		&i.DeleteAfter,
 	)
 	return i, err
 }
 
+const createUserWithoutPassword = `-- name: CreateUserWithoutPassword :one
+INSERT INTO users (id, created_at, updated_at, email, email_verified_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    NOW(),             -- current time
+    $1,                -- insert email
+    $2                 -- "null" unless the provider vouched for the email
+)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, email_verified_at, pending_email, handle, display_name, bio, avatar_url, avatar_key, delete_after
+`
+
+type CreateUserWithoutPasswordParams struct {
+	Email           string
+	EmailVerifiedAt sql.NullTime
+}
+
+// add "one" user signing up through an identity provider, the password stays "unset"
+func (q *Queries) CreateUserWithoutPassword(ctx context.Context, arg CreateUserWithoutPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUserWithoutPassword, arg.Email, arg.EmailVerifiedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.AvatarKey,
+		&i.DeleteAfter,
+	)
+	return i, err
+}
+
+const deleteUserIfDue = `-- name: DeleteUserIfDue :execrows
+DELETE FROM users
+WHERE id = $1
+  AND delete_after <= NOW()
+`
+
+// delete an account whose grace period is over, no rows if it was cancelled meanwhile
+// chirps, tokens and everything else go with it (on delete cascade)
+func (q *Queries) DeleteUserIfDue(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserIfDue, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
-WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, email_verified_at, pending_email, handle, display_name, bio, avatar_url, avatar_key, delete_after FROM users
+WHERE lower(email) = lower($1)
 LIMIT 1
 `
 
-// select one user by email
+// select one user by email, ignoring case
 // by user email as input
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
 	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
@@ -65,12 +188,21 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.AvatarKey,
+		&i.DeleteAfter,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, email_verified_at, pending_email, handle, display_name, bio, avatar_url, avatar_key, delete_after FROM users
 WHERE id = $1
 LIMIT 1
 `
@@ -87,10 +219,93 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.AvatarKey,
+		&i.DeleteAfter,
 	)
 	return i, err
 }
 
+const getUsersDueForDeletion = `-- name: GetUsersDueForDeletion :many
+SELECT id, avatar_key FROM users
+WHERE delete_after <= NOW()
+`
+
+type GetUsersDueForDeletionRow struct {
+	ID        uuid.UUID
+	AvatarKey sql.NullString
+}
+
+// accounts whose grace period is over, with the avatar to clean up
+func (q *Queries) GetUsersDueForDeletion(ctx context.Context) ([]GetUsersDueForDeletionRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersDueForDeletion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersDueForDeletionRow
+	for rows.Next() {
+		var i GetUsersDueForDeletionRow
+		if err := rows.Scan(&i.ID, &i.AvatarKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :exec
+UPDATE users
+SET
+  email_verified_at = COALESCE(email_verified_at, NOW()), -- keep the first time
+  updated_at = NOW()                                      -- audit trail
+WHERE id = $1
+`
+
+// the user proved they read mail at their address
+// by user id as input
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markUserEmailVerified, id)
+	return err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :execrows
+UPDATE users
+SET
+  hashed_password = $1, -- new hash
+  updated_at = NOW()                  -- audit trail
+WHERE id = $2
+  AND hashed_password = $3
+`
+
+type RehashUserPasswordParams struct {
+	HashedPassword    string
+	ID                uuid.UUID
+	OldHashedPassword string
+}
+
+// swap in a fresh hash of the same password, only if the hash is still the one that was verified
+// 0 rows when the password was changed meanwhile, the newer password wins
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rehashUserPassword, arg.HashedPassword, arg.ID, arg.OldHashedPassword)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const resetUsers = `-- name: ResetUsers :exec
 DELETE FROM users
 `
@@ -101,6 +316,26 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :exec
+UPDATE users
+SET
+  delete_after = $2, -- end of the grace period
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+`
+
+type ScheduleUserDeletionParams struct {
+	ID          uuid.UUID
+	DeleteAfter sql.NullTime
+}
+
+// start the grace period, the account goes at delete_after unless cancelled
+// by user id as input
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleUserDeletion, arg.ID, arg.DeleteAfter)
+	return err
+}
+
 const setIsChirpyRedTrue = `-- name: SetIsChirpyRedTrue :one
 UPDATE users
 SET
@@ -124,11 +359,61 @@ func (q *Queries) SetIsChirpyRedTrue(ctx context.Context, id uuid.UUID) (SetIsCh
 	return i, err
 }
 
+const setUserPendingEmail = `-- name: SetUserPendingEmail :exec
+UPDATE users
+SET
+  pending_email = $2, -- requested address ("null" cancels)
+  updated_at = NOW()  -- audit trail
+WHERE id = $1
+`
+
+type SetUserPendingEmailParams struct {
+	ID           uuid.UUID
+	PendingEmail sql.NullString
+}
+
+// park a requested new address until the user confirms it
+// by user id as input
+func (q *Queries) SetUserPendingEmail(ctx context.Context, arg SetUserPendingEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPendingEmail, arg.ID, arg.PendingEmail)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET
+  role = $2,         -- new role
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+RETURNING id, role, updated_at
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+type SetUserRoleRow struct {
+	ID        uuid.UUID
+	Role      string
+	UpdatedAt time.Time
+}
+
+// set a user's role (user/moderator/admin)
+// by user id as input
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (SetUserRoleRow, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i SetUserRoleRow
+	err := row.Scan(&i.ID, &i.Role, &i.UpdatedAt)
+	return i, err
+}
+
 const updateUserLogin = `-- name: UpdateUserLogin :one
 UPDATE users 
 SET 
   updated_at = NOW(),  -- audit trail
-  email = $2, -- user provides new email
+  email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END, -- a new address must be verified again
+  email = $2, -- user provides new email (only ever a confirmed one)
   hashed_password = $3 -- user provides new password
 WHERE id = $1 -- use userid from token get bearer (unique as it's a pk) 
 RETURNING updated_at
@@ -147,3 +432,23 @@ func (q *Queries) UpdateUserLogin(ctx context.Context, arg UpdateUserLoginParams
 	err := row.Scan(&updated_at)
 	return updated_at, err
 }
+
+const updateUserPasswordHash = `-- name: UpdateUserPasswordHash :exec
+UPDATE users
+SET
+  hashed_password = $2, -- new hash
+  updated_at = NOW()    -- audit trail
+WHERE id = $1
+`
+
+type UpdateUserPasswordHashParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+// swap in a fresh hash of the same password (algorithm or cost upgrade)
+// by user id as input
+func (q *Queries) UpdateUserPasswordHash(ctx context.Context, arg UpdateUserPasswordHashParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPasswordHash, arg.ID, arg.HashedPassword)
+	return err
+}
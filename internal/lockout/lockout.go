@@ -0,0 +1,190 @@
+// lockout.go
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// STRUCTS
+// failed attempts recorded against one key (an account, an ip, ...)
+type Record struct {
+	Failures    int       // consecutive failures
+	LastFailure time.Time // when the latest failure happened
+	LockedUntil time.Time // zero unless locked out
+}
+
+// where records live, in-memory or postgres backed
+// Get returns a zero Record (and no error) for unknown keys
+// Increment must count atomically, parallel failures on one key can't undercount
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	Increment(ctx context.Context, key string, now, staleBefore time.Time) (Record, error) // one more failure, from 1 if quiet since staleBefore and not locked out
+	Lock(ctx context.Context, key string, until time.Time) error
+	Delete(ctx context.Context, key string) error
+	Prune(ctx context.Context, staleBefore, now time.Time) (int, error) // forget keys quiet since staleBefore and not locked out
+}
+
+// how quickly attempts are slowed down and locked out
+type Policy struct {
+	FreeAttempts     int           // failures allowed before any backoff
+	BaseDelay        time.Duration // first backoff delay, doubles per failure
+	MaxDelay         time.Duration // backoff cap
+	LockoutThreshold int           // failures that trigger a lockout
+	LockoutDuration  time.Duration // how long a lockout lasts
+	ResetAfter       time.Duration // quiet period after which failures are forgotten
+}
+
+// LIMITER
+// applies a policy to the records in a store
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time // swappable clock for tests
+}
+
+// create a limiter for a store and policy
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// how long until any of the keys may try again, zero means go ahead
+func (l *Limiter) Check(ctx context.Context, keys ...string) (time.Duration, error) {
+	now := l.now().UTC()
+	var wait time.Duration
+
+	// longest wait across all keys wins
+	for _, key := range keys {
+		rec, err := l.store.Get(ctx, key)
+
+		// get record check
+		if err != nil {
+			return 0, err // early return
+		}
+
+		wait = max(wait, l.waitFor(l.current(rec, now), now))
+	}
+
+	return wait, nil
+}
+
+// record a failed attempt against each key, returns the resulting wait
+func (l *Limiter) Fail(ctx context.Context, keys ...string) (time.Duration, error) {
+	now := l.now().UTC()
+	var wait time.Duration
+
+	// bump each key
+	for _, key := range keys {
+		rec, err := l.store.Increment(ctx, key, now, l.staleBefore(now))
+
+		// increment record check
+		if err != nil {
+			return 0, err // early return
+		}
+
+		// too many, lock it out
+		if l.policy.LockoutThreshold > 0 && rec.Failures >= l.policy.LockoutThreshold {
+			rec.LockedUntil = now.Add(l.policy.LockoutDuration)
+			err = l.store.Lock(ctx, key, rec.LockedUntil)
+
+			// lock record check
+			if err != nil {
+				return 0, err // early return
+			}
+		}
+
+		wait = max(wait, l.waitFor(rec, now))
+	}
+
+	return wait, nil
+}
+
+// forget the failures (and any lockout) for each key
+func (l *Limiter) Reset(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		err := l.store.Delete(ctx, key)
+
+		// delete record check
+		if err != nil {
+			return err // early return
+		}
+	}
+	return nil
+}
+
+// forget records quiet for longer than olderThan and not locked out, returns number dropped
+// stores can be shared by limiters, so pass the longest ResetAfter among them
+func (l *Limiter) Prune(ctx context.Context, olderThan time.Duration) (int, error) {
+	now := l.now().UTC()
+	return l.store.Prune(ctx, now.Add(-olderThan), now)
+}
+
+// HELPER FUNCS
+
+// failures before this are forgotten, zero time when they never are
+func (l *Limiter) staleBefore(now time.Time) time.Time {
+	if l.policy.ResetAfter <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-l.policy.ResetAfter)
+}
+
+// drop failures once the quiet period passes (but never mid lockout)
+func (l *Limiter) current(rec Record, now time.Time) Record {
+	// still locked out
+	if now.Before(rec.LockedUntil) {
+		return rec
+	}
+
+	// quiet for long enough, start afresh
+	if l.policy.ResetAfter > 0 && now.Sub(rec.LastFailure) > l.policy.ResetAfter {
+		return Record{}
+	}
+
+	// lockout served, failures start counting again from the threshold
+	if !rec.LockedUntil.IsZero() {
+		rec.LockedUntil = time.Time{}
+	}
+
+	return rec
+}
+
+// how long a record makes callers wait
+func (l *Limiter) waitFor(rec Record, now time.Time) time.Duration {
+	// lockout trumps backoff
+	if now.Before(rec.LockedUntil) {
+		return rec.LockedUntil.Sub(now)
+	}
+
+	// exponential backoff once the free attempts are used up
+	extra := rec.Failures - l.policy.FreeAttempts
+	if extra <= 0 {
+		return 0
+	}
+	delay := l.backoff(extra)
+
+	// wait what's left of the delay since the last failure
+	nextAllowed := rec.LastFailure.Add(delay)
+	if now.Before(nextAllowed) {
+		return nextAllowed.Sub(now)
+	}
+	return 0
+}
+
+// base delay doubled per extra failure, capped
+func (l *Limiter) backoff(extra int) time.Duration {
+	delay := l.policy.BaseDelay
+	for i := 1; i < extra; i++ {
+		delay *= 2
+
+		// cap check (also stops overflow)
+		if delay >= l.policy.MaxDelay {
+			return l.policy.MaxDelay
+		}
+	}
+	return min(delay, l.policy.MaxDelay)
+}
@@ -0,0 +1,183 @@
+// lockout_test.go
+
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing" // importing testing package for unit tests
+	"time"
+)
+
+// test policy, small numbers keep the cases readable
+var testPolicy = Policy{
+	FreeAttempts:     2,
+	BaseDelay:        time.Second,
+	MaxDelay:         8 * time.Second,
+	LockoutThreshold: 6,
+	LockoutDuration:  time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+// limiter on a memory store with a clock we control
+func newTestLimiter(now *time.Time) *Limiter {
+	limiter := NewLimiter(NewMemoryStore(), testPolicy)
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+// test exponential backoff after the free attempts
+func TestLimiterBackoff(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now)
+
+	// expected wait right after each failure
+	expected := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second}
+
+	// loop through failures
+	for i, want := range expected {
+		got, err := limiter.Fail(ctx, "account:bob")
+
+		// fail check
+		if err != nil {
+			t.Fatalf("Fail failed: %v", err) // fatal, don't continue
+		}
+
+		// wait check
+		if got != want {
+			t.Errorf("failure %d: wait = %s, want %s", i+1, got, want)
+		}
+
+		// check agrees with fail
+		check, _ := limiter.Check(ctx, "account:bob")
+		if check != want {
+			t.Errorf("failure %d: check = %s, want %s", i+1, check, want)
+		}
+	}
+
+	// waiting out the delay lets them try again
+	now = now.Add(4 * time.Second)
+	if wait, _ := limiter.Check(ctx, "account:bob"); wait != 0 {
+		t.Errorf("Check after backoff = %s, want 0", wait)
+	}
+}
+
+// test lockout after repeated failures, and reset
+func TestLimiterLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now)
+
+	// fail up to the threshold
+	var wait time.Duration
+	for i := 0; i < testPolicy.LockoutThreshold; i++ {
+		wait, _ = limiter.Fail(ctx, "account:bob")
+	}
+
+	// locked out for the full duration
+	if wait != testPolicy.LockoutDuration {
+		t.Errorf("wait at threshold = %s, want %s", wait, testPolicy.LockoutDuration)
+	}
+
+	// other keys are unaffected
+	if other, _ := limiter.Check(ctx, "account:alice"); other != 0 {
+		t.Errorf("unrelated key wait = %s, want 0", other)
+	}
+
+	// combined check takes the longest wait
+	if combined, _ := limiter.Check(ctx, "account:alice", "account:bob"); combined != testPolicy.LockoutDuration {
+		t.Errorf("combined wait = %s, want %s", combined, testPolicy.LockoutDuration)
+	}
+
+	// admin unlock
+	if err := limiter.Reset(ctx, "account:bob"); err != nil {
+		t.Fatalf("Reset failed: %v", err) // fatal, don't continue
+	}
+	if after, _ := limiter.Check(ctx, "account:bob"); after != 0 {
+		t.Errorf("wait after reset = %s, want 0", after)
+	}
+}
+
+// test failures are forgotten after a quiet period
+func TestLimiterResetAfter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now)
+
+	// use up the free attempts and more
+	for i := 0; i < 4; i++ {
+		limiter.Fail(ctx, "ip:1.2.3.4")
+	}
+
+	// go quiet for longer than the reset period
+	now = now.Add(testPolicy.ResetAfter + time.Second)
+
+	// next failure is a first failure again
+	wait, _ := limiter.Fail(ctx, "ip:1.2.3.4")
+	if wait != 0 {
+		t.Errorf("wait after quiet period = %s, want 0", wait)
+	}
+}
+
+// test backoff is capped
+func TestLimiterBackoffCap(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), testPolicy)
+
+	// far past the cap
+	if got := limiter.backoff(100); got != testPolicy.MaxDelay {
+		t.Errorf("backoff(100) = %s, want %s", got, testPolicy.MaxDelay)
+	}
+}
+
+// test parallel failures are all counted
+func TestLimiterFailConcurrent(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now)
+
+	// fail from many goroutines at once
+	var wg sync.WaitGroup
+	for i := 0; i < testPolicy.LockoutThreshold; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Fail(ctx, "account:bob")
+		}()
+	}
+	wg.Wait()
+
+	// every one of them counted, so the key is locked out
+	if wait, _ := limiter.Check(ctx, "account:bob"); wait != testPolicy.LockoutDuration {
+		t.Errorf("wait after parallel failures = %s, want %s", wait, testPolicy.LockoutDuration)
+	}
+}
+
+// test quiet keys are pruned, locked out ones are kept
+func TestLimiterPrune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, testPolicy)
+	limiter.now = func() time.Time { return now }
+
+	// one quiet key, one locked out for longer than the quiet period
+	limiter.Fail(ctx, "ip:1.2.3.4")
+	for i := 0; i < testPolicy.LockoutThreshold; i++ {
+		limiter.Fail(ctx, "account:bob")
+	}
+	store.Lock(ctx, "account:bob", now.Add(2*testPolicy.ResetAfter))
+
+	// after the quiet period only the quiet key goes
+	now = now.Add(testPolicy.ResetAfter + time.Second)
+	dropped, err := limiter.Prune(ctx, testPolicy.ResetAfter)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err) // fatal, don't continue
+	}
+	if dropped != 1 {
+		t.Errorf("Prune dropped %d, want 1", dropped)
+	}
+	if rec, _ := store.Get(ctx, "account:bob"); rec.Failures == 0 {
+		t.Errorf("Prune dropped a locked out key")
+	}
+}
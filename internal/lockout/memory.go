@@ -0,0 +1,77 @@
+// memory.go
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// in-memory store, safe for concurrent use, lost on restart
+type MemoryStore struct {
+	mu      sync.Mutex        // guards records
+	records map[string]Record // key -> record
+}
+
+// create an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]Record),
+	}
+}
+
+// get the record for a key, zero if none
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+// count one more failure for a key under the lock
+func (s *MemoryStore) Increment(ctx context.Context, key string, now, staleBefore time.Time) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := s.records[key]
+
+	// quiet for long enough and not locked out, start afresh
+	if rec.LastFailure.Before(staleBefore) && !now.Before(rec.LockedUntil) {
+		rec = Record{}
+	}
+
+	rec.Failures++
+	rec.LastFailure = now
+	s.records[key] = rec
+	return rec, nil
+}
+
+// lock a key out until a time
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := s.records[key]
+	rec.LockedUntil = until
+	s.records[key] = rec
+	return nil
+}
+
+// forget the record for a key
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// forget keys quiet since staleBefore and not locked out, returns number dropped
+func (s *MemoryStore) Prune(ctx context.Context, staleBefore, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := 0
+	for key, rec := range s.records {
+		if rec.LastFailure.Before(staleBefore) && !now.Before(rec.LockedUntil) {
+			delete(s.records, key)
+			dropped++
+		}
+	}
+	return dropped, nil
+}
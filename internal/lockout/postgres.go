@@ -0,0 +1,90 @@
+// postgres.go
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+)
+
+// postgres backed store, shared by every instance and survives restarts
+type PostgresStore struct {
+	db *database.Queries
+}
+
+// create a store on top of our sqlc queries
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// get the record for a key, zero if none
+func (s *PostgresStore) Get(ctx context.Context, key string) (Record, error) {
+	row, err := s.db.GetLoginAttempt(ctx, key)
+
+	// no record is a clean slate
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, nil
+	}
+
+	// get record check
+	if err != nil {
+		return Record{}, err
+	}
+
+	return recordFromRow(row), nil
+}
+
+// count one more failure for a key in a single upsert
+func (s *PostgresStore) Increment(ctx context.Context, key string, now, staleBefore time.Time) (Record, error) {
+	row, err := s.db.IncrementLoginAttempt(ctx, database.IncrementLoginAttemptParams{
+		Key:         key,
+		Now:         now,
+		StaleBefore: staleBefore,
+	})
+
+	// increment check
+	if err != nil {
+		return Record{}, err
+	}
+
+	return recordFromRow(row), nil
+}
+
+// lock a key out until a time
+func (s *PostgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.db.LockLoginAttempt(ctx, database.LockLoginAttemptParams{
+		Key:         key,
+		LockedUntil: sql.NullTime{Time: until, Valid: true},
+	})
+}
+
+// forget the record for a key
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	return s.db.DeleteLoginAttempt(ctx, key)
+}
+
+// forget keys quiet since staleBefore and not locked out, returns number dropped
+func (s *PostgresStore) Prune(ctx context.Context, staleBefore, now time.Time) (int, error) {
+	dropped, err := s.db.DeleteStaleLoginAttempts(ctx, database.DeleteStaleLoginAttemptsParams{
+		StaleBefore: staleBefore,
+		Now:         now,
+	})
+	return int(dropped), err
+}
+
+// HELPER FUNCS
+
+// a row as a record, the nullable lockout maps to the zero time
+func recordFromRow(row database.LoginAttempt) Record {
+	rec := Record{
+		Failures:    int(row.Failures),
+		LastFailure: row.LastFailureAt,
+	}
+	if row.LockedUntil.Valid {
+		rec.LockedUntil = row.LockedUntil.Time
+	}
+	return rec
+}
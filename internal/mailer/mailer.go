@@ -0,0 +1,44 @@
+// mailer.go
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// STRUCTS
+// one plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// anything that can deliver an email, smtp in production, an outbox in dev and tests
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// render a message as RFC 5322 text, what smtp sends and the file outbox writes
+func (msg Message) Bytes(from string, now time.Time) []byte {
+	// header injection check, a newline in a header would start a new one
+	clean := func(s string) string {
+		return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", clean(from))
+	fmt.Fprintf(&sb, "To: %s\r\n", clean(msg.To))
+	fmt.Fprintf(&sb, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", clean(msg.Subject)))
+	fmt.Fprintf(&sb, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	sb.WriteString("\r\n")
+
+	// body lines end in crlf on the wire
+	sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(sb.String())
+}
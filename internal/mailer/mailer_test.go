@@ -0,0 +1,78 @@
+// mailer_test.go
+
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+)
+
+// test message rendering
+func TestMessageBytes(t *testing.T) {
+	msg := Message{
+		To:      "bob@example.com",
+		Subject: "Verify your email",
+		Body:    "line one\nline two",
+	}
+	raw := string(msg.Bytes("noreply@chirpy.test", time.Unix(0, 0)))
+
+	for _, want := range []string{
+		"From: noreply@chirpy.test\r\n",
+		"To: bob@example.com\r\n",
+		"Subject: Verify your email\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("message is missing %q:\n%s", want, raw)
+		}
+	}
+}
+
+// test header injection is neutralised
+func TestMessageBytesHeaderInjection(t *testing.T) {
+	msg := Message{
+		To:      "bob@example.com\r\nBcc: eve@example.com",
+		Subject: "hi\nBcc: eve@example.com",
+	}
+	raw := string(msg.Bytes("noreply@chirpy.test", time.Unix(0, 0)))
+	if strings.Contains(raw, "\r\nBcc:") || strings.Contains(raw, "\nBcc:") {
+		t.Errorf("header injection got through:\n%s", raw)
+	}
+}
+
+// test the in-memory outbox
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	m.Send(context.Background(), Message{To: "a@example.com"})
+	m.Send(context.Background(), Message{To: "b@example.com"})
+
+	got := m.Messages()
+	if len(got) != 2 || got[0].To != "a@example.com" || got[1].To != "b@example.com" {
+		t.Errorf("unexpected messages: %+v", got)
+	}
+}
+
+// test the file outbox
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m := NewFileMailer(dir, "noreply@chirpy.test")
+
+	err := m.Send(context.Background(), Message{To: "bob@example.com", Subject: "Hello", Body: "hi"})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err) // fatal, don't continue
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("outbox has %d entries (%v), want 1", len(entries), err) // fatal, don't continue
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if !strings.Contains(string(raw), "To: bob@example.com") {
+		t.Errorf("unexpected outbox file:\n%s", raw)
+	}
+}
@@ -0,0 +1,71 @@
+// outbox.go
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// keeps sent messages in memory, for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// new empty in-memory outbox
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// "send" one message
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// every message sent so far, oldest first
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// writes each message to a .eml file, for local development
+type FileMailer struct {
+	Dir  string // outbox directory, created on first send
+	From string // header sender
+
+	mu  sync.Mutex
+	seq int
+}
+
+// new file outbox in a directory
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+// write one message
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// outbox dir
+	err := os.MkdirAll(m.Dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	// timestamped, sequenced names sort in send order
+	now := time.Now()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d.eml", now.UTC().Format("20060102T150405"), m.seq)
+
+	// owner only, these hold live tokens
+	return os.WriteFile(filepath.Join(m.Dir, name), msg.Bytes(m.From, now), 0o600)
+}
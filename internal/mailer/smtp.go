@@ -0,0 +1,38 @@
+// smtp.go
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// sends through an smtp server (STARTTLS is used when the server offers it)
+type SMTPMailer struct {
+	Addr     string // host:port, e.g. "smtp.example.com:587"
+	From     string // envelope and header sender
+	Username string // optional, PLAIN auth when set
+	Password string
+}
+
+// send one message
+// net/smtp has no context support, so ctx only guards the start
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	// cancelled check
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// PLAIN auth only when configured (net/smtp refuses it without tls, except on localhost)
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, msg.Bytes(m.From, time.Now()))
+}
@@ -0,0 +1,69 @@
+// avatar.go
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+)
+
+// square sizes every avatar is stored at, smallest first
+var AvatarSizes = []int{64, 256}
+
+// the stored avatar size to serve for a requested size, the smallest that's big enough
+func AvatarSize(requested int) int {
+	for _, size := range AvatarSizes {
+		if requested <= size {
+			return size
+		}
+	}
+	return AvatarSizes[len(AvatarSizes)-1]
+}
+
+// crop an upload to a centred square and scale it to each of AvatarSizes
+// always png, so transparent avatars stay transparent, animations keep their first frame
+func Avatar(data []byte) ([]Encoded, error) {
+	// type and size checks
+	contentType, err := check(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// upright, jpegs carry their rotation in exif
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	// centred square
+	square := squareCrop(img)
+
+	// one png per size
+	avatars := make([]Encoded, 0, len(AvatarSizes))
+	for _, size := range AvatarSizes {
+		avatar, err := encode(resize(square, size, size), "image/png", 0)
+		if err != nil {
+			return nil, err
+		}
+		avatars = append(avatars, avatar)
+	}
+	return avatars, nil
+}
+
+// the largest centred square of an image
+func squareCrop(img image.Image) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	// copy out the square, not every decoded type has SubImage
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, image.Pt(x0, y0), draw.Src)
+	return square
+}
@@ -0,0 +1,88 @@
+// gif.go
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// gif block introducers
+const (
+	gifExtension = 0x21
+	gifImage     = 0x2C
+	gifTrailer   = 0x3B
+)
+
+// the block structure is broken, the decoder would refuse it anyway
+var errGIFMalformed = errors.New("malformed gif")
+
+// count the frames of a gif and the pixels decoding all of them would allocate
+// only walks the block structure, the lzw data is skipped unread
+func gifFrames(data []byte) (frames, pixels int, err error) {
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, errGIFMalformed
+	}
+	pos := 13
+
+	// global colour table
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	// walk the blocks
+	for pos < len(data) {
+		switch data[pos] {
+		case gifTrailer:
+			return frames, pixels, nil
+
+		case gifExtension:
+			// introducer and label, then the data sub-blocks
+			pos, err = skipGIFSubBlocks(data, pos+2)
+
+		case gifImage:
+			// image descriptor, the frame's own size counts (frames can be smaller than the screen)
+			if pos+10 > len(data) {
+				return 0, 0, errGIFMalformed
+			}
+			width := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			height := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			packed := data[pos+9]
+			frames++
+			pixels += width * height
+			pos += 10
+
+			// local colour table
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+
+			// lzw minimum code size, then the pixel data sub-blocks
+			pos, err = skipGIFSubBlocks(data, pos+1)
+
+		default:
+			return 0, 0, errGIFMalformed
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// no trailer, the decoder will say whether that's fatal
+	return frames, pixels, nil
+}
+
+// skip a run of length prefixed sub-blocks, returns the position after the terminator
+func skipGIFSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errGIFMalformed
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+}
@@ -0,0 +1,62 @@
+// identicon.go
+package media
+
+import (
+	"crypto/sha256"
+	"image"
+	"image/color"
+)
+
+// identicon cells per side, mirrored around the middle column
+const identiconGrid = 5
+
+// identicon background, light grey so any foreground shows
+var identiconBackground = color.RGBA{R: 240, G: 240, B: 240, A: 255}
+
+// a symmetric 5x5 pattern in one colour, the same seed always draws the same picture
+func Identicon(seed []byte, size int) image.Image {
+	// spread the seed, uuids have fixed version bits
+	sum := sha256.Sum256(seed)
+
+	// mid-range colour so it's never near the background
+	fg := color.RGBA{R: 48 + sum[0]%160, G: 48 + sum[1]%160, B: 48 + sum[2]%160, A: 255}
+
+	// which cells are filled, the left half and middle column, mirrored
+	var filled [identiconGrid][identiconGrid]bool
+	half := (identiconGrid + 1) / 2
+	for row := range identiconGrid {
+		for col := range half {
+			bit := row*half + col
+			on := sum[3+bit/8]&(1<<(bit%8)) != 0
+			filled[row][col] = on
+			filled[row][identiconGrid-1-col] = on
+		}
+	}
+
+	// half a cell of margin on every side
+	cell := max(1, size/(identiconGrid+1))
+	margin := (size - cell*identiconGrid) / 2
+
+	// paint
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			c := identiconBackground
+			row, col := (y-margin)/cell, (x-margin)/cell
+			if x >= margin && y >= margin && row < identiconGrid && col < identiconGrid && filled[row][col] {
+				c = fg
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// an identicon ready to serve
+func IdenticonPNG(seed []byte, size int) ([]byte, error) {
+	encoded, err := encode(Identicon(seed, size), "image/png", 0)
+	if err != nil {
+		return nil, err
+	}
+	return encoded.Data, nil
+}
@@ -0,0 +1,269 @@
+// media.go
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// limits and output settings
+const (
+	MaxPixels        = 40_000_000 // decoded size cap, stops decompression bombs (summed over every gif frame)
+	MaxFrames        = 300        // gif animation frame cap
+	ThumbnailSize    = 320        // longest thumbnail side in pixels
+	jpegQuality      = 90         // re-encoded originals
+	thumbnailQuality = 80         // thumbnails are small anyway
+)
+
+// ERRORS
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+	ErrTooManyFrames   = errors.New("animation has too many frames")
+)
+
+// image types we accept, by sniffed content type, and their file extensions
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// STRUCTS
+// one encoded image
+type Encoded struct {
+	ContentType string
+	Ext         string // with the dot, for blob keys
+	Data        []byte
+}
+
+// an upload after processing, metadata stripped and ready to store
+type Processed struct {
+	Original  Encoded
+	Thumbnail Encoded
+	Width     int // of the original, after orientation is applied
+	Height    int
+}
+
+// HELPERS
+// sniff the content type from the bytes, never trust the client's header
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	return contentType, nil
+}
+
+// the content type for a blob's file extension, "" if we don't serve it
+func ContentTypeForExt(ext string) string {
+	for contentType, known := range extensions {
+		if known == ext {
+			return contentType
+		}
+	}
+	return ""
+}
+
+// PROCESSING
+// check, clean and thumbnail an upload
+// decoding and re-encoding drops exif, gps and every other metadata block
+func Process(data []byte) (Processed, error) {
+	// type and size checks
+	contentType, err := check(data)
+	if err != nil {
+		return Processed{}, err
+	}
+
+	switch contentType {
+	case "image/gif":
+		return processGIF(data)
+	case "image/png":
+		return processStill(data, contentType, 1)
+	default:
+		// jpegs carry their rotation in exif, bake it in before the exif goes
+		return processStill(data, contentType, jpegOrientation(data))
+	}
+}
+
+// sniff the type and check the dimensions before decoding a single pixel
+func check(data []byte) (string, error) {
+	// type check
+	contentType, err := Sniff(data)
+	if err != nil {
+		return "", err
+	}
+
+	// dimensions check
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return "", ErrTooManyPixels
+	}
+	return contentType, nil
+}
+
+// re-encode a jpeg or png
+func processStill(data []byte, contentType string, orientation int) (Processed, error) {
+	// decode
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, err
+	}
+
+	// upright
+	img = applyOrientation(img, orientation)
+
+	// re-encode the original
+	original, err := encode(img, contentType, jpegQuality)
+	if err != nil {
+		return Processed{}, err
+	}
+
+	// thumbnail in the same format, pngs keep their transparency
+	thumb, err := encode(Thumbnail(img, ThumbnailSize), contentType, thumbnailQuality)
+	if err != nil {
+		return Processed{}, err
+	}
+
+	bounds := img.Bounds()
+	return Processed{
+		Original:  original,
+		Thumbnail: thumb,
+		Width:     bounds.Dx(),
+		Height:    bounds.Dy(),
+	}, nil
+}
+
+// re-encode a gif, keeping every frame of an animation
+func processGIF(data []byte) (Processed, error) {
+	// the screen size passed check, but every frame is decoded, so cap them all before decoding any
+	frames, pixels, err := gifFrames(data)
+	if err != nil {
+		return Processed{}, err
+	}
+	if frames > MaxFrames {
+		return Processed{}, ErrTooManyFrames
+	}
+	if pixels > MaxPixels {
+		return Processed{}, ErrTooManyPixels
+	}
+
+	// decode all frames
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, err
+	}
+
+	// re-encode (comments and application extensions are dropped)
+	var buf bytes.Buffer
+	err = gif.EncodeAll(&buf, anim)
+	if err != nil {
+		return Processed{}, err
+	}
+
+	// thumbnail the first frame as a still png
+	first := image.NewRGBA(image.Rect(0, 0, anim.Config.Width, anim.Config.Height))
+	draw.Draw(first, anim.Image[0].Bounds(), anim.Image[0], anim.Image[0].Bounds().Min, draw.Over)
+	thumb, err := encode(Thumbnail(first, ThumbnailSize), "image/png", 0)
+	if err != nil {
+		return Processed{}, err
+	}
+
+	return Processed{
+		Original:  Encoded{ContentType: "image/gif", Ext: ".gif", Data: buf.Bytes()},
+		Thumbnail: thumb,
+		Width:     anim.Config.Width,
+		Height:    anim.Config.Height,
+	}, nil
+}
+
+// encode an image as jpeg or png
+func encode(img image.Image, contentType string, quality int) (Encoded, error) {
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	default:
+		contentType = "image/png"
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return Encoded{}, err
+	}
+	return Encoded{ContentType: contentType, Ext: extensions[contentType], Data: buf.Bytes()}, nil
+}
+
+// THUMBNAILS
+// shrink an image to fit in a size x size box, keeping its aspect ratio
+// images are never enlarged
+func Thumbnail(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	// already small enough
+	if srcW <= size && srcH <= size {
+		return src
+	}
+
+	// fit the longest side
+	dstW, dstH := size, size
+	if srcW > srcH {
+		dstH = max(1, srcH*size/srcW)
+	} else {
+		dstW = max(1, srcW*size/srcH)
+	}
+	return resize(src, dstW, dstH)
+}
+
+// scale an image to exactly dstW x dstH
+// each output pixel averages the source pixels it covers, so enlarging repeats pixels
+func resize(src image.Image, dstW, dstH int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	// flat rgba copy so pixel reads are cheap
+	rgba := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	// box filter
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := range dstH {
+		y0, y1 := y*srcH/dstH, max((y+1)*srcH/dstH, y*srcH/dstH+1)
+		for x := range dstW {
+			x0, x1 := x*srcW/dstW, max((x+1)*srcW/dstW, x*srcW/dstW+1)
+
+			// sum the covered block
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			// average it
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
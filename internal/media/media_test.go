@@ -0,0 +1,334 @@
+// media_test.go
+
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing" // importing testing package for unit tests
+)
+
+// solid test image
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// a jpeg with an exif segment holding an orientation
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err) // fatal, don't continue
+	}
+	raw := buf.Bytes()
+
+	// little endian tiff, one ifd entry
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry[0:], exifOrientationTag)
+	binary.LittleEndian.PutUint16(entry[2:], 3) // short
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0) // no next ifd
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	// app1 straight after soi
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	out := append([]byte{}, raw[:2]...)
+	out = append(out, segment...)
+	return append(out, raw[2:]...)
+}
+
+// test sniffing ignores anything but real images
+func TestSniff(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(2, 2))
+	if got, err := Sniff(buf.Bytes()); err != nil || got != "image/png" {
+		t.Errorf("Sniff(png) = %q, %v", got, err)
+	}
+	if _, err := Sniff([]byte("<html><script>alert(1)</script>")); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Sniff(html) err = %v, want ErrUnsupportedType", err)
+	}
+}
+
+// test jpegs come back upright, without exif, with a thumbnail
+func TestProcessJPEG(t *testing.T) {
+	data := jpegWithOrientation(t, testImage(800, 400), 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation = %d, want 6", got) // fatal, don't continue
+	}
+
+	out, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err) // fatal, don't continue
+	}
+
+	// a quarter turn swaps the sides
+	if out.Width != 400 || out.Height != 800 {
+		t.Errorf("size = %dx%d, want 400x800", out.Width, out.Height)
+	}
+
+	// exif is gone
+	if bytes.Contains(out.Original.Data, []byte("Exif")) {
+		t.Errorf("original still carries exif")
+	}
+	if jpegOrientation(out.Original.Data) != 1 {
+		t.Errorf("original still carries an orientation")
+	}
+
+	// thumbnail fits the box
+	thumb, err := jpeg.Decode(bytes.NewReader(out.Thumbnail.Data))
+	if err != nil {
+		t.Fatalf("thumbnail decode failed: %v", err) // fatal, don't continue
+	}
+	if b := thumb.Bounds(); b.Dx() != 160 || b.Dy() != ThumbnailSize {
+		t.Errorf("thumbnail = %dx%d, want 160x%d", b.Dx(), b.Dy(), ThumbnailSize)
+	}
+	if out.Original.Ext != ".jpg" || out.Thumbnail.ContentType != "image/jpeg" {
+		t.Errorf("unexpected types %+v %+v", out.Original.ContentType, out.Thumbnail.ContentType)
+	}
+}
+
+// test animated gifs keep their frames and get a png thumbnail
+func TestProcessGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for range 3 {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 400, 200), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("gif.EncodeAll failed: %v", err) // fatal, don't continue
+	}
+
+	out, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Process failed: %v", err) // fatal, don't continue
+	}
+	again, err := gif.DecodeAll(bytes.NewReader(out.Original.Data))
+	if err != nil || len(again.Image) != 3 {
+		t.Errorf("re-encoded gif has %v frames (err %v), want 3", len(again.Image), err)
+	}
+	if out.Thumbnail.ContentType != "image/png" {
+		t.Errorf("thumbnail type = %q, want image/png", out.Thumbnail.ContentType)
+	}
+}
+
+// a gif whose frames only claim a size, the pixel data is a stub the decoder would reject
+func gifClaiming(frames, w, h int) []byte {
+	// header, screen the size of a frame, no global colour table
+	data := []byte("GIF89a\x00\x00\x00\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint16(data[6:], uint16(w))
+	binary.LittleEndian.PutUint16(data[8:], uint16(h))
+	for range frames {
+		// graphic control extension, like a real animation
+		data = append(data, 0x21, 0xF9, 0x04, 0x00, 0x0A, 0x00, 0x00, 0x00)
+
+		// image descriptor, local colour table of 2, then a stub of lzw data
+		descriptor := []byte{0x2C, 0, 0, 0, 0, 0, 0, 0, 0, 0x80}
+		binary.LittleEndian.PutUint16(descriptor[5:], uint16(w))
+		binary.LittleEndian.PutUint16(descriptor[7:], uint16(h))
+		data = append(data, descriptor...)
+		data = append(data, 0, 0, 0, 0xFF, 0xFF, 0xFF)
+		data = append(data, 0x02, 0x02, 0x4C, 0x01, 0x00)
+	}
+	return append(data, 0x3B)
+}
+
+// test the gif walk counts frames and their pixels without decoding
+func TestGIFFrames(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		name           string // name for test case
+		data           []byte // input to func
+		expectedFrames int    // frames we want our func to count
+		expectedPixels int    // pixels we want our func to count
+		expectErr      bool   // false if err == nil, true if err != nil
+	}{ // }{ -- inits the test values for input vs expected
+		{
+			name:           "Test case: Single Frame",
+			data:           gifClaiming(1, 10, 20),
+			expectedFrames: 1,
+			expectedPixels: 200,
+		},
+		{
+			name:           "Test case: Many Frames",
+			data:           gifClaiming(3, 1000, 1000),
+			expectedFrames: 3,
+			expectedPixels: 3_000_000,
+		},
+		{
+			name:      "Test case: Truncated",
+			data:      gifClaiming(1, 10, 10)[:20],
+			expectErr: true,
+		},
+		{
+			name:      "Test case: Unknown Block",
+			data:      append([]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00"), 0x99),
+			expectErr: true,
+		},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		frames, pixels, err := gifFrames(tc.data)
+
+		// check if err bool matches the expected err
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%s: error = %v, expectErr %v", tc.name, err, tc.expectErr)
+		}
+
+		// check the counts
+		if frames != tc.expectedFrames || pixels != tc.expectedPixels {
+			t.Errorf("%s: got %d frames %d pixels, want %d frames %d pixels", tc.name, frames, pixels, tc.expectedFrames, tc.expectedPixels)
+		}
+	}
+}
+
+// test long or huge animations are refused before decoding any frame
+func TestProcessGIFBomb(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		name        string // name for test case
+		data        []byte // input to func
+		expectedErr error  // error we want our func to return
+	}{ // }{ -- inits the test values for input vs expected
+		{
+			name:        "Test case: Too Many Frames",
+			data:        gifClaiming(MaxFrames+1, 1, 1),
+			expectedErr: ErrTooManyFrames,
+		},
+		{
+			name:        "Test case: Too Many Pixels Across Frames",
+			data:        gifClaiming(25, 2000, 1000),
+			expectedErr: ErrTooManyPixels,
+		},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		_, err := Process(tc.data)
+
+		// check result
+		if !errors.Is(err, tc.expectedErr) {
+			t.Errorf("%s: Process err = %v, want %v", tc.name, err, tc.expectedErr)
+		}
+	}
+}
+
+// test oversized dimensions are refused before decoding
+func TestProcessTooManyPixels(t *testing.T) {
+	// a header claiming 10000x10000, no pixel data needed
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(1, 1))
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 10000)
+	binary.BigEndian.PutUint32(data[20:], 10000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29])) // ihdr checksum
+
+	if _, err := Process(data); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Process err = %v, want ErrTooManyPixels", err)
+	}
+}
+
+// test orientations map pixels to the right place
+func TestApplyOrientation(t *testing.T) {
+	// 2x1, red on the left
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{R: 255, A: 255})
+	src.Set(1, 0, color.RGBA{B: 255, A: 255})
+
+	// quarter turn clockwise puts red on top
+	got := applyOrientation(src, 6)
+	if b := got.Bounds(); b.Dx() != 1 || b.Dy() != 2 {
+		t.Fatalf("size = %dx%d, want 1x2", b.Dx(), b.Dy()) // fatal, don't continue
+	}
+	if r, _, _, _ := got.At(0, 0).RGBA(); r == 0 {
+		t.Errorf("orientation 6 didn't put red on top")
+	}
+
+	// mirrored puts red on the right
+	got = applyOrientation(src, 2)
+	if r, _, _, _ := got.At(1, 0).RGBA(); r == 0 {
+		t.Errorf("orientation 2 didn't put red on the right")
+	}
+}
+
+// test small images are left alone
+func TestThumbnailNoUpscale(t *testing.T) {
+	src := testImage(100, 50)
+	if got := Thumbnail(src, ThumbnailSize); got.Bounds().Dx() != 100 {
+		t.Errorf("thumbnail width = %d, want 100", got.Bounds().Dx())
+	}
+}
+
+// test avatars come out square at every size
+func TestAvatar(t *testing.T) {
+	data := jpegWithOrientation(t, testImage(300, 100), 1)
+	avatars, err := Avatar(data)
+	if err != nil {
+		t.Fatalf("Avatar failed: %v", err) // fatal, don't continue
+	}
+	if len(avatars) != len(AvatarSizes) {
+		t.Fatalf("got %d avatars, want %d", len(avatars), len(AvatarSizes)) // fatal, don't continue
+	}
+	for i, avatar := range avatars {
+		img, err := png.Decode(bytes.NewReader(avatar.Data))
+		if err != nil {
+			t.Fatalf("avatar decode failed: %v", err) // fatal, don't continue
+		}
+		if b := img.Bounds(); b.Dx() != AvatarSizes[i] || b.Dy() != AvatarSizes[i] {
+			t.Errorf("avatar %d = %dx%d, want %dx%d", i, b.Dx(), b.Dy(), AvatarSizes[i], AvatarSizes[i])
+		}
+	}
+}
+
+// test requested sizes snap to a stored one
+func TestAvatarSize(t *testing.T) {
+	cases := map[int]int{0: 64, 32: 64, 64: 64, 65: 256, 256: 256, 4096: 256}
+	for requested, want := range cases {
+		if got := AvatarSize(requested); got != want {
+			t.Errorf("AvatarSize(%d) = %d, want %d", requested, got, want)
+		}
+	}
+}
+
+// test identicons are deterministic, mirrored and differ between seeds
+func TestIdenticon(t *testing.T) {
+	a, _ := IdenticonPNG([]byte("alice"), 64)
+	again, _ := IdenticonPNG([]byte("alice"), 64)
+	b, _ := IdenticonPNG([]byte("bob"), 64)
+	if !bytes.Equal(a, again) {
+		t.Errorf("same seed drew different identicons")
+	}
+	if bytes.Equal(a, b) {
+		t.Errorf("different seeds drew the same identicon")
+	}
+
+	// left and right halves mirror each other
+	img := Identicon([]byte("alice"), 60)
+	for y := range 60 {
+		for x := range 30 {
+			if img.At(x, y) != img.At(59-x, y) {
+				t.Fatalf("identicon isn't mirrored at %d,%d", x, y) // fatal, don't continue
+			}
+		}
+	}
+}
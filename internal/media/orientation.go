@@ -0,0 +1,139 @@
+// orientation.go
+package media
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// exif orientation tag in the first ifd
+const exifOrientationTag = 0x0112
+
+// read the exif orientation (1-8) of a jpeg, 1 (as stored) when there is none
+// only walks the marker segments before the image data, never the pixels
+func jpegOrientation(data []byte) int {
+	// start of image check
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// walk the segments
+	pos := 2
+	for pos+4 <= len(data) {
+		// every segment starts with a marker
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+
+		// start of scan, the metadata is behind us
+		if marker == 0xDA {
+			return 1
+		}
+
+		// segment length includes its own two bytes
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+
+		// app1 exif segment
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+
+		pos += 2 + length
+	}
+	return 1
+}
+
+// find the orientation entry in a tiff header's first ifd
+func tiffOrientation(tiff []byte) int {
+	// byte order check
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	// first ifd
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+
+	// 12 byte entries: tag, type, count, value
+	for i := range count {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) != exifOrientationTag {
+			continue
+		}
+
+		// a short stored in the first two value bytes
+		orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+		if orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+	return 1
+}
+
+// turn and flip an image so it displays upright without its exif
+func applyOrientation(src image.Image, orientation int) image.Image {
+	// as stored
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	// flat rgba copy so pixel reads are cheap
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	// 5 to 8 swap width and height
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	// for every output pixel, which source pixel lands there
+	for y := range dstH {
+		for x := range dstW {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				sx, sy = x, h-1-y
+			case 5: // mirrored, on its side
+				sx, sy = y, x
+			case 6: // needs a quarter turn clockwise
+				sx, sy = y, h-1-x
+			case 7: // mirrored, on its other side
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs a quarter turn anticlockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], rgba.Pix[rgba.PixOffset(sx, sy):rgba.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,78 @@
+// jwks.go
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// a provider's published signing keys
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// one key, only the members we read
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`   // rsa modulus
+	E   string `json:"e,omitempty"`   // rsa exponent
+	Crv string `json:"crv,omitempty"` // ec curve
+	X   string `json:"x,omitempty"`   // ec x coordinate
+	Y   string `json:"y,omitempty"`   // ec y coordinate
+}
+
+// the usable signing keys by kid, anything we can't use is skipped
+func (set jsonWebKeySet) publicKeys() map[string]any {
+	keys := make(map[string]any)
+	for _, jwk := range set.Keys {
+		// encryption keys are not for us
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key := jwk.publicKey(); key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys
+}
+
+// the key as a crypto public key, nil if unsupported or malformed
+func (jwk jsonWebKey) publicKey() any {
+	switch jwk.Kty {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if errX != nil || errY != nil {
+			return nil
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		// reject points off the curve
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil
+		}
+		return key
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// mock_test.go
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// a local openid provider for tests, issues codes and signs id tokens with one rsa key
+type mockProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	kid      string
+	clientID string
+	secret   string
+
+	mu     sync.Mutex
+	codes  map[string]mockGrant // issued codes by value
+	claim  func(*Claims)        // optional tweak to the next id tokens
+	issuer string               // optional issuer to advertise instead of the server url
+}
+
+// what a code was issued for
+type mockGrant struct {
+	subject       string
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+}
+
+// starts a mock provider, closed when the test ends
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err) // fatal, don't continue
+	}
+	m := &mockProvider{
+		key:      key,
+		kid:      "test-key",
+		clientID: "chirpy-client",
+		secret:   "chirpy-secret",
+		codes:    make(map[string]mockGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.handleDiscovery)
+	mux.HandleFunc("GET /jwks", m.handleJWKS)
+	mux.HandleFunc("POST /token", m.handleToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// a provider config pointing at the mock
+func (m *mockProvider) config() Config {
+	return Config{
+		Name:         "mock",
+		Issuer:       m.server.URL,
+		ClientID:     m.clientID,
+		ClientSecret: m.secret,
+		RedirectURL:  "http://localhost:8080/api/oidc/mock/callback",
+		Scopes:       []string{"email"},
+	}
+}
+
+// stands in for the user approving at the authorization endpoint
+func (m *mockProvider) authorize(t *testing.T, authURL, subject string) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err) // fatal, don't continue
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+	}
+	code = base64.RawURLEncoding.EncodeToString([]byte(subject + q.Get("state")))
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		subject:       subject,
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		redirectURI:   q.Get("redirect_uri"),
+	}
+	m.mu.Unlock()
+	return code, q.Get("state")
+}
+
+// signs an id token
+func (m *mockProvider) idToken(claims Claims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = m.kid
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (m *mockProvider) handleDiscovery(w http.ResponseWriter, req *http.Request) {
+	issuer := m.server.URL
+	if m.issuer != "" {
+		issuer = m.issuer
+	}
+	json.NewEncoder(w).Encode(Metadata{
+		Issuer:                issuer,
+		AuthorizationEndpoint: m.server.URL + "/authorize",
+		TokenEndpoint:         m.server.URL + "/token",
+		JWKSURI:               m.server.URL + "/jwks",
+	})
+}
+
+func (m *mockProvider) handleJWKS(w http.ResponseWriter, req *http.Request) {
+	json.NewEncoder(w).Encode(jsonWebKeySet{Keys: []jsonWebKey{{
+		Kty: "RSA",
+		Kid: m.kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+func (m *mockProvider) handleToken(w http.ResponseWriter, req *http.Request) {
+	// client authentication
+	id, secret, ok := req.BasicAuth()
+	if !ok || id != m.clientID || secret != m.secret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// codes are single use
+	m.mu.Lock()
+	grant, found := m.codes[req.FormValue("code")]
+	delete(m.codes, req.FormValue("code"))
+	tweak := m.claim
+	m.mu.Unlock()
+
+	// grant checks, including pkce
+	if !found || req.FormValue("grant_type") != "authorization_code" ||
+		req.FormValue("redirect_uri") != grant.redirectURI ||
+		CodeChallenge(req.FormValue("code_verifier")) != grant.codeChallenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.server.URL,
+			Subject:   grant.subject,
+			Audience:  jwt.ClaimStrings{m.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+		Nonce:         grant.nonce,
+		Email:         grant.subject + "@example.com",
+		EmailVerified: true,
+	}
+	if tweak != nil {
+		tweak(&claims)
+	}
+
+	json.NewEncoder(w).Encode(Token{
+		AccessToken: "access-" + grant.subject,
+		TokenType:   "Bearer",
+		IDToken:     m.idToken(claims),
+		ExpiresIn:   300,
+	})
+}
@@ -0,0 +1,359 @@
+// oidc.go
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// STRUCTS
+// one identity provider, as configured by us
+type Config struct {
+	Name         string   // our name for it, used in urls, e.g. "google"
+	Issuer       string   // the provider's issuer url, e.g. "https://accounts.google.com"
+	ClientID     string   // our client id at the provider
+	ClientSecret string   // our client secret at the provider
+	RedirectURL  string   // where the provider sends the user back to
+	Scopes       []string // scopes to ask for, "openid" is always added
+}
+
+// what the provider publishes at /.well-known/openid-configuration
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// the token endpoint's answer
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// the id token claims we use
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce           string    `json:"nonce"`
+	AuthorizedParty string    `json:"azp,omitempty"`
+	Email           string    `json:"email,omitempty"`
+	EmailVerified   claimBool `json:"email_verified,omitempty"`
+	Name            string    `json:"name,omitempty"`
+}
+
+// a relying party for one provider, discovery and keys are fetched lazily and cached
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	metadata  *Metadata
+	keys      map[string]any // by kid
+	keysFetch time.Time      // when keys were last fetched
+}
+
+// how long a token's times may be off by (clock skew between us and the provider)
+const clockSkew = time.Minute
+
+// shortest time between key refetches for an unknown kid
+const keysRefetchInterval = time.Minute
+
+// id token signing algorithms we accept
+var signingMethods = []string{"RS256", "ES256"}
+
+// creates a provider, a nil client uses one with a sane timeout
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	// default client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	// always ask for openid
+	scopes := []string{"openid"}
+	for _, scope := range cfg.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	cfg.Scopes = scopes
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+
+	return &Provider{config: cfg, client: client}
+}
+
+// our name for the provider
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// RANDOM VALUES
+// a fresh state, nonce or pkce verifier (32 random bytes, base64url)
+func NewRandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// the S256 pkce challenge for a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// FLOW
+// the url to send the user to, carrying our state, nonce and pkce challenge
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	// find the endpoint
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// build the query
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	// keep any query the provider's endpoint already has
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + query.Encode(), nil
+}
+
+// swaps an authorization code for tokens
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (Token, error) {
+	// find the endpoint
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+
+	// build the form
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	// client_secret_basic, both parts form encoded first (rfc 6749 2.3.1)
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	// send it
+	var token Token
+	if err := p.doJSON(req, &token); err != nil {
+		return Token{}, fmt.Errorf("token exchange: %w", err)
+	}
+
+	// openid providers must return an id token
+	if token.IDToken == "" {
+		return Token{}, errors.New("token exchange: no id_token in response")
+	}
+
+	return token, nil
+}
+
+// verifies an id token's signature, issuer, audience, times and nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+	// find the issuer
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	// parse and validate
+	claims := Claims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods(signingMethods), // reject alg swapping and "none"
+		jwt.WithIssuer(meta.Issuer),          // must be the provider
+		jwt.WithAudience(p.config.ClientID),  // must be meant for us
+		jwt.WithExpirationRequired(),         // exp must be present (and valid)
+		jwt.WithIssuedAt(),                   // iat must not be in the future
+		jwt.WithLeeway(clockSkew),            // allow for clock drift
+	)
+
+	// validation check
+	if err != nil {
+		return Claims{}, fmt.Errorf("id token: %w", err)
+	}
+
+	// with several audiences, we must be the authorized party
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return Claims{}, errors.New("id token: authorized party is not this client")
+	}
+
+	// nonce must match the one we sent (replay protection)
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return Claims{}, errors.New("id token: nonce mismatch")
+	}
+
+	// subject presence check
+	if claims.Subject == "" {
+		return Claims{}, errors.New("id token: missing subject")
+	}
+
+	return claims, nil
+}
+
+// HELPER FUNCS
+
+// fetches (once) the provider's discovery document
+func (p *Provider) discover(ctx context.Context) (Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// cached check
+	if p.metadata != nil {
+		return *p.metadata, nil
+	}
+
+	// fetch it
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return Metadata{}, err
+	}
+	var meta Metadata
+	if err := p.doJSON(req, &meta); err != nil {
+		return Metadata{}, fmt.Errorf("discovery: %w", err)
+	}
+
+	// the document must be about the issuer we configured
+	if strings.TrimRight(meta.Issuer, "/") != p.config.Issuer {
+		return Metadata{}, fmt.Errorf("discovery: issuer %q does not match %q", meta.Issuer, p.config.Issuer)
+	}
+
+	// endpoints check
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return Metadata{}, errors.New("discovery: missing endpoints")
+	}
+
+	p.metadata = &meta
+	return meta, nil
+}
+
+// finds the signing key for a kid, refetching the key set when it's unknown
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	// find the key set
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// cached check
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	// the provider may have rotated, but don't let bad tokens hammer it
+	if !p.keysFetch.IsZero() && time.Since(p.keysFetch) < keysRefetchInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	// fetch the key set
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set jsonWebKeySet
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	p.keys = set.publicKeys()
+	p.keysFetch = time.Now()
+
+	// second look
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// looks a key up by kid, with no kid only a single key set is unambiguous
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" {
+		if len(p.keys) != 1 {
+			return nil, false
+		}
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// sends a request and decodes a json answer, non 2xx is an error
+func (p *Provider) doJSON(req *http.Request, dst any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// cap what we read from a third party
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	// status check
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.Unmarshal(body, dst)
+}
+
+// email_verified as a bool, some providers send the string "true"
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null", "":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean claim %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// oidc_test.go
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// runs the flow up to the id token, returning what the callback would verify
+func startFlow(t *testing.T, m *mockProvider, p *Provider, subject string) (token Token, nonce string) {
+	t.Helper()
+	ctx := context.Background()
+	state, _ := NewRandomString()
+	nonce, _ = NewRandomString()
+	verifier, _ := NewRandomString()
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err) // fatal, don't continue
+	}
+	code, gotState := m.authorize(t, authURL, subject)
+	if gotState != state {
+		t.Fatalf("state = %q, want %q", gotState, state)
+	}
+
+	token, err = p.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err) // fatal, don't continue
+	}
+	return token, nonce
+}
+
+// test the whole code flow against the mock provider
+func TestCodeFlow(t *testing.T) {
+	m := newMockProvider(t)
+	p := NewProvider(m.config(), nil)
+
+	token, nonce := startFlow(t, m, p, "alice")
+	claims, err := p.VerifyIDToken(context.Background(), token.IDToken, nonce)
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err) // fatal, don't continue
+	}
+	if claims.Subject != "alice" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+		t.Errorf("claims = %+v, want alice with a verified email", claims)
+	}
+}
+
+// test the auth url carries what the provider needs
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockProvider(t)
+	p := NewProvider(m.config(), nil)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "st", "no", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err) // fatal, don't continue
+	}
+	for _, want := range []string{"response_type=code", "client_id=chirpy-client", "scope=openid+email", "state=st", "nonce=no", "code_challenge=" + CodeChallenge("verifier")} {
+		if !strings.Contains(authURL, want) {
+			t.Errorf("auth url %q missing %q", authURL, want)
+		}
+	}
+}
+
+// test pkce is enforced by the exchange
+func TestExchangeWrongVerifier(t *testing.T) {
+	m := newMockProvider(t)
+	p := NewProvider(m.config(), nil)
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "right-verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err) // fatal, don't continue
+	}
+	code, _ := m.authorize(t, authURL, "bob")
+	if _, err := p.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Errorf("Exchange accepted the wrong code verifier")
+	}
+}
+
+// test id token rejections
+func TestVerifyIDTokenRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		tweak func(*Claims)
+		nonce string // "" uses the flow's nonce
+	}{
+		{name: "wrong nonce", nonce: "someone-elses-nonce"},
+		{name: "wrong audience", tweak: func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-client"} }},
+		{name: "wrong issuer", tweak: func(c *Claims) { c.Issuer = "https://evil.example" }},
+		{name: "expired", tweak: func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }},
+		{name: "missing expiry", tweak: func(c *Claims) { c.ExpiresAt = nil }},
+		{name: "other authorized party", tweak: func(c *Claims) {
+			c.Audience = jwt.ClaimStrings{"chirpy-client", "other-client"}
+			c.AuthorizedParty = "other-client"
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMockProvider(t)
+			m.claim = tc.tweak
+			p := NewProvider(m.config(), nil)
+
+			token, nonce := startFlow(t, m, p, "carol")
+			if tc.nonce != "" {
+				nonce = tc.nonce
+			}
+			if _, err := p.VerifyIDToken(context.Background(), token.IDToken, nonce); err == nil {
+				t.Errorf("VerifyIDToken accepted a token with %s", tc.name)
+			}
+		})
+	}
+}
+
+// test tokens signed by someone else are rejected
+func TestVerifyIDTokenForeignKey(t *testing.T) {
+	m := newMockProvider(t)
+	other := newMockProvider(t)
+	p := NewProvider(m.config(), nil)
+
+	// same claims, other key, same kid
+	forged := other.idToken(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.server.URL,
+			Subject:   "mallory",
+			Audience:  jwt.ClaimStrings{m.clientID},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Nonce: "nonce",
+	})
+	if _, err := p.VerifyIDToken(context.Background(), forged, "nonce"); err == nil {
+		t.Errorf("VerifyIDToken accepted a token signed by another key")
+	}
+}
+
+// test the issuer in discovery must be the configured one
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	m.issuer = "https://evil.example"
+	p := NewProvider(m.config(), nil)
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "v"); err == nil {
+		t.Errorf("AuthCodeURL accepted a discovery document for another issuer")
+	}
+}
+
+// test email_verified accepts the string form
+func TestClaimBool(t *testing.T) {
+	for raw, want := range map[string]bool{`true`: true, `"true"`: true, `false`: false, `"false"`: false} {
+		var c Claims
+		if err := json.Unmarshal([]byte(`{"email_verified":`+raw+`}`), &c); err != nil {
+			t.Fatalf("unmarshal %s: %v", raw, err) // fatal, don't continue
+		}
+		if bool(c.EmailVerified) != want {
+			t.Errorf("email_verified %s = %v, want %v", raw, c.EmailVerified, want)
+		}
+	}
+}
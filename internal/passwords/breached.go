@@ -0,0 +1,111 @@
+// breached.go
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// BREACHED PASSWORD INDEX
+// compact index of breached passwords, 8 bytes per entry
+// keeps only the first 64 bits of each SHA-1, sorted for binary search;
+// a false positive needs a 64 bit collision, so it never happens in practice
+type BreachedIndex struct {
+	prefixes []uint64 // sorted, de-duplicated sha1 prefixes
+}
+
+// load an index from a corpus file
+func LoadBreachedIndex(path string) (*BreachedIndex, error) {
+	file, err := os.Open(path)
+
+	// open check
+	if err != nil {
+		return nil, err // early return
+	}
+
+	// close on exit to prevent leak
+	defer file.Close()
+
+	return NewBreachedIndex(file)
+}
+
+// build an index from a corpus, one entry per line, either:
+//   - a SHA-1 hex digest, optionally with a ":count" suffix (HIBP range format)
+//   - a plaintext password
+//
+// blank lines and lines starting with # are skipped
+func NewBreachedIndex(r io.Reader) (*BreachedIndex, error) {
+	var prefixes []uint64
+
+	// read line by line, corpora can be large
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// skip blanks and comments
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// digest lines are stored as is, anything else is hashed
+		if prefix, ok := digestPrefix(line); ok {
+			prefixes = append(prefixes, prefix)
+		} else {
+			prefixes = append(prefixes, passwordPrefix(line))
+		}
+	}
+
+	// scan check
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// sort for binary search, drop duplicates
+	slices.Sort(prefixes)
+	prefixes = slices.Compact(prefixes)
+
+	return &BreachedIndex{prefixes: slices.Clip(prefixes)}, nil
+}
+
+// check if a password is in the corpus
+func (b *BreachedIndex) Contains(password string) bool {
+	_, found := slices.BinarySearch(b.prefixes, passwordPrefix(password))
+	return found
+}
+
+// number of distinct entries
+func (b *BreachedIndex) Len() int {
+	return len(b.prefixes)
+}
+
+// HELPER FUNCS
+
+// first 64 bits of a password's sha1
+func passwordPrefix(password string) uint64 {
+	sum := sha1.Sum([]byte(password))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// parse "HEX" or "HEX:count" into the first 64 bits of the digest
+func digestPrefix(line string) (uint64, bool) {
+	digest, _, _ := strings.Cut(line, ":")
+
+	// sha1 is 40 hex chars
+	if len(digest) != 2*sha1.Size {
+		return 0, false
+	}
+
+	// hex check
+	raw, err := hex.DecodeString(digest)
+	if err != nil {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint64(raw[:8]), true
+}
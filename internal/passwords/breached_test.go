@@ -0,0 +1,51 @@
+// breached_test.go
+
+package passwords
+
+import (
+	"strings"
+	"testing" // importing testing package for unit tests
+)
+
+// test building and querying the breached index
+func TestBreachedIndex(t *testing.T) {
+	// mixed corpus: comment, plaintext, bare digest, HIBP digest with count, duplicate
+	corpus := strings.Join([]string{
+		"# top passwords",
+		"password",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8",          // sha1("password"), duplicate of the line above
+		"7C4A8D09CA3762AF61E59520943DC26494F8941B:24230577", // sha1("123456")
+		"",
+		"letmein\r",
+	}, "\n")
+
+	// build it
+	index, err := NewBreachedIndex(strings.NewReader(corpus))
+	if err != nil {
+		t.Fatalf("NewBreachedIndex failed: %v", err) // fatal, don't continue
+	}
+
+	// duplicates collapse
+	if index.Len() != 3 {
+		t.Errorf("Len = %d, want 3", index.Len())
+	}
+
+	// build test cases
+	testCases := []struct {
+		password string
+		expected bool
+	}{
+		{"password", true},
+		{"123456", true},
+		{"letmein", true},
+		{"Password", false},
+		{"correct horse battery staple", false},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		if actual := index.Contains(tc.password); actual != tc.expected {
+			t.Errorf("Contains(%q) = %v, want %v", tc.password, actual, tc.expected)
+		}
+	}
+}
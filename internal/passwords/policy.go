@@ -0,0 +1,120 @@
+// policy.go
+package passwords
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// field error codes, stable for clients to switch on
+const (
+	CodeRequired = "required"  // nothing given
+	CodeTooShort = "too_short" // under the minimum length
+	CodeTooLong  = "too_long"  // over the maximum length
+	CodeIsEmail  = "is_email"  // same as (part of) the email
+	CodeBreached = "breached"  // seen in a known breach
+)
+
+// STRUCTS
+// one problem with one request field
+type FieldError struct {
+	Field   string // request field, e.g. "password"
+	Code    string // machine readable, one of the Code consts
+	Message string // human readable
+}
+
+// what a new password must satisfy
+type Policy struct {
+	MinLength     int            // minimum characters
+	MaxLength     int            // maximum characters, 0 for no limit
+	MaxBytes      int            // maximum bytes, 0 for no limit (bcrypt needs 72)
+	DisallowEmail bool           // refuse the email or its local part
+	Breached      *BreachedIndex // optional breached password corpus
+}
+
+// sensible defaults, no breach corpus
+var DefaultPolicy = Policy{
+	MinLength:     8,
+	MaxLength:     128,
+	DisallowEmail: true,
+}
+
+// check a password for a user with this email, nil means it's acceptable
+func (p Policy) Check(password, email string) []FieldError {
+	var problems []FieldError
+
+	// handle empty password
+	if password == "" {
+		return []FieldError{{Field: "password", Code: CodeRequired, Message: "Password is required"}}
+	}
+
+	// length in characters, not bytes
+	length := utf8.RuneCountInString(password)
+
+	// too short check
+	if length < p.MinLength {
+		problems = append(problems, FieldError{
+			Field:   "password",
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+
+	// too long check (characters, then bytes)
+	if (p.MaxLength > 0 && length > p.MaxLength) || (p.MaxBytes > 0 && len(password) > p.MaxBytes) {
+		problems = append(problems, FieldError{
+			Field:   "password",
+			Code:    CodeTooLong,
+			Message: p.tooLongMessage(),
+		})
+	}
+
+	// email as password check
+	if p.DisallowEmail && matchesEmail(password, email) {
+		problems = append(problems, FieldError{
+			Field:   "password",
+			Code:    CodeIsEmail,
+			Message: "Password must not be your email address",
+		})
+	}
+
+	// breached corpus check
+	if p.Breached != nil && p.Breached.Contains(password) {
+		problems = append(problems, FieldError{
+			Field:   "password",
+			Code:    CodeBreached,
+			Message: "Password has appeared in a data breach, please choose another",
+		})
+	}
+
+	return problems
+}
+
+// HELPER FUNCS
+
+// message for the tightest length limit
+func (p Policy) tooLongMessage() string {
+	if p.MaxBytes > 0 && (p.MaxLength == 0 || p.MaxBytes < p.MaxLength) {
+		return fmt.Sprintf("Password must be at most %d bytes", p.MaxBytes)
+	}
+	return fmt.Sprintf("Password must be at most %d characters", p.MaxLength)
+}
+
+// check if a password is the email, or just its local part
+func matchesEmail(password, email string) bool {
+	// no email, nothing to match
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return false
+	}
+
+	// whole address
+	if strings.EqualFold(password, email) {
+		return true
+	}
+
+	// local part (before the @), ignoring trivially short ones
+	local, _, found := strings.Cut(email, "@")
+	return found && len(local) >= 3 && strings.EqualFold(password, local)
+}
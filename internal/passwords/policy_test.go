@@ -0,0 +1,70 @@
+// policy_test.go
+
+package passwords
+
+import (
+	"strings"
+	"testing" // importing testing package for unit tests
+)
+
+// test the password policy checks
+func TestPolicyCheck(t *testing.T) {
+	// policy with a tiny breach corpus
+	breached, _ := NewBreachedIndex(strings.NewReader("hunter2hunter2\n"))
+	policy := Policy{
+		MinLength:     8,
+		MaxLength:     30,
+		DisallowEmail: true,
+		Breached:      breached,
+	}
+
+	// build test cases
+	testCases := []struct {
+		name     string
+		password string
+		email    string
+		expected []string // codes
+	}{
+		{"Test case: Good Password", "correct horse battery", "bob@example.com", nil},
+		{"Test case: Empty", "", "bob@example.com", []string{CodeRequired}},
+		{"Test case: Too Short", "short", "bob@example.com", []string{CodeTooShort}},
+		{"Test case: Too Long", strings.Repeat("x", 31), "bob@example.com", []string{CodeTooLong}},
+		{"Test case: Multibyte Counts Characters", strings.Repeat("é", 8), "bob@example.com", nil},
+		{"Test case: Email As Password", "Bobby@Example.com", "bobby@example.com", []string{CodeIsEmail}},
+		{"Test case: Local Part As Password", "roberta1", "roberta1@example.com", []string{CodeIsEmail}},
+		{"Test case: Breached", "hunter2hunter2", "bob@example.com", []string{CodeBreached}},
+		{"Test case: Short And Email", "bob@x.io", "bob@x.io", []string{CodeIsEmail}},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		problems := policy.Check(tc.password, tc.email)
+
+		// collect the codes
+		var codes []string
+		for _, problem := range problems {
+			codes = append(codes, problem.Code)
+		}
+
+		// check codes match
+		if strings.Join(codes, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%s: codes = %v, want %v", tc.name, codes, tc.expected)
+		}
+	}
+}
+
+// test the byte limit for bcrypt
+func TestPolicyMaxBytes(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 128, MaxBytes: 72}
+
+	// 40 characters but 80 bytes
+	problems := policy.Check(strings.Repeat("é", 40), "")
+
+	// too long check
+	if len(problems) != 1 || problems[0].Code != CodeTooLong {
+		t.Fatalf("problems = %+v, want one %s", problems, CodeTooLong) // fatal, don't continue
+	}
+	if !strings.Contains(problems[0].Message, "72 bytes") {
+		t.Errorf("message = %q, want it to mention 72 bytes", problems[0].Message)
+	}
+}
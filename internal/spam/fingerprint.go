@@ -0,0 +1,75 @@
+// fingerprint.go
+package spam
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// NEAR DUPLICATE FINGERPRINTS
+// 64 bit simhash of a chirp's words and word pairs: similar chirps get fingerprints
+// a few bits apart, so a bot swapping a word or a number still looks like itself
+func Fingerprint(body string) uint64 {
+	words := normalizedWords(body)
+
+	// nothing to hash
+	if len(words) == 0 {
+		return 0
+	}
+
+	// every word and every pair of neighbouring words votes on every bit
+	var votes [64]int
+	vote := func(feature string) {
+		hash := fnv.New64a()
+		hash.Write([]byte(feature))
+		sum := hash.Sum64()
+		for bit := range votes {
+			if sum&(1<<bit) != 0 {
+				votes[bit]++
+			} else {
+				votes[bit]--
+			}
+		}
+	}
+	for i, word := range words {
+		vote(word)
+		if i > 0 {
+			vote(words[i-1] + " " + word)
+		}
+	}
+
+	// bits with more yes than no votes are set
+	var fingerprint uint64
+	for bit, count := range votes {
+		if count > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// how many bits two fingerprints differ by, 0 for the same words in the same order
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// HELPER FUNCS
+
+// lowercase words with punctuation dropped and digits folded to 0,
+// so counters and ids don't make copies look different
+func normalizedWords(body string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, field := range fields {
+		fields[i] = strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return '0'
+			}
+			return r
+		}, field)
+	}
+	return fields
+}
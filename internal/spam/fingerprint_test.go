@@ -0,0 +1,45 @@
+// fingerprint_test.go
+
+package spam
+
+import (
+	"testing" // importing testing package for unit tests
+)
+
+// test copies land close together and different chirps far apart
+func TestFingerprintDistance(t *testing.T) {
+	base := "Buy cheap followers now at the best price, limited offer for everyone today only"
+
+	// build test cases
+	testCases := []struct {
+		name    string
+		body    string
+		maxDist int // inclusive
+		minDist int // inclusive
+	}{
+		{"Test case: Same Words", "buy CHEAP followers now at the best price limited offer for everyone today only!!", 0, 0},
+		{"Test case: Counter Changed", "Buy cheap followers now at the best price, limited offer for everyone today only 1", 10, 0},
+		{"Test case: Word Swapped", "Buy cheap followers now at the lowest price, limited offer for everyone today only", 10, 1},
+		{"Test case: Unrelated", "I had a lovely walk in the park with my dog this morning, the weather was great", 64, 16},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Distance(Fingerprint(base), Fingerprint(tc.body))
+			if got < tc.minDist || got > tc.maxDist {
+				t.Errorf("Distance() = %d, want %d to %d", got, tc.minDist, tc.maxDist)
+			}
+		})
+	}
+}
+
+// test digits are folded, so counters don't change the fingerprint
+func TestFingerprintFoldsDigits(t *testing.T) {
+	if Fingerprint("order 1234 now") != Fingerprint("order 9876 now") {
+		t.Error("Fingerprint() differs by digits only")
+	}
+	if Fingerprint("") != 0 || Fingerprint("!!!") != 0 {
+		t.Error("Fingerprint() of no words should be 0")
+	}
+}
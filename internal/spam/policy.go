@@ -0,0 +1,183 @@
+// policy.go
+package spam
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// what to do with a chirp
+const (
+	OutcomeAllow  = "allow"  // post it
+	OutcomeHold   = "hold"   // post it hidden until a moderator has looked
+	OutcomeReject = "reject" // refuse it
+)
+
+// signal codes, stable for logs and reports
+const (
+	SignalDuplicate     = "duplicate"      // an exact copy of the author's recent chirps
+	SignalNearDuplicate = "near_duplicate" // a slightly changed copy of the author's recent chirps
+	SignalLinks         = "links"          // too many links, or too little besides them
+	SignalMentions      = "mentions"       // too many @mentions
+	SignalNewAccount    = "new_account"    // the account is only just made
+	SignalRate          = "rate"           // a new account posting too fast
+)
+
+// how much each signal adds to a chirp's score
+var signalWeights = map[string]int{
+	SignalDuplicate:     4,
+	SignalNearDuplicate: 2,
+	SignalLinks:         1,
+	SignalMentions:      2,
+	SignalNewAccount:    1,
+}
+
+// links and mentions as they appear in a chirp body
+var (
+	linkPattern    = regexp.MustCompile(`(?i)^(https?://|www\.)\S+`)
+	mentionPattern = regexp.MustCompile(`^@[A-Za-z0-9_]+`)
+)
+
+// STRUCTS
+// thresholds for the spam checks
+type Policy struct {
+	DuplicateDistance int           // fingerprints this many bits apart or closer are near duplicates
+	DuplicateWindow   time.Duration // how far back the author's chirps are compared
+	MaxDuplicates     int           // copies allowed in the window before it counts
+	MaxLinks          int           // links allowed in one chirp
+	MaxLinkPercent    int           // share of words that may be links, 0 to 100
+	MaxMentions       int           // @mentions allowed in one chirp
+	NewAccountAge     time.Duration // accounts younger than this are new
+	NewAccountChirps  int           // chirps a new account may post per NewAccountWindow, 0 for no limit
+	NewAccountWindow  time.Duration // the new account rate limit window
+	HoldScore         int           // score at which a chirp is held for review, 0 never holds
+	RejectScore       int           // score at which a chirp is refused, 0 never refuses
+}
+
+// sensible defaults, an exact repeat is refused and anything else suspicious is held
+var DefaultPolicy = Policy{
+	DuplicateDistance: 10,
+	DuplicateWindow:   24 * time.Hour,
+	MaxDuplicates:     0,
+	MaxLinks:          3,
+	MaxLinkPercent:    50,
+	MaxMentions:       5,
+	NewAccountAge:     24 * time.Hour,
+	NewAccountChirps:  10,
+	NewAccountWindow:  time.Hour,
+	HoldScore:         2,
+	RejectScore:       4,
+}
+
+// one of the author's earlier chirps
+type Post struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+// who is chirping
+type Author struct {
+	CreatedAt time.Time // when the account was made
+	Recent    []Post    // their chirps going back at least Lookback, any order
+}
+
+// the result of checking one chirp
+type Verdict struct {
+	Outcome    string        // one of the Outcome consts
+	Score      int           // sum of the signal weights
+	Signals    []string      // codes of what tripped, in check order
+	RetryAfter time.Duration // set when a new account is posting too fast
+}
+
+// how far back the author's chirps must go for Check
+func (p Policy) Lookback() time.Duration {
+	return max(p.DuplicateWindow, p.NewAccountWindow)
+}
+
+// check a new chirp by an author
+func (p Policy) Check(now time.Time, body string, author Author) Verdict {
+	var verdict Verdict
+	isNew := now.Sub(author.CreatedAt) < p.NewAccountAge
+
+	// new accounts are rate limited before anything else, retry once enough chirps leave the window
+	if isNew && p.NewAccountChirps > 0 {
+		var inWindow []time.Time
+		for _, post := range author.Recent {
+			if now.Sub(post.CreatedAt) < p.NewAccountWindow {
+				inWindow = append(inWindow, post.CreatedAt)
+			}
+		}
+		if len(inWindow) >= p.NewAccountChirps {
+			// newest first, room opens up when the limit-th newest drops out
+			slices.SortFunc(inWindow, func(a, b time.Time) int { return b.Compare(a) })
+			return Verdict{
+				Outcome:    OutcomeReject,
+				Signals:    []string{SignalRate},
+				RetryAfter: inWindow[p.NewAccountChirps-1].Add(p.NewAccountWindow).Sub(now),
+			}
+		}
+	}
+
+	// copies of the author's recent chirps, chirps without words (emoji only, say) are only compared exactly
+	fingerprint := Fingerprint(body)
+	exact, near := 0, 0
+	for _, post := range author.Recent {
+		if now.Sub(post.CreatedAt) >= p.DuplicateWindow {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(post.Body), strings.TrimSpace(body)) {
+			exact++
+		} else if fingerprint != 0 && Distance(fingerprint, Fingerprint(post.Body)) <= p.DuplicateDistance {
+			near++
+		}
+	}
+	if exact > 0 && exact+near > p.MaxDuplicates {
+		verdict.flag(SignalDuplicate)
+	} else if exact+near > p.MaxDuplicates {
+		verdict.flag(SignalNearDuplicate)
+	}
+
+	// links and mentions
+	words := strings.Fields(body)
+	links, mentions := 0, 0
+	for _, word := range words {
+		if linkPattern.MatchString(word) {
+			links++
+		}
+		if mentionPattern.MatchString(word) {
+			mentions++
+		}
+	}
+	if links > p.MaxLinks || (links > 0 && links*100 > len(words)*p.MaxLinkPercent) {
+		verdict.flag(SignalLinks)
+	}
+	if mentions > p.MaxMentions {
+		verdict.flag(SignalMentions)
+	}
+
+	// new accounts are given less benefit of the doubt, but only alongside something else
+	if isNew && len(verdict.Signals) > 0 {
+		verdict.flag(SignalNewAccount)
+	}
+
+	// score to outcome
+	switch {
+	case p.RejectScore > 0 && verdict.Score >= p.RejectScore:
+		verdict.Outcome = OutcomeReject
+	case p.HoldScore > 0 && verdict.Score >= p.HoldScore:
+		verdict.Outcome = OutcomeHold
+	default:
+		verdict.Outcome = OutcomeAllow
+	}
+	return verdict
+}
+
+// HELPER FUNCS
+
+// record a tripped signal and add its weight
+func (v *Verdict) flag(signal string) {
+	v.Signals = append(v.Signals, signal)
+	v.Score += signalWeights[signal]
+}
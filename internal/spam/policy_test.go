@@ -0,0 +1,117 @@
+// policy_test.go
+
+package spam
+
+import (
+	"slices"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+)
+
+// test the spam policy checks
+func TestPolicyCheck(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := DefaultPolicy
+
+	// an established account with a couple of chirps
+	spammy := "Buy cheap followers now at the best price, limited offer for everyone today only"
+	established := Author{
+		CreatedAt: now.Add(-30 * 24 * time.Hour),
+		Recent: []Post{
+			{Body: spammy, CreatedAt: now.Add(-time.Hour)},
+			{Body: "Coffee first, then code", CreatedAt: now.Add(-2 * time.Hour)},
+		},
+	}
+	fresh := Author{CreatedAt: now.Add(-time.Hour)}
+
+	// build test cases
+	testCases := []struct {
+		name     string
+		body     string
+		author   Author
+		outcome  string
+		expected []string // signals
+	}{
+		{"Test case: Ordinary", "What a lovely day for a walk", established, OutcomeAllow, nil},
+		{"Test case: Exact Repeat", "  " + strings.ToUpper(spammy), established, OutcomeReject, []string{SignalDuplicate}},
+		{"Test case: Near Repeat", strings.Replace(spammy, "best", "lowest", 1), established, OutcomeHold, []string{SignalNearDuplicate}},
+		{"Test case: Link Only", "https://example.com/win", established, OutcomeAllow, []string{SignalLinks}},
+		{"Test case: Link Only New Account", "https://example.com/win", fresh, OutcomeHold, []string{SignalLinks, SignalNewAccount}},
+		{"Test case: Link In Sentence", "Wrote up my trip, www.example.com/trip has the photos", fresh, OutcomeAllow, nil},
+		{"Test case: Mention Flood", "@a @b @c @d @e @f look at this", established, OutcomeHold, []string{SignalMentions}},
+		{"Test case: Mention Flood New Account", "@a @b @c @d @e @f look at this", fresh, OutcomeHold, []string{SignalMentions, SignalNewAccount}},
+		{"Test case: Everything", "@a @b @c @d @e @f https://x.io https://y.io https://z.io https://w.io", fresh, OutcomeReject, []string{SignalLinks, SignalMentions, SignalNewAccount}},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verdict := policy.Check(now, tc.body, tc.author)
+			if verdict.Outcome != tc.outcome {
+				t.Errorf("Outcome = %q (score %d), want %q", verdict.Outcome, verdict.Score, tc.outcome)
+			}
+			if !slices.Equal(verdict.Signals, tc.expected) {
+				t.Errorf("Signals = %v, want %v", verdict.Signals, tc.expected)
+			}
+		})
+	}
+}
+
+// test old copies and the allowance don't count as duplicates
+func TestPolicyCheckDuplicateWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := DefaultPolicy
+	policy.MaxDuplicates = 1
+	author := Author{
+		CreatedAt: now.Add(-30 * 24 * time.Hour),
+		Recent:    []Post{{Body: "gm", CreatedAt: now.Add(-policy.DuplicateWindow - time.Minute)}},
+	}
+
+	// a day old copy is fine
+	if verdict := policy.Check(now, "gm", author); verdict.Outcome != OutcomeAllow {
+		t.Errorf("old copy Outcome = %q, want %q", verdict.Outcome, OutcomeAllow)
+	}
+
+	// one recent copy is within the allowance, a second is not
+	author.Recent = append(author.Recent, Post{Body: "gm", CreatedAt: now.Add(-time.Minute)})
+	if verdict := policy.Check(now, "gm", author); verdict.Outcome != OutcomeAllow {
+		t.Errorf("one copy Outcome = %q, want %q", verdict.Outcome, OutcomeAllow)
+	}
+	author.Recent = append(author.Recent, Post{Body: "gm", CreatedAt: now.Add(-2 * time.Minute)})
+	if verdict := policy.Check(now, "gm", author); verdict.Outcome != OutcomeReject {
+		t.Errorf("two copies Outcome = %q, want %q", verdict.Outcome, OutcomeReject)
+	}
+
+	// different chirps without words aren't copies of each other
+	author.Recent = []Post{{Body: "🔥", CreatedAt: now.Add(-time.Minute)}, {Body: "🎉", CreatedAt: now.Add(-time.Minute)}}
+	if verdict := policy.Check(now, "😂", author); verdict.Outcome != OutcomeAllow {
+		t.Errorf("emoji Outcome = %q %v, want %q", verdict.Outcome, verdict.Signals, OutcomeAllow)
+	}
+}
+
+// test new accounts are rate limited until their oldest chirp leaves the window
+func TestPolicyCheckNewAccountRate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := DefaultPolicy
+	policy.NewAccountChirps = 3
+	author := Author{CreatedAt: now.Add(-2 * time.Hour)}
+	for i := 1; i <= 3; i++ {
+		author.Recent = append(author.Recent, Post{Body: "chirp", CreatedAt: now.Add(-time.Duration(i*10) * time.Minute)})
+	}
+
+	// fourth chirp inside the hour
+	verdict := policy.Check(now, "something new", author)
+	if verdict.Outcome != OutcomeReject || !slices.Equal(verdict.Signals, []string{SignalRate}) {
+		t.Errorf("Check() = %q %v, want %q [%s]", verdict.Outcome, verdict.Signals, OutcomeReject, SignalRate)
+	}
+	if verdict.RetryAfter != 30*time.Minute {
+		t.Errorf("RetryAfter = %s, want %s", verdict.RetryAfter, 30*time.Minute)
+	}
+
+	// established accounts aren't limited
+	author.CreatedAt = now.Add(-policy.NewAccountAge - time.Minute)
+	if verdict := policy.Check(now, "something new", author); verdict.Outcome != OutcomeAllow {
+		t.Errorf("established Outcome = %q, want %q", verdict.Outcome, OutcomeAllow)
+	}
+}
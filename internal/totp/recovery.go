@@ -0,0 +1,55 @@
+// recovery.go
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// how many recovery codes a user gets on enrolment
+const RecoveryCodeCount = 10
+
+// recovery codes avoid look-alike characters so they survive being written down
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// makes a batch of single use recovery codes, formatted "xxxxx-xxxxx"
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		// 10 random bytes, one per character
+		raw := make([]byte, 10)
+
+		// fill the slice with random raw bytes 0-255
+		_, err := rand.Read(raw)
+
+		// random check
+		if err != nil {
+			return nil, err // early return
+		}
+
+		// map onto the alphabet (31 symbols, the modulo bias is negligible here)
+		var sb strings.Builder
+		for j, b := range raw {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+		}
+		codes[i] = sb.String()
+	}
+	return codes, nil
+}
+
+// hashes a recovery code for storage, ignoring case, spaces and dashes
+// codes are random so a fast hash is enough, same as api tokens
+func HashRecoveryCode(code string) string {
+	// normalise what the user typed
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	// hex encoded sha256
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
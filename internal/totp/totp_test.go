@@ -0,0 +1,117 @@
+// totp_test.go
+
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+)
+
+// RFC 6238 appendix B secret ("12345678901234567890") in base32
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// test codes against the RFC 6238 SHA1 vectors (last 6 digits)
+func TestCodeAtRFCVectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tc := range tests {
+		got, err := CodeAt(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("CodeAt failed: %v", err) // fatal, don't continue
+		}
+		if got != tc.want {
+			t.Errorf("CodeAt(%d) = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+// test the skew window
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err) // fatal, don't continue
+	}
+	now := time.Unix(1700000000, 0)
+	step := Step(now)
+
+	// current, previous and next steps are accepted
+	for _, s := range []int64{step - 1, step, step + 1} {
+		code, _ := CodeAt(secret, s)
+		got, err := Validate(secret, code, now)
+		if err != nil {
+			t.Errorf("Validate rejected code for step %d: %v", s, err)
+		}
+		if got != s {
+			t.Errorf("Validate returned step %d, want %d", got, s)
+		}
+	}
+
+	// two steps away is rejected
+	code, _ := CodeAt(secret, step+2)
+	if _, err := Validate(secret, code, now); err == nil {
+		t.Errorf("Validate accepted a code outside the skew window")
+	}
+
+	// spaces are tolerated, junk is not
+	code, _ = CodeAt(secret, step)
+	if _, err := Validate(secret, code[:3]+" "+code[3:], now); err != nil {
+		t.Errorf("Validate rejected a spaced code: %v", err)
+	}
+	if _, err := Validate(secret, "12345", now); err == nil {
+		t.Errorf("Validate accepted a short code")
+	}
+}
+
+// test the otpauth uri
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Chirpy", "bob@example.com", "ABCDEF")
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:bob@example.com?") {
+		t.Errorf("unexpected uri prefix: %s", uri)
+	}
+	for _, want := range []string{"secret=ABCDEF", "issuer=Chirpy", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("uri %s is missing %s", uri, want)
+		}
+	}
+}
+
+// test recovery codes are unique and hash the same however they are typed
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err) // fatal, don't continue
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected code shape: %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	// case, dashes and spaces don't matter
+	code := codes[0]
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+	if HashRecoveryCode(code) != HashRecoveryCode(typed) {
+		t.Errorf("hash differs for %q and %q", code, typed)
+	}
+}
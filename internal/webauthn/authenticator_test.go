@@ -0,0 +1,199 @@
+// authenticator_test.go
+
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"testing" // importing testing package for unit tests
+)
+
+// a software authenticator, stands in for a security key or phone in tests
+type softAuthenticator struct {
+	t         *testing.T
+	rpID      string
+	origin    string
+	key       *ecdsa.PrivateKey
+	id        []byte
+	userID    []byte
+	signCount uint32
+	format    string // "none" or "packed"
+}
+
+// new authenticator for a relying party, with one P-256 credential
+func newSoftAuthenticator(t *testing.T, rpID, origin string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err) // fatal, don't continue
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{t: t, rpID: rpID, origin: origin, key: key, id: id, format: "none"}
+}
+
+// answer navigator.credentials.create
+func (a *softAuthenticator) register(opts CreationOptions) RegistrationResponse {
+	a.userID, _ = DecodeBase64(opts.User.ID)
+	clientDataJSON := a.clientData("webauthn.create", opts.Challenge)
+
+	// attested credential data: aaguid, id length, id, COSE key
+	attested := make([]byte, 16)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, encodeCBOR(a.t, a.coseKey())...)
+	authData := append(a.authData(flagUserPresent|flagUserVerified|flagAttested), attested...)
+
+	// attestation statement
+	attStmt := map[any]any{}
+	if a.format == "packed" {
+		attStmt = map[any]any{"alg": int64(AlgES256), "sig": a.sign(authData, clientDataJSON)}
+	}
+	attestationObject := encodeCBOR(a.t, map[any]any{
+		"fmt":      a.format,
+		"attStmt":  attStmt,
+		"authData": authData,
+	})
+
+	var resp RegistrationResponse
+	resp.ID = EncodeBase64(a.id)
+	resp.RawID = EncodeBase64(a.id)
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = EncodeBase64(clientDataJSON)
+	resp.Response.AttestationObject = EncodeBase64(attestationObject)
+	return resp
+}
+
+// answer navigator.credentials.get
+func (a *softAuthenticator) assert(opts RequestOptions) AssertionResponse {
+	a.signCount++
+	clientDataJSON := a.clientData("webauthn.get", opts.Challenge)
+	authData := a.authData(flagUserPresent | flagUserVerified)
+
+	var resp AssertionResponse
+	resp.ID = EncodeBase64(a.id)
+	resp.RawID = EncodeBase64(a.id)
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = EncodeBase64(clientDataJSON)
+	resp.Response.AuthenticatorData = EncodeBase64(authData)
+	resp.Response.Signature = EncodeBase64(a.sign(authData, clientDataJSON))
+	resp.Response.UserHandle = EncodeBase64(a.userID)
+	return resp
+}
+
+// client data json as a browser builds it
+func (a *softAuthenticator) clientData(ceremony, challenge string) []byte {
+	raw, err := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		a.t.Fatalf("marshalling client data failed: %v", err) // fatal, don't continue
+	}
+	return raw
+}
+
+// rp id hash, flags and counter
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+// ES256 signature over authenticator data and the client data hash
+func (a *softAuthenticator) sign(authData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatalf("SignASN1 failed: %v", err) // fatal, don't continue
+	}
+	return sig
+}
+
+// the credential public key as a COSE_Key map
+func (a *softAuthenticator) coseKey() map[any]any {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return map[any]any{
+		int64(coseKty): int64(coseKtyEC2),
+		int64(coseAlg): int64(AlgES256),
+		int64(-1):      int64(coseCrvP256),
+		int64(-2):      x,
+		int64(-3):      y,
+	}
+}
+
+// minimal CBOR encoder for the test authenticator
+func encodeCBOR(t *testing.T, value any) []byte {
+	var out []byte
+	head := func(major byte, arg uint64) {
+		switch {
+		case arg < 24:
+			out = append(out, major<<5|byte(arg))
+		case arg <= 0xff:
+			out = append(out, major<<5|24, byte(arg))
+		case arg <= 0xffff:
+			out = append(out, major<<5|25)
+			out = binary.BigEndian.AppendUint16(out, uint16(arg))
+		case arg <= 0xffffffff:
+			out = append(out, major<<5|26)
+			out = binary.BigEndian.AppendUint32(out, uint32(arg))
+		default:
+			out = append(out, major<<5|27)
+			out = binary.BigEndian.AppendUint64(out, arg)
+		}
+	}
+
+	switch v := value.(type) {
+	case int64:
+		if v >= 0 {
+			head(cborUint, uint64(v))
+		} else {
+			head(cborNegInt, uint64(-1-v))
+		}
+	case []byte:
+		head(cborBytes, uint64(len(v)))
+		out = append(out, v...)
+	case string:
+		head(cborText, uint64(len(v)))
+		out = append(out, v...)
+	case []any:
+		head(cborArray, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(t, item)...)
+		}
+	case map[any]any:
+		// deterministic order, encoded keys sorted bytewise
+		type pair struct{ key, value []byte }
+		pairs := make([]pair, 0, len(v))
+		for key, item := range v {
+			pairs = append(pairs, pair{encodeCBOR(t, key), encodeCBOR(t, item)})
+		}
+		sort.Slice(pairs, func(i, j int) bool { return string(pairs[i].key) < string(pairs[j].key) })
+		head(cborMap, uint64(len(v)))
+		for _, p := range pairs {
+			out = append(out, p.key...)
+			out = append(out, p.value...)
+		}
+	case bool:
+		if v {
+			out = append(out, 0xf5)
+		} else {
+			out = append(out, 0xf4)
+		}
+	case nil:
+		out = append(out, 0xf6)
+	default:
+		t.Fatalf("encodeCBOR: unsupported type %T", value) // fatal, don't continue
+	}
+	return out
+}
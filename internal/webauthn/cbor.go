@@ -0,0 +1,189 @@
+// cbor.go
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// just enough CBOR (RFC 8949) to read attestation objects and COSE keys
+// integers come back as int64, byte strings as []byte, text as string,
+// arrays as []any and maps as map[any]any
+// indefinite lengths and floats never appear in webauthn, so they're errors
+
+// nesting limit, real attestation objects are 3 or 4 deep
+const maxCBORDepth = 16
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// decodes one CBOR item and returns it with whatever bytes follow it
+func decodeCBOR(data []byte) (any, []byte, error) {
+	d := cborDecoder{data: data}
+	value, err := d.item(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, d.data[d.pos:], nil
+}
+
+// cursor over the input
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// read the head of an item, major type and argument
+func (d *cborDecoder) head() (byte, uint64, error) {
+	// initial byte
+	if d.pos >= len(d.data) {
+		return 0, 0, errCBORTruncated
+	}
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, initial&0x1f
+
+	// small values live in the initial byte
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+
+	// otherwise 1, 2, 4 or 8 following bytes
+	var size int
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+	if len(d.data)-d.pos < size {
+		return 0, 0, errCBORTruncated
+	}
+	raw := d.data[d.pos : d.pos+size]
+	d.pos += size
+
+	// big endian argument
+	switch size {
+	case 1:
+		return major, uint64(raw[0]), nil
+	case 2:
+		return major, uint64(binary.BigEndian.Uint16(raw)), nil
+	case 4:
+		return major, uint64(binary.BigEndian.Uint32(raw)), nil
+	default:
+		return major, binary.BigEndian.Uint64(raw), nil
+	}
+}
+
+// read one item of any type
+func (d *cborDecoder) item(depth int) (any, error) {
+	// depth check
+	if depth > maxCBORDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), nil
+
+	case cborNegInt:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), nil
+
+	case cborBytes, cborText:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		raw := d.data[d.pos : d.pos+int(arg)]
+		d.pos += int(arg)
+		if major == cborText {
+			return string(raw), nil
+		}
+		return append([]byte(nil), raw...), nil
+
+	case cborArray:
+		// every item is at least one byte, so a bigger count is a lie
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			value, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+
+	case cborMap:
+		// every pair is at least two bytes
+		if arg > uint64(len(d.data)-d.pos)/2 {
+			return nil, errCBORTruncated
+		}
+		items := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			// only comparable keys, webauthn uses ints and text
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("cbor: unsupported map key type %T", key)
+			}
+			value, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items[key] = value
+		}
+		return items, nil
+
+	case cborTag:
+		// tags carry no meaning we need, return the tagged item
+		return d.item(depth + 1)
+
+	default: // cborSimple
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("cbor: unsupported simple value %d", arg)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// cbor_test.go
+
+package webauthn
+
+import (
+	"bytes"
+	"reflect"
+	"testing" // importing testing package for unit tests
+)
+
+// test decoding against the RFC 8949 appendix A examples
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want any
+	}{
+		{"zero", []byte{0x00}, int64(0)},
+		{"uint8", []byte{0x18, 0x64}, int64(100)},
+		{"uint32", []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}, int64(1000000)},
+		{"negative", []byte{0x38, 0x63}, int64(-100)},
+		{"bytes", []byte{0x44, 0x01, 0x02, 0x03, 0x04}, []byte{1, 2, 3, 4}},
+		{"text", []byte{0x64, 0x49, 0x45, 0x54, 0x46}, "IETF"},
+		{"array", []byte{0x83, 0x01, 0x02, 0x03}, []any{int64(1), int64(2), int64(3)}},
+		{"map", []byte{0xa2, 0x61, 0x61, 0x01, 0x20, 0xf5}, map[any]any{"a": int64(1), int64(-1): true}},
+		{"tagged", []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, int64(1363896240)},
+		{"null", []byte{0xf6}, nil},
+	}
+
+	for _, tc := range tests {
+		got, rest, err := decodeCBOR(tc.in)
+		if err != nil {
+			t.Errorf("%s: decodeCBOR failed: %v", tc.name, err)
+			continue
+		}
+		if len(rest) != 0 {
+			t.Errorf("%s: %d trailing bytes", tc.name, len(rest))
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %#v, want %#v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// test trailing bytes are handed back, COSE keys in authenticator data rely on it
+func TestDecodeCBORRest(t *testing.T) {
+	_, rest, err := decodeCBOR([]byte{0x01, 0xaa, 0xbb})
+	if err != nil {
+		t.Fatalf("decodeCBOR failed: %v", err) // fatal, don't continue
+	}
+	if !bytes.Equal(rest, []byte{0xaa, 0xbb}) {
+		t.Errorf("rest = %x, want aabb", rest)
+	}
+}
+
+// test malformed input is rejected rather than panicking
+func TestDecodeCBORMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":            {},
+		"truncated uint":   {0x19, 0x01},
+		"truncated bytes":  {0x45, 0x01, 0x02},
+		"huge array":       {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"huge map":         {0xbb, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"indefinite bytes": {0x5f, 0x41, 0x01, 0xff},
+		"float":            {0xf9, 0x3c, 0x00},
+		"array map key":    {0xa1, 0x80, 0x01},
+		"too deep":         bytes.Repeat([]byte{0x81}, maxCBORDepth+2),
+	}
+
+	for name, in := range tests {
+		if _, _, err := decodeCBOR(in); err == nil {
+			t.Errorf("%s: decodeCBOR accepted malformed input", name)
+		}
+	}
+}
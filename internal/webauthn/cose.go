@@ -0,0 +1,131 @@
+// cose.go
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms we accept, in order of preference
+const (
+	AlgES256 = -7   // ECDSA P-256 with SHA-256, what almost every authenticator uses
+	AlgEdDSA = -8   // Ed25519
+	AlgRS256 = -257 // RSASSA-PKCS1-v1_5 with SHA-256, Windows Hello
+)
+
+// every algorithm offered during registration
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 9053)
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// a credential public key, parsed from its COSE form
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parse a COSE_Key, rejecting anything we can't verify with
+func parsePublicKey(coseKey []byte) (publicKey, error) {
+	// decode the key map
+	value, rest, err := decodeCBOR(coseKey)
+	if err != nil {
+		return publicKey{}, err
+	}
+	if len(rest) != 0 {
+		return publicKey{}, errors.New("trailing bytes after public key")
+	}
+	params, ok := value.(map[any]any)
+	if !ok {
+		return publicKey{}, errors.New("public key is not a map")
+	}
+
+	// key type and algorithm
+	kty, _ := params[int64(coseKty)].(int64)
+	alg, _ := params[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		// curve and coordinates
+		crv, _ := params[int64(-1)].(int64)
+		x, _ := params[int64(-2)].([]byte)
+		y, _ := params[int64(-3)].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, errors.New("invalid ES256 public key")
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		// point must be on the curve
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return publicKey{}, errors.New("ES256 public key is not on the curve")
+		}
+		return publicKey{alg: alg, key: key}, nil
+
+	case kty == coseKtyOKP && alg == AlgEdDSA:
+		// curve and point
+		crv, _ := params[int64(-1)].(int64)
+		x, _ := params[int64(-2)].([]byte)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errors.New("invalid EdDSA public key")
+		}
+		return publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKtyRSA && alg == AlgRS256:
+		// modulus and exponent
+		n, _ := params[int64(-1)].([]byte)
+		e, _ := params[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return publicKey{}, errors.New("invalid RS256 public key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return publicKey{alg: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, nil
+
+	default:
+		return publicKey{}, fmt.Errorf("unsupported public key (kty %d, alg %d)", kty, alg)
+	}
+}
+
+// check a signature over data with the credential key
+func (pk publicKey) verify(data, signature []byte) error {
+	switch key := pk.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+
+	default:
+		return errors.New("unsupported public key")
+	}
+}
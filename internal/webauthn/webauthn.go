@@ -0,0 +1,427 @@
+// webauthn.go
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// STRUCTS
+// who we are to the authenticator
+type Config struct {
+	RPID    string        // relying party id, the site's domain, e.g. "chirpy.example"
+	RPName  string        // shown to the user, e.g. "Chirpy"
+	Origins []string      // allowed client origins, e.g. "https://chirpy.example"
+	Timeout time.Duration // how long the browser should wait for the user
+}
+
+// a registered credential, what we keep after registration
+type Credential struct {
+	ID        []byte // credential id chosen by the authenticator
+	PublicKey []byte // COSE_Key bytes
+	SignCount uint32 // signature counter, 0 if the authenticator doesn't keep one
+}
+
+// a user as the authenticator sees it
+type User struct {
+	ID          []byte // opaque handle, never the email
+	Name        string // account name, e.g. the email
+	DisplayName string // friendly name
+}
+
+// CEREMONY OPTIONS
+// what the browser passes to navigator.credentials.create
+type CreationOptions struct {
+	RP                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// what the browser passes to navigator.credentials.get
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CEREMONY RESPONSES
+// the browser's PublicKeyCredential from a registration, base64url fields
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// the browser's PublicKeyCredential from a sign in, base64url fields
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// makes a random challenge, base64url encoded like everything else on the wire
+func NewChallenge() (string, error) {
+	// make zero'd slice with 32 bytes (which is 256 bits)
+	raw := make([]byte, 32)
+
+	// fill the slice with random raw bytes 0-255
+	_, err := rand.Read(raw)
+
+	// random check
+	if err != nil {
+		return "", err // early return
+	}
+
+	return EncodeBase64(raw), nil
+}
+
+// base64url without padding, the webauthn wire encoding
+func EncodeBase64(raw []byte) string {
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decode base64url, tolerating padding
+func DecodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// the options for a registration ceremony
+// existing credentials are excluded so one authenticator isn't registered twice
+func (c Config) CreationOptions(user User, challenge string, existing [][]byte) CreationOptions {
+	// offered algorithms
+	params := make([]CredentialParameter, len(SupportedAlgorithms))
+	for i, alg := range SupportedAlgorithms {
+		params[i] = CredentialParameter{Type: "public-key", Alg: alg}
+	}
+
+	// already registered
+	exclude := make([]CredentialDescriptor, len(existing))
+	for i, id := range existing {
+		exclude[i] = CredentialDescriptor{Type: "public-key", ID: EncodeBase64(id)}
+	}
+
+	return CreationOptions{
+		RP:                 RelyingParty{ID: c.RPID, Name: c.RPName},
+		User:               UserEntity{ID: EncodeBase64(user.ID), Name: user.Name, DisplayName: user.DisplayName},
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            c.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required", // discoverable, so sign in needs no username
+			UserVerification: "required", // pin or biometric, a passkey is both factors
+		},
+		Attestation: "none",
+	}
+}
+
+// the options for a sign in ceremony, discoverable credentials so no allow list
+func (c Config) RequestOptions(challenge string) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          c.Timeout.Milliseconds(),
+		RPID:             c.RPID,
+		UserVerification: "required",
+	}
+}
+
+// finish a registration ceremony, returning the credential to store
+func (c Config) VerifyRegistration(challenge string, resp RegistrationResponse) (Credential, error) {
+	// credential type check
+	if resp.Type != "public-key" {
+		return Credential{}, errors.New("unexpected credential type")
+	}
+
+	// client data: what the browser says happened
+	clientDataJSON, err := DecodeBase64(resp.Response.ClientDataJSON)
+	if err != nil {
+		return Credential{}, fmt.Errorf("decoding client data: %w", err)
+	}
+	err = c.verifyClientData(clientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	// attestation object: what the authenticator says happened
+	attestationObject, err := DecodeBase64(resp.Response.AttestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("decoding attestation object: %w", err)
+	}
+	value, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("decoding attestation object: %w", err)
+	}
+	attestation, ok := value.(map[any]any)
+	if !ok {
+		return Credential{}, errors.New("attestation object is not a map")
+	}
+	format, _ := attestation["fmt"].(string)
+	authData, _ := attestation["authData"].([]byte)
+	attStmt, _ := attestation["attStmt"].(map[any]any)
+
+	// authenticator data
+	parsed, err := c.parseAuthData(authData)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	// registration must carry the new credential
+	if parsed.flags&flagAttested == 0 || parsed.credentialID == nil {
+		return Credential{}, errors.New("no attested credential data")
+	}
+
+	// the credential key must be one we can verify with
+	key, err := parsePublicKey(parsed.publicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	// attestation statement
+	// we ask for "none", but "packed" self attestation is cheap to check so accept it too
+	// certificate chains (x5c) would need a trust store, which we don't keep
+	switch format {
+	case "none":
+		if len(attStmt) != 0 {
+			return Credential{}, errors.New("none attestation with a statement")
+		}
+	case "packed":
+		// certificate check
+		if _, hasX5C := attStmt["x5c"]; hasX5C {
+			return Credential{}, errors.New("packed attestation with certificates is not supported")
+		}
+		// self attestation is signed by the credential key itself
+		alg, _ := attStmt["alg"].(int64)
+		sig, _ := attStmt["sig"].([]byte)
+		if alg != key.alg {
+			return Credential{}, errors.New("attestation algorithm does not match credential key")
+		}
+		clientDataHash := sha256.Sum256(clientDataJSON)
+		err = key.verify(append(slices.Clone(authData), clientDataHash[:]...), sig)
+		if err != nil {
+			return Credential{}, fmt.Errorf("attestation signature: %w", err)
+		}
+	default:
+		return Credential{}, fmt.Errorf("unsupported attestation format %q", format)
+	}
+
+	// raw id must match what the authenticator attested
+	rawID, err := DecodeBase64(resp.RawID)
+	if err != nil || !bytes.Equal(rawID, parsed.credentialID) {
+		return Credential{}, errors.New("credential id mismatch")
+	}
+
+	return Credential{
+		ID:        parsed.credentialID,
+		PublicKey: parsed.publicKey,
+		SignCount: parsed.signCount,
+	}, nil
+}
+
+// finish a sign in ceremony against a stored credential
+// returns the new signature counter to store
+func (c Config) VerifyAssertion(challenge string, cred Credential, resp AssertionResponse) (uint32, error) {
+	// credential type check
+	if resp.Type != "public-key" {
+		return 0, errors.New("unexpected credential type")
+	}
+
+	// the assertion must be for this credential
+	rawID, err := DecodeBase64(resp.RawID)
+	if err != nil || !bytes.Equal(rawID, cred.ID) {
+		return 0, errors.New("credential id mismatch")
+	}
+
+	// client data
+	clientDataJSON, err := DecodeBase64(resp.Response.ClientDataJSON)
+	if err != nil {
+		return 0, fmt.Errorf("decoding client data: %w", err)
+	}
+	err = c.verifyClientData(clientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	// authenticator data
+	authData, err := DecodeBase64(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("decoding authenticator data: %w", err)
+	}
+	parsed, err := c.parseAuthData(authData)
+	if err != nil {
+		return 0, err
+	}
+
+	// signature over authenticator data and the client data hash
+	signature, err := DecodeBase64(resp.Response.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("decoding signature: %w", err)
+	}
+	key, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	err = key.verify(append(slices.Clone(authData), clientDataHash[:]...), signature)
+	if err != nil {
+		return 0, err
+	}
+
+	// a counter that didn't go up means a cloned authenticator
+	// authenticators without a counter always send 0
+	if (parsed.signCount != 0 || cred.SignCount != 0) && parsed.signCount <= cred.SignCount {
+		return 0, errors.New("signature counter did not increase, possible cloned authenticator")
+	}
+
+	return parsed.signCount, nil
+}
+
+// check the client data json the browser built
+func (c Config) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	// decode
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	err := json.Unmarshal(clientDataJSON, &clientData)
+	if err != nil {
+		return fmt.Errorf("decoding client data: %w", err)
+	}
+
+	// ceremony check, stops a sign in being replayed as a registration
+	if clientData.Type != ceremony {
+		return fmt.Errorf("unexpected ceremony %q", clientData.Type)
+	}
+
+	// challenge check, constant time
+	if subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return errors.New("challenge mismatch")
+	}
+
+	// origin check, stops phishing sites relaying the ceremony
+	if !slices.Contains(c.Origins, clientData.Origin) {
+		return fmt.Errorf("unexpected origin %q", clientData.Origin)
+	}
+
+	return nil
+}
+
+// authenticator data, the parts we use
+type authData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte // registration only
+	publicKey    []byte // registration only, COSE_Key bytes
+}
+
+// parse and check authenticator data
+func (c Config) parseAuthData(raw []byte) (authData, error) {
+	// rp id hash (32) + flags (1) + counter (4)
+	if len(raw) < 37 {
+		return authData{}, errors.New("authenticator data too short")
+	}
+
+	// rp id check, the authenticator scoped the credential to our domain
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if subtle.ConstantTimeCompare(raw[:32], rpIDHash[:]) != 1 {
+		return authData{}, errors.New("rp id mismatch")
+	}
+
+	parsed := authData{
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	// user present and verified, we always ask for both
+	if parsed.flags&flagUserPresent == 0 {
+		return authData{}, errors.New("user not present")
+	}
+	if parsed.flags&flagUserVerified == 0 {
+		return authData{}, errors.New("user not verified")
+	}
+
+	// attested credential data, registration only
+	if parsed.flags&flagAttested != 0 {
+		// aaguid (16) + id length (2)
+		rest := raw[37:]
+		if len(rest) < 18 {
+			return authData{}, errors.New("attested credential data too short")
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLen {
+			return authData{}, errors.New("credential id truncated")
+		}
+		parsed.credentialID = slices.Clone(rest[:idLen])
+		rest = rest[idLen:]
+
+		// the COSE key is the next cbor item, extensions may follow
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return authData{}, fmt.Errorf("decoding credential public key: %w", err)
+		}
+		parsed.publicKey = slices.Clone(rest[:len(rest)-len(after)])
+	}
+
+	return parsed, nil
+}
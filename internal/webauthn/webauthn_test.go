@@ -0,0 +1,136 @@
+// webauthn_test.go
+
+package webauthn
+
+import (
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+)
+
+// test relying party
+var testConfig = Config{
+	RPID:    "chirpy.test",
+	RPName:  "Chirpy",
+	Origins: []string{"https://chirpy.test"},
+	Timeout: time.Minute,
+}
+
+// register a soft authenticator, failing the test on error
+func registerSoft(t *testing.T, a *softAuthenticator) Credential {
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge failed: %v", err) // fatal, don't continue
+	}
+	opts := testConfig.CreationOptions(User{ID: []byte("user-1"), Name: "bob@example.com"}, challenge, nil)
+	cred, err := testConfig.VerifyRegistration(challenge, a.register(opts))
+	if err != nil {
+		t.Fatalf("VerifyRegistration failed: %v", err) // fatal, don't continue
+	}
+	return cred
+}
+
+// test a full registration then sign in
+func TestRegistrationAndAssertion(t *testing.T) {
+	for _, format := range []string{"none", "packed"} {
+		a := newSoftAuthenticator(t, testConfig.RPID, testConfig.Origins[0])
+		a.format = format
+
+		// register
+		cred := registerSoft(t, a)
+		if string(cred.ID) != string(a.id) {
+			t.Errorf("%s: credential id = %x, want %x", format, cred.ID, a.id)
+		}
+
+		// sign in twice, the counter moves on each time
+		for i := 1; i <= 2; i++ {
+			challenge, _ := NewChallenge()
+			signCount, err := testConfig.VerifyAssertion(challenge, cred, a.assert(testConfig.RequestOptions(challenge)))
+			if err != nil {
+				t.Fatalf("%s: VerifyAssertion failed: %v", format, err) // fatal, don't continue
+			}
+			if signCount != uint32(i) {
+				t.Errorf("%s: sign count = %d, want %d", format, signCount, i)
+			}
+			cred.SignCount = signCount
+		}
+	}
+}
+
+// test registration is tied to the challenge, origin and rp id
+func TestVerifyRegistrationRejects(t *testing.T) {
+	challenge, _ := NewChallenge()
+	opts := testConfig.CreationOptions(User{ID: []byte("user-1"), Name: "bob@example.com"}, challenge, nil)
+
+	// wrong challenge
+	a := newSoftAuthenticator(t, testConfig.RPID, testConfig.Origins[0])
+	other, _ := NewChallenge()
+	if _, err := testConfig.VerifyRegistration(other, a.register(opts)); err == nil {
+		t.Errorf("VerifyRegistration accepted the wrong challenge")
+	}
+
+	// phishing origin
+	a = newSoftAuthenticator(t, testConfig.RPID, "https://chirpy.evil")
+	if _, err := testConfig.VerifyRegistration(challenge, a.register(opts)); err == nil {
+		t.Errorf("VerifyRegistration accepted a foreign origin")
+	}
+
+	// credential scoped to another site
+	a = newSoftAuthenticator(t, "chirpy.evil", testConfig.Origins[0])
+	if _, err := testConfig.VerifyRegistration(challenge, a.register(opts)); err == nil {
+		t.Errorf("VerifyRegistration accepted a foreign rp id")
+	}
+
+	// a sign in response replayed as a registration
+	a = newSoftAuthenticator(t, testConfig.RPID, testConfig.Origins[0])
+	resp := a.register(opts)
+	assertion := a.assert(testConfig.RequestOptions(challenge))
+	resp.Response.ClientDataJSON = assertion.Response.ClientDataJSON
+	if _, err := testConfig.VerifyRegistration(challenge, resp); err == nil {
+		t.Errorf("VerifyRegistration accepted sign in client data")
+	}
+}
+
+// test sign in rejects forged, replayed and cloned responses
+func TestVerifyAssertionRejects(t *testing.T) {
+	a := newSoftAuthenticator(t, testConfig.RPID, testConfig.Origins[0])
+	cred := registerSoft(t, a)
+	challenge, _ := NewChallenge()
+
+	// signed by a different key
+	imposter := newSoftAuthenticator(t, testConfig.RPID, testConfig.Origins[0])
+	imposter.id = a.id
+	if _, err := testConfig.VerifyAssertion(challenge, cred, imposter.assert(testConfig.RequestOptions(challenge))); err == nil {
+		t.Errorf("VerifyAssertion accepted a signature from another key")
+	}
+
+	// tampered signature
+	resp := a.assert(testConfig.RequestOptions(challenge))
+	resp.Response.Signature = strings.Repeat("A", len(resp.Response.Signature))
+	if _, err := testConfig.VerifyAssertion(challenge, cred, resp); err == nil {
+		t.Errorf("VerifyAssertion accepted a tampered signature")
+	}
+
+	// counter went backwards (cloned authenticator)
+	cred.SignCount = 100
+	if _, err := testConfig.VerifyAssertion(challenge, cred, a.assert(testConfig.RequestOptions(challenge))); err == nil {
+		t.Errorf("VerifyAssertion accepted a stale signature counter")
+	}
+}
+
+// test the options advertise what we verify
+func TestCreationOptions(t *testing.T) {
+	opts := testConfig.CreationOptions(User{ID: []byte("user-1"), Name: "bob@example.com"}, "abc", [][]byte{{1, 2, 3}})
+	if opts.RP.ID != testConfig.RPID || opts.Challenge != "abc" {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if len(opts.PubKeyCredParams) != len(SupportedAlgorithms) || opts.PubKeyCredParams[0].Alg != AlgES256 {
+		t.Errorf("unexpected algorithms: %+v", opts.PubKeyCredParams)
+	}
+	if len(opts.ExcludeCredentials) != 1 || opts.ExcludeCredentials[0].ID != "AQID" {
+		t.Errorf("unexpected exclude list: %+v", opts.ExcludeCredentials)
+	}
+	if opts.AuthenticatorSelection.UserVerification != "required" {
+		t.Errorf("user verification = %q, want required", opts.AuthenticatorSelection.UserVerification)
+	}
+}
@@ -0,0 +1,241 @@
+// magiclink.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/mailer"
+)
+
+// magic link lifetime, short as the link is as good as a password
+const magicLinkDuration = 15 * time.Minute
+
+// email a user a one time sign in link
+func (apiCfg *apiConfig) sendMagicLinkEmail(ctx context.Context, user database.User) error {
+	// make the token
+	token, err := apiCfg.issueEmailToken(ctx, user.ID, auth.EmailPurposeMagic, magicLinkDuration)
+	if err != nil {
+		return err
+	}
+
+	// send it
+	return apiCfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy sign in link",
+		Body: "Sign in to Chirpy by opening the link below:\n\n" +
+			apiCfg.appLink("/app/magic-login", token) + "\n\n" +
+			"The link expires in 15 minutes and works once. If you didn't ask for it, you can ignore this email.\n",
+	})
+}
+
+// MagicLink handler that emails a sign in link instead of taking a password
+// always answers 202 so it can't be used to find out who has an account
+func (apiCfg *apiConfig) handlerMagicLink(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// json request from client
+	var reqMagic JsonMagicLinkRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqMagic)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqMagic is now successfully populated
+
+	// check email empty
+	if len(reqMagic.Email) == 0 {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Email is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// mail bombing check, per email whether or not it has an account
+	throttleKey := magicLinkThrottleKey(reqMagic.Email)
+	wait, err := apiCfg.magicLinkLimiter.Check(req.Context(), throttleKey)
+	if err != nil {
+		log.Printf("Error checking magic link throttle: %s", err) // log msg with err
+	}
+
+	// throttled check
+	if wait > 0 {
+		log.Printf("Magic link throttled for %s: retry in %s", reqMagic.Email, wait) // log msg
+		// helper to insert error msg + 429 too many requests status code
+		WriteTooManyRequests(w, "Too many sign in links requested, try again later", wait)
+		return // early return
+	}
+
+	// count this request
+	_, err = apiCfg.magicLinkLimiter.Fail(req.Context(), throttleKey)
+	if err != nil {
+		log.Printf("Error recording magic link request: %s", err) // log msg with err
+	}
+
+	// get the user by email
+	user, err := apiCfg.db.GetUserByEmail(req.Context(), reqMagic.Email)
+
+	// unknown email, same answer as a known one
+	if err != nil {
+		log.Printf("Magic link requested for unknown email: %s", err) // log msg with err
+		w.WriteHeader(http.StatusAccepted)                            // status code 202 to client
+		return                                                        // early return
+	}
+
+	// send the link (failures are logged, the answer stays the same)
+	err = apiCfg.sendMagicLinkEmail(req.Context(), user)
+	if err != nil {
+		log.Printf("Error sending magic link email: %s", err) // log msg with err
+	}
+
+	// 202 accepted, the rest happens in the user's inbox
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// RedeemMagicLink handler that swaps an emailed sign in token for the usual login tokens
+// the link only proves the inbox, so 2fa users still get the totp challenge
+func (apiCfg *apiConfig) handlerRedeemMagicLink(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// json request from client
+	var reqToken JsonEmailTokenRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqToken)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqToken is now successfully populated
+
+	// spend the token, single use
+	userID, err := apiCfg.consumeEmailToken(req.Context(), reqToken.Token, auth.EmailPurposeMagic)
+
+	// token check (unknown, used, superseded or expired)
+	if err != nil {
+		log.Printf("Error consuming magic link token: %s", err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Invalid or expired sign in link", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the user
+	loginUser, err := apiCfg.db.GetUserByID(req.Context(), userID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Invalid or expired sign in link", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// opening the link proves the address too
+	if !loginUser.EmailVerifiedAt.Valid {
+		err = apiCfg.db.MarkUserEmailVerified(req.Context(), loginUser.ID)
+		if err != nil {
+			log.Printf("Error marking email verified after magic link: %s", err) // log msg with err
+		}
+	}
+
+	// the link was the right inbox, the send limit can start over
+	err = apiCfg.magicLinkLimiter.Reset(req.Context(), magicLinkThrottleKey(loginUser.Email))
+	if err != nil {
+		log.Printf("Error resetting magic link throttle: %s", err) // log msg with err
+	}
+
+	// look up the second factor
+	mfaEnabled, err := apiCfg.totpEnabled(req.Context(), loginUser.ID)
+
+	// 2fa lookup check (fail closed)
+	if err != nil {
+		log.Printf("Error getting totp for user %s: %s", loginUser.ID, err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Error occurred during login", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// suspended check
+	if apiCfg.refuseSuspended(w, req, loginUser.ID) {
+		return // early return
+	}
+
+	// 2fa on, hand back a challenge instead of tokens
+	if mfaEnabled {
+		apiCfg.writeMFAChallenge(w, loginUser.ID)
+		return // early return
+	}
+
+	// make the access and refresh tokens
+	respLogin, err := apiCfg.issueLoginTokens(req.Context(), loginUser)
+
+	// issue tokens check
+	if err != nil {
+		log.Printf("Error issuing login tokens: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Internal server token generation error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respLogin, http.StatusOK)
+}
@@ -0,0 +1,50 @@
+// magiclink_test.go
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+
+	"github.com/PietPadda/chirpy/internal/lockout"
+)
+
+// test magic link requests are throttled per email before any lookup
+func TestMagicLinkThrottled(t *testing.T) {
+	apiCfg := &apiConfig{
+		magicLinkLimiter: lockout.NewLimiter(lockout.NewMemoryStore(), magicLinkPolicy),
+	}
+
+	// use up the free sends and the first delayed one, case shouldn't dodge the limit
+	for i := 0; i <= magicLinkPolicy.FreeAttempts; i++ {
+		if _, err := apiCfg.magicLinkLimiter.Fail(context.Background(), magicLinkThrottleKey("Walt@Example.com")); err != nil {
+			t.Fatalf("Fail failed: %v", err) // fatal, don't continue
+		}
+	}
+
+	// next request is refused without touching the db
+	body := strings.NewReader(`{"email":"walt@example.com"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/login/magic", body)
+	rec := httptest.NewRecorder()
+	apiCfg.handlerMagicLink(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("missing Retry-After header")
+	}
+}
+
+// test redeeming needs a token
+func TestRedeemMagicLinkEmptyBody(t *testing.T) {
+	apiCfg := &apiConfig{}
+	req := httptest.NewRequest(http.MethodPost, "/api/login/magic/redeem", strings.NewReader(""))
+	rec := httptest.NewRecorder()
+	apiCfg.handlerRedeemMagicLink(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -4,6 +4,7 @@ package main
 import (
 	// std go libraries
 	// for printing
+	"context"
 	"database/sql"
 	"log"      // for err logging
 	"net/http" // http protocol
@@ -15,7 +16,15 @@ import (
 	"sync/atomic" // allows safe incr + read of ints for goroutines
 
 	// driver init
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/blobstore"
 	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/lockout"
+	"github.com/PietPadda/chirpy/internal/mailer"
+	"github.com/PietPadda/chirpy/internal/oidc"
+	"github.com/PietPadda/chirpy/internal/passwords"
+	"github.com/PietPadda/chirpy/internal/spam"
+	"github.com/PietPadda/chirpy/internal/webauthn"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq" // postgresql driver
@@ -24,11 +33,23 @@ import (
 // STRUCTS
 // stateful struct
 type apiConfig struct {
-	fileserverHits atomic.Int32      // for metrics
-	db             *database.Queries // for db access
-	platform       string            // for role auth
-	serverKey      string            // for use auth
-	apiKey         string            // for webhook auth
+	fileserverHits   atomic.Int32              // for metrics
+	db               querier                   // for db access
+	platform         string                    // for role auth
+	serverKey        string                    // for use auth
+	apiKey           string                    // for webhook auth
+	denylist         *auth.MemoryDenylist      // for access token revocation
+	accountLimiter   *lockout.Limiter          // for per account login throttling
+	ipLimiter        *lockout.Limiter          // for per ip login throttling
+	magicLinkLimiter *lockout.Limiter          // for per email magic link and password reset throttling
+	passwordHasher   auth.PasswordHasher       // for hashing and verifying passwords
+	passwordPolicy   passwords.Policy          // for vetting new passwords
+	webauthn         webauthn.Config           // for passkey ceremonies
+	mailer           mailer.Mailer             // for verification and reset emails
+	baseURL          string                    // for links in emails
+	oidcProviders    map[string]*oidc.Provider // for social sign in, by name
+	blobStore        blobstore.BlobStore       // for uploaded media
+	spamPolicy       spam.Policy               // for spotting spam chirps
 }
 
 // user database struct
@@ -47,8 +68,11 @@ func main() {
 	// get fields from .env file
 	dbURL := os.Getenv("DB_URL")
 	appPlatform := os.Getenv("PLATFORM")
-	secretKey := strings.TrimSpace(os.Getenv("SECRET_KEY")) // remove whitespace from start and finish!
-	polkaKey := strings.TrimSpace(os.Getenv("POLKA_KEY"))   // remove ws
+	secretKey := strings.TrimSpace(os.Getenv("SECRET_KEY"))             // remove whitespace from start and finish!
+	polkaKey := strings.TrimSpace(os.Getenv("POLKA_KEY"))               // remove ws
+	adminEmail := strings.TrimSpace(os.Getenv("BOOTSTRAP_ADMIN_EMAIL")) // optional, promoted to admin on start
+	attemptStore := strings.TrimSpace(os.Getenv("LOGIN_ATTEMPT_STORE")) // optional, memory (default) or postgres
+	baseURL := strings.TrimSpace(os.Getenv("APP_BASE_URL"))             // optional, where emailed links point
 	// reaches into os env and gets the value at key
 
 	// dbURL check
@@ -71,6 +95,67 @@ func main() {
 		log.Fatal("POLKA_KEY is not set")
 	}
 
+	// password hashing config
+	passwordHasher, err := passwordHasherFromEnv()
+
+	// hasher config check
+	if err != nil {
+		log.Fatal("invalid password hashing config:", err)
+	}
+
+	// password policy config (may load a breached password corpus)
+	passwordPolicy, err := passwordPolicyFromEnv(passwordHasher)
+
+	// policy config check
+	if err != nil {
+		log.Fatal("invalid password policy config:", err)
+	}
+
+	// passkey relying party config
+	webauthnConfig, err := webauthnConfigFromEnv()
+
+	// webauthn config check
+	if err != nil {
+		log.Fatal("invalid webauthn config:", err)
+	}
+
+	// mailer config
+	appMailer, err := mailerFromEnv()
+
+	// mailer config check
+	if err != nil {
+		log.Fatal("invalid mailer config:", err)
+	}
+
+	// media storage config
+	blobStore, err := blobStoreFromEnv()
+
+	// blob store config check
+	if err != nil {
+		log.Fatal("invalid media store config:", err)
+	}
+
+	// spam filter config
+	spamPolicy, err := spamPolicyFromEnv()
+
+	// spam policy check
+	if err != nil {
+		log.Fatal("invalid spam policy config:", err)
+	}
+
+	// default links to the local server
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	// identity providers config (redirects come back to baseURL)
+	oidcProviders, err := oidcProvidersFromEnv(baseURL)
+
+	// oidc config check
+	if err != nil {
+		log.Fatal("invalid oidc config:", err)
+	}
+
 	// open connection to your database using the DBUrl and driver
 	db, err := sql.Open("postgres", dbURL)
 
@@ -82,6 +167,17 @@ func main() {
 	// use SQLC database package
 	dbQueries := database.New(db)
 
+	// pick where failed login attempts are tracked
+	var loginAttempts lockout.Store
+	switch attemptStore {
+	case "", "memory":
+		loginAttempts = lockout.NewMemoryStore() // per instance, lost on restart
+	case "postgres":
+		loginAttempts = lockout.NewPostgresStore(dbQueries) // shared by every instance
+	default:
+		log.Fatal("LOGIN_ATTEMPT_STORE must be memory or postgres")
+	}
+
 	// set constants
 	const filepathRoot = "." // used constant
 	const port = "8080"
@@ -91,11 +187,53 @@ func main() {
 
 	// create apiConfig instance
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{}, // explicitly set to 0
-		db:             dbQueries,      // init the DBqueries for use in our handler
-		platform:       appPlatform,    // init the platform for handler auth
-		serverKey:      secretKey,      // init the server key for handler auth
-		apiKey:         polkaKey,       // init the polka key for webhook auth
+		fileserverHits:   atomic.Int32{},                                        // explicitly set to 0
+		db:               sqlQuerier{dbQueries, db},                             // init the DBqueries for use in our handler, with the pool for transactions
+		platform:         appPlatform,                                           // init the platform for handler auth
+		serverKey:        secretKey,                                             // init the server key for handler auth
+		apiKey:           polkaKey,                                              // init the polka key for webhook auth
+		denylist:         auth.NewMemoryDenylist(),                              // init the empty access token denylist
+		accountLimiter:   lockout.NewLimiter(loginAttempts, accountLoginPolicy), // init the per account login throttle
+		ipLimiter:        lockout.NewLimiter(loginAttempts, ipLoginPolicy),      // init the per ip login throttle
+		magicLinkLimiter: lockout.NewLimiter(loginAttempts, magicLinkPolicy),    // init the per email mail link throttle
+		passwordHasher:   passwordHasher,                                        // init the password hasher
+		passwordPolicy:   passwordPolicy,                                        // init the password policy
+		webauthn:         webauthnConfig,                                        // init the passkey relying party
+		mailer:           appMailer,                                             // init the mailer
+		baseURL:          baseURL,                                               // init the emailed link base
+		oidcProviders:    oidcProviders,                                         // init the identity providers
+		blobStore:        blobStore,                                             // init the media store
+		spamPolicy:       spamPolicy,                                            // init the spam filter
+	}
+
+	// load revoked access tokens before serving any requests
+	err = apiCfg.syncDenylist(context.Background())
+
+	// denylist sync check
+	if err != nil {
+		log.Fatal("error loading access token denylist:", err)
+	}
+
+	// then keep it fresh so emergency revocations land within a minute
+	go apiCfg.runDenylistSync(time.Minute)
+
+	// delete accounts whose grace period is over
+	go apiCfg.runAccountPurge(time.Hour)
+
+	// delete uploads no chirp used in time
+	go apiCfg.runAttachmentSweep(time.Hour)
+
+	// forget quiet login attempts so per ip records don't pile up
+	go apiCfg.runLoginAttemptPrune(10 * time.Minute)
+
+	// promote the bootstrap admin, if set
+	if adminEmail != "" {
+		err = apiCfg.bootstrapAdmin(context.Background(), adminEmail)
+
+		// bootstrap check (they may simply not have registered yet)
+		if err != nil {
+			log.Printf("Could not bootstrap admin %s: %s", adminEmail, err)
+		}
 	}
 
 	// create the file server handle
@@ -110,10 +248,16 @@ func main() {
 	mux.Handle("/app/", fsHandler)
 	// mux.Handle("/app/", ...) -- server handle all requests
 
+	// AUTH POLICIES (what each route demands of its caller)
+	staffOnly := authPolicy{Roles: []string{auth.RoleModerator, auth.RoleAdmin}}  // moderators and admins
+	adminOnly := authPolicy{Roles: []string{auth.RoleAdmin}}                      // admins only
+	sessionOnly := authPolicy{SessionOnly: true}                                  // any user, but no api tokens
+	optionalChirpsRead := authPolicy{Optional: true, Scope: auth.ScopeChirpsRead} // anonymous or chirps:read
+
 	// REGISTER HANDLERS
 	// ADMIN ONLY HANDLERS
 	// register handlerMetrics, using /admin/metrics system endpoint
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics) // register func that receives apiCfg
+	mux.Handle("GET /admin/metrics", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerMetrics)) // staff only
 	// GET HTTP method routing only
 	// metrics, no z, as this is a conventional name!
 
@@ -121,6 +265,57 @@ func main() {
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerAdminUsersReset) // register func that receives apiCfg
 	// POST HTTP method routing only
 	// reset, no z as this is a conventional name!
+	// destructive, so stays guarded by PLATFORM == "dev" rather than by role
+
+	// register handlerAdminSetUserRole, using /admin/users/{userID}/role system endpoint
+	mux.Handle("PUT /admin/users/{userID}/role", apiCfg.middlewareAuth(adminOnly, apiCfg.handlerAdminSetUserRole)) // admins only
+	// PUT HTTP method routing only
+
+	// register handlerAdminUnlockUser, using /admin/users/{userID}/unlock system endpoint
+	mux.Handle("POST /admin/users/{userID}/unlock", apiCfg.middlewareAuth(adminOnly, apiCfg.handlerAdminUnlockUser)) // admins only
+	// POST HTTP method routing only
+
+	// register handlerAdminRevokeAccessToken, using /admin/tokens/revoke system endpoint
+	mux.Handle("POST /admin/tokens/revoke", apiCfg.middlewareAuth(adminOnly, apiCfg.handlerAdminRevokeAccessToken)) // admins only
+	// POST HTTP method routing only
+
+	// MODERATION HANDLERS
+	// register handlerListReports, using /admin/reports system endpoint
+	mux.Handle("GET /admin/reports", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerListReports)) // staff only
+	// GET HTTP method routing only
+	// the queue, ?status= ?limit=
+
+	// register handlerGetReport, using /admin/reports/{reportID} system endpoint
+	mux.Handle("GET /admin/reports/{reportID}", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerGetReport)) // staff only
+	// GET HTTP method routing only
+
+	// register handlerModerateReport, using /admin/reports/{reportID}/actions system endpoint
+	mux.Handle("POST /admin/reports/{reportID}/actions", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerModerateReport)) // staff only
+	// POST HTTP method routing only
+	// dismiss, hide_chirp, delete_chirp or suspend_user, recorded with the moderator and notes
+
+	// register handlerSuspendUser, using /admin/users/{userID}/suspension system endpoint
+	mux.Handle("POST /admin/users/{userID}/suspension", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerSuspendUser)) // staff only
+	// POST HTTP method routing only
+	// login refused, live tokens rejected, chirps hidden, the user is emailed
+
+	// register handlerLiftSuspension, using /admin/users/{userID}/suspension system endpoint
+	mux.Handle("DELETE /admin/users/{userID}/suspension", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerLiftSuspension)) // staff only
+	// DELETE HTTP method routing only
+
+	// register handlerShadowBanUser, using /admin/users/{userID}/shadow-ban system endpoint
+	mux.Handle("POST /admin/users/{userID}/shadow-ban", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerShadowBanUser)) // staff only
+	// POST HTTP method routing only
+	// chirps seen by the user only, nobody is told
+
+	// register handlerLiftShadowBan, using /admin/users/{userID}/shadow-ban system endpoint
+	mux.Handle("DELETE /admin/users/{userID}/shadow-ban", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerLiftShadowBan)) // staff only
+	// DELETE HTTP method routing only
+
+	// register handlerGetUserRestrictions, using /admin/users/{userID}/restrictions system endpoint
+	mux.Handle("GET /admin/users/{userID}/restrictions", apiCfg.middlewareAuth(staffOnly, apiCfg.handlerGetUserRestrictions)) // staff only
+	// GET HTTP method routing only
+	// suspension and shadow ban history, latest first
 
 	// SYSTEM READINESS HANDLERS
 	// register handlerReadiness, using /api/healthz system endpoint
@@ -130,21 +325,69 @@ func main() {
 
 	// CHIRPS HANDLERS
 	// register handlerCreateChirp, using /api/chirps system endpoint
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp) // register func that receives apiCfg
+	mux.Handle("POST /api/chirps", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeChirpsWrite}, apiCfg.handlerCreateChirp)) // register func that receives apiCfg
 	// POST HTTP method routing only
 
 	// register handlerGetChirps, using /api/chirps system endpoint
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps) // register func that receives apiCfg
+	mux.Handle("GET /api/chirps", apiCfg.middlewareAuth(optionalChirpsRead, apiCfg.handlerGetChirps)) // register func that receives apiCfg
 	// GET HTTP method routing only
 	// now handles author id query e.g. ?author_id=1
 
 	// register handlerGetChirp, using /api/chirps/{chirpID} system endpoint
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp) // register func that receives apiCfg
+	mux.Handle("GET /api/chirps/{chirpID}", apiCfg.middlewareAuth(optionalChirpsRead, apiCfg.handlerGetChirp)) // register func that receives apiCfg
 	// GET HTTP method routing only
 
 	// register handlerDeleteChirp, using /api/chirps/{chirpID} system endpoint
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp) // register func that receives apiCfg
+	mux.Handle("DELETE /api/chirps/{chirpID}", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeChirpsWrite}, apiCfg.handlerDeleteChirp)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// register handlerReportChirp, using /api/chirps/{chirpID}/report system endpoint
+	mux.Handle("POST /api/chirps/{chirpID}/report", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeReportsWrite}, apiCfg.handlerReportChirp)) // register func that receives apiCfg
+	// POST HTTP method routing only
+	// goes to the moderation queue
+
+	// BOOKMARKS HANDLERS
+	// register handlerBookmarkChirp, using /api/chirps/{chirpID}/bookmark system endpoint
+	mux.Handle("POST /api/chirps/{chirpID}/bookmark", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeBookmarksWrite}, apiCfg.handlerBookmarkChirp)) // register func that receives apiCfg
+	// POST HTTP method routing only
+	// optional collection_id, bookmarking again moves it between collections
+
+	// register handlerUnbookmarkChirp, using /api/chirps/{chirpID}/bookmark system endpoint
+	mux.Handle("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeBookmarksWrite}, apiCfg.handlerUnbookmarkChirp)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// register handlerGetBookmarks, using /api/bookmarks system endpoint
+	mux.Handle("GET /api/bookmarks", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeBookmarksRead}, apiCfg.handlerGetBookmarks)) // register func that receives apiCfg
+	// GET HTTP method routing only
+	// newest first, ?limit= ?cursor= ?collection_id=
+
+	// register handlerCreateCollection, using /api/bookmarks/collections system endpoint
+	mux.Handle("POST /api/bookmarks/collections", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeBookmarksWrite}, apiCfg.handlerCreateCollection)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerListCollections, using /api/bookmarks/collections system endpoint
+	mux.Handle("GET /api/bookmarks/collections", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeBookmarksRead}, apiCfg.handlerListCollections)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerRenameCollection, using /api/bookmarks/collections/{collectionID} system endpoint
+	mux.Handle("PATCH /api/bookmarks/collections/{collectionID}", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeBookmarksWrite}, apiCfg.handlerRenameCollection)) // register func that receives apiCfg
+	// PATCH HTTP method routing only
+
+	// register handlerDeleteCollection, using /api/bookmarks/collections/{collectionID} system endpoint
+	mux.Handle("DELETE /api/bookmarks/collections/{collectionID}", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeBookmarksWrite}, apiCfg.handlerDeleteCollection)) // register func that receives apiCfg
 	// DELETE HTTP method routing only
+	// the bookmarks in it are kept, just uncollected
+
+	// MEDIA HANDLERS
+	// register handlerUploadAttachment, using /api/attachments system endpoint
+	mux.Handle("POST /api/attachments", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeChirpsWrite}, apiCfg.handlerUploadAttachment)) // register func that receives apiCfg
+	// POST HTTP method routing only
+	// multipart upload, attached to a chirp by id when it's posted
+
+	// register handlerServeMedia, using /media/{key} system endpoint
+	mux.HandleFunc("GET /media/{key}", apiCfg.handlerServeMedia) // register func that receives apiCfg
+	// GET HTTP method routing only (HEAD is matched too)
+	// public, long lived caching
 
 	// USERS HANDLERS
 	// register handlerCreateUser, using /api/users system endpoint
@@ -152,13 +395,229 @@ func main() {
 	// POST HTTP method routing only
 
 	// register handlerUpdateUser, using /api/users system endpoint
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser) // register func that receives apiCfg
+	mux.Handle("PUT /api/users", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerUpdateUser)) // register func that receives apiCfg
 	// PUT HTTP method routing only
+	// login details, so no api tokens
+
+	// register handlerGetMe, using /api/users/me system endpoint
+	mux.Handle("GET /api/users/me", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileRead}, apiCfg.handlerGetMe)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerPatchMe, using /api/users/me system endpoint
+	mux.Handle("PATCH /api/users/me", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileWrite}, apiCfg.handlerPatchMe)) // register func that receives apiCfg
+	// PATCH HTTP method routing only
+
+	// register handlerImportChirps, using /api/users/me/import system endpoint
+	mux.Handle("POST /api/users/me/import", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeChirpsWrite}, apiCfg.handlerImportChirps)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerDeleteMe, using /api/users/me system endpoint
+	mux.Handle("DELETE /api/users/me", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerDeleteMe)) // register func that receives apiCfg
+	// deleted after a grace period, cancel with DELETE /api/users/me/deletion
+	// DELETE HTTP method routing only
+
+	// register handlerCancelDeletion, using /api/users/me/deletion system endpoint
+	mux.Handle("DELETE /api/users/me/deletion", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerCancelDeletion)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// register handlerExportMe, using /api/users/me/export system endpoint
+	mux.Handle("GET /api/users/me/export", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerExportMe)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerChangePassword, using /api/users/me/password system endpoint
+	mux.Handle("POST /api/users/me/password", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerChangePassword)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerGetProfile, using /api/users/{handle} system endpoint
+	mux.Handle("GET /api/users/{handle}", apiCfg.middlewareAuth(authPolicy{Optional: true}, apiCfg.handlerGetProfile)) // register func that receives apiCfg
+	// public, /api/users/me is the more specific pattern and wins
+	// signed in callers get a 404 across a block
+	// GET HTTP method routing only
+
+	// register handlerUploadAvatar, using /api/users/me/avatar system endpoint
+	mux.Handle("POST /api/users/me/avatar", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileWrite}, apiCfg.handlerUploadAvatar)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerDeleteAvatar, using /api/users/me/avatar system endpoint
+	mux.Handle("DELETE /api/users/me/avatar", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileWrite}, apiCfg.handlerDeleteAvatar)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// register handlerGetAvatar, using /api/users/{userID}/avatar system endpoint
+	mux.HandleFunc("GET /api/users/{userID}/avatar", apiCfg.handlerGetAvatar) // register func that receives apiCfg
+	// public, an identicon until the user uploads one
+	// GET HTTP method routing only
+
+	// register handlerVerifyEmail, using /api/users/verify-email system endpoint
+	mux.HandleFunc("POST /api/users/verify-email", apiCfg.handlerVerifyEmail) // register func that receives apiCfg
+	// the emailed token is the credential
+	// POST HTTP method routing only
+
+	// register handlerConfirmEmailChange, using /api/users/email/confirm system endpoint
+	mux.HandleFunc("POST /api/users/email/confirm", apiCfg.handlerConfirmEmailChange) // register func that receives apiCfg
+	// the emailed token is the credential
+	// POST HTTP method routing only
+
+	// register handlerResendVerification, using /api/users/verify-email/resend system endpoint
+	mux.Handle("POST /api/users/verify-email/resend", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerResendVerification)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerForgotPassword, using /api/password/forgot system endpoint
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.handlerForgotPassword) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerResetPassword, using /api/password/reset system endpoint
+	mux.HandleFunc("POST /api/password/reset", apiCfg.handlerResetPassword) // register func that receives apiCfg
+	// the emailed token is the credential
+	// POST HTTP method routing only
 
 	// register handlerLoginUser, using /api/users system endpoint
 	mux.HandleFunc("POST /api/login", apiCfg.handlerUserLogin) // register func that receives apiCfg
 	// POST HTTP method routing only
 
+	// register handlerMagicLink, using /api/login/magic system endpoint
+	mux.HandleFunc("POST /api/login/magic", apiCfg.handlerMagicLink) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerRedeemMagicLink, using /api/login/magic/redeem system endpoint
+	mux.HandleFunc("POST /api/login/magic/redeem", apiCfg.handlerRedeemMagicLink) // register func that receives apiCfg
+	// the emailed token is the credential
+	// POST HTTP method routing only
+
+	// register handlerReportUser, using /api/users/{userID}/report system endpoint
+	mux.Handle("POST /api/users/{userID}/report", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeReportsWrite}, apiCfg.handlerReportUser)) // register func that receives apiCfg
+	// POST HTTP method routing only
+	// goes to the moderation queue
+
+	// BLOCKS AND MUTES HANDLERS
+	// register handlerListBlocks, using /api/users/me/blocks system endpoint
+	mux.Handle("GET /api/users/me/blocks", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileRead}, apiCfg.handlerListBlocks)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerBlockUser, using /api/users/me/blocks/{userID} system endpoint
+	mux.Handle("POST /api/users/me/blocks/{userID}", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileWrite}, apiCfg.handlerBlockUser)) // register func that receives apiCfg
+	// POST HTTP method routing only
+	// both ways: neither sees the other's chirps, profile or bookmarks of them
+
+	// register handlerUnblockUser, using /api/users/me/blocks/{userID} system endpoint
+	mux.Handle("DELETE /api/users/me/blocks/{userID}", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileWrite}, apiCfg.handlerUnblockUser)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// register handlerListMutes, using /api/users/me/mutes system endpoint
+	mux.Handle("GET /api/users/me/mutes", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileRead}, apiCfg.handlerListMutes)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerMuteUser, using /api/users/me/mutes/{userID} system endpoint
+	mux.Handle("POST /api/users/me/mutes/{userID}", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileWrite}, apiCfg.handlerMuteUser)) // register func that receives apiCfg
+	// POST HTTP method routing only
+	// one way: hides them from the caller's feed, nothing else
+
+	// register handlerUnmuteUser, using /api/users/me/mutes/{userID} system endpoint
+	mux.Handle("DELETE /api/users/me/mutes/{userID}", apiCfg.middlewareAuth(authPolicy{Scope: auth.ScopeProfileWrite}, apiCfg.handlerUnmuteUser)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// SOCIAL LOGIN HANDLERS
+	// register handlerListOIDCProviders, using /api/oidc/providers system endpoint
+	mux.HandleFunc("GET /api/oidc/providers", apiCfg.handlerListOIDCProviders) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerOIDCLogin, using /api/oidc/{provider}/login system endpoint
+	mux.HandleFunc("GET /api/oidc/{provider}/login", apiCfg.handlerOIDCLogin) // register func that receives apiCfg
+	// redirects the browser to the provider
+	// GET HTTP method routing only
+
+	// register handlerOIDCCallback, using /api/oidc/{provider}/callback system endpoint
+	mux.HandleFunc("GET /api/oidc/{provider}/callback", apiCfg.handlerOIDCCallback) // register func that receives apiCfg
+	// the provider redirects the browser here
+	// GET HTTP method routing only
+
+	// register handlerListIdentities, using /api/users/me/identities system endpoint
+	mux.Handle("GET /api/users/me/identities", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerListIdentities)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerLinkIdentity, using /api/users/me/identities/{provider} system endpoint
+	mux.Handle("POST /api/users/me/identities/{provider}", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerLinkIdentity)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerUnlinkIdentity, using /api/users/me/identities/{provider} system endpoint
+	mux.Handle("DELETE /api/users/me/identities/{provider}", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerUnlinkIdentity)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// OAUTH AUTHORIZATION SERVER HANDLERS
+	// register handlerOAuthAuthorizePage, using /oauth/authorize system endpoint
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.handlerOAuthAuthorizePage) // register func that receives apiCfg
+	// serves the consent page
+	// GET HTTP method routing only
+
+	// register handlerOAuthAuthorize, using /api/oauth/authorize system endpoint
+	mux.Handle("POST /api/oauth/authorize", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerOAuthAuthorize)) // register func that receives apiCfg
+	// the consent page posts the user's answer here
+	// POST HTTP method routing only
+
+	// register handlerOAuthToken, using /oauth/token system endpoint
+	mux.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken) // register func that receives apiCfg
+	// clients authenticate themselves
+	// POST HTTP method routing only
+
+	// register handlerOAuthRevoke, using /oauth/revoke system endpoint
+	mux.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke) // register func that receives apiCfg
+	// clients authenticate themselves
+	// POST HTTP method routing only
+
+	// register handlerCreateOAuthClient, using /api/oauth/clients system endpoint
+	mux.Handle("POST /api/oauth/clients", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerCreateOAuthClient)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerListOAuthClients, using /api/oauth/clients system endpoint
+	mux.Handle("GET /api/oauth/clients", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerListOAuthClients)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerDeleteOAuthClient, using /api/oauth/clients/{clientID} system endpoint
+	mux.Handle("DELETE /api/oauth/clients/{clientID}", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerDeleteOAuthClient)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// TWO FACTOR HANDLERS
+	// register handlerLoginMFA, using /api/login/2fa system endpoint
+	mux.HandleFunc("POST /api/login/2fa", apiCfg.handlerLoginMFA) // register func that receives apiCfg
+	// the mfa challenge token from /api/login is the credential
+	// POST HTTP method routing only
+
+	// register handlerEnrolTOTP, using /api/users/me/totp system endpoint
+	mux.Handle("POST /api/users/me/totp", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerEnrolTOTP)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerConfirmTOTP, using /api/users/me/totp/confirm system endpoint
+	mux.Handle("POST /api/users/me/totp/confirm", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerConfirmTOTP)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerDisableTOTP, using /api/users/me/totp system endpoint
+	mux.Handle("DELETE /api/users/me/totp", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerDisableTOTP)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
+	// PASSKEY HANDLERS
+	// register handlerBeginPasskeyLogin, using /api/passkeys/login/begin system endpoint
+	mux.HandleFunc("POST /api/passkeys/login/begin", apiCfg.handlerBeginPasskeyLogin) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerFinishPasskeyLogin, using /api/passkeys/login/finish system endpoint
+	mux.HandleFunc("POST /api/passkeys/login/finish", apiCfg.handlerFinishPasskeyLogin) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerBeginPasskeyRegistration, using /api/passkeys/register/begin system endpoint
+	mux.Handle("POST /api/passkeys/register/begin", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerBeginPasskeyRegistration)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerFinishPasskeyRegistration, using /api/passkeys/register/finish system endpoint
+	mux.Handle("POST /api/passkeys/register/finish", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerFinishPasskeyRegistration)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerListPasskeys, using /api/passkeys system endpoint
+	mux.Handle("GET /api/passkeys", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerListPasskeys)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerDeletePasskey, using /api/passkeys/{passkeyID} system endpoint
+	mux.Handle("DELETE /api/passkeys/{passkeyID}", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerDeletePasskey)) // register func that receives apiCfg
+	// DELETE HTTP method routing only
+
 	// TOKENS HANDLERS
 	// register handlerRefresh, using /api/refresh system endpoint
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh) // register func that receives apiCfg
@@ -168,6 +627,20 @@ func main() {
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke) // register func that receives apiCfg
 	// POST HTTP method routing only
 
+	// API TOKENS HANDLERS
+	// register handlerCreateAPIToken, using /api/tokens system endpoint
+	mux.Handle("POST /api/tokens", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerCreateAPIToken)) // register func that receives apiCfg
+	// POST HTTP method routing only
+
+	// register handlerListAPITokens, using /api/tokens system endpoint
+	mux.Handle("GET /api/tokens", apiCfg.middlewareAuth(sessionOnly, apiCfg.handlerListAPITokens)) // register func that receives apiCfg
+	// GET HTTP method routing only
+
+	// register handlerRevokeAPIToken, using /api/tokens/{tokenID} system endpoint
+	mux.Handle("DELETE /api/tokens/{tokenID}", apiCfg.middlewareAuth(authPolicy{}, apiCfg.handlerRevokeAPIToken)) // register func that receives apiCfg
+	// any authenticated caller, a token may revoke itself
+	// DELETE HTTP method routing only
+
 	// WEBHOOK HANDLERS
 	// register handlerPolaWebhook, using /api/polka/webhooks system endpoint
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhook) // register func that receives apiCfg
@@ -4,6 +4,7 @@ package main
 import (
 	"time"
 
+	"github.com/PietPadda/chirpy/internal/webauthn"
 	"github.com/google/uuid"
 )
 
@@ -16,14 +17,37 @@ type JsonRequest struct {
 
 // CreateUser request
 type JsonUserRequest struct {
-	Password string `json:"password"`
-	Email    string `json:"email"`
+	Password        string `json:"password"`
+	Email           string `json:"email"`
+	CurrentPassword string `json:"current_password"` // re-authentication, only for updates
+}
+
+// PatchMe request, nil fields are left alone
+type JsonUserPatchRequest struct {
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"` // needed to change the email
+	Handle          *string `json:"handle"`           // "" clears it
+	DisplayName     *string `json:"display_name"`
+	Bio             *string `json:"bio"`
+	AvatarURL       *string `json:"avatar_url"` // "" clears it
+}
+
+// ChangePassword request
+type JsonChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// DeleteAccount request, the password again so a stolen session can't do it
+type JsonDeleteAccountRequest struct {
+	CurrentPassword string `json:"current_password"`
 }
 
 // CreateChirp request
 type JsonChirpRequest struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body          string      `json:"body"`
+	UserID        uuid.UUID   `json:"user_id"`
+	AttachmentIDs []uuid.UUID `json:"attachment_ids"` // uploaded first via /api/attachments
 }
 
 // UserLogin request
@@ -44,6 +68,92 @@ type JsonPolkaWebhookRequest struct {
 	} `json:"data"`
 }
 
+// CreateAPIToken request
+type JsonAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays *int     `json:"expires_in_days"` // ptr so we can default when omitted
+}
+
+// Admin set user role request
+type JsonRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// Admin access token revocation request (token or jti)
+type JsonRevokeAccessTokenRequest struct {
+	Token  string `json:"token"`  // the full access token, preferred
+	JTI    string `json:"jti"`    // or just its id
+	Reason string `json:"reason"` // for the audit trail
+}
+
+// TOTP confirm / disable request
+type JsonTOTPCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"` // instead of code, disable only
+}
+
+// Second login step request
+type JsonMFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"` // when the authenticator is lost
+}
+
+// Passkey registration finish request
+type JsonPasskeyRegisterRequest struct {
+	ChallengeID uuid.UUID                     `json:"challenge_id"`
+	Name        string                        `json:"name"`
+	Credential  webauthn.RegistrationResponse `json:"credential"` // navigator.credentials.create result
+}
+
+// Passkey sign in finish request
+type JsonPasskeyLoginRequest struct {
+	ChallengeID uuid.UUID                  `json:"challenge_id"`
+	Credential  webauthn.AssertionResponse `json:"credential"` // navigator.credentials.get result
+}
+
+// Verify email request (token from the emailed link)
+type JsonEmailTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// Forgot password request
+type JsonForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// Reset password request (token from the emailed link)
+type JsonResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// Magic link login request
+type JsonMagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+// OAuth client registration request
+type JsonOAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	Confidential bool     `json:"confidential"` // server side apps that can keep a secret
+}
+
+// OAuth consent request (the authorization request plus the user's answer)
+type JsonOAuthAuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Approve             bool   `json:"approve"`
+}
+
 // RESPONSES
 // API JSON Response to Client
 type JsonResponse struct {
@@ -55,21 +165,63 @@ type JsonResponseError struct {
 	Error string `json:"error"`
 }
 
+// Client validation error response, one entry per field problem
+type JsonResponseFieldErrors struct {
+	Error  string           `json:"error"`
+	Fields []JsonFieldError `json:"fields"`
+}
+
+// Client field problem
+type JsonFieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 // Client user created response
 type JsonUserResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	PendingEmail  string     `json:"pending_email,omitempty"` // awaiting confirmation from the new inbox
+	Handle        string     `json:"handle,omitempty"`
+	DisplayName   string     `json:"display_name"`
+	Bio           string     `json:"bio"`
+	AvatarURL     string     `json:"avatar_url,omitempty"`
+	IsChirpyRed   bool       `json:"is_chirpy_red"`
+	DeleteAfter   *time.Time `json:"delete_after,omitempty"` // a deletion is pending until then
+}
+
+// Public profile response, never the email
+type JsonProfileResponse struct {
 	ID          uuid.UUID `json:"id"`
 	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	ChirpCount  int64     `json:"chirp_count"`
+}
+
+// One blocked or muted user, when says since when
+type JsonRelatedUserResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Handle    string    `json:"handle,omitempty"`
+	AvatarURL string    `json:"avatar_url"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Client user updated response
 type JsonUserUpdatedResponse struct {
-	ID          uuid.UUID `json:"id"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID           uuid.UUID `json:"id"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Email        string    `json:"email"`
+	PendingEmail string    `json:"pending_email,omitempty"` // awaiting confirmation from the new inbox
+	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Token        string    `json:"token,omitempty"`         // fresh pair when the password changed
+	RefreshToken string    `json:"refresh_token,omitempty"` // the old ones are revoked
 }
 
 // Client login successful response
@@ -85,14 +237,264 @@ type JsonUserLoginResponse struct {
 
 // Client chirp response
 type JsonChirpResponse struct {
-	ID        uuid.UUID `json:"id"`
+	ID             uuid.UUID                `json:"id"`
+	CreatedAt      time.Time                `json:"created_at"`
+	UpdatedAt      time.Time                `json:"updated_at"`
+	Body           string                   `json:"body"`
+	UserID         uuid.UUID                `json:"user_id"`
+	AuthorHandle   string                   `json:"author_handle,omitempty"` // empty until the author picks one
+	Attachments    []JsonAttachmentResponse `json:"attachments"`
+	BookmarkedByMe *bool                    `json:"bookmarked_by_me,omitempty"` // only for signed in callers
+	Hidden         bool                     `json:"hidden,omitempty"`           // hidden by a moderator, only its author sees it
+	Held           bool                     `json:"held,omitempty"`             // held for review by the spam filter, only its author sees it
+}
+
+// Bookmark request, the body is optional
+type JsonBookmarkRequest struct {
+	CollectionID *uuid.UUID `json:"collection_id"` // null or missing for uncollected
+}
+
+// Bookmark collection create/rename request
+type JsonCollectionRequest struct {
+	Name string `json:"name"`
+}
+
+// Bookmarks page response
+type JsonBookmarksResponse struct {
+	Bookmarks  []JsonBookmarkResponse `json:"bookmarks"`
+	NextCursor string                 `json:"next_cursor,omitempty"` // pass as ?cursor= for the next page, empty on the last
+}
+
+// One bookmarked chirp
+type JsonBookmarkResponse struct {
+	Chirp        JsonChirpResponse `json:"chirp"`
+	BookmarkedAt time.Time         `json:"bookmarked_at"`
+	CollectionID *uuid.UUID        `json:"collection_id"`
+}
+
+// Bookmark collection response
+type JsonCollectionResponse struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Name          string    `json:"name"`
+	BookmarkCount int64     `json:"bookmark_count"`
+}
+
+// Chirp attachment response
+type JsonAttachmentResponse struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+}
+
+// Account deletion scheduled response
+type JsonDeletionResponse struct {
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+// Data export session entry, never the token itself
+type JsonExportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// Data export subscription history entry
+type JsonExportSubscriptionEvent struct {
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	Event     string    `json:"event"`
+	Plan      string    `json:"plan"`
+}
+
+// Import report response
+type JsonImportResponse struct {
+	Imported int                 `json:"imported"`
+	Held     int                 `json:"held"` // imported, but held for review by the spam filter
+	Failed   []JsonImportFailure `json:"failed"`
+}
+
+// Import report entry for a chirp that didn't make it
+type JsonImportFailure struct {
+	Line  int    `json:"line"` // csv line, or position in the json list (from 1)
+	Error string `json:"error"`
+}
+
+// Avatar upload response
+type JsonAvatarResponse struct {
+	AvatarURL string `json:"avatar_url"`
 }
 
 // Client refresh response
 type JsonRefreshResponse struct {
 	Token string `json:"token"`
 }
+
+// Client personal access token response
+type JsonAPITokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`    // null until first use
+	Token      string     `json:"token,omitempty"` // only ever returned on creation
+}
+
+// Admin user role response
+type JsonRoleResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Role      string    `json:"role"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TOTP enrolment response
+type JsonTOTPEnrolResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// uri for a QR code
+}
+
+// TOTP confirmed response
+type JsonTOTPConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // shown once, single use
+}
+
+// Login response when a second factor is still needed
+type JsonMFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Passkey ceremony start response
+type JsonPasskeyBeginResponse struct {
+	ChallengeID uuid.UUID `json:"challenge_id"`
+	PublicKey   any       `json:"public_key"` // options for the browser webauthn api
+}
+
+// Client passkey response
+type JsonPasskeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at"` // null until first use
+}
+
+// Identity provider listing response
+type JsonOIDCProviderResponse struct {
+	Name     string `json:"name"`
+	LoginURL string `json:"login_url"` // send the browser here to sign in
+}
+
+// Identity link start response
+type JsonOIDCLinkResponse struct {
+	AuthorizationURL string    `json:"authorization_url"` // send the browser here to link
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// Client linked identity response
+type JsonIdentityResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"` // null until first sign in
+}
+
+// Client oauth app response
+type JsonOAuthClientResponse struct {
+	ClientID     uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	ClientSecret string    `json:"client_secret,omitempty"` // only ever shown once, at registration
+}
+
+// OAuth consent answer, where to send the browser next
+type JsonOAuthAuthorizeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// OAuth token endpoint response (rfc 6749 5.1)
+type JsonOAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// OAuth error response (rfc 6749 5.2)
+type JsonOAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Client report request, for a chirp or a user
+type JsonReportRequest struct {
+	Reason  string `json:"reason"`  // reason code, e.g. "spam"
+	Details string `json:"details"` // optional, required for "other"
+}
+
+// Report response
+type JsonReportResponse struct {
+	ID         uuid.UUID                      `json:"id"`
+	CreatedAt  time.Time                      `json:"created_at"`
+	Kind       string                         `json:"kind"`
+	ReporterID *uuid.UUID                     `json:"reporter_id"` // null when the spam filter held the chirp
+	UserID     uuid.UUID                      `json:"user_id"`
+	ChirpID    *uuid.UUID                     `json:"chirp_id"`
+	ChirpBody  string                         `json:"chirp_body,omitempty"` // the chirp as reported
+	Reason     string                         `json:"reason"`
+	Details    string                         `json:"details"`
+	Status     string                         `json:"status"`
+	ResolvedAt *time.Time                     `json:"resolved_at"`
+	Actions    []JsonModerationActionResponse `json:"actions,omitempty"` // moderators only
+}
+
+// Moderator action request
+type JsonModerationActionRequest struct {
+	Action      string `json:"action"`       // dismiss, hide_chirp, delete_chirp or suspend_user
+	Notes       string `json:"notes"`        // for other moderators, never shown to users
+	SuspendDays int    `json:"suspend_days"` // suspend_user only, defaults to a week
+}
+
+// Moderator action response
+type JsonModerationActionResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReportID    uuid.UUID  `json:"report_id"`
+	ModeratorID *uuid.UUID `json:"moderator_id"`
+	Action      string     `json:"action"`
+	Notes       string     `json:"notes"`
+}
+
+// Moderator suspension or shadow ban request
+type JsonRestrictionRequest struct {
+	Reason string `json:"reason"` // reason code, e.g. "spam"
+	Days   int    `json:"days"`   // defaults to a week
+}
+
+// Suspension or shadow ban response
+type JsonRestrictionResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Kind        string     `json:"kind"` // suspension or shadow_ban
+	UserID      uuid.UUID  `json:"user_id"`
+	ModeratorID *uuid.UUID `json:"moderator_id"`
+	Reason      string     `json:"reason"`
+	EndsAt      time.Time  `json:"ends_at"`
+	LiftedAt    *time.Time `json:"lifted_at"`
+}
+
+// A user's suspension and shadow ban history, latest first
+type JsonUserRestrictionsResponse struct {
+	Suspensions []JsonRestrictionResponse `json:"suspensions"`
+	ShadowBans  []JsonRestrictionResponse `json:"shadow_bans"`
+}
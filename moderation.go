@@ -0,0 +1,439 @@
+// moderation.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// what a moderator can do about a report
+const (
+	moderationActionDismiss     = "dismiss"      // nothing wrong, close it (and release a chirp held as spam)
+	moderationActionHideChirp   = "hide_chirp"   // only the author still sees the chirp
+	moderationActionDeleteChirp = "delete_chirp" // the chirp is gone for good
+	moderationActionSuspendUser = "suspend_user" // the reported user is suspended for a while
+)
+
+// moderation limits
+const (
+	defaultSuspendDays       = 7    // a week unless the moderator says otherwise
+	maxSuspendDays           = 365  // longer than this is a ban, not a suspension
+	maxModerationNotesLength = 1000 // in characters, not bytes
+	defaultReportPageSize    = 50   // reports per page unless ?limit= says otherwise
+	maxReportPageSize        = 100  // largest page a moderator may ask for
+)
+
+// another moderator closed the report first
+var errReportResolved = errors.New("report already resolved")
+
+// HELPERS
+// whether a chirp hidden by a moderator or held by the spam filter is hidden from the caller, only its author still sees it
+func hiddenFromCaller(ctx context.Context, chirp database.Chirp) bool {
+	if !chirp.HiddenAt.Valid && !chirp.HeldAt.Valid {
+		return false
+	}
+	caller, ok := principalFromContext(ctx)
+	return !ok || caller.UserID != chirp.UserID
+}
+
+// a moderation action as the api shows it
+func moderationActionResponse(action database.ModerationAction) JsonModerationActionResponse {
+	resp := JsonModerationActionResponse{
+		ID:        action.ID,
+		CreatedAt: action.CreatedAt,
+		ReportID:  action.ReportID,
+		Action:    action.Action,
+		Notes:     action.Notes,
+	}
+	if action.ModeratorID.Valid {
+		resp.ModeratorID = &action.ModeratorID.UUID
+	}
+	return resp
+}
+
+// tell the reported user what was done, best effort so failures are only logged
+func (apiCfg *apiConfig) notifyModerationAction(ctx context.Context, report database.Report, action string, suspendedUntil time.Time) {
+	// dismissals aren't news to anyone
+	if action == moderationActionDismiss {
+		return
+	}
+
+	// get the reported user
+	user, err := apiCfg.db.GetUserByID(ctx, report.UserID)
+	if err != nil {
+		log.Printf("Error getting user %s to notify: %s", report.UserID, err)
+		return
+	}
+
+	// send the matching notice
+	if action == moderationActionSuspendUser {
+		err = apiCfg.sendSuspensionNotice(ctx, user, report.Reason, suspendedUntil)
+	} else {
+		err = apiCfg.sendChirpActionedNotice(ctx, user, report.ChirpBody.String, action, report.Reason)
+	}
+	if err != nil {
+		log.Printf("Error sending moderation notice to %s: %s", user.ID, err)
+	}
+}
+
+// HANDLERS
+// ListReports handler that returns the moderation queue, oldest first (staff only)
+func (apiCfg *apiConfig) handlerListReports(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// the page to fetch, open reports unless ?status= says otherwise
+	query := req.URL.Query()
+	params := database.ListReportsParams{
+		Status:   reportStatusOpen,
+		PageSize: defaultReportPageSize,
+	}
+
+	// status check
+	if status := query.Get("status"); status != "" {
+		if status != reportStatusOpen && status != reportStatusDismissed && status != reportStatusActioned {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Status must be open, dismissed or actioned", http.StatusBadRequest)
+			return // early return
+		}
+		params.Status = status
+	}
+
+	// page size check
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxReportPageSize {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Limit must be between 1 and 100", http.StatusBadRequest)
+			return // early return
+		}
+		params.PageSize = int32(limit)
+	}
+
+	// get the reports
+	reports, err := apiCfg.db.ListReports(req.Context(), params)
+
+	// list reports check
+	if err != nil {
+		log.Printf("Error listing reports: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting reports", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload
+	respReports := make([]JsonReportResponse, 0, len(reports))
+	for _, report := range reports {
+		respReports = append(respReports, reportResponse(report, nil))
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respReports, http.StatusOK)
+}
+
+// GetReport handler that returns one report with what's been done about it (staff only)
+func (apiCfg *apiConfig) handlerGetReport(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get report id from api endpoint path string
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid report ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the report
+	report, err := apiCfg.db.GetReport(req.Context(), reportID)
+
+	// not found check
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Report not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get report check
+	if err != nil {
+		log.Printf("Error getting report: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting report", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// get its history
+	actions, err := apiCfg.db.ListModerationActionsForReport(req.Context(), report.ID)
+
+	// list actions check
+	if err != nil {
+		log.Printf("Error listing moderation actions: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting report", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, reportResponse(report, actions), http.StatusOK)
+}
+
+// ModerateReport handler that acts on an open report and records who did what (staff only)
+// the reported user is emailed when their chirp is hidden or deleted, or they're suspended
+func (apiCfg *apiConfig) handlerModerateReport(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated moderator (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get report id from api endpoint path string
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid report ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// json request from client
+	var reqAction JsonModerationActionRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err = decoder.Decode(&reqAction)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqAction is now successfully populated
+
+	// known action check
+	switch reqAction.Action {
+	case moderationActionDismiss, moderationActionHideChirp, moderationActionDeleteChirp, moderationActionSuspendUser:
+	default:
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Action must be dismiss, hide_chirp, delete_chirp or suspend_user", http.StatusBadRequest)
+		return // early return
+	}
+
+	// notes check
+	reqAction.Notes = strings.TrimSpace(reqAction.Notes)
+	if utf8.RuneCountInString(reqAction.Notes) > maxModerationNotesLength {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Notes are too long", http.StatusBadRequest)
+		return // early return
+	}
+
+	// suspension length check
+	if reqAction.Action == moderationActionSuspendUser {
+		if reqAction.SuspendDays == 0 {
+			reqAction.SuspendDays = defaultSuspendDays
+		}
+		if reqAction.SuspendDays < 1 || reqAction.SuspendDays > maxSuspendDays {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "Suspensions must be 1 to 365 days", http.StatusBadRequest)
+			return // early return
+		}
+	}
+
+	// get the report
+	report, err := apiCfg.db.GetReport(req.Context(), reportID)
+
+	// not found check
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Report not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get report check
+	if err != nil {
+		log.Printf("Error getting report: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred moderating report", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// still open check
+	if report.Status != reportStatusOpen {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "Report has already been resolved", http.StatusConflict)
+		return // early return
+	}
+
+	// chirp actions need a chirp
+	if reqAction.Action == moderationActionHideChirp || reqAction.Action == moderationActionDeleteChirp {
+		// user report check
+		if report.Kind != reportKindChirp {
+			// helper to insert error msg + 400 bad req status code
+			WriteJSONError(w, "This report is not about a chirp", http.StatusBadRequest)
+			return // early return
+		}
+
+		// deleted since check
+		if !report.ChirpID.Valid {
+			// helper to insert error msg + 409 conflict status code
+			WriteJSONError(w, "The chirp no longer exists", http.StatusConflict)
+			return // early return
+		}
+	}
+
+	// suspensions go through the same target checks as the restriction endpoints
+	if reqAction.Action == moderationActionSuspendUser && apiCfg.refuseRestrictionTarget(w, req, caller, report.UserID) {
+		return // early return
+	}
+
+	// close the report as the outcome says
+	status := reportStatusActioned
+	if reqAction.Action == moderationActionDismiss {
+		status = reportStatusDismissed
+	}
+
+	// claim the report and carry out the action together, so two moderators can't both act on it
+	var action database.ModerationAction
+	var attachments []database.Attachment
+	var suspension database.Suspension
+	err = apiCfg.db.inTx(req.Context(), func(q database.Querier) error {
+		// claim it, someone else may have closed it since we looked
+		resolved, err := q.ResolveReport(req.Context(), database.ResolveReportParams{
+			ID:     report.ID,
+			Status: status,
+		})
+		if err != nil {
+			return err
+		}
+		if resolved == 0 {
+			return errReportResolved
+		}
+
+		// carry out the action
+		switch reqAction.Action {
+		case moderationActionDismiss:
+			// a chirp held by the spam filter is let through (reporters' dismissals change nothing)
+			// only the hold is lifted, a moderator's own hide stays put
+			if !report.ReporterID.Valid && report.ChirpID.Valid {
+				_, err = q.ReleaseChirp(req.Context(), report.ChirpID.UUID)
+			}
+
+		case moderationActionHideChirp:
+			// hide it (already hidden is fine)
+			_, err = q.HideChirp(req.Context(), report.ChirpID.UUID)
+
+		case moderationActionDeleteChirp:
+			// note the attachments, their rows go with the chirp but the blobs don't
+			attachments, err = q.ListAttachmentsForChirps(req.Context(), []uuid.UUID{report.ChirpID.UUID})
+			if err != nil {
+				return err
+			}
+
+			// delete it (deleted since is fine)
+			_, err = q.DeleteChirp(req.Context(), report.ChirpID.UUID)
+			if errors.Is(err, sql.ErrNoRows) {
+				err = nil
+			}
+
+		case moderationActionSuspendUser:
+			// suspend them and sign them out everywhere
+			suspendedUntil := time.Now().UTC().Add(time.Duration(reqAction.SuspendDays) * 24 * time.Hour)
+			suspension, err = suspendUser(req.Context(), q, report.UserID, caller.UserID, report.Reason, suspendedUntil)
+		}
+		if err != nil {
+			return err
+		}
+
+		// record who did what
+		action, err = q.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+			ReportID:    report.ID,
+			ModeratorID: uuid.NullUUID{UUID: caller.UserID, Valid: true},
+			Action:      reqAction.Action,
+			Notes:       reqAction.Notes,
+		})
+		return err
+	})
+
+	// claimed meanwhile check
+	if errors.Is(err, errReportResolved) {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "Report has already been resolved", http.StatusConflict)
+		return // early return
+	}
+
+	// action check
+	if err != nil {
+		log.Printf("Error carrying out %s on report %s: %s", reqAction.Action, report.ID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred moderating report", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// committed, now the side effects outside the db
+	apiCfg.deleteAttachmentBlobs(req.Context(), attachments)
+	if reqAction.Action == moderationActionSuspendUser {
+		apiCfg.denySuspended(suspension)
+	}
+
+	// tell the reported user (best effort)
+	apiCfg.notifyModerationAction(req.Context(), report, reqAction.Action, suspension.EndsAt)
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, moderationActionResponse(action), http.StatusCreated)
+}
@@ -0,0 +1,157 @@
+// moderation_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test a hidden or held chirp is only seen by its author
+func TestHiddenFromCaller(t *testing.T) {
+	authorID := uuid.New()
+	visible := database.Chirp{ID: uuid.New(), UserID: authorID}
+	hidden := database.Chirp{ID: uuid.New(), UserID: authorID, HiddenAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	held := database.Chirp{ID: uuid.New(), UserID: authorID, HeldAt: sql.NullTime{Time: time.Now(), Valid: true}}
+
+	anonymous := context.Background()
+	author := context.WithValue(context.Background(), principalContextKey, principal{UserID: authorID})
+	other := context.WithValue(context.Background(), principalContextKey, principal{UserID: uuid.New()})
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		chirp database.Chirp
+		want  bool
+	}{
+		{"Visible", anonymous, visible, false},
+		{"Hidden anonymous", anonymous, hidden, true},
+		{"Hidden other", other, hidden, true},
+		{"Hidden author", author, hidden, false},
+		{"Held other", other, held, true},
+		{"Held author", author, held, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hiddenFromCaller(tt.ctx, tt.chirp); got != tt.want {
+				t.Errorf("hiddenFromCaller() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// test bad actions are refused before the db is touched
+func TestModerateReportBadRequest(t *testing.T) {
+	apiCfg := &apiConfig{}
+	tests := []struct {
+		name     string
+		reportID string
+		body     string
+	}{
+		{"Invalid ID", "nope", `{"action":"dismiss"}`},
+		{"Empty body", uuid.NewString(), ``},
+		{"Unknown action", uuid.NewString(), `{"action":"ban_forever"}`},
+		{"Suspension too long", uuid.NewString(), `{"action":"suspend_user","suspend_days":366}`},
+		{"Notes too long", uuid.NewString(), `{"action":"dismiss","notes":"` + strings.Repeat("a", maxModerationNotesLength+1) + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/reports/"+tt.reportID+"/actions", strings.NewReader(tt.body))
+			req.SetPathValue("reportID", tt.reportID)
+			ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+			rec := httptest.NewRecorder()
+			apiCfg.handlerModerateReport(rec, req.WithContext(ctx))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// test bad queue filters are refused before the db is touched
+func TestListReportsBadRequest(t *testing.T) {
+	apiCfg := &apiConfig{}
+	for _, query := range []string{"status=closed", "limit=0", "limit=101"} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/reports?"+query, nil)
+			rec := httptest.NewRecorder()
+			apiCfg.handlerListReports(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// test dismissing a report only lifts a spam filter hold, never a moderator's own hide
+func TestModerateReportHolds(t *testing.T) {
+	now := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	reporter := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+
+	// build test cases
+	testCases := []struct {
+		name           string         // name for test case
+		chirp          database.Chirp // the reported chirp, author and id filled in
+		reporterID     uuid.NullUUID  // unset for the spam filter's own reports
+		status         string         // report status before acting
+		action         string         // what the moderator does
+		expectedStatus int            // status code we want
+		expectedHidden bool           // moderator hide after
+		expectedHeld   bool           // spam hold after
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: dismiss spam hold", database.Chirp{HeldAt: now}, uuid.NullUUID{}, reportStatusOpen, moderationActionDismiss, http.StatusCreated, false, false},
+		{"Test case: dismiss spam hold, hidden by a moderator too", database.Chirp{HeldAt: now, HiddenAt: now}, uuid.NullUUID{}, reportStatusOpen, moderationActionDismiss, http.StatusCreated, true, false},
+		{"Test case: dismiss user report", database.Chirp{HiddenAt: now}, reporter, reportStatusOpen, moderationActionDismiss, http.StatusCreated, true, false},
+		{"Test case: hide held chirp", database.Chirp{HeldAt: now}, uuid.NullUUID{}, reportStatusOpen, moderationActionHideChirp, http.StatusCreated, true, true},
+		{"Test case: already dismissed", database.Chirp{HeldAt: now}, uuid.NullUUID{}, reportStatusDismissed, moderationActionDismiss, http.StatusConflict, false, true},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		// one reported chirp
+		fake := newFakeQuerier()
+		chirp := tc.chirp
+		chirp.ID = uuid.New()
+		chirp.UserID = uuid.New()
+		fake.chirps[chirp.ID] = chirp
+		report := database.Report{
+			ID:         uuid.New(),
+			Kind:       reportKindChirp,
+			ReporterID: tc.reporterID,
+			UserID:     chirp.UserID,
+			ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Reason:     "spam",
+			Status:     tc.status,
+		}
+		fake.reports[report.ID] = report
+		apiCfg := &apiConfig{db: fake}
+
+		// act on it
+		body := strings.NewReader(`{"action":"` + tc.action + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/admin/reports/"+report.ID.String()+"/actions", body)
+		req.SetPathValue("reportID", report.ID.String())
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New(), Role: auth.RoleModerator})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerModerateReport(rec, req.WithContext(ctx))
+
+		// check the outcome
+		if rec.Code != tc.expectedStatus {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.expectedStatus)
+		}
+		after := fake.chirps[chirp.ID]
+		if after.HiddenAt.Valid != tc.expectedHidden || after.HeldAt.Valid != tc.expectedHeld {
+			t.Errorf("%s: hidden, held = %v, %v, want %v, %v", tc.name, after.HiddenAt.Valid, after.HeldAt.Valid, tc.expectedHidden, tc.expectedHeld)
+		}
+	}
+}
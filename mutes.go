@@ -0,0 +1,174 @@
+// mutes.go
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// HANDLERS
+// MuteUser handler that mutes a user, their chirps leave the caller's feeds but nothing else changes
+func (apiCfg *apiConfig) handlerMuteUser(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get user id from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// self check
+	if userID == caller.UserID {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "You can't mute yourself", http.StatusBadRequest)
+		return // early return
+	}
+
+	// mute them
+	err = apiCfg.db.CreateMute(req.Context(), database.CreateMuteParams{
+		MuterID: caller.UserID,
+		MutedID: userID,
+	})
+
+	// no such user check (foreign key violation)
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23503" {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// create mute check
+	if err != nil {
+		log.Printf("Error muting user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred muting user", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
+
+// UnmuteUser handler that lifts a mute, lifting one that isn't there is fine
+func (apiCfg *apiConfig) handlerUnmuteUser(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get user id from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// unmute them
+	err = apiCfg.db.DeleteMute(req.Context(), database.DeleteMuteParams{
+		MuterID: caller.UserID,
+		MutedID: userID,
+	})
+
+	// delete mute check
+	if err != nil {
+		log.Printf("Error unmuting user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred unmuting user", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// set the status code
+	w.WriteHeader(http.StatusNoContent) // 204 no content
+}
+
+// ListMutes handler that returns the users the caller has muted, newest first
+func (apiCfg *apiConfig) handlerListMutes(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the mutes
+	rows, err := apiCfg.db.ListMutes(req.Context(), caller.UserID)
+
+	// list mutes check
+	if err != nil {
+		log.Printf("Error listing mutes: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting muted users", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload
+	respMutes := make([]JsonRelatedUserResponse, 0, len(rows))
+	for _, row := range rows {
+		respMutes = append(respMutes, JsonRelatedUserResponse{
+			UserID:    row.MutedID,
+			Handle:    row.Handle.String,
+			AvatarURL: apiCfg.avatarURL(row.MutedID, row.AvatarUrl),
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respMutes, http.StatusOK)
+}
@@ -0,0 +1,80 @@
+// mutes_test.go
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing" // importing testing package for unit tests
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test bad targets are refused before the db is touched
+func TestMuteUserBadRequest(t *testing.T) {
+	apiCfg := &apiConfig{}
+	callerID := uuid.New()
+	for name, target := range map[string]string{"Invalid ID": "nope", "Self": callerID.String()} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/users/me/mutes/"+target, nil)
+			req.SetPathValue("userID", target)
+			ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: callerID})
+			rec := httptest.NewRecorder()
+			apiCfg.handlerMuteUser(rec, req.WithContext(ctx))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// test muting is refused for the caller and unknown users, and muting or unmuting twice is a no-op
+func TestMuteUnmuteUser(t *testing.T) {
+	callerID := uuid.New()
+	userID := uuid.New()
+	fake := newFakeQuerier()
+	fake.users[userID] = database.User{ID: userID}
+	apiCfg := &apiConfig{db: fake}
+
+	// build test cases, run in order against the same db
+	testCases := []struct {
+		name           string           // name for test case
+		handler        http.HandlerFunc // mute or unmute
+		target         uuid.UUID        // who
+		expectedStatus int              // status code we want
+		expectedMuted  int              // mutes stored after
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: mute self", apiCfg.handlerMuteUser, callerID, http.StatusBadRequest, 0},
+		{"Test case: mute unknown user", apiCfg.handlerMuteUser, uuid.New(), http.StatusNotFound, 0},
+		{"Test case: mute", apiCfg.handlerMuteUser, userID, http.StatusNoContent, 1},
+		{"Test case: mute again", apiCfg.handlerMuteUser, userID, http.StatusNoContent, 1},
+		{"Test case: unmute", apiCfg.handlerUnmuteUser, userID, http.StatusNoContent, 0},
+		{"Test case: unmute again", apiCfg.handlerUnmuteUser, userID, http.StatusNoContent, 0},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/api/users/me/mutes/"+tc.target.String(), nil)
+		req.SetPathValue("userID", tc.target.String())
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: callerID})
+		rec := httptest.NewRecorder()
+		tc.handler(rec, req.WithContext(ctx))
+
+		// check the outcome
+		if rec.Code != tc.expectedStatus {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.expectedStatus)
+		}
+		if len(fake.muted) != tc.expectedMuted {
+			t.Errorf("%s: %d mutes stored, want %d", tc.name, len(fake.muted), tc.expectedMuted)
+		}
+	}
+
+	// a self mute never reaches the db
+	expectedQueries := []string{"CreateMute", "CreateMute", "CreateMute", "DeleteMute", "DeleteMute"}
+	if !slices.Equal(fake.calls, expectedQueries) {
+		t.Errorf("queries = %v, want %v", fake.calls, expectedQueries)
+	}
+}
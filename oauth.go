@@ -0,0 +1,775 @@
+// oauth.go
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// oauth lifetimes
+const (
+	oauthCodeDuration         = 5 * time.Minute     // time from consent to code exchange
+	oauthAccessTokenDuration  = 15 * time.Minute    // short, a deleted app's tokens can't be recalled
+	oauthRefreshTokenDuration = 30 * 24 * time.Hour // 30 days, rotated on every use
+)
+
+// what each scope lets an app do, shown on the consent page
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:     "Read chirps as you",
+	auth.ScopeChirpsWrite:    "Post and delete chirps as you",
+	auth.ScopeProfileRead:    "See your account details, including your email",
+	auth.ScopeProfileWrite:   "Change your public profile",
+	auth.ScopeBookmarksRead:  "See your private bookmarks",
+	auth.ScopeBookmarksWrite: "Add, remove and organise your bookmarks",
+	auth.ScopeReportsWrite:   "Report chirps and users to moderators",
+}
+
+// STRUCTS
+// an oauth protocol error (rfc 6749 4.1.2.1 and 5.2)
+type oauthError struct {
+	Code        string // e.g. "invalid_request", "invalid_grant"
+	Description string // for the developer, never shown to the user as the only hint
+	Redirect    bool   // safe to send back to the client's redirect uri
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// a checked authorization request
+type oauthAuthorization struct {
+	Client      database.OauthClient
+	RedirectURI string
+	Scopes      []string
+	State       string
+	Challenge   string
+}
+
+// validate an authorization request, the same rules for the consent page and the consent answer
+// errors with Redirect false must never be sent to the redirect uri (it may be an attacker's)
+func (apiCfg *apiConfig) checkAuthorizeRequest(ctx context.Context, r JsonOAuthAuthorizeRequest) (oauthAuthorization, *oauthError) {
+	// client check
+	clientUUID, err := uuid.Parse(r.ClientID)
+	if err != nil {
+		return oauthAuthorization{}, &oauthError{Code: "invalid_request", Description: "unknown client_id"}
+	}
+	client, err := apiCfg.db.GetOAuthClient(ctx, clientUUID)
+	if err != nil {
+		return oauthAuthorization{}, &oauthError{Code: "invalid_request", Description: "unknown client_id"}
+	}
+
+	// redirect uri check, exact match (may be left out when only one is registered)
+	redirectURI := r.RedirectURI
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return oauthAuthorization{}, &oauthError{Code: "invalid_request", Description: "redirect_uri is not registered for this client"}
+	}
+
+	// from here errors go back to the client
+	authz := oauthAuthorization{Client: client, RedirectURI: redirectURI, State: r.State}
+
+	// response type check, codes only
+	if r.ResponseType != "code" {
+		return authz, &oauthError{Code: "unsupported_response_type", Description: "response_type must be code", Redirect: true}
+	}
+
+	// pkce check, S256 required for every client
+	if r.CodeChallengeMethod != "S256" || len(r.CodeChallenge) != 43 {
+		return authz, &oauthError{Code: "invalid_request", Description: "code_challenge with code_challenge_method S256 is required", Redirect: true}
+	}
+	authz.Challenge = r.CodeChallenge
+
+	// scope check, default to everything the client registered
+	scopes := strings.Fields(r.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return authz, &oauthError{Code: "invalid_scope", Description: "scope not allowed for this client: " + scope, Redirect: true}
+		}
+	}
+	authz.Scopes = slices.Compact(slices.Sorted(slices.Values(scopes)))
+
+	return authz, nil
+}
+
+// OAuthAuthorizePage handler that shows the consent page for an authorization request
+// the page answers through the json api with the user's own session
+func (apiCfg *apiConfig) handlerOAuthAuthorizePage(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// the request, from the query string
+	query := req.URL.Query()
+	reqAuthorize := JsonOAuthAuthorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	// check the request
+	authz, oerr := apiCfg.checkAuthorizeRequest(req.Context(), reqAuthorize)
+
+	// bad client or redirect uri, tell the user and stop
+	if oerr != nil && !oerr.Redirect {
+		log.Printf("Error in oauth authorization request: %s", oerr) // log msg with err
+		renderConsentPage(w, consentPage{Error: oerr.Description}, http.StatusBadRequest)
+		return // early return
+	}
+
+	// anything else goes back to the client
+	if oerr != nil {
+		http.Redirect(w, req, oauthRedirect(authz.RedirectURI, url.Values{
+			"error":             {oerr.Code},
+			"error_description": {oerr.Description},
+		}, authz.State), http.StatusFound)
+		return // early return
+	}
+
+	// describe what is asked for
+	page := consentPage{ClientName: authz.Client.Name, Request: reqAuthorize}
+	page.Request.RedirectURI = authz.RedirectURI
+	page.Request.Scope = strings.Join(authz.Scopes, " ")
+	for _, scope := range authz.Scopes {
+		page.Scopes = append(page.Scopes, scopeDescriptions[scope])
+	}
+
+	// show it
+	renderConsentPage(w, page, http.StatusOK)
+}
+
+// OAuthAuthorize handler that records the user's consent answer and says where to send the browser
+func (apiCfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqAuthorize JsonOAuthAuthorizeRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqAuthorize)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqAuthorize is now successfully populated
+
+	// check the request again, the page may have been tampered with
+	authz, oerr := apiCfg.checkAuthorizeRequest(req.Context(), reqAuthorize)
+
+	// bad client or redirect uri check
+	if oerr != nil && !oerr.Redirect {
+		log.Printf("Error in oauth authorization request: %s", oerr) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, oerr.Description, http.StatusBadRequest)
+		return // early return
+	}
+
+	// other errors, and a refusal, go back to the client
+	if oerr == nil && !reqAuthorize.Approve {
+		oerr = &oauthError{Code: "access_denied", Description: "the user denied the request", Redirect: true}
+	}
+	if oerr != nil {
+		// helper to insert body response + 200 ok status code
+		WriteJSONResponse(w, JsonOAuthAuthorizeResponse{
+			RedirectTo: oauthRedirect(authz.RedirectURI, url.Values{
+				"error":             {oerr.Code},
+				"error_description": {oerr.Description},
+			}, authz.State),
+		}, http.StatusOK)
+		return // early return
+	}
+
+	// tidy expired codes (best effort)
+	err = apiCfg.db.DeleteExpiredOAuthCodes(req.Context())
+	if err != nil {
+		log.Printf("Error deleting expired oauth codes: %s", err) // log msg with err
+	}
+
+	// make the code
+	code, err := auth.MakeOAuthCode()
+
+	// make code check
+	if err != nil {
+		log.Printf("Error generating oauth code: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred authorizing app", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// store its hash
+	err = apiCfg.db.CreateOAuthCode(req.Context(), database.CreateOAuthCodeParams{
+		CodeHash:      auth.HashOAuthToken(code),
+		ClientID:      authz.Client.ID,
+		UserID:        caller.UserID,
+		RedirectUri:   authz.RedirectURI,
+		Scopes:        authz.Scopes,
+		CodeChallenge: authz.Challenge,
+		ExpiresAt:     time.Now().UTC().Add(oauthCodeDuration),
+	})
+
+	// create code check
+	if err != nil {
+		log.Printf("Error adding oauth code to database: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred authorizing app", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, JsonOAuthAuthorizeResponse{
+		RedirectTo: oauthRedirect(authz.RedirectURI, url.Values{"code": {code}}, authz.State),
+	}, http.StatusOK)
+}
+
+// OAuthToken handler that swaps a code or refresh token for tokens (rfc 6749 section 3.2)
+// form encoded in, json out, errors in the oauth shape
+func (apiCfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// tokens must never be cached
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	// who is asking
+	client, oerr := apiCfg.authenticateOAuthClient(req)
+	if oerr != nil {
+		log.Printf("Error authenticating oauth client: %s", oerr) // log msg with err
+		WriteOAuthError(w, oerr, oauthErrorStatus(oerr))
+		return // early return
+	}
+
+	// which grant
+	var respToken JsonOAuthTokenResponse
+	switch req.PostForm.Get("grant_type") {
+	case "authorization_code":
+		respToken, oerr = apiCfg.exchangeOAuthCode(req.Context(), client, req.PostForm)
+	case "refresh_token":
+		respToken, oerr = apiCfg.refreshOAuthToken(req.Context(), client, req.PostForm)
+	default:
+		oerr = &oauthError{Code: "unsupported_grant_type", Description: "grant_type must be authorization_code or refresh_token"}
+	}
+
+	// grant check
+	if oerr != nil {
+		log.Printf("Error in oauth token request from client %s: %s", client.ID, oerr) // log msg with err
+		WriteOAuthError(w, oerr, oauthErrorStatus(oerr))
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respToken, http.StatusOK)
+}
+
+// OAuthRevoke handler that lets a client give up a token (rfc 7009)
+// always 200, whether or not the token was known
+func (apiCfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// who is asking
+	client, oerr := apiCfg.authenticateOAuthClient(req)
+	if oerr != nil {
+		log.Printf("Error authenticating oauth client: %s", oerr) // log msg with err
+		WriteOAuthError(w, oerr, oauthErrorStatus(oerr))
+		return // early return
+	}
+
+	// token check
+	token := req.PostForm.Get("token")
+	if token == "" {
+		WriteOAuthError(w, &oauthError{Code: "invalid_request", Description: "token is required"}, http.StatusBadRequest)
+		return // early return
+	}
+
+	// refresh tokens end the whole grant
+	if strings.HasPrefix(token, auth.OAuthRefreshTokenPrefix) {
+		refresh, err := apiCfg.db.GetOAuthRefreshTokenByHash(req.Context(), auth.HashOAuthToken(token))
+		if err == nil && refresh.ClientID == client.ID {
+			err = apiCfg.db.RevokeOAuthGrant(req.Context(), refresh.GrantID)
+			if err != nil {
+				log.Printf("Error revoking oauth grant: %s", err) // log msg with err
+				WriteOAuthError(w, &oauthError{Code: "server_error", Description: "revocation failed"}, http.StatusInternalServerError)
+				return // early return
+			}
+			log.Printf("OAuth grant %s revoked by client %s", refresh.GrantID, client.ID) // log msg
+		}
+		w.WriteHeader(http.StatusOK) // status code 200 to client
+		return                       // early return
+	}
+
+	// access tokens are denylisted, but only the client's own
+	claims, err := auth.ValidateAccessToken(token, apiCfg.serverKey, nil)
+	if err == nil && claims.ClientID == client.ID.String() {
+		revoke := database.RevokeAccessTokenParams{
+			Jti:       claims.ID,
+			UserID:    uuid.NullUUID{UUID: claims.UserID(), Valid: true},
+			ExpiresAt: claims.ExpiresAt.Time.UTC(),
+			Reason:    "revoked by oauth client",
+		}
+
+		// persist so every instance (and restarts) pick it up
+		err = apiCfg.db.RevokeAccessToken(req.Context(), revoke)
+		if err != nil {
+			log.Printf("Error revoking access token: %s", err) // log msg with err
+			WriteOAuthError(w, &oauthError{Code: "server_error", Description: "revocation failed"}, http.StatusInternalServerError)
+			return // early return
+		}
+
+		// deny it here right away, other instances catch up on their next sync
+		apiCfg.denylist.Deny(revoke.Jti, revoke.ExpiresAt)
+		log.Printf("Access token revoked by oauth client %s: jti = %s", client.ID, revoke.Jti) // log msg
+	}
+
+	w.WriteHeader(http.StatusOK) // status code 200 to client
+}
+
+// HELPER FUNCS
+
+// authenticate the client on the token and revocation endpoints
+// confidential clients prove their secret (basic auth or form), public ones only name themselves
+func (apiCfg *apiConfig) authenticateOAuthClient(req *http.Request) (database.OauthClient, *oauthError) {
+	// form body
+	err := req.ParseForm()
+	if err != nil {
+		return database.OauthClient{}, &oauthError{Code: "invalid_request", Description: "malformed form body"}
+	}
+
+	// credentials, basic auth first (rfc 6749 2.3.1, both parts form encoded)
+	clientID, clientSecret, hasBasic := req.BasicAuth()
+	if hasBasic {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = req.PostForm.Get("client_id")
+		clientSecret = req.PostForm.Get("client_secret")
+	}
+
+	// client check
+	invalidClient := &oauthError{Code: "invalid_client", Description: "client authentication failed"}
+	clientUUID, err := uuid.Parse(clientID)
+	if err != nil {
+		return database.OauthClient{}, invalidClient
+	}
+	client, err := apiCfg.db.GetOAuthClient(req.Context(), clientUUID)
+	if err != nil {
+		return database.OauthClient{}, invalidClient
+	}
+
+	// public clients have no secret to check
+	if !client.SecretHash.Valid {
+		if clientSecret != "" {
+			return database.OauthClient{}, invalidClient
+		}
+		return client, nil
+	}
+
+	// secret check
+	hash := auth.HashOAuthToken(clientSecret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash.String)) != 1 {
+		return database.OauthClient{}, invalidClient
+	}
+
+	return client, nil
+}
+
+// swap an authorization code for tokens, a replayed code revokes everything issued from it
+func (apiCfg *apiConfig) exchangeOAuthCode(ctx context.Context, client database.OauthClient, form url.Values) (JsonOAuthTokenResponse, *oauthError) {
+	invalidGrant := &oauthError{Code: "invalid_grant", Description: "code is invalid, expired or already used"}
+
+	// find the code
+	code, err := apiCfg.db.GetOAuthCodeByHash(ctx, auth.HashOAuthToken(form.Get("code")))
+	if err != nil || code.ClientID != client.ID {
+		return JsonOAuthTokenResponse{}, invalidGrant
+	}
+
+	// replay check, someone has the code who shouldn't
+	if code.UsedAt.Valid {
+		apiCfg.revokeOAuthGrant(ctx, code.ID)
+		return JsonOAuthTokenResponse{}, invalidGrant
+	}
+
+	// expiry check
+	if time.Now().UTC().After(code.ExpiresAt) {
+		return JsonOAuthTokenResponse{}, invalidGrant
+	}
+
+	// the redirect uri must match the one the code was sent to
+	if form.Get("redirect_uri") != code.RedirectUri {
+		return JsonOAuthTokenResponse{}, &oauthError{Code: "invalid_grant", Description: "redirect_uri does not match the authorization request"}
+	}
+
+	// pkce check, proves this is the app that started the flow
+	if !auth.VerifyPKCE(form.Get("code_verifier"), code.CodeChallenge) {
+		return JsonOAuthTokenResponse{}, &oauthError{Code: "invalid_grant", Description: "code_verifier does not match the code_challenge"}
+	}
+
+	// spend it, losing the race is a replay too
+	spent, err := apiCfg.db.MarkOAuthCodeUsed(ctx, code.ID)
+	if err != nil {
+		log.Printf("Error spending oauth code: %s", err) // log msg with err
+		return JsonOAuthTokenResponse{}, &oauthError{Code: "server_error", Description: "could not issue tokens"}
+	}
+	if spent == 0 {
+		apiCfg.revokeOAuthGrant(ctx, code.ID)
+		return JsonOAuthTokenResponse{}, invalidGrant
+	}
+
+	// the code's id names the grant
+	return apiCfg.issueOAuthTokens(ctx, client, code.UserID, code.ID, code.Scopes)
+}
+
+// rotate a refresh token, a reused one revokes the whole grant
+func (apiCfg *apiConfig) refreshOAuthToken(ctx context.Context, client database.OauthClient, form url.Values) (JsonOAuthTokenResponse, *oauthError) {
+	invalidGrant := &oauthError{Code: "invalid_grant", Description: "refresh token is invalid, expired or revoked"}
+
+	// find the token
+	refresh, err := apiCfg.db.GetOAuthRefreshTokenByHash(ctx, auth.HashOAuthToken(form.Get("refresh_token")))
+	if err != nil || refresh.ClientID != client.ID {
+		return JsonOAuthTokenResponse{}, invalidGrant
+	}
+
+	// reuse check, an old token after rotation means it leaked
+	if refresh.RevokedAt.Valid {
+		apiCfg.revokeOAuthGrant(ctx, refresh.GrantID)
+		return JsonOAuthTokenResponse{}, invalidGrant
+	}
+
+	// expiry check
+	if time.Now().UTC().After(refresh.ExpiresAt) {
+		return JsonOAuthTokenResponse{}, invalidGrant
+	}
+
+	// optional narrower scope
+	scopes := refresh.Scopes
+	if requested := strings.Fields(form.Get("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !slices.Contains(refresh.Scopes, scope) {
+				return JsonOAuthTokenResponse{}, &oauthError{Code: "invalid_scope", Description: "scope exceeds the original grant: " + scope}
+			}
+		}
+		scopes = slices.Compact(slices.Sorted(slices.Values(requested)))
+	}
+
+	// retire it, losing the race counts as reuse
+	revoked, err := apiCfg.db.RevokeOAuthRefreshToken(ctx, refresh.ID)
+	if err != nil {
+		log.Printf("Error rotating oauth refresh token: %s", err) // log msg with err
+		return JsonOAuthTokenResponse{}, &oauthError{Code: "server_error", Description: "could not issue tokens"}
+	}
+	if revoked == 0 {
+		apiCfg.revokeOAuthGrant(ctx, refresh.GrantID)
+		return JsonOAuthTokenResponse{}, invalidGrant
+	}
+
+	return apiCfg.issueOAuthTokens(ctx, client, refresh.UserID, refresh.GrantID, scopes)
+}
+
+// make a scoped access token and a refresh token for a grant
+func (apiCfg *apiConfig) issueOAuthTokens(ctx context.Context, client database.OauthClient, userID, grantID uuid.UUID, scopes []string) (JsonOAuthTokenResponse, *oauthError) {
+	serverError := &oauthError{Code: "server_error", Description: "could not issue tokens"}
+
+	// the user, for plan and role
+	user, err := apiCfg.db.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return JsonOAuthTokenResponse{}, &oauthError{Code: "invalid_grant", Description: "user no longer exists"}
+	}
+	if err != nil {
+		log.Printf("Error getting user %s: %s", userID, err) // log msg with err
+		return JsonOAuthTokenResponse{}, serverError
+	}
+
+	// suspended users get no new tokens, the grant stands for when it ends
+	_, err = apiCfg.db.GetActiveSuspension(ctx, user.ID)
+	if err == nil {
+		return JsonOAuthTokenResponse{}, &oauthError{Code: "invalid_grant", Description: "user is suspended"}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error checking suspension for user %s: %s", user.ID, err) // log msg with err
+		return JsonOAuthTokenResponse{}, serverError
+	}
+
+	// premium users get the red plan
+	plan := auth.PlanFree
+	if user.IsChirpyRed {
+		plan = auth.PlanChirpyRed
+	}
+
+	// scoped access token, same signing and validation as a login session
+	accessToken, err := auth.MakeAccessToken(user.ID, apiCfg.serverKey, oauthAccessTokenDuration, auth.TokenOptions{
+		Plan:     plan,
+		Role:     user.Role,
+		Scopes:   scopes,
+		ClientID: client.ID.String(),
+	})
+	if err != nil {
+		log.Printf("Error making oauth access token: %s", err) // log msg with err
+		return JsonOAuthTokenResponse{}, serverError
+	}
+
+	// refresh token, stored hashed
+	refreshToken, err := auth.MakeOAuthRefreshToken()
+	if err != nil {
+		log.Printf("Error making oauth refresh token: %s", err) // log msg with err
+		return JsonOAuthTokenResponse{}, serverError
+	}
+	err = apiCfg.db.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{
+		TokenHash: auth.HashOAuthToken(refreshToken),
+		GrantID:   grantID,
+		ClientID:  client.ID,
+		UserID:    user.ID,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().UTC().Add(oauthRefreshTokenDuration),
+	})
+	if err != nil {
+		log.Printf("Error adding oauth refresh token to database: %s", err) // log msg with err
+		return JsonOAuthTokenResponse{}, serverError
+	}
+
+	return JsonOAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(oauthAccessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// revoke a grant after a replay, logged loudly as it means a leaked code or token
+func (apiCfg *apiConfig) revokeOAuthGrant(ctx context.Context, grantID uuid.UUID) {
+	log.Printf("OAuth grant %s replayed, revoking its refresh tokens", grantID) // log msg
+	err := apiCfg.db.RevokeOAuthGrant(ctx, grantID)
+	if err != nil {
+		log.Printf("Error revoking oauth grant %s: %s", grantID, err) // log msg with err
+	}
+}
+
+// build a redirect back to the client, keeping any query the redirect uri already has
+func oauthRedirect(redirectURI string, params url.Values, state string) string {
+	// state round trips untouched
+	if state != "" {
+		params.Set("state", state)
+	}
+
+	// registered uris parsed at registration, so this can't fail
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// status code for an oauth error on the token and revocation endpoints
+func oauthErrorStatus(oerr *oauthError) int {
+	switch oerr.Code {
+	case "invalid_client":
+		return http.StatusUnauthorized
+	case "server_error":
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// OAUTH ERROR helper, the error shape oauth clients expect
+func WriteOAuthError(w http.ResponseWriter, oerr *oauthError, statusCode int) {
+	// clients must retry with basic auth when told to
+	if oerr.Code == "invalid_client" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+
+	// helper to insert body response + status code
+	WriteJSONResponse(w, JsonOAuthErrorResponse{Error: oerr.Code, ErrorDescription: oerr.Description}, statusCode)
+}
+
+// CONSENT PAGE
+// what the consent page template needs
+type consentPage struct {
+	Error      string                    // set instead of the rest when the request can't be shown
+	ClientName string                    // the app asking
+	Scopes     []string                  // descriptions of what it asks for
+	Request    JsonOAuthAuthorizeRequest // posted back with the answer
+}
+
+// consent page, the user signs in on the page itself (or reuses a token the chirpy app left in this tab)
+// then answers through the json api with that session
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Chirpy - Authorize app</title>
+  </head>
+  <body>
+    {{if .Error}}
+    <h1>Can't authorize this app</h1>
+    <p>{{.Error}}</p>
+    {{else}}
+    <h1>{{.ClientName}} wants to use your Chirpy account</h1>
+    <p>It will be able to:</p>
+    <ul>
+      {{range .Scopes}}<li>{{.}}</li>{{end}}
+    </ul>
+    <p id="message"></p>
+    <form id="signin" hidden>
+      <p>Sign in to Chirpy to continue.</p>
+      <label>Email <input id="email" type="email" autocomplete="username" required></label>
+      <label>Password <input id="password" type="password" autocomplete="current-password" required></label>
+      <label id="code-label" hidden>Two-factor code <input id="code" inputmode="numeric" autocomplete="one-time-code"></label>
+      <button type="submit">Sign in</button>
+    </form>
+    <div id="consent" hidden>
+      <button id="approve">Allow</button>
+      <button id="deny">Deny</button>
+    </div>
+    <script>
+      const request = {{.Request}};
+      const message = document.getElementById("message");
+      let mfaToken = "";
+      function show() {
+        const signedIn = !!sessionStorage.getItem("chirpy_access_token");
+        document.getElementById("signin").hidden = signedIn;
+        document.getElementById("consent").hidden = !signedIn;
+      }
+      async function post(path, body, token) {
+        const headers = {"Content-Type": "application/json"};
+        if (token) headers["Authorization"] = "Bearer " + token;
+        const resp = await fetch(path, {method: "POST", headers: headers, body: JSON.stringify(body)});
+        return {ok: resp.ok, status: resp.status, body: await resp.json()};
+      }
+      async function signIn(event) {
+        event.preventDefault();
+        message.textContent = "";
+        const resp = mfaToken
+          ? await post("/api/login/2fa", {mfa_token: mfaToken, code: document.getElementById("code").value})
+          : await post("/api/login", {email: document.getElementById("email").value, password: document.getElementById("password").value});
+        if (!resp.ok) {
+          message.textContent = resp.body.error;
+          return;
+        }
+        if (resp.body.mfa_required) {
+          mfaToken = resp.body.mfa_token;
+          document.getElementById("code-label").hidden = false;
+          return;
+        }
+        sessionStorage.setItem("chirpy_access_token", resp.body.token);
+        show();
+      }
+      async function answer(approve) {
+        request.approve = approve;
+        const resp = await post("/api/oauth/authorize", request, sessionStorage.getItem("chirpy_access_token"));
+        if (resp.status === 401) {
+          sessionStorage.removeItem("chirpy_access_token");
+          message.textContent = "Your session has expired, sign in again.";
+          show();
+          return;
+        }
+        if (!resp.ok) {
+          message.textContent = resp.body.error;
+          return;
+        }
+        window.location = resp.body.redirect_to;
+      }
+      document.getElementById("signin").onsubmit = signIn;
+      document.getElementById("approve").onclick = () => answer(true);
+      document.getElementById("deny").onclick = () => answer(false);
+      show();
+    </script>
+    {{end}}
+  </body>
+</html>
+`))
+
+// render the consent page
+func renderConsentPage(w http.ResponseWriter, page consentPage, statusCode int) {
+	// never framed (clickjacking) or cached
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	// template check
+	err := consentTemplate.Execute(w, page)
+	if err != nil {
+		log.Printf("Error rendering consent page: %s", err) // log msg with err
+	}
+}
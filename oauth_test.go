@@ -0,0 +1,105 @@
+// oauth_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing" // importing testing package for unit tests
+)
+
+// test redirect uri rules for client registration
+func TestNormaliseRedirectURIs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		uris    []string
+		wantErr bool
+	}{
+		{"Test case: HTTPS", []string{"https://app.example/callback"}, false},
+		{"Test case: Loopback HTTP", []string{"http://127.0.0.1:9000/cb", "http://localhost/cb"}, false},
+		{"Test case: None", nil, true},
+		{"Test case: Plain HTTP", []string{"http://app.example/callback"}, true},
+		{"Test case: Relative", []string{"/callback"}, true},
+		{"Test case: Fragment", []string{"https://app.example/callback#x"}, true},
+		{"Test case: Custom Scheme", []string{"javascript://app.example/%0aalert(1)"}, true},
+	}
+
+	for _, tc := range testCases {
+		_, err := normaliseRedirectURIs(tc.uris)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+
+	// duplicates collapse
+	uris, _ := normaliseRedirectURIs([]string{"https://a.example/cb", "https://a.example/cb"})
+	if len(uris) != 1 {
+		t.Errorf("duplicates kept: %v", uris)
+	}
+}
+
+// test redirects back to the client keep its query and our state
+func TestOAuthRedirect(t *testing.T) {
+	got := oauthRedirect("https://app.example/cb?tenant=7", url.Values{"code": {"abc"}}, "xyz")
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err) // fatal, don't continue
+	}
+	q := u.Query()
+	if u.Host != "app.example" || q.Get("tenant") != "7" || q.Get("code") != "abc" || q.Get("state") != "xyz" {
+		t.Errorf("redirect = %s", got)
+	}
+}
+
+// test the token endpoint turns away unidentified clients in the oauth error shape
+func TestOAuthTokenInvalidClient(t *testing.T) {
+	apiCfg := &apiConfig{}
+	body := strings.NewReader("grant_type=authorization_code&code=abc")
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	apiCfg.handlerOAuthToken(rec, req)
+
+	// status check
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+
+	// body check
+	var resp JsonOAuthErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode failed: %v", err) // fatal, don't continue
+	}
+	if resp.Error != "invalid_client" {
+		t.Errorf("error = %q, want invalid_client", resp.Error)
+	}
+}
+
+// test the consent page escapes what the client controls
+func TestRenderConsentPage(t *testing.T) {
+	rec := httptest.NewRecorder()
+	renderConsentPage(rec, consentPage{
+		ClientName: "<script>alert(1)</script>",
+		Scopes:     []string{scopeDescriptions["chirps:read"]},
+		Request:    JsonOAuthAuthorizeRequest{State: `</script><script>alert(2)</script>`},
+	}, http.StatusOK)
+
+	page := rec.Body.String()
+	if strings.Contains(page, "<script>alert(1)") || strings.Contains(page, "</script><script>alert(2)") {
+		t.Errorf("consent page is not escaped:\n%s", page)
+	}
+	if rec.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("consent page may be framed")
+	}
+
+	// a user without a session in this tab can sign in right there
+	if !strings.Contains(page, `id="signin"`) || !strings.Contains(page, `"/api/login"`) {
+		t.Errorf("consent page has no way to sign in:\n%s", page)
+	}
+}
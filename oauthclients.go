@@ -0,0 +1,301 @@
+// oauthclients.go
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// oauth client limits
+const (
+	oauthClientMaxNameLen      = 100 // longest app name we show on the consent page
+	oauthClientMaxRedirectURIs = 10  // most redirect uris per app
+)
+
+// CreateOAuthClient handler that registers a third party app owned by the caller
+func (apiCfg *apiConfig) handlerCreateOAuthClient(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqClient JsonOAuthClientRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqClient)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqClient is now successfully populated
+
+	// check the name
+	name := strings.TrimSpace(reqClient.Name)
+	if name == "" || len(name) > oauthClientMaxNameLen {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "App name must be 1 to 100 characters", http.StatusBadRequest)
+		return // early return
+	}
+
+	// check the redirect uris
+	redirectURIs, err := normaliseRedirectURIs(reqClient.RedirectURIs)
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid redirect URIs: "+err.Error(), http.StatusBadRequest)
+		return // early return
+	}
+
+	// check scopes, at least one and all known
+	scopes, err := normaliseScopes(reqClient.Scopes)
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid scopes: "+err.Error(), http.StatusBadRequest)
+		return // early return
+	}
+
+	// confidential apps get a secret, public ones (mobile, spa) rely on pkce alone
+	var clientSecret string
+	secretHash := sql.NullString{}
+	if reqClient.Confidential {
+		clientSecret, err = auth.MakeOAuthClientSecret()
+
+		// make secret check
+		if err != nil {
+			log.Printf("Error generating client secret: %s", err) // log msg with err
+			// helper to insert error msg + 500 internal error status code
+			WriteJSONError(w, "Error occurred registering app", http.StatusInternalServerError)
+			return // early return
+		}
+		secretHash = sql.NullString{String: auth.HashOAuthToken(clientSecret), Valid: true}
+	}
+
+	// store the client
+	client, err := apiCfg.db.CreateOAuthClient(req.Context(), database.CreateOAuthClientParams{
+		OwnerID:      caller.UserID,
+		Name:         name,
+		RedirectUris: redirectURIs,
+		Scopes:       scopes,
+		SecretHash:   secretHash,
+	})
+
+	// create client check
+	if err != nil {
+		log.Printf("Error adding oauth client to database: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred registering app", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// the secret is only ever shown here
+	respClient := oauthClientResponse(client)
+	respClient.ClientSecret = clientSecret
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, respClient, http.StatusCreated)
+}
+
+// ListOAuthClients handler that lists the apps the caller registered
+func (apiCfg *apiConfig) handlerListOAuthClients(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the caller's apps
+	clients, err := apiCfg.db.ListOAuthClientsByOwner(req.Context(), caller.UserID)
+
+	// list clients check
+	if err != nil {
+		log.Printf("Error listing oauth clients: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Failed to retrieve apps", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// transform database clients into JSON response format
+	clientResponses := make([]JsonOAuthClientResponse, len(clients))
+	for i, client := range clients {
+		clientResponses[i] = oauthClientResponse(client)
+	}
+
+	// helper to insert body response + 200 OK status code
+	WriteJSONResponse(w, clientResponses, http.StatusOK)
+}
+
+// DeleteOAuthClient handler that removes one of the caller's apps
+// its codes and refresh tokens go with it, access tokens already out expire on their own
+func (apiCfg *apiConfig) handlerDeleteOAuthClient(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get client id from api endpoint path string
+	clientUUID, err := uuid.Parse(req.PathValue("clientID"))
+
+	// uuid conv check
+	if err != nil {
+		log.Printf("Error getting client ID: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid client ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// delete it, only matches the caller's own apps
+	_, err = apiCfg.db.DeleteOAuthClient(req.Context(), database.DeleteOAuthClientParams{
+		ID:      clientUUID,
+		OwnerID: caller.UserID,
+	})
+
+	// not found (or not theirs) check
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error oauth client not found: %s", clientUUID) // log msg
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "App not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// delete check
+	if err != nil {
+		log.Printf("Error deleting oauth client: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// write to server and client that app deleted
+	log.Printf("OAuth client has been deleted: ID = %s", clientUUID) // log msg
+	w.WriteHeader(http.StatusNoContent)                              // status code 204 to client
+}
+
+// HELPER FUNCS
+
+// check redirect uris are absolute, https (or loopback http for native apps) and fragment free
+func normaliseRedirectURIs(requested []string) ([]string, error) {
+	// count check
+	if len(requested) == 0 {
+		return nil, errors.New("at least one redirect URI is required")
+	}
+	if len(requested) > oauthClientMaxRedirectURIs {
+		return nil, errors.New("at most 10 redirect URIs are allowed")
+	}
+
+	// keep the first of each, in order
+	seen := make(map[string]bool, len(requested))
+	uris := make([]string, 0, len(requested))
+	for _, raw := range requested {
+		u, err := url.Parse(raw)
+
+		// absolute url check
+		if err != nil || u.Host == "" || u.User != nil {
+			return nil, errors.New("not an absolute URL: " + raw)
+		}
+
+		// fragments are never sent back (rfc 6749 3.1.2)
+		if u.Fragment != "" || strings.Contains(raw, "#") {
+			return nil, errors.New("must not contain a fragment: " + raw)
+		}
+
+		// scheme check, plain http only for loopback
+		loopback := u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1" || u.Hostname() == "::1"
+		if u.Scheme != "https" && !(u.Scheme == "http" && loopback) {
+			return nil, errors.New("must use https: " + raw)
+		}
+
+		// duplicate check
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		uris = append(uris, raw)
+	}
+
+	return uris, nil
+}
+
+// build a client response (never includes the secret hash)
+func oauthClientResponse(client database.OauthClient) JsonOAuthClientResponse {
+	return JsonOAuthClientResponse{
+		ClientID:     client.ID,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.SecretHash.Valid,
+	}
+}
@@ -0,0 +1,584 @@
+// passkeys.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/webauthn"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// passkey settings
+const (
+	passkeyCeremonyRegistration = "registration"  // matches the webauthn_challenges check
+	passkeyCeremonyLogin        = "login"         // matches the webauthn_challenges check
+	passkeyChallengeTimeout     = 5 * time.Minute // how long a ceremony may take
+	passkeyDefaultName          = "Passkey"       // label when none is given
+	passkeyMaxNameLen           = 100             // longest label we allow
+)
+
+// build the relying party config from the environment
+// WEBAUTHN_RP_ID is the site's domain, WEBAUTHN_ORIGINS a comma separated list
+func webauthnConfigFromEnv() (webauthn.Config, error) {
+	// defaults suit local development
+	cfg := webauthn.Config{
+		RPID:    "localhost",
+		RPName:  "Chirpy",
+		Origins: []string{"http://localhost:8080"},
+		Timeout: passkeyChallengeTimeout,
+	}
+
+	// relying party id
+	if rpID := strings.TrimSpace(os.Getenv("WEBAUTHN_RP_ID")); rpID != "" {
+		cfg.RPID = rpID
+	}
+
+	// relying party name
+	if rpName := strings.TrimSpace(os.Getenv("WEBAUTHN_RP_NAME")); rpName != "" {
+		cfg.RPName = rpName
+	}
+
+	// allowed origins
+	if raw := strings.TrimSpace(os.Getenv("WEBAUTHN_ORIGINS")); raw != "" {
+		cfg.Origins = nil
+		for _, origin := range strings.Split(raw, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.Origins = append(cfg.Origins, strings.TrimRight(origin, "/"))
+			}
+		}
+	}
+
+	// origins check
+	if len(cfg.Origins) == 0 {
+		return webauthn.Config{}, errors.New("WEBAUTHN_ORIGINS must list at least one origin")
+	}
+
+	return cfg, nil
+}
+
+// store a new challenge for a ceremony, returning its id and value
+func (apiCfg *apiConfig) newPasskeyChallenge(ctx context.Context, userID uuid.NullUUID, ceremony string) (database.WebauthnChallenge, error) {
+	// tidy abandoned ceremonies (best effort)
+	err := apiCfg.db.DeleteExpiredWebAuthnChallenges(ctx)
+	if err != nil {
+		log.Printf("Error deleting expired webauthn challenges: %s", err) // log msg with err
+	}
+
+	// generate the challenge
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return database.WebauthnChallenge{}, err
+	}
+
+	// store it
+	return apiCfg.db.CreateWebAuthnChallenge(ctx, database.CreateWebAuthnChallengeParams{
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		ExpiresAt: time.Now().UTC().Add(passkeyChallengeTimeout),
+	})
+}
+
+// BeginPasskeyRegistration handler that starts adding a passkey to the caller's account
+func (apiCfg *apiConfig) handlerBeginPasskeyRegistration(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the user, their email names the passkey in the browser
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// existing passkeys, so the same authenticator isn't added twice
+	existing, err := apiCfg.db.ListWebAuthnCredentialsByUser(req.Context(), caller.UserID)
+
+	// list passkeys check
+	if err != nil {
+		log.Printf("Error listing passkeys: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred starting passkey registration", http.StatusInternalServerError)
+		return // early return
+	}
+	existingIDs := make([][]byte, len(existing))
+	for i, cred := range existing {
+		existingIDs[i] = cred.CredentialID
+	}
+
+	// store the challenge
+	challenge, err := apiCfg.newPasskeyChallenge(req.Context(), uuid.NullUUID{UUID: caller.UserID, Valid: true}, passkeyCeremonyRegistration)
+
+	// challenge check
+	if err != nil {
+		log.Printf("Error creating webauthn challenge: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred starting passkey registration", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// the user handle is the user id, never the email
+	webauthnUser := webauthn.User{
+		ID:          caller.UserID[:],
+		Name:        user.Email,
+		DisplayName: user.Email,
+	}
+
+	// json response payload
+	respBegin := JsonPasskeyBeginResponse{
+		ChallengeID: challenge.ID,
+		PublicKey:   apiCfg.webauthn.CreationOptions(webauthnUser, challenge.Challenge, existingIDs),
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respBegin, http.StatusOK)
+}
+
+// FinishPasskeyRegistration handler that verifies the authenticator's response and stores the passkey
+func (apiCfg *apiConfig) handlerFinishPasskeyRegistration(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqRegister JsonPasskeyRegisterRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqRegister)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqRegister is now successfully populated
+
+	// default and check the name
+	name := strings.TrimSpace(reqRegister.Name)
+	if name == "" {
+		name = passkeyDefaultName
+	}
+	if len(name) > passkeyMaxNameLen {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Passkey name must be at most 100 characters", http.StatusBadRequest)
+		return // early return
+	}
+
+	// take the challenge, single use
+	challenge, err := apiCfg.db.ConsumeWebAuthnChallenge(req.Context(), database.ConsumeWebAuthnChallengeParams{
+		ID:       reqRegister.ChallengeID,
+		Ceremony: passkeyCeremonyRegistration,
+	})
+
+	// challenge check, must be live and started by this caller
+	if err != nil || challenge.UserID.UUID != caller.UserID {
+		log.Printf("Error consuming webauthn challenge %s: %v", reqRegister.ChallengeID, err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid or expired passkey challenge", http.StatusBadRequest)
+		return // early return
+	}
+
+	// verify the authenticator's response
+	cred, err := apiCfg.webauthn.VerifyRegistration(challenge.Challenge, reqRegister.Credential)
+
+	// verify check
+	if err != nil {
+		log.Printf("Error verifying passkey registration: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Passkey registration failed", http.StatusBadRequest)
+		return // early return
+	}
+
+	// store the passkey
+	passkey, err := apiCfg.db.CreateWebAuthnCredential(req.Context(), database.CreateWebAuthnCredentialParams{
+		UserID:       caller.UserID,
+		CredentialID: cred.ID,
+		PublicKey:    cred.PublicKey,
+		SignCount:    int64(cred.SignCount),
+		Name:         name,
+	})
+
+	// duplicate credential check (already registered, to this or another account)
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+		log.Printf("Error passkey already registered: %s", err) // log msg with err
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "Passkey is already registered", http.StatusConflict)
+		return // early return
+	}
+
+	// create passkey check
+	if err != nil {
+		log.Printf("Error adding passkey to database: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred registering passkey", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, passkeyResponse(passkey), http.StatusCreated)
+}
+
+// ListPasskeys handler that lists the caller's passkeys
+func (apiCfg *apiConfig) handlerListPasskeys(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the caller's passkeys
+	passkeys, err := apiCfg.db.ListWebAuthnCredentialsByUser(req.Context(), caller.UserID)
+
+	// list passkeys check
+	if err != nil {
+		log.Printf("Error listing passkeys: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Failed to retrieve passkeys", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// transform database passkeys into JSON response format
+	passkeyResponses := make([]JsonPasskeyResponse, len(passkeys))
+	for i, passkey := range passkeys {
+		passkeyResponses[i] = passkeyResponse(passkey)
+	}
+
+	// helper to insert body response + 200 OK status code
+	WriteJSONResponse(w, passkeyResponses, http.StatusOK)
+}
+
+// DeletePasskey handler that removes one of the caller's passkeys
+func (apiCfg *apiConfig) handlerDeletePasskey(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get passkey id from api endpoint path string
+	passkeyUUID, err := uuid.Parse(req.PathValue("passkeyID"))
+
+	// uuid conv check
+	if err != nil {
+		log.Printf("Error getting passkey ID: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid passkey ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// delete it, only matches the caller's own passkeys
+	_, err = apiCfg.db.DeleteWebAuthnCredential(req.Context(), database.DeleteWebAuthnCredentialParams{
+		ID:     passkeyUUID,
+		UserID: caller.UserID,
+	})
+
+	// not found (or not theirs) check
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error passkey not found: %s", passkeyUUID) // log msg
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Passkey not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// delete check
+	if err != nil {
+		log.Printf("Error deleting passkey: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// write to server and client that passkey deleted
+	log.Printf("Passkey has been deleted: ID = %s", passkeyUUID) // log msg
+	w.WriteHeader(http.StatusNoContent)                          // status code 204 to client
+}
+
+// BeginPasskeyLogin handler that starts a passwordless sign in
+// no email is asked for, the authenticator offers its discoverable passkeys
+func (apiCfg *apiConfig) handlerBeginPasskeyLogin(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// store the challenge, not tied to anyone yet
+	challenge, err := apiCfg.newPasskeyChallenge(req.Context(), uuid.NullUUID{}, passkeyCeremonyLogin)
+
+	// challenge check
+	if err != nil {
+		log.Printf("Error creating webauthn challenge: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred starting passkey sign in", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload
+	respBegin := JsonPasskeyBeginResponse{
+		ChallengeID: challenge.ID,
+		PublicKey:   apiCfg.webauthn.RequestOptions(challenge.Challenge),
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respBegin, http.StatusOK)
+}
+
+// FinishPasskeyLogin handler that verifies the assertion and issues the same tokens as a password login
+// passkeys require user verification, so they count as both factors and skip the totp step
+func (apiCfg *apiConfig) handlerFinishPasskeyLogin(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// json request from client
+	var reqLogin JsonPasskeyLoginRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqLogin)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqLogin is now successfully populated
+
+	// take the challenge, single use
+	challenge, err := apiCfg.db.ConsumeWebAuthnChallenge(req.Context(), database.ConsumeWebAuthnChallengeParams{
+		ID:       reqLogin.ChallengeID,
+		Ceremony: passkeyCeremonyLogin,
+	})
+
+	// challenge check
+	if err != nil {
+		log.Printf("Error consuming webauthn challenge %s: %s", reqLogin.ChallengeID, err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Invalid or expired passkey challenge", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// find the passkey the authenticator used
+	credentialID, err := webauthn.DecodeBase64(reqLogin.Credential.RawID)
+	if err != nil {
+		log.Printf("Error decoding passkey id: %s", err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Passkey sign in failed", http.StatusUnauthorized)
+		return // early return
+	}
+	passkey, err := apiCfg.db.GetWebAuthnCredentialByCredentialID(req.Context(), credentialID)
+
+	// get passkey check
+	if err != nil {
+		log.Printf("Error getting passkey: %s", err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Passkey sign in failed", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// the user handle, when sent, must name the passkey's owner
+	if reqLogin.Credential.Response.UserHandle != "" {
+		userHandle, err := webauthn.DecodeBase64(reqLogin.Credential.Response.UserHandle)
+		if err != nil || !bytes.Equal(userHandle, passkey.UserID[:]) {
+			log.Printf("Error passkey %s user handle mismatch", passkey.ID) // log msg
+			// helper to insert error msg + 401 unauthorised status code
+			WriteJSONError(w, "Passkey sign in failed", http.StatusUnauthorized)
+			return // early return
+		}
+	}
+
+	// verify the signature
+	signCount, err := apiCfg.webauthn.VerifyAssertion(challenge.Challenge, webauthn.Credential{
+		ID:        passkey.CredentialID,
+		PublicKey: passkey.PublicKey,
+		SignCount: uint32(passkey.SignCount),
+	}, reqLogin.Credential)
+
+	// verify check
+	if err != nil {
+		log.Printf("Error verifying passkey %s: %s", passkey.ID, err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Passkey sign in failed", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// record the new counter
+	err = apiCfg.db.UpdateWebAuthnCredentialSignCount(req.Context(), database.UpdateWebAuthnCredentialSignCountParams{
+		ID:        passkey.ID,
+		SignCount: int64(signCount),
+	})
+
+	// update counter check (fail closed, a stale counter weakens clone detection)
+	if err != nil {
+		log.Printf("Error updating passkey sign count: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred during login", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// get the user, for the token claims
+	loginUser, err := apiCfg.db.GetUserByID(req.Context(), passkey.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", passkey.UserID, err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Passkey sign in failed", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// suspended check
+	if apiCfg.refuseSuspended(w, req, loginUser.ID) {
+		return // early return
+	}
+
+	// make the access and refresh tokens
+	respLogin, err := apiCfg.issueLoginTokens(req.Context(), loginUser)
+
+	// issue tokens check
+	if err != nil {
+		log.Printf("Error issuing login tokens: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Internal server token generation error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respLogin, http.StatusOK)
+}
+
+// HELPER FUNCS
+
+// build a passkey response (never includes the key material)
+func passkeyResponse(passkey database.WebauthnCredential) JsonPasskeyResponse {
+	resp := JsonPasskeyResponse{
+		ID:        passkey.ID,
+		CreatedAt: passkey.CreatedAt,
+		Name:      passkey.Name,
+	}
+
+	// only set last used when it has been used
+	if passkey.LastUsedAt.Valid {
+		resp.LastUsedAt = &passkey.LastUsedAt.Time
+	}
+
+	return resp
+}
@@ -0,0 +1,38 @@
+// passkeys_test.go
+
+package main
+
+import (
+	"slices"
+	"testing" // importing testing package for unit tests
+)
+
+// test relying party config from the environment
+func TestWebauthnConfigFromEnv(t *testing.T) {
+	// defaults suit local development
+	cfg, err := webauthnConfigFromEnv()
+	if err != nil {
+		t.Fatalf("webauthnConfigFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if cfg.RPID != "localhost" || !slices.Equal(cfg.Origins, []string{"http://localhost:8080"}) {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+
+	// several origins, trailing slashes dropped
+	t.Setenv("WEBAUTHN_RP_ID", "chirpy.example")
+	t.Setenv("WEBAUTHN_ORIGINS", "https://chirpy.example/, https://www.chirpy.example")
+	cfg, err = webauthnConfigFromEnv()
+	if err != nil {
+		t.Fatalf("webauthnConfigFromEnv failed: %v", err) // fatal, don't continue
+	}
+	want := []string{"https://chirpy.example", "https://www.chirpy.example"}
+	if cfg.RPID != "chirpy.example" || !slices.Equal(cfg.Origins, want) {
+		t.Errorf("config = %+v, want rp chirpy.example origins %v", cfg, want)
+	}
+
+	// no usable origins is rejected
+	t.Setenv("WEBAUTHN_ORIGINS", " , ")
+	if _, err := webauthnConfigFromEnv(); err == nil {
+		t.Errorf("webauthnConfigFromEnv accepted an empty origin list")
+	}
+}
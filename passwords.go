@@ -0,0 +1,206 @@
+// passwords.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/passwords"
+	"github.com/google/uuid"
+)
+
+// password hashes running at once unless PASSWORD_HASH_CONCURRENCY says otherwise
+const defaultHashConcurrency = 4
+
+// build the password hasher from the environment, defaults are argon2id
+// PASSWORD_HASH_ALGORITHM = argon2id | bcrypt
+// ARGON2_MEMORY_KIB, ARGON2_ITERATIONS, ARGON2_PARALLELISM, BCRYPT_COST tune the cost
+// PASSWORD_HASH_CONCURRENCY caps hashes running at once (default 4, ~256MiB at the default argon2id cost)
+func passwordHasherFromEnv() (auth.PasswordHasher, error) {
+	// start from the defaults
+	hasher := auth.DefaultPasswordHasher
+
+	// algorithm for new hashes
+	if algorithm := strings.TrimSpace(os.Getenv("PASSWORD_HASH_ALGORITHM")); algorithm != "" {
+		hasher.Algorithm = algorithm
+	}
+
+	// argon2id memory in KiB
+	memory, err := envUint("ARGON2_MEMORY_KIB", uint64(hasher.Argon2.Memory), 32)
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	hasher.Argon2.Memory = uint32(memory)
+
+	// argon2id passes
+	iterations, err := envUint("ARGON2_ITERATIONS", uint64(hasher.Argon2.Iterations), 32)
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	hasher.Argon2.Iterations = uint32(iterations)
+
+	// argon2id lanes
+	parallelism, err := envUint("ARGON2_PARALLELISM", uint64(hasher.Argon2.Parallelism), 8)
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	hasher.Argon2.Parallelism = uint8(parallelism)
+
+	// bcrypt cost
+	cost, err := envUint("BCRYPT_COST", uint64(hasher.BcryptCost), 8)
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	hasher.BcryptCost = int(cost)
+
+	// hashes at once
+	concurrency, err := envUint("PASSWORD_HASH_CONCURRENCY", defaultHashConcurrency, 16)
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	if concurrency == 0 {
+		return auth.PasswordHasher{}, errors.New("PASSWORD_HASH_CONCURRENCY must be at least 1")
+	}
+	hasher.Slots = auth.NewHashSlots(int(concurrency))
+
+	// final sanity check
+	return hasher, hasher.Validate()
+}
+
+// build the password policy from the environment
+// PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH, PASSWORD_DISALLOW_EMAIL tune the rules
+// BREACHED_PASSWORDS_FILE optionally points at a breached password corpus
+func passwordPolicyFromEnv(hasher auth.PasswordHasher) (passwords.Policy, error) {
+	// start from the defaults
+	policy := passwords.DefaultPolicy
+
+	// minimum characters
+	minLength, err := envUint("PASSWORD_MIN_LENGTH", uint64(policy.MinLength), 16)
+	if err != nil {
+		return passwords.Policy{}, err
+	}
+	policy.MinLength = int(minLength)
+
+	// maximum characters
+	maxLength, err := envUint("PASSWORD_MAX_LENGTH", uint64(policy.MaxLength), 16)
+	if err != nil {
+		return passwords.Policy{}, err
+	}
+	policy.MaxLength = int(maxLength)
+
+	// sanity check the range
+	if policy.MaxLength > 0 && policy.MaxLength < policy.MinLength {
+		return passwords.Policy{}, errors.New("PASSWORD_MAX_LENGTH must not be below PASSWORD_MIN_LENGTH")
+	}
+
+	// email as password
+	if raw := strings.TrimSpace(os.Getenv("PASSWORD_DISALLOW_EMAIL")); raw != "" {
+		disallow, err := strconv.ParseBool(raw)
+		if err != nil {
+			return passwords.Policy{}, fmt.Errorf("PASSWORD_DISALLOW_EMAIL must be true or false: %w", err)
+		}
+		policy.DisallowEmail = disallow
+	}
+
+	// bcrypt ignores everything past 72 bytes, so refuse rather than truncate
+	if hasher.Algorithm == auth.AlgorithmBcrypt {
+		policy.MaxBytes = 72
+	}
+
+	// breached corpus
+	if path := strings.TrimSpace(os.Getenv("BREACHED_PASSWORDS_FILE")); path != "" {
+		breached, err := passwords.LoadBreachedIndex(path)
+		if err != nil {
+			return passwords.Policy{}, fmt.Errorf("loading BREACHED_PASSWORDS_FILE: %w", err)
+		}
+		policy.Breached = breached
+		log.Printf("Loaded %d breached passwords from %s", breached.Len(), path)
+	}
+
+	return policy, nil
+}
+
+// check a new password against the policy, writing a 400 with field errors if it fails
+// returns false when the caller should stop processing
+func (apiCfg *apiConfig) checkPasswordPolicy(w http.ResponseWriter, password, email string) bool {
+	// policy check
+	problems := apiCfg.passwordPolicy.Check(password, email)
+	if len(problems) == 0 {
+		return true
+	}
+
+	// conv to client field errors
+	fields := make([]JsonFieldError, len(problems))
+	for i, problem := range problems {
+		fields[i] = JsonFieldError{
+			Field:   problem.Field,
+			Code:    problem.Code,
+			Message: problem.Message,
+		}
+	}
+
+	// helper to insert error msg + field errors + 400 bad req status code
+	WriteJSONFieldErrors(w, "Password does not meet requirements", fields, http.StatusBadRequest)
+	return false
+}
+
+// store a fresh hash of a just verified password, in place of the hash it was verified against
+// best effort, the old hash still works so failures are only logged
+func (apiCfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, oldHash, password string) {
+	// hash with the current algorithm and cost
+	hash, err := apiCfg.passwordHasher.Hash(password)
+
+	// hash check
+	if err != nil {
+		log.Printf("Error rehashing password for %s: %s", userID, err)
+		return
+	}
+
+	// save it, unless the password changed since we verified it
+	swapped, err := apiCfg.db.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+		HashedPassword:    hash,
+		ID:                userID,
+		OldHashedPassword: oldHash,
+	})
+
+	// save check
+	if err != nil {
+		log.Printf("Error saving rehashed password for %s: %s", userID, err)
+		return
+	}
+
+	// changed meanwhile check
+	if swapped == 0 {
+		log.Printf("Skipped password rehash for %s, the password changed meanwhile", userID)
+		return
+	}
+
+	log.Printf("Upgraded password hash for %s", userID)
+}
+
+// HELPER FUNCS
+
+// read an optional unsigned int from the environment
+func envUint(key string, fallback uint64, bitSize int) (uint64, error) {
+	// unset, use the fallback
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+
+	// parse check
+	value, err := strconv.ParseUint(raw, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a positive integer: %w", key, err)
+	}
+
+	return value, nil
+}
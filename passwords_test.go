@@ -0,0 +1,100 @@
+// passwords_test.go
+
+package main
+
+import (
+	"testing" // importing testing package for unit tests
+
+	"github.com/PietPadda/chirpy/internal/auth"
+)
+
+// test password hasher config from the environment
+func TestPasswordHasherFromEnv(t *testing.T) {
+	// defaults when nothing is set
+	hasher, err := passwordHasherFromEnv()
+	if err != nil {
+		t.Fatalf("passwordHasherFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if hasher.Algorithm != auth.AlgorithmArgon2id {
+		t.Errorf("default algorithm = %q, want %q", hasher.Algorithm, auth.AlgorithmArgon2id)
+	}
+
+	// tuned argon2id
+	t.Setenv("ARGON2_MEMORY_KIB", "19456")
+	t.Setenv("ARGON2_ITERATIONS", "2")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+	hasher, err = passwordHasherFromEnv()
+	if err != nil {
+		t.Fatalf("passwordHasherFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if hasher.Argon2.Memory != 19456 || hasher.Argon2.Iterations != 2 || hasher.Argon2.Parallelism != 1 {
+		t.Errorf("argon2 params = %+v, want m=19456 t=2 p=1", hasher.Argon2)
+	}
+
+	// garbage is rejected
+	t.Setenv("ARGON2_ITERATIONS", "lots")
+	if _, err := passwordHasherFromEnv(); err == nil {
+		t.Errorf("passwordHasherFromEnv accepted a non numeric value")
+	}
+	t.Setenv("ARGON2_ITERATIONS", "2")
+
+	// concurrency cap, defaulted and tuned
+	if cap(hasher.Slots) != defaultHashConcurrency {
+		t.Errorf("hash slots = %d, want %d", cap(hasher.Slots), defaultHashConcurrency)
+	}
+	t.Setenv("PASSWORD_HASH_CONCURRENCY", "2")
+	hasher, err = passwordHasherFromEnv()
+	if err != nil {
+		t.Fatalf("passwordHasherFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if cap(hasher.Slots) != 2 {
+		t.Errorf("hash slots = %d, want 2", cap(hasher.Slots))
+	}
+	t.Setenv("PASSWORD_HASH_CONCURRENCY", "0")
+	if _, err := passwordHasherFromEnv(); err == nil {
+		t.Errorf("passwordHasherFromEnv accepted a zero concurrency")
+	}
+	t.Setenv("PASSWORD_HASH_CONCURRENCY", "2")
+
+	// unknown algorithm is rejected
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "md5")
+	if _, err := passwordHasherFromEnv(); err == nil {
+		t.Errorf("passwordHasherFromEnv accepted an unknown algorithm")
+	}
+}
+
+// test password policy config from the environment
+func TestPasswordPolicyFromEnv(t *testing.T) {
+	// bcrypt caps the policy at 72 bytes
+	policy, err := passwordPolicyFromEnv(auth.PasswordHasher{Algorithm: auth.AlgorithmBcrypt})
+	if err != nil {
+		t.Fatalf("passwordPolicyFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if policy.MaxBytes != 72 {
+		t.Errorf("bcrypt MaxBytes = %d, want 72", policy.MaxBytes)
+	}
+
+	// tuned lengths
+	t.Setenv("PASSWORD_MIN_LENGTH", "12")
+	t.Setenv("PASSWORD_MAX_LENGTH", "64")
+	policy, err = passwordPolicyFromEnv(auth.DefaultPasswordHasher)
+	if err != nil {
+		t.Fatalf("passwordPolicyFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if policy.MinLength != 12 || policy.MaxLength != 64 || policy.MaxBytes != 0 {
+		t.Errorf("policy = %+v, want min=12 max=64 no byte cap", policy)
+	}
+
+	// inverted range is rejected
+	t.Setenv("PASSWORD_MAX_LENGTH", "8")
+	if _, err := passwordPolicyFromEnv(auth.DefaultPasswordHasher); err == nil {
+		t.Errorf("passwordPolicyFromEnv accepted max below min")
+	}
+	t.Setenv("PASSWORD_MAX_LENGTH", "64")
+
+	// missing breached corpus is fatal rather than silently skipped
+	t.Setenv("BREACHED_PASSWORDS_FILE", "/nonexistent/breached.txt")
+	if _, err := passwordPolicyFromEnv(auth.DefaultPasswordHasher); err == nil {
+		t.Errorf("passwordPolicyFromEnv accepted a missing breached corpus")
+	}
+}
@@ -0,0 +1,266 @@
+// profiles.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// profile field limits
+const (
+	minHandleLength      = 3    // short enough for initials
+	maxHandleLength      = 15   // fits next to a chirp
+	maxDisplayNameLength = 50   // in characters, not bytes
+	maxBioLength         = 160  // in characters, not bytes
+	maxAvatarURLLength   = 2048 // longest url browsers reliably handle
+)
+
+// handles nobody may take, they name routes or sound like us
+var reservedHandles = map[string]bool{
+	"about":     true,
+	"api":       true,
+	"app":       true,
+	"help":      true,
+	"login":     true,
+	"logout":    true,
+	"me":        true,
+	"mod":       true,
+	"moderator": true,
+	"null":      true,
+	"oauth":     true,
+	"root":      true,
+	"security":  true,
+	"settings":  true,
+	"signup":    true,
+	"staff":     true,
+	"support":   true,
+	"system":    true,
+	"undefined": true,
+	"users":     true,
+}
+
+// words a handle may not contain, so nobody can pose as the service
+var reservedHandleWords = []string{"admin", "chirpy"}
+
+// ERRORS
+var errHandleTaken = errors.New("handle already belongs to another account")
+
+// HELPERS
+// checks a handle against the rules and case folds it, a leading @ is dropped
+func normaliseHandle(raw string) (string, error) {
+	// "@name" and "name" are the same handle
+	handle := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(raw), "@"))
+
+	// length check
+	if len(handle) < minHandleLength || len(handle) > maxHandleLength {
+		return "", errors.New("handle must be 3 to 15 characters")
+	}
+
+	// must start with a letter, so it never looks like an id
+	if handle[0] < 'a' || handle[0] > 'z' {
+		return "", errors.New("handle must start with a letter")
+	}
+
+	// letters, digits and underscores only
+	for _, c := range handle {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' {
+			return "", errors.New("handle may only use letters, digits and underscores")
+		}
+	}
+
+	// reserved check
+	if reservedHandles[handle] {
+		return "", errors.New("handle is reserved")
+	}
+	for _, word := range reservedHandleWords {
+		if strings.Contains(handle, word) {
+			return "", errors.New("handle is reserved")
+		}
+	}
+
+	return handle, nil
+}
+
+// checks an avatar url, only absolute https links are shown to other users
+func validateAvatarURL(raw string) error {
+	// length check
+	if len(raw) > maxAvatarURLLength {
+		return errors.New("avatar url is too long")
+	}
+
+	// parse check
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	// https only, no javascript: or mixed content
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("avatar url must be an https link")
+	}
+	return nil
+}
+
+// apply the profile fields of a patch to a user, nil fields are left alone
+// returns the problem for the client on invalid input
+func applyProfilePatch(user database.User, patch JsonUserPatchRequest) (database.UpdateUserProfileParams, string) {
+	// start from what's stored
+	params := database.UpdateUserProfileParams{
+		ID:          user.ID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+	}
+
+	// handle ("" clears it)
+	if patch.Handle != nil {
+		params.Handle = sql.NullString{}
+		if *patch.Handle != "" {
+			handle, err := normaliseHandle(*patch.Handle)
+			if err != nil {
+				return params, "Invalid handle: " + err.Error()
+			}
+			params.Handle = sql.NullString{String: handle, Valid: true}
+		}
+	}
+
+	// display name
+	if patch.DisplayName != nil {
+		name := strings.TrimSpace(*patch.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return params, "Display name is too long"
+		}
+		params.DisplayName = name
+	}
+
+	// bio
+	if patch.Bio != nil {
+		bio := strings.TrimSpace(*patch.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return params, "Bio is too long"
+		}
+		params.Bio = bio
+	}
+
+	// avatar url ("" clears it)
+	if patch.AvatarURL != nil {
+		params.AvatarUrl = sql.NullString{}
+		if raw := strings.TrimSpace(*patch.AvatarURL); raw != "" {
+			if err := validateAvatarURL(raw); err != nil {
+				return params, "Invalid avatar url: " + err.Error()
+			}
+			params.AvatarUrl = sql.NullString{String: raw, Valid: true}
+		}
+	}
+
+	return params, ""
+}
+
+// look up the handles of the given authors, authors without one are left out
+func (apiCfg *apiConfig) authorHandles(ctx context.Context, authorIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	// nothing to look up
+	handles := make(map[uuid.UUID]string)
+	if len(authorIDs) == 0 {
+		return handles, nil
+	}
+
+	// one query for the whole page
+	rows, err := apiCfg.db.GetUserHandles(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		handles[row.ID] = row.Handle.String
+	}
+	return handles, nil
+}
+
+// HANDLERS
+// GetProfile handler that returns a user's public profile by handle, never the email
+func (apiCfg *apiConfig) handlerGetProfile(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get handle from api endpoint path string ("@name" works too)
+	handle := strings.TrimPrefix(req.PathValue("handle"), "@")
+
+	// get the profile
+	profile, err := apiCfg.db.GetPublicProfile(req.Context(), handle)
+
+	// not found check
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get profile check
+	if err != nil {
+		log.Printf("Error getting profile %q: %s", handle, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting profile", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// block check, either side of a block sees no profile at all
+	blocked, err := apiCfg.blockedEitherWay(req.Context(), profile.ID)
+
+	// get block check
+	if err != nil {
+		log.Printf("Error checking blocks: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting profile", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// blocked check
+	if blocked {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// restriction check, a suspended or shadow banned user's chirps don't show, so they don't count either
+	restricted, err := apiCfg.restrictedFromCaller(req.Context(), profile.ID)
+
+	// get restriction check
+	if err != nil {
+		log.Printf("Error checking restrictions: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting profile", http.StatusInternalServerError)
+		return // early return
+	}
+	if restricted {
+		profile.ChirpCount = 0
+	}
+
+	// json response payload
+	respProfile := JsonProfileResponse{
+		ID:          profile.ID,
+		CreatedAt:   profile.CreatedAt,
+		Handle:      profile.Handle.String,
+		DisplayName: profile.DisplayName,
+		Bio:         profile.Bio,
+		AvatarURL:   apiCfg.avatarURL(profile.ID, profile.AvatarUrl),
+		ChirpCount:  profile.ChirpCount,
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respProfile, http.StatusOK)
+}
@@ -0,0 +1,167 @@
+// profiles_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test handle rules and case folding
+func TestNormaliseHandle(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		raw      string
+		expected string
+		wantErr  bool
+	}{
+		{"piet", "piet", false},
+		{"@Piet_Padda", "piet_padda", false},
+		{"  bob42 ", "bob42", false},
+		{"ab", "", true},
+		{"a_very_long_handle", "", true},
+		{"42bob", "", true},
+		{"_bob", "", true},
+		{"bob-smith", "", true},
+		{"bób", "", true},
+		{"me", "", true},
+		{"Support", "", true},
+		{"realchirpy", "", true},
+		{"the_admin", "", true},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		actual, err := normaliseHandle(tc.raw)
+
+		// error check
+		if (err != nil) != tc.wantErr {
+			t.Errorf("normaliseHandle(%q) err = %v, wantErr %v", tc.raw, err, tc.wantErr)
+			continue
+		}
+
+		// check result
+		if actual != tc.expected {
+			t.Errorf("normaliseHandle(%q) = %q, want %q", tc.raw, actual, tc.expected)
+		}
+	}
+}
+
+// test avatar urls must be https links
+func TestValidateAvatarURL(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{"https://cdn.example.com/me.png", false},
+		{"http://cdn.example.com/me.png", true},
+		{"javascript:alert(1)", true},
+		{"/relative.png", true},
+		{"https://" + strings.Repeat("a", maxAvatarURLLength), true},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		err := validateAvatarURL(tc.raw)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("validateAvatarURL(%q) err = %v, wantErr %v", tc.raw, err, tc.wantErr)
+		}
+	}
+}
+
+// test patching keeps unsent fields and clears on ""
+func TestApplyProfilePatch(t *testing.T) {
+	user := database.User{
+		ID:          uuid.New(),
+		Handle:      sql.NullString{String: "piet", Valid: true},
+		DisplayName: "Piet",
+		Bio:         "hello",
+		AvatarUrl:   sql.NullString{String: "https://cdn.example.com/me.png", Valid: true},
+	}
+
+	// only the bio changes
+	bio := "  new bio  "
+	params, problem := applyProfilePatch(user, JsonUserPatchRequest{Bio: &bio})
+	if problem != "" {
+		t.Fatalf("applyProfilePatch failed: %s", problem) // fatal, don't continue
+	}
+	if params.Bio != "new bio" || params.Handle.String != "piet" || params.DisplayName != "Piet" || !params.AvatarUrl.Valid {
+		t.Errorf("unexpected params %+v", params)
+	}
+
+	// "" clears the handle and avatar
+	empty := ""
+	params, problem = applyProfilePatch(user, JsonUserPatchRequest{Handle: &empty, AvatarURL: &empty})
+	if problem != "" {
+		t.Fatalf("applyProfilePatch failed: %s", problem) // fatal, don't continue
+	}
+	if params.Handle.Valid || params.AvatarUrl.Valid {
+		t.Errorf("handle and avatar not cleared: %+v", params)
+	}
+
+	// bad input is rejected
+	long := strings.Repeat("é", maxBioLength+1)
+	if _, problem := applyProfilePatch(user, JsonUserPatchRequest{Bio: &long}); problem == "" {
+		t.Errorf("applyProfilePatch accepted a bio that's too long")
+	}
+	reserved := "admin"
+	if _, problem := applyProfilePatch(user, JsonUserPatchRequest{Handle: &reserved}); problem == "" {
+		t.Errorf("applyProfilePatch accepted a reserved handle")
+	}
+}
+
+// test a restricted user's profile shows no chirps to others, while they still see their own count
+func TestGetProfileChirpCount(t *testing.T) {
+	userID := uuid.New()
+	restrictedID := uuid.New()
+	fake := newFakeQuerier()
+	fake.users[userID] = database.User{ID: userID, Handle: sql.NullString{String: "piet", Valid: true}}
+	fake.users[restrictedID] = database.User{ID: restrictedID, Handle: sql.NullString{String: "spammer", Valid: true}}
+	fake.restricted = []uuid.UUID{restrictedID}
+	for _, authorID := range []uuid.UUID{userID, restrictedID, restrictedID} {
+		chirp := database.Chirp{ID: uuid.New(), UserID: authorID}
+		fake.chirps[chirp.ID] = chirp
+	}
+	apiCfg := &apiConfig{db: fake}
+
+	// build test cases
+	testCases := []struct {
+		name     string    // name for test case
+		callerID uuid.UUID // who is asking
+		handle   string    // whose profile
+		expected int64     // chirp count we want shown
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: unrestricted", uuid.New(), "piet", 1},
+		{"Test case: restricted", uuid.New(), "@spammer", 0},
+		{"Test case: restricted, own profile", restrictedID, "spammer", 2},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/"+tc.handle, nil)
+		req.SetPathValue("handle", tc.handle)
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: tc.callerID})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerGetProfile(rec, req.WithContext(ctx))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", tc.name, rec.Code, http.StatusOK) // fatal, don't continue
+		}
+
+		var profile JsonProfileResponse
+		if err := json.NewDecoder(rec.Body).Decode(&profile); err != nil {
+			t.Fatalf("%s: decoding response failed: %v", tc.name, err) // fatal, don't continue
+		}
+		if profile.ChirpCount != tc.expected {
+			t.Errorf("%s: ChirpCount = %d, want %d", tc.name, profile.ChirpCount, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// reports.go
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// what a report is about
+const (
+	reportKindChirp = "chirp" // one chirp, and through it its author
+	reportKindUser  = "user"  // an account as a whole
+)
+
+// where a report is in the moderation queue
+const (
+	reportStatusOpen      = "open"      // waiting for a moderator
+	reportStatusDismissed = "dismissed" // looked at, nothing done
+	reportStatusActioned  = "actioned"  // looked at, something done
+)
+
+// longest details a reporter may add, in characters
+const maxReportDetailsLength = 500
+
+// reason codes a report may give, with how they read in emails
+var reportReasons = map[string]string{
+	"spam":           "spam",
+	"harassment":     "harassment",
+	"hate":           "hateful conduct",
+	"violence":       "violent threats",
+	"sexual_content": "sexual content",
+	"self_harm":      "self-harm",
+	"impersonation":  "impersonation",
+	"other":          "other",
+}
+
+// HELPERS
+// how a reason code reads to a person
+func reportReasonLabel(reason string) string {
+	if label, ok := reportReasons[reason]; ok {
+		return label
+	}
+	return reason
+}
+
+// decode and check a report request, writing a 400 if it's no good
+// returns false when the caller should stop processing
+func decodeReportRequest(w http.ResponseWriter, req *http.Request) (JsonReportRequest, bool) {
+	// json request from client
+	var reqReport JsonReportRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqReport)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return reqReport, false
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return reqReport, false
+	}
+
+	// reason check
+	if _, ok := reportReasons[reqReport.Reason]; !ok {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Unknown report reason", http.StatusBadRequest)
+		return reqReport, false
+	}
+
+	// details check, "other" needs them to mean anything
+	reqReport.Details = strings.TrimSpace(reqReport.Details)
+	if utf8.RuneCountInString(reqReport.Details) > maxReportDetailsLength {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Report details are too long", http.StatusBadRequest)
+		return reqReport, false
+	}
+	if reqReport.Reason == "other" && reqReport.Details == "" {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Reports for other reasons need details", http.StatusBadRequest)
+		return reqReport, false
+	}
+
+	return reqReport, true
+}
+
+// a report as the api shows it, actions are for moderators only
+func reportResponse(report database.Report, actions []database.ModerationAction) JsonReportResponse {
+	resp := JsonReportResponse{
+		ID:        report.ID,
+		CreatedAt: report.CreatedAt,
+		Kind:      report.Kind,
+		UserID:    report.UserID,
+		ChirpBody: report.ChirpBody.String,
+		Reason:    report.Reason,
+		Details:   report.Details,
+		Status:    report.Status,
+	}
+	if report.ReporterID.Valid {
+		resp.ReporterID = &report.ReporterID.UUID
+	}
+	if report.ChirpID.Valid {
+		resp.ChirpID = &report.ChirpID.UUID
+	}
+	if report.ResolvedAt.Valid {
+		resp.ResolvedAt = &report.ResolvedAt.Time
+	}
+	for _, action := range actions {
+		resp.Actions = append(resp.Actions, moderationActionResponse(action))
+	}
+	return resp
+}
+
+// HANDLERS
+// ReportChirp handler that reports a chirp to the moderators
+func (apiCfg *apiConfig) handlerReportChirp(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get chirp id from api endpoint path string
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid chirp ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode and check the report
+	reqReport, ok := decodeReportRequest(w, req)
+	if !ok {
+		return // early return
+	}
+
+	// get the chirp
+	chirp, err := apiCfg.db.GetChirp(req.Context(), chirpID)
+
+	// not found check (hidden ones can't be seen so can't be reported)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && hiddenFromCaller(req.Context(), chirp)) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "Chirp not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// get chirp check
+	if err != nil {
+		log.Printf("Error getting chirp: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred reporting chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// own chirp check (blocked authors can still be reported, on purpose)
+	if chirp.UserID == caller.UserID {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "You can't report your own chirp", http.StatusBadRequest)
+		return // early return
+	}
+
+	// file it, with a copy of the chirp in case it's deleted
+	report, err := apiCfg.db.CreateReport(req.Context(), database.CreateReportParams{
+		Kind:       reportKindChirp,
+		ReporterID: uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		UserID:     chirp.UserID,
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody:  sql.NullString{String: chirp.Body, Valid: true},
+		Reason:     reqReport.Reason,
+		Details:    reqReport.Details,
+	})
+
+	// already reported check
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "You have already reported this chirp", http.StatusConflict)
+		return // early return
+	}
+
+	// create report check
+	if err != nil {
+		log.Printf("Error creating report: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred reporting chirp", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, reportResponse(report, nil), http.StatusCreated)
+}
+
+// ReportUser handler that reports an account to the moderators
+func (apiCfg *apiConfig) handlerReportUser(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get user id from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// self check
+	if userID == caller.UserID {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "You can't report yourself", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode and check the report
+	reqReport, ok := decodeReportRequest(w, req)
+	if !ok {
+		return // early return
+	}
+
+	// file it
+	report, err := apiCfg.db.CreateReport(req.Context(), database.CreateReportParams{
+		Kind:       reportKindUser,
+		ReporterID: uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		UserID:     userID,
+		Reason:     reqReport.Reason,
+		Details:    reqReport.Details,
+	})
+
+	// no such user check (foreign key violation)
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23503" {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// already reported check
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23505" {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "You have already reported this user", http.StatusConflict)
+		return // early return
+	}
+
+	// create report check
+	if err != nil {
+		log.Printf("Error creating report: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred reporting user", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, reportResponse(report, nil), http.StatusCreated)
+}
@@ -0,0 +1,141 @@
+// reports_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test bad reports are refused before the db is touched
+func TestReportChirpBadRequest(t *testing.T) {
+	apiCfg := &apiConfig{}
+	tests := []struct {
+		name    string
+		chirpID string
+		body    string
+	}{
+		{"Invalid ID", "nope", `{"reason":"spam"}`},
+		{"Empty body", uuid.NewString(), ``},
+		{"Unknown reason", uuid.NewString(), `{"reason":"boring"}`},
+		{"Other without details", uuid.NewString(), `{"reason":"other","details":"  "}`},
+		{"Details too long", uuid.NewString(), `{"reason":"spam","details":"` + strings.Repeat("a", maxReportDetailsLength+1) + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/chirps/"+tt.chirpID+"/report", strings.NewReader(tt.body))
+			req.SetPathValue("chirpID", tt.chirpID)
+			ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: uuid.New()})
+			rec := httptest.NewRecorder()
+			apiCfg.handlerReportChirp(rec, req.WithContext(ctx))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// test only chirps the caller can see, and didn't write, can be reported
+func TestReportChirpVisibility(t *testing.T) {
+	callerID := uuid.New()
+	otherID := uuid.New()
+	now := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	// build test cases
+	testCases := []struct {
+		name           string         // name for test case
+		chirp          database.Chirp // the chirp, id filled in
+		exists         bool           // whether it's in the db
+		expectedStatus int            // status code we want
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: visible", database.Chirp{UserID: otherID}, true, http.StatusCreated},
+		{"Test case: missing", database.Chirp{UserID: otherID}, false, http.StatusNotFound},
+		{"Test case: hidden by a moderator", database.Chirp{UserID: otherID, HiddenAt: now}, true, http.StatusNotFound},
+		{"Test case: held by the spam filter", database.Chirp{UserID: otherID, HeldAt: now}, true, http.StatusNotFound},
+		{"Test case: own chirp", database.Chirp{UserID: callerID}, true, http.StatusBadRequest},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		fake := newFakeQuerier()
+		chirp := tc.chirp
+		chirp.ID = uuid.New()
+		chirp.Body = "buy now"
+		if tc.exists {
+			fake.chirps[chirp.ID] = chirp
+		}
+		apiCfg := &apiConfig{db: fake}
+
+		// report it
+		req := httptest.NewRequest(http.MethodPost, "/api/chirps/"+chirp.ID.String()+"/report", strings.NewReader(`{"reason":"spam"}`))
+		req.SetPathValue("chirpID", chirp.ID.String())
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: callerID})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerReportChirp(rec, req.WithContext(ctx))
+
+		// check the outcome
+		if rec.Code != tc.expectedStatus {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.expectedStatus)
+			continue
+		}
+		if rec.Code != http.StatusCreated {
+			continue
+		}
+
+		// filed with the reporter and a copy of the chirp
+		if len(fake.reports) != 1 {
+			t.Fatalf("%s: %d reports filed, want 1", tc.name, len(fake.reports)) // fatal, don't continue
+		}
+		for _, report := range fake.reports {
+			if report.ReporterID.UUID != callerID || report.ChirpBody.String != chirp.Body || report.Status != reportStatusOpen {
+				t.Errorf("%s: report = %+v, want an open report by the caller with the chirp body", tc.name, report)
+			}
+		}
+	}
+}
+
+// test nobody can report themselves
+func TestReportUserSelf(t *testing.T) {
+	apiCfg := &apiConfig{}
+	callerID := uuid.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/users/"+callerID.String()+"/report", strings.NewReader(`{"reason":"spam"}`))
+	req.SetPathValue("userID", callerID.String())
+	ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: callerID})
+	rec := httptest.NewRecorder()
+	apiCfg.handlerReportUser(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// test the report response keeps the chirp copy and leaves out what's not set
+func TestReportResponse(t *testing.T) {
+	report := database.Report{
+		ID:        uuid.New(),
+		Kind:      reportKindChirp,
+		ChirpBody: sql.NullString{String: "buy now", Valid: true},
+		Reason:    "spam",
+		Status:    reportStatusOpen,
+	}
+
+	resp := reportResponse(report, nil)
+	if resp.ChirpID != nil || resp.ResolvedAt != nil || resp.Actions != nil {
+		t.Errorf("reportResponse() = %+v, want no chirp id, resolved at or actions", resp)
+	}
+	if resp.ChirpBody != "buy now" {
+		t.Errorf("ChirpBody = %q, want %q", resp.ChirpBody, "buy now")
+	}
+
+	if got := reportReasonLabel("sexual_content"); got != "sexual content" {
+		t.Errorf("reportReasonLabel() = %q, want %q", got, "sexual content")
+	}
+}
@@ -0,0 +1,473 @@
+// restrictions.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// the kinds of restriction a moderator can put on an account
+const (
+	restrictionKindSuspension = "suspension" // can't sign in, post or use live tokens, chirps hidden
+	restrictionKindShadowBan  = "shadow_ban" // carries on as normal, but only they see their chirps
+)
+
+// HELPERS
+// suspend a user and sign them out everywhere, q may be a transaction
+// follow up with denySuspended once it's committed
+func suspendUser(ctx context.Context, q database.Querier, userID, moderatorID uuid.UUID, reason string, endsAt time.Time) (database.Suspension, error) {
+	// record the suspension
+	suspension, err := q.CreateSuspension(ctx, database.CreateSuspensionParams{
+		UserID:      userID,
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Reason:      reason,
+		EndsAt:      endsAt,
+	})
+	if err != nil {
+		return database.Suspension{}, err
+	}
+
+	// sign them out everywhere
+	err = q.RevokeAllRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return database.Suspension{}, err
+	}
+
+	return suspension, nil
+}
+
+// stop a suspended user's access tokens working on this instance right away
+// other instances pick the suspension up on their next denylist sync
+func (apiCfg *apiConfig) denySuspended(suspension database.Suspension) {
+	if apiCfg.denylist != nil {
+		apiCfg.denylist.DenyUser(suspension.UserID, suspension.EndsAt)
+	}
+}
+
+// refuse restricting yourself, or a moderator or admin unless the caller is an admin
+// writes the error and returns true when the caller should stop processing
+func (apiCfg *apiConfig) refuseRestrictionTarget(w http.ResponseWriter, req *http.Request, caller principal, userID uuid.UUID) bool {
+	// self check
+	if userID == caller.UserID {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "You can't restrict yourself", http.StatusBadRequest)
+		return true
+	}
+
+	// get the target
+	target, err := apiCfg.db.GetUserByID(req.Context(), userID)
+
+	// not found check
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return true
+	}
+
+	// get target check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return true
+	}
+
+	// staff check, moderators can't lock each other (or an admin) out
+	if (target.Role == auth.RoleModerator || target.Role == auth.RoleAdmin) && !caller.hasRole(auth.RoleAdmin) {
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "Only admins can restrict staff", http.StatusForbidden)
+		return true
+	}
+
+	return false
+}
+
+// authors whose chirps only they see right now, the suspended and shadow banned, minus the caller
+func (apiCfg *apiConfig) restrictedAuthors(ctx context.Context) (map[uuid.UUID]bool, error) {
+	restrictedIDs, err := apiCfg.db.GetRestrictedUserIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	restricted := make(map[uuid.UUID]bool, len(restrictedIDs))
+	for _, id := range restrictedIDs {
+		restricted[id] = true
+	}
+
+	// everyone still sees their own
+	if caller, ok := principalFromContext(ctx); ok {
+		delete(restricted, caller.UserID)
+	}
+	return restricted, nil
+}
+
+// whether an author's chirps are hidden from the caller because they're suspended or shadow banned
+func (apiCfg *apiConfig) restrictedFromCaller(ctx context.Context, authorID uuid.UUID) (bool, error) {
+	// everyone still sees their own
+	caller, ok := principalFromContext(ctx)
+	if ok && caller.UserID == authorID {
+		return false, nil
+	}
+
+	return apiCfg.db.IsUserRestricted(ctx, authorID)
+}
+
+// refuse a suspended user, writing a 403 with when it ends (or a 500 if we can't tell)
+// returns true when the caller should stop processing
+func (apiCfg *apiConfig) refuseSuspended(w http.ResponseWriter, req *http.Request, userID uuid.UUID) bool {
+	// get the suspension in force, if any
+	suspension, err := apiCfg.db.GetActiveSuspension(req.Context(), userID)
+
+	// not suspended check
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+
+	// get suspension check
+	if err != nil {
+		log.Printf("Error checking suspension for user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return true
+	}
+
+	// helper to insert error msg + 403 forbidden status code
+	WriteJSONError(w, "Your account is suspended until "+suspension.EndsAt.Format("2 January 2006 15:04 MST")+
+		" for "+reportReasonLabel(suspension.Reason), http.StatusForbidden)
+	return true
+}
+
+// a suspension or shadow ban as the api shows it
+// the two tables share a shape, so a shadow ban converts straight to database.Suspension
+func restrictionResponse(kind string, restriction database.Suspension) JsonRestrictionResponse {
+	resp := JsonRestrictionResponse{
+		ID:        restriction.ID,
+		CreatedAt: restriction.CreatedAt,
+		Kind:      kind,
+		UserID:    restriction.UserID,
+		Reason:    restriction.Reason,
+		EndsAt:    restriction.EndsAt,
+	}
+	if restriction.ModeratorID.Valid {
+		resp.ModeratorID = &restriction.ModeratorID.UUID
+	}
+	if restriction.LiftedAt.Valid {
+		resp.LiftedAt = &restriction.LiftedAt.Time
+	}
+	return resp
+}
+
+// parse the target user from the path and decode and check the restriction request
+// writes a 400 if either is no good, returns false when the caller should stop processing
+func decodeRestrictionRequest(w http.ResponseWriter, req *http.Request) (uuid.UUID, JsonRestrictionRequest, bool) {
+	// get user id from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return uuid.Nil, JsonRestrictionRequest{}, false // early return
+	}
+
+	// json request from client
+	var reqRestriction JsonRestrictionRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err = decoder.Decode(&reqRestriction)
+
+	// decode check
+	if err != nil && !errors.Is(err, io.EOF) {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid JSON format", http.StatusBadRequest)
+		return uuid.Nil, JsonRestrictionRequest{}, false // early return
+	}
+
+	// reason check
+	if _, ok := reportReasons[reqRestriction.Reason]; !ok {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Unknown reason", http.StatusBadRequest)
+		return uuid.Nil, JsonRestrictionRequest{}, false // early return
+	}
+
+	// length check (a week unless the moderator says otherwise)
+	if reqRestriction.Days == 0 {
+		reqRestriction.Days = defaultSuspendDays
+	}
+	if reqRestriction.Days < 1 || reqRestriction.Days > maxSuspendDays {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Days must be between 1 and 365", http.StatusBadRequest)
+		return uuid.Nil, JsonRestrictionRequest{}, false // early return
+	}
+
+	return userID, reqRestriction, true
+}
+
+// end whatever restriction of the kind is in force on the user in the path, for the lift handlers
+func (apiCfg *apiConfig) liftRestriction(w http.ResponseWriter, req *http.Request, kind string) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get user id from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// lift whatever is in force
+	var lifted int64
+	label, notInForce := "suspension", "User is not suspended"
+	if kind == restrictionKindShadowBan {
+		label, notInForce = "shadow ban", "User is not shadow banned"
+		lifted, err = apiCfg.db.LiftShadowBans(req.Context(), userID)
+	} else {
+		lifted, err = apiCfg.db.LiftSuspensions(req.Context(), userID)
+	}
+
+	// lift check
+	if err != nil {
+		log.Printf("Error lifting %s for user %s: %s", label, userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred lifting "+label, http.StatusInternalServerError)
+		return // early return
+	}
+
+	// nothing in force check
+	if lifted == 0 {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, notInForce, http.StatusNotFound)
+		return // early return
+	}
+
+	// their access tokens work again on this instance, others catch up on their next sync
+	if kind == restrictionKindSuspension && apiCfg.denylist != nil {
+		apiCfg.denylist.AllowUser(userID)
+	}
+
+	// 204 no content status code
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HANDLERS
+// SuspendUser handler that suspends an account for a number of days (staff only)
+func (apiCfg *apiConfig) handlerSuspendUser(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated moderator (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// parse and check the request
+	userID, reqRestriction, ok := decodeRestrictionRequest(w, req)
+	if !ok {
+		return // early return
+	}
+
+	// target check (writes the error on failure)
+	if apiCfg.refuseRestrictionTarget(w, req, caller, userID) {
+		return // early return
+	}
+
+	// suspend them
+	endsAt := time.Now().UTC().Add(time.Duration(reqRestriction.Days) * 24 * time.Hour)
+	suspension, err := suspendUser(req.Context(), apiCfg.db, userID, caller.UserID, reqRestriction.Reason, endsAt)
+
+	// no such user check (foreign key violation)
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23503" {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// suspend check
+	if err != nil {
+		log.Printf("Error suspending user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred suspending user", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// their live access tokens stop now
+	apiCfg.denySuspended(suspension)
+
+	// tell them (best effort)
+	user, err := apiCfg.db.GetUserByID(req.Context(), userID)
+	if err == nil {
+		err = apiCfg.sendSuspensionNotice(req.Context(), user, suspension.Reason, suspension.EndsAt)
+	}
+	if err != nil {
+		log.Printf("Error sending suspension notice to %s: %s", userID, err) // log msg with err
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, restrictionResponse(restrictionKindSuspension, suspension), http.StatusCreated)
+}
+
+// LiftSuspension handler that ends a user's suspension early (staff only)
+func (apiCfg *apiConfig) handlerLiftSuspension(w http.ResponseWriter, req *http.Request) {
+	apiCfg.liftRestriction(w, req, restrictionKindSuspension)
+}
+
+// ShadowBanUser handler that hides an account's chirps from everyone else for a number of days (staff only)
+func (apiCfg *apiConfig) handlerShadowBanUser(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated moderator (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// parse and check the request
+	userID, reqRestriction, ok := decodeRestrictionRequest(w, req)
+	if !ok {
+		return // early return
+	}
+
+	// target check (writes the error on failure)
+	if apiCfg.refuseRestrictionTarget(w, req, caller, userID) {
+		return // early return
+	}
+
+	// ban them (no notice, that's the point)
+	ban, err := apiCfg.db.CreateShadowBan(req.Context(), database.CreateShadowBanParams{
+		UserID:      userID,
+		ModeratorID: uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		Reason:      reqRestriction.Reason,
+		EndsAt:      time.Now().UTC().Add(time.Duration(reqRestriction.Days) * 24 * time.Hour),
+	})
+
+	// no such user check (foreign key violation)
+	if pqErr, isPQError := err.(*pq.Error); isPQError && pqErr.Code == "23503" {
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// shadow ban check
+	if err != nil {
+		log.Printf("Error shadow banning user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred shadow banning user", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, restrictionResponse(restrictionKindShadowBan, database.Suspension(ban)), http.StatusCreated)
+}
+
+// LiftShadowBan handler that ends a user's shadow ban early (staff only)
+func (apiCfg *apiConfig) handlerLiftShadowBan(w http.ResponseWriter, req *http.Request) {
+	apiCfg.liftRestriction(w, req, restrictionKindShadowBan)
+}
+
+// GetUserRestrictions handler that returns a user's suspension and shadow ban history (staff only)
+func (apiCfg *apiConfig) handlerGetUserRestrictions(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get user id from api endpoint path string
+	userID, err := uuid.Parse(req.PathValue("userID"))
+
+	// uuid conv check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid user ID format", http.StatusBadRequest)
+		return // early return
+	}
+
+	// get their suspensions
+	suspensions, err := apiCfg.db.ListSuspensionsForUser(req.Context(), userID)
+
+	// list suspensions check
+	if err != nil {
+		log.Printf("Error listing suspensions for user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting restrictions", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// and their shadow bans
+	bans, err := apiCfg.db.ListShadowBansForUser(req.Context(), userID)
+
+	// list shadow bans check
+	if err != nil {
+		log.Printf("Error listing shadow bans for user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred getting restrictions", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// map both to the response (empty lists, not null)
+	resp := JsonUserRestrictionsResponse{
+		Suspensions: make([]JsonRestrictionResponse, 0, len(suspensions)),
+		ShadowBans:  make([]JsonRestrictionResponse, 0, len(bans)),
+	}
+	for _, suspension := range suspensions {
+		resp.Suspensions = append(resp.Suspensions, restrictionResponse(restrictionKindSuspension, suspension))
+	}
+	for _, ban := range bans {
+		resp.ShadowBans = append(resp.ShadowBans, restrictionResponse(restrictionKindShadowBan, database.Suspension(ban)))
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, resp, http.StatusOK)
+}
@@ -0,0 +1,228 @@
+// restrictions_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// test authors always see their own chirps, whatever restriction they're under
+func TestRestrictedFromCallerSelf(t *testing.T) {
+	apiCfg := &apiConfig{}
+	authorID := uuid.New()
+	ctx := context.WithValue(context.Background(), principalContextKey, principal{UserID: authorID})
+	restricted, err := apiCfg.restrictedFromCaller(ctx, authorID)
+	if err != nil || restricted {
+		t.Errorf("restrictedFromCaller() self = %v, %v, want false, nil", restricted, err)
+	}
+}
+
+// test bad suspension and shadow ban requests are refused before the db is touched
+func TestRestrictUserBadRequest(t *testing.T) {
+	apiCfg := &apiConfig{}
+	callerID := uuid.New()
+	tests := []struct {
+		name   string
+		userID string
+		body   string
+	}{
+		{"Invalid ID", "nope", `{"reason":"spam"}`},
+		{"Self", callerID.String(), `{"reason":"spam"}`},
+		{"Bad JSON", uuid.NewString(), `{"reason":`},
+		{"Empty body", uuid.NewString(), ``},
+		{"Unknown reason", uuid.NewString(), `{"reason":"vibes"}`},
+		{"Negative days", uuid.NewString(), `{"reason":"spam","days":-1}`},
+		{"Too long", uuid.NewString(), `{"reason":"spam","days":366}`},
+	}
+
+	handlers := map[string]http.HandlerFunc{
+		"suspension": apiCfg.handlerSuspendUser,
+		"shadow-ban": apiCfg.handlerShadowBanUser,
+	}
+	for path, handler := range handlers {
+		for _, tt := range tests {
+			t.Run(path+"/"+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPost, "/admin/users/"+tt.userID+"/"+path, strings.NewReader(tt.body))
+				req.SetPathValue("userID", tt.userID)
+				ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: callerID})
+				rec := httptest.NewRecorder()
+				handler(rec, req.WithContext(ctx))
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+				}
+			})
+		}
+	}
+}
+
+// test lifting and listing refuse a bad user id before the db is touched
+func TestRestrictionsInvalidID(t *testing.T) {
+	apiCfg := &apiConfig{}
+	handlers := map[string]http.HandlerFunc{
+		"lift suspension": apiCfg.handlerLiftSuspension,
+		"lift shadow ban": apiCfg.handlerLiftShadowBan,
+		"restrictions":    apiCfg.handlerGetUserRestrictions,
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/admin/users/nope/suspension", nil)
+			req.SetPathValue("userID", "nope")
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// test restrictedAuthors hides the suspended and shadow banned from everyone but themselves
+func TestRestrictedAuthors(t *testing.T) {
+	callerID := uuid.New()
+	restrictedID := uuid.New()
+
+	// build test cases
+	testCases := []struct {
+		name     string          // name for test case
+		ctx      context.Context // who is asking
+		expected []uuid.UUID     // authors we want hidden
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: anonymous", context.Background(), []uuid.UUID{restrictedID, callerID}},
+		{"Test case: restricted caller", context.WithValue(context.Background(), principalContextKey, principal{UserID: callerID}), []uuid.UUID{restrictedID}},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		fake := newFakeQuerier()
+		fake.restricted = []uuid.UUID{restrictedID, callerID}
+		apiCfg := &apiConfig{db: fake}
+
+		restricted, err := apiCfg.restrictedAuthors(tc.ctx)
+		if err != nil {
+			t.Fatalf("%s: restrictedAuthors failed: %v", tc.name, err) // fatal, don't continue
+		}
+		if len(restricted) != len(tc.expected) {
+			t.Errorf("%s: restrictedAuthors = %v, want %v", tc.name, restricted, tc.expected)
+		}
+		for _, id := range tc.expected {
+			if !restricted[id] {
+				t.Errorf("%s: %s not restricted", tc.name, id)
+			}
+		}
+	}
+}
+
+// test restrictedFromCaller, authors always see their own chirps whatever restriction they're under
+func TestRestrictedFromCaller(t *testing.T) {
+	callerID := uuid.New()
+	restrictedID := uuid.New()
+	signedIn := context.WithValue(context.Background(), principalContextKey, principal{UserID: callerID})
+
+	// build test cases
+	testCases := []struct {
+		name     string          // name for test case
+		ctx      context.Context // who is asking
+		authorID uuid.UUID       // whose chirps
+		expected bool            // hidden from the caller
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: self", signedIn, callerID, false},
+		{"Test case: restricted author", signedIn, restrictedID, true},
+		{"Test case: restricted author, anonymous", context.Background(), restrictedID, true},
+		{"Test case: unrestricted author", signedIn, uuid.New(), false},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		fake := newFakeQuerier()
+		fake.restricted = []uuid.UUID{restrictedID, callerID}
+		apiCfg := &apiConfig{db: fake}
+
+		restricted, err := apiCfg.restrictedFromCaller(tc.ctx, tc.authorID)
+		if err != nil || restricted != tc.expected {
+			t.Errorf("%s: restrictedFromCaller = %v, %v, want %v, nil", tc.name, restricted, err, tc.expected)
+		}
+	}
+}
+
+// test who may be suspended or shadow banned, and by whom
+func TestRefuseRestrictionTarget(t *testing.T) {
+	callerID := uuid.New()
+	userID := uuid.New()
+	moderatorID := uuid.New()
+	adminID := uuid.New()
+	fake := newFakeQuerier()
+	fake.users[userID] = database.User{ID: userID, Role: auth.RoleUser}
+	fake.users[moderatorID] = database.User{ID: moderatorID, Role: auth.RoleModerator}
+	fake.users[adminID] = database.User{ID: adminID, Role: auth.RoleAdmin}
+	apiCfg := &apiConfig{db: fake}
+
+	// build test cases
+	testCases := []struct {
+		name           string    // name for test case
+		callerRole     string    // who is restricting
+		targetID       uuid.UUID // who they're restricting
+		expectedRefuse bool      // whether we want them turned away
+		expectedStatus int       // status written when refused
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: user by moderator", auth.RoleModerator, userID, false, http.StatusOK},
+		{"Test case: self", auth.RoleAdmin, callerID, true, http.StatusBadRequest},
+		{"Test case: unknown user", auth.RoleModerator, uuid.New(), true, http.StatusNotFound},
+		{"Test case: moderator by moderator", auth.RoleModerator, moderatorID, true, http.StatusForbidden},
+		{"Test case: admin by moderator", auth.RoleModerator, adminID, true, http.StatusForbidden},
+		{"Test case: moderator by admin", auth.RoleAdmin, moderatorID, false, http.StatusOK},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/"+tc.targetID.String()+"/suspension", nil)
+		rec := httptest.NewRecorder()
+		caller := principal{UserID: callerID, Role: tc.callerRole}
+
+		refused := apiCfg.refuseRestrictionTarget(rec, req, caller, tc.targetID)
+		if refused != tc.expectedRefuse || rec.Code != tc.expectedStatus {
+			t.Errorf("%s: refused = %v with status %d, want %v with %d", tc.name, refused, rec.Code, tc.expectedRefuse, tc.expectedStatus)
+		}
+	}
+}
+
+// test restrictions come back with their moderator and lift time only when set
+func TestRestrictionResponses(t *testing.T) {
+	moderatorID := uuid.New()
+	liftedAt := time.Now().UTC()
+
+	suspension := restrictionResponse(restrictionKindSuspension, database.Suspension{
+		ID:          uuid.New(),
+		UserID:      uuid.New(),
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Reason:      "spam",
+		EndsAt:      liftedAt.Add(time.Hour),
+		LiftedAt:    sql.NullTime{Time: liftedAt, Valid: true},
+	})
+	if suspension.Kind != restrictionKindSuspension {
+		t.Errorf("suspension Kind = %q, want %q", suspension.Kind, restrictionKindSuspension)
+	}
+	if suspension.ModeratorID == nil || *suspension.ModeratorID != moderatorID {
+		t.Errorf("suspension ModeratorID = %v, want %s", suspension.ModeratorID, moderatorID)
+	}
+	if suspension.LiftedAt == nil || !suspension.LiftedAt.Equal(liftedAt) {
+		t.Errorf("suspension LiftedAt = %v, want %s", suspension.LiftedAt, liftedAt)
+	}
+
+	ban := restrictionResponse(restrictionKindShadowBan, database.Suspension(database.ShadowBan{ID: uuid.New(), UserID: uuid.New(), Reason: "spam"}))
+	if ban.Kind != restrictionKindShadowBan {
+		t.Errorf("shadow ban Kind = %q, want %q", ban.Kind, restrictionKindShadowBan)
+	}
+	if ban.ModeratorID != nil || ban.LiftedAt != nil {
+		t.Errorf("shadow ban ModeratorID, LiftedAt = %v, %v, want nil, nil", ban.ModeratorID, ban.LiftedAt)
+	}
+}
@@ -0,0 +1,168 @@
+// spam.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/spam"
+	"github.com/google/uuid"
+)
+
+// most recent chirps the spam filter compares a new one with
+const spamHistoryLimit = 100
+
+// build the spam policy from the environment
+// SPAM_DUPLICATE_DISTANCE, SPAM_DUPLICATE_WINDOW_HOURS, SPAM_MAX_DUPLICATES tune duplicate detection
+// SPAM_MAX_LINKS, SPAM_MAX_LINK_PERCENT, SPAM_MAX_MENTIONS tune link and mention scoring
+// SPAM_NEW_ACCOUNT_HOURS, SPAM_NEW_ACCOUNT_CHIRPS, SPAM_NEW_ACCOUNT_WINDOW_MINUTES tune new account throttling
+// SPAM_HOLD_SCORE, SPAM_REJECT_SCORE pick the outcome, 0 turns either off
+func spamPolicyFromEnv() (spam.Policy, error) {
+	// start from the defaults
+	policy := spam.DefaultPolicy
+
+	// near duplicate bits, out of 64
+	distance, err := envUint("SPAM_DUPLICATE_DISTANCE", uint64(policy.DuplicateDistance), 7)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.DuplicateDistance = int(distance)
+
+	// duplicate lookback
+	duplicateHours, err := envUint("SPAM_DUPLICATE_WINDOW_HOURS", uint64(policy.DuplicateWindow/time.Hour), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.DuplicateWindow = time.Duration(duplicateHours) * time.Hour
+
+	// copies allowed
+	maxDuplicates, err := envUint("SPAM_MAX_DUPLICATES", uint64(policy.MaxDuplicates), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.MaxDuplicates = int(maxDuplicates)
+
+	// links per chirp
+	maxLinks, err := envUint("SPAM_MAX_LINKS", uint64(policy.MaxLinks), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.MaxLinks = int(maxLinks)
+
+	// share of words that may be links
+	maxLinkPercent, err := envUint("SPAM_MAX_LINK_PERCENT", uint64(policy.MaxLinkPercent), 8)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.MaxLinkPercent = int(maxLinkPercent)
+
+	// mentions per chirp
+	maxMentions, err := envUint("SPAM_MAX_MENTIONS", uint64(policy.MaxMentions), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.MaxMentions = int(maxMentions)
+
+	// how long an account counts as new
+	newAccountHours, err := envUint("SPAM_NEW_ACCOUNT_HOURS", uint64(policy.NewAccountAge/time.Hour), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.NewAccountAge = time.Duration(newAccountHours) * time.Hour
+
+	// chirps a new account may post per window
+	newAccountChirps, err := envUint("SPAM_NEW_ACCOUNT_CHIRPS", uint64(policy.NewAccountChirps), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.NewAccountChirps = int(newAccountChirps)
+
+	// the new account window
+	newAccountMinutes, err := envUint("SPAM_NEW_ACCOUNT_WINDOW_MINUTES", uint64(policy.NewAccountWindow/time.Minute), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.NewAccountWindow = time.Duration(newAccountMinutes) * time.Minute
+
+	// score to hold at
+	holdScore, err := envUint("SPAM_HOLD_SCORE", uint64(policy.HoldScore), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.HoldScore = int(holdScore)
+
+	// score to reject at
+	rejectScore, err := envUint("SPAM_REJECT_SCORE", uint64(policy.RejectScore), 16)
+	if err != nil {
+		return spam.Policy{}, err
+	}
+	policy.RejectScore = int(rejectScore)
+
+	// sanity check the ranges
+	if policy.DuplicateDistance > 64 {
+		return spam.Policy{}, errors.New("SPAM_DUPLICATE_DISTANCE must be at most 64")
+	}
+	if policy.MaxLinkPercent > 100 {
+		return spam.Policy{}, errors.New("SPAM_MAX_LINK_PERCENT must be at most 100")
+	}
+	if policy.HoldScore > 0 && policy.RejectScore > 0 && policy.RejectScore < policy.HoldScore {
+		return spam.Policy{}, errors.New("SPAM_REJECT_SCORE must not be below SPAM_HOLD_SCORE")
+	}
+
+	return policy, nil
+}
+
+// check a new chirp against the spam policy
+// run it in the transaction that inserts the chirp, after LockChirpAuthor, so two chirps can't both pass against the same history
+// earlier are chirps the db history won't show yet, an import's items before this one
+func (apiCfg *apiConfig) checkSpam(ctx context.Context, q database.Querier, author database.User, body string, earlier []spam.Post) (spam.Verdict, error) {
+	now := time.Now().UTC()
+
+	// the author's recent chirps
+	recent, err := q.GetRecentChirpsForUser(ctx, database.GetRecentChirpsForUserParams{
+		UserID:    author.ID,
+		Since:     now.Add(-apiCfg.spamPolicy.Lookback()),
+		MaxChirps: spamHistoryLimit,
+	})
+	if err != nil {
+		return spam.Verdict{}, err
+	}
+
+	// compare the new one with them
+	posts := make([]spam.Post, 0, len(recent)+len(earlier))
+	posts = append(posts, earlier...)
+	for _, chirp := range recent {
+		posts = append(posts, spam.Post{Body: chirp.Body, CreatedAt: chirp.CreatedAt})
+	}
+	return apiCfg.spamPolicy.Check(now, body, spam.Author{CreatedAt: author.CreatedAt, Recent: posts}), nil
+}
+
+// hold a new chirp for review: queue it for moderators and hide it from everyone but its author
+// run it in the chirp's transaction, so the chirp is never up without its hold
+// dismissing the report releases the hold, a moderator's hide is separate
+func holdChirp(ctx context.Context, q database.Querier, chirp *database.Chirp, verdict spam.Verdict) error {
+	// queue it, nobody reported it so there's no reporter
+	_, err := q.CreateReport(ctx, database.CreateReportParams{
+		Kind:      reportKindChirp,
+		UserID:    chirp.UserID,
+		ChirpID:   uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody: sql.NullString{String: chirp.Body, Valid: true},
+		Reason:    "spam",
+		Details:   "Held by the spam filter: " + strings.Join(verdict.Signals, ", "),
+	})
+	if err != nil {
+		return err
+	}
+
+	// hold it
+	_, err = q.HoldChirp(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	chirp.HeldAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return nil
+}
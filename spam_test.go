@@ -0,0 +1,133 @@
+// spam_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/spam"
+	"github.com/google/uuid"
+)
+
+// test the spam policy env overrides and their sanity checks
+func TestSpamPolicyFromEnv(t *testing.T) {
+	// defaults when unset
+	policy, err := spamPolicyFromEnv()
+	if err != nil {
+		t.Fatalf("spamPolicyFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if policy != spam.DefaultPolicy {
+		t.Errorf("policy = %+v, want the defaults", policy)
+	}
+
+	// tuned thresholds
+	t.Setenv("SPAM_DUPLICATE_WINDOW_HOURS", "6")
+	t.Setenv("SPAM_NEW_ACCOUNT_WINDOW_MINUTES", "30")
+	t.Setenv("SPAM_HOLD_SCORE", "0")
+	policy, err = spamPolicyFromEnv()
+	if err != nil {
+		t.Fatalf("spamPolicyFromEnv failed: %v", err) // fatal, don't continue
+	}
+	if policy.DuplicateWindow != 6*time.Hour || policy.NewAccountWindow != 30*time.Minute || policy.HoldScore != 0 {
+		t.Errorf("policy = %+v, want 6h duplicates, 30m new account window, no holds", policy)
+	}
+
+	// out of range values are rejected
+	for key, value := range map[string]string{
+		"SPAM_DUPLICATE_DISTANCE": "65",
+		"SPAM_MAX_LINK_PERCENT":   "101",
+		"SPAM_MAX_LINKS":          "lots",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := spamPolicyFromEnv(); err == nil {
+				t.Errorf("spamPolicyFromEnv accepted %s=%s", key, value)
+			}
+		})
+	}
+
+	// reject below hold is rejected
+	t.Setenv("SPAM_HOLD_SCORE", "5")
+	t.Setenv("SPAM_REJECT_SCORE", "3")
+	if _, err := spamPolicyFromEnv(); err == nil {
+		t.Errorf("spamPolicyFromEnv accepted a reject score below the hold score")
+	}
+}
+
+// test new chirps go through the spam filter: let through, held for review, refused or throttled
+func TestCreateChirpSpam(t *testing.T) {
+	now := time.Now().UTC()
+	verified := sql.NullTime{Time: now, Valid: true}
+
+	// build test cases
+	testCases := []struct {
+		name            string        // name for test case
+		accountAge      time.Duration // how old the author's account is
+		recent          int           // chirps the author posted in the last few minutes
+		body            string        // the new chirp
+		expectedStatus  int           // status code we want
+		expectedCreated bool          // whether it was stored
+		expectedHeld    bool          // whether it was held for review
+	}{ // }{ -- inits the test values for input vs expected
+		{"Test case: fine", 30 * 24 * time.Hour, 1, "a fine chirp", http.StatusCreated, true, false},
+		{"Test case: mention spam", 30 * 24 * time.Hour, 1, "@a @b @c @d @e @f look", http.StatusAccepted, true, true},
+		{"Test case: duplicate", 30 * 24 * time.Hour, 1, "earlier chirp 0", http.StatusBadRequest, false, false},
+		{"Test case: new account too fast", time.Hour, 10, "a fine chirp", http.StatusTooManyRequests, false, false},
+	}
+
+	// loop through the testcases and call the helper function
+	for _, tc := range testCases {
+		// an author with some recent chirps
+		fake := newFakeQuerier()
+		author := database.User{ID: uuid.New(), CreatedAt: now.Add(-tc.accountAge), EmailVerifiedAt: verified}
+		fake.users[author.ID] = author
+		for i := range tc.recent {
+			chirp := database.Chirp{ID: uuid.New(), CreatedAt: now.Add(-time.Minute), Body: "earlier chirp " + strconv.Itoa(i), UserID: author.ID}
+			fake.chirps[chirp.ID] = chirp
+		}
+		apiCfg := &apiConfig{db: fake, spamPolicy: spam.DefaultPolicy}
+
+		// chirp
+		body := strings.NewReader(`{"body":"` + tc.body + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/chirps", body)
+		ctx := context.WithValue(req.Context(), principalContextKey, principal{UserID: author.ID})
+		rec := httptest.NewRecorder()
+		apiCfg.handlerCreateChirp(rec, req.WithContext(ctx))
+
+		// check the outcome
+		if rec.Code != tc.expectedStatus {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.expectedStatus)
+		}
+		if fake.called("CreateChirp") != tc.expectedCreated {
+			t.Errorf("%s: created = %v, want %v", tc.name, fake.called("CreateChirp"), tc.expectedCreated)
+		}
+		if !fake.called("LockChirpAuthor") {
+			t.Errorf("%s: spam check ran without the author's lock", tc.name)
+		}
+		if !tc.expectedCreated {
+			continue
+		}
+
+		// held ones are queued for moderators and marked as held, not hidden
+		var resp JsonChirpResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: decoding response failed: %v", tc.name, err) // fatal, don't continue
+		}
+		stored := fake.chirps[resp.ID]
+		if resp.Held != tc.expectedHeld || stored.HeldAt.Valid != tc.expectedHeld || stored.HiddenAt.Valid {
+			t.Errorf("%s: held = %v (stored held %v, hidden %v), want held %v", tc.name, resp.Held, stored.HeldAt.Valid, stored.HiddenAt.Valid, tc.expectedHeld)
+		}
+		if fake.called("CreateReport") != tc.expectedHeld {
+			t.Errorf("%s: report filed = %v, want %v", tc.name, fake.called("CreateReport"), tc.expectedHeld)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+-- api_tokens.sql
+
+-- name: CreateAPIToken :one
+-- add "one" personal access token to the DB, user_id is fk
+INSERT INTO api_tokens (id, created_at, updated_at, user_id, name, token_hash, token_prefix, scopes, expires_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    NOW(),             -- current time
+    $1,                -- insert user id fk
+    $2,                -- insert token name
+    $3,                -- insert token hash
+    $4,                -- insert token prefix
+    $5,                -- insert scopes
+    $6                 -- insert expiration time
+)
+-- func generated will return these values for use in code
+RETURNING *;
+
+-- name: GetAPITokenByHash :one
+-- select one token (and its owner's plan and role) by token hash
+SELECT api_tokens.id, api_tokens.user_id, api_tokens.scopes, api_tokens.expires_at,
+       api_tokens.revoked_at, users.is_chirpy_red, users.role
+FROM api_tokens
+JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.token_hash = $1
+LIMIT 1;
+
+-- name: ListAPITokensByUser :many
+-- select all of a user's unrevoked tokens
+SELECT * FROM api_tokens
+WHERE user_id = $1
+  AND revoked_at IS NULL
+-- newest first
+ORDER BY created_at DESC;
+
+-- name: TouchAPIToken :exec
+-- record token use
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: RevokeAPIToken :one
+-- revoke one of the user's tokens
+UPDATE api_tokens
+SET
+  updated_at = NOW(), -- audit trail
+  revoked_at = NOW()  -- no longer accepted
+WHERE id = $1
+  AND user_id = $2 -- only the owner may revoke
+  AND revoked_at IS NULL
+RETURNING id;
+
+-- name: RevokeAllAPITokensForUser :exec
+-- revoke every one of a user's tokens (account deletion requested)
+UPDATE api_tokens
+SET
+  updated_at = NOW(), -- audit trail
+  revoked_at = NOW()  -- no longer accepted
+WHERE user_id = $1
+  AND revoked_at IS NULL;
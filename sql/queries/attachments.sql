@@ -0,0 +1,58 @@
+-- attachments.sql
+
+-- name: CreateAttachment :one
+-- add "one" processed upload, not yet part of a chirp
+INSERT INTO attachments (id, created_at, user_id, content_type, size_bytes, width, height, blob_key, thumbnail_key)
+VALUES (
+    $1,                -- id picked by the handler, it names the blobs
+    NOW(),             -- current time
+    $2,                -- insert uploader fk
+    $3,                -- insert sniffed content type
+    $4,                -- insert stored size
+    $5,                -- insert width
+    $6,                -- insert height
+    $7,                -- insert original blob key
+    $8                 -- insert thumbnail blob key
+)
+RETURNING *;
+
+-- name: AttachToChirp :execrows
+-- hand the uploader's unused attachments to their new chirp, in the order given
+-- all or nothing, a single unusable id attaches none
+UPDATE attachments
+SET
+  chirp_id = @chirp_id,                       -- the new chirp
+  position = array_position(@ids::uuid[], id) -- order as listed
+WHERE id = ANY(@ids::uuid[])
+  AND user_id = @user_id -- only the uploader's own
+  AND chirp_id IS NULL   -- and only once
+  AND (
+    SELECT COUNT(*) FROM attachments usable
+    WHERE usable.id = ANY(@ids::uuid[])
+      AND usable.user_id = @user_id
+      AND usable.chirp_id IS NULL
+  ) = cardinality(@ids::uuid[]);
+
+-- name: ListAttachmentsForChirps :many
+-- attachments for a page of chirps, in chirp order
+SELECT * FROM attachments
+WHERE chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: ListAttachmentsForUser :many
+-- everything a user uploaded, attached or not, for cleaning up their blobs
+SELECT * FROM attachments
+WHERE user_id = $1;
+
+-- name: CountPendingAttachmentsForUser :one
+-- uploads waiting for a chirp, capped per user
+SELECT COUNT(*) FROM attachments
+WHERE user_id = $1
+  AND chirp_id IS NULL;
+
+-- name: DeleteStaleAttachments :many
+-- forget uploads no chirp used in time, the caller deletes their blobs
+DELETE FROM attachments
+WHERE chirp_id IS NULL
+  AND created_at < @created_before
+RETURNING *;
@@ -0,0 +1,41 @@
+-- blocks.sql
+
+-- name: CreateBlock :exec
+-- block a user, blocking them again is a no-op
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    $1,   -- insert blocker fk
+    $2,   -- insert blocked fk
+    NOW() -- current time
+)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :exec
+-- unblock a user, unblocking someone not blocked is a no-op
+DELETE FROM user_blocks
+WHERE blocker_id = $1
+  AND blocked_id = $2;
+
+-- name: ListBlocks :many
+-- the users a user has blocked, newest first
+SELECT user_blocks.blocked_id, user_blocks.created_at, users.handle, users.avatar_url
+FROM user_blocks
+JOIN users ON users.id = user_blocks.blocked_id
+WHERE user_blocks.blocker_id = $1
+ORDER BY user_blocks.created_at DESC;
+
+-- name: IsBlockedEitherWay :one
+-- whether either user has blocked the other
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = @user_id AND blocked_id = @other_id)
+       OR (blocker_id = @other_id AND blocked_id = @user_id)
+);
+
+-- name: GetBlockedUserIDs :many
+-- everyone the user has blocked or been blocked by
+SELECT blocked_id FROM user_blocks
+WHERE blocker_id = $1
+UNION
+SELECT blocker_id FROM user_blocks
+WHERE blocked_id = $1;
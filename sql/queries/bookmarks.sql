@@ -0,0 +1,97 @@
+-- bookmarks.sql
+
+-- name: CreateBookmark :exec
+-- bookmark a chirp, bookmarking it again moves it to the given collection
+INSERT INTO bookmarks (user_id, chirp_id, created_at, collection_id)
+VALUES (
+    $1,     -- insert user id fk
+    $2,     -- insert chirp id fk
+    NOW(),  -- current time
+    $3      -- "null" for uncollected
+)
+ON CONFLICT (user_id, chirp_id) DO UPDATE
+SET collection_id = EXCLUDED.collection_id; -- keep the original time
+
+-- name: DeleteBookmark :exec
+-- remove a bookmark, nothing happens if there wasn't one
+DELETE FROM bookmarks
+WHERE user_id = $1
+  AND chirp_id = $2;
+
+-- name: ListBookmarks :many
+-- a page of a user's bookmarked chirps, newest bookmark first
+-- collection_id narrows it to one collection, before_at and before_id continue after a page
+SELECT chirps.*, bookmarks.created_at AS bookmarked_at, bookmarks.collection_id
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = @user_id
+  AND (sqlc.narg('collection_id')::uuid IS NULL OR bookmarks.collection_id = sqlc.narg('collection_id')::uuid)
+  AND (sqlc.narg('before_at')::timestamp IS NULL
+    OR (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.narg('before_at')::timestamp, sqlc.narg('before_id')::uuid))
+  -- chirps hidden by a moderator or held by the spam filter stay hidden, except from their author
+  AND ((chirps.hidden_at IS NULL AND chirps.held_at IS NULL) OR chirps.user_id = bookmarks.user_id)
+  -- chirps by the suspended and shadow banned stay hidden, except from their author
+  AND (chirps.user_id = bookmarks.user_id OR chirps.user_id NOT IN (
+    SELECT user_id FROM suspensions WHERE lifted_at IS NULL AND ends_at > NOW()
+    UNION
+    SELECT user_id FROM shadow_bans WHERE lifted_at IS NULL AND ends_at > NOW()
+  ))
+  -- chirps by someone on either side of a block stay hidden
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = bookmarks.user_id AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = bookmarks.user_id)
+  )
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT @page_size;
+
+-- name: GetBookmarkedChirpIDs :many
+-- which of a page of chirps the user has bookmarked
+SELECT chirp_id FROM bookmarks
+WHERE user_id = @user_id
+  AND chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: CreateBookmarkCollection :one
+-- add "one" named collection
+INSERT INTO bookmark_collections (id, created_at, updated_at, user_id, name)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    NOW(),             -- current time
+    $1,                -- insert owner fk
+    $2                 -- insert name
+)
+RETURNING *;
+
+-- name: GetBookmarkCollection :one
+-- one of the user's collections, never someone else's
+SELECT * FROM bookmark_collections
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: ListBookmarkCollections :many
+-- the user's collections with how many bookmarks each holds
+SELECT
+  bookmark_collections.*,
+  (SELECT COUNT(*) FROM bookmarks WHERE bookmarks.collection_id = bookmark_collections.id) AS bookmark_count
+FROM bookmark_collections
+WHERE user_id = $1
+ORDER BY lower(name) ASC;
+
+-- name: RenameBookmarkCollection :one
+-- rename one of the user's collections
+UPDATE bookmark_collections
+SET
+  name = $3,         -- the new name
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+  AND user_id = $2
+RETURNING
+  *,
+  (SELECT COUNT(*) FROM bookmarks WHERE bookmarks.collection_id = bookmark_collections.id) AS bookmark_count;
+
+-- name: DeleteBookmarkCollection :execrows
+-- delete one of the user's collections, its bookmarks stay uncollected
+DELETE FROM bookmark_collections
+WHERE id = $1
+  AND user_id = $2;
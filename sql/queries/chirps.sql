@@ -45,4 +45,55 @@ RETURNING *;         -- get the deleted record from chirps table!
 SELECT user_id FROM chirps
 -- by chirp id as input
 WHERE id = $1 -- user chirp id to get user
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+-- name: CreateImportedChirp :one
+-- add "one" chirp brought over from an archive, keeping its original time
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    $1,                -- insert original time
+    $1,                -- never edited since
+    $2,                -- insert moderated body
+    $3                 -- insert importing user id fk
+)
+-- the spam filter may still hold it
+RETURNING *;
+
+-- name: HideChirp :execrows
+-- hide a chirp from everyone but its author, hiding it again is a no-op
+UPDATE chirps
+SET
+  hidden_at = NOW(), -- hidden now
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+  AND hidden_at IS NULL;
+
+-- name: HoldChirp :execrows
+-- hold a chirp for review, only its author sees it until a moderator releases it
+UPDATE chirps
+SET
+  held_at = NOW(),   -- held now
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+  AND held_at IS NULL;
+
+-- name: ReleaseChirp :execrows
+-- release a held chirp, 0 rows when it wasn't held so a moderator's hide stays put
+UPDATE chirps
+SET
+  held_at = NULL,    -- review done
+  updated_at = NOW() -- audit trail
+WHERE id = $1
+  AND held_at IS NOT NULL;
+
+-- name: GetRecentChirpsForUser :many
+-- a user's latest chirps since a time, newest first, for the spam filter
+SELECT body, created_at FROM chirps
+WHERE user_id = @user_id
+  AND created_at > @since
+ORDER BY created_at DESC
+LIMIT @max_chirps;
+
+-- name: LockChirpAuthor :exec
+-- take a user's chirping lock until the transaction ends, so their spam check and insert can't interleave
+SELECT pg_advisory_xact_lock(hashtext(@user_id::uuid::text));
@@ -0,0 +1,32 @@
+-- email_tokens.sql
+
+-- name: CreateEmailToken :one
+-- add "one" emailed token to the DB, user_id is fk
+INSERT INTO email_tokens (id, created_at, user_id, purpose, token_hash, expires_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    $1,                -- insert user id fk
+    $2,                -- insert purpose
+    $3,                -- insert token hash
+    $4                 -- insert expiration time
+)
+RETURNING *;
+
+-- name: ConsumeEmailToken :one
+-- spend a live token, only works once
+UPDATE email_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+  AND purpose = $2
+  AND used_at IS NULL
+  AND expires_at > NOW()
+RETURNING user_id;
+
+-- name: InvalidateEmailTokens :exec
+-- retire a user's outstanding tokens for a purpose, only the newest link works
+UPDATE email_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+  AND purpose = $2
+  AND used_at IS NULL;
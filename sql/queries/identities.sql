@@ -0,0 +1,70 @@
+-- identities.sql
+
+-- name: CreateOIDCLogin :exec
+-- store a sign in (or link) flow in progress
+INSERT INTO oidc_logins (state, created_at, provider, nonce, code_verifier, user_id, expires_at)
+VALUES (
+    $1,    -- insert state
+    NOW(), -- current time
+    $2,    -- insert provider
+    $3,    -- insert nonce
+    $4,    -- insert pkce verifier
+    $5,    -- insert user id fk (nullable)
+    $6     -- insert expiration time
+);
+
+-- name: ConsumeOIDCLogin :one
+-- take a live flow, each state can only be used once
+DELETE FROM oidc_logins
+WHERE state = $1
+  AND provider = $2
+  AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLogins :exec
+-- drop abandoned flows
+DELETE FROM oidc_logins
+WHERE expires_at <= NOW();
+
+-- name: CreateUserIdentity :one
+-- link "one" external identity to a user, user_id is fk
+INSERT INTO user_identities (id, created_at, updated_at, user_id, provider, subject, email)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    NOW(),             -- current time
+    $1,                -- insert user id fk
+    $2,                -- insert provider
+    $3,                -- insert subject
+    $4                 -- insert email
+)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+-- select the identity for a provider's subject
+SELECT * FROM user_identities
+WHERE provider = $1
+  AND subject = $2
+LIMIT 1;
+
+-- name: ListUserIdentities :many
+-- select a user's linked identities, oldest first
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: RecordUserIdentityLogin :exec
+-- record a sign in, keeping the provider's latest email
+UPDATE user_identities
+SET
+  updated_at = NOW(),    -- audit trail
+  last_login_at = NOW(), -- last sign in
+  email = $2             -- latest email
+WHERE id = $1;
+
+-- name: DeleteUserIdentity :one
+-- unlink a provider, only the owner's
+DELETE FROM user_identities
+WHERE user_id = $1
+  AND provider = $2
+RETURNING id;
@@ -0,0 +1,48 @@
+-- login_attempts.sql
+
+-- name: GetLoginAttempt :one
+-- select the failed attempts recorded against one key
+SELECT * FROM login_attempts
+WHERE key = $1
+LIMIT 1;
+
+-- name: IncrementLoginAttempt :one
+-- count one more failure for a key in a single statement, so parallel failures can't undercount
+-- a key quiet since stale_before (and not locked out) starts again from 1
+INSERT INTO login_attempts (key, updated_at, failures, last_failure_at, locked_until)
+VALUES (
+    @key,   -- insert key
+    NOW(),  -- current time
+    1,      -- first failure
+    @now,   -- insert last failure time
+    NULL    -- not locked out
+)
+ON CONFLICT (key) DO UPDATE
+SET
+  updated_at = NOW(), -- audit trail
+  failures = CASE
+    WHEN login_attempts.last_failure_at < @stale_before
+      AND (login_attempts.locked_until IS NULL OR login_attempts.locked_until <= @now) THEN 1
+    ELSE login_attempts.failures + 1
+  END,
+  last_failure_at = @now -- new last failure
+RETURNING *;
+
+-- name: LockLoginAttempt :exec
+-- lock a key out until a time
+UPDATE login_attempts
+SET
+  updated_at = NOW(),   -- audit trail
+  locked_until = $2     -- new lockout
+WHERE key = $1;
+
+-- name: DeleteLoginAttempt :exec
+-- forget a key (successful login or admin unlock)
+DELETE FROM login_attempts
+WHERE key = $1;
+
+-- name: DeleteStaleLoginAttempts :execrows
+-- forget keys quiet since stale_before and not locked out, so the table doesn't grow forever
+DELETE FROM login_attempts
+WHERE last_failure_at < @stale_before
+  AND (locked_until IS NULL OR locked_until <= @now);
@@ -0,0 +1,106 @@
+-- moderation.sql
+
+-- name: CreateModerationAction :one
+-- record "one" moderator action against a report
+INSERT INTO moderation_actions (id, created_at, report_id, moderator_id, action, notes)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    $1,                -- insert report fk
+    $2,                -- insert moderator fk
+    $3,                -- insert action
+    $4                 -- insert notes
+)
+RETURNING *;
+
+-- name: ListModerationActionsForReport :many
+-- a report's history, oldest first
+SELECT * FROM moderation_actions
+WHERE report_id = $1
+ORDER BY created_at ASC;
+
+-- name: CreateSuspension :one
+-- suspend a user until ends_at
+INSERT INTO suspensions (id, created_at, user_id, moderator_id, reason, ends_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    $1,                -- insert suspended user fk
+    $2,                -- insert moderator fk
+    $3,                -- insert reason
+    $4                 -- insert end time
+)
+RETURNING *;
+
+-- name: GetActiveSuspension :one
+-- the user's suspension in force now, the one ending last if there's more than one
+SELECT * FROM suspensions
+WHERE user_id = $1
+  AND lifted_at IS NULL
+  AND ends_at > NOW()
+ORDER BY ends_at DESC
+LIMIT 1;
+
+-- name: GetActiveSuspensions :many
+-- every suspension in force now, for the access token denylist
+SELECT user_id, ends_at FROM suspensions
+WHERE lifted_at IS NULL
+  AND ends_at > NOW();
+
+-- name: LiftSuspensions :execrows
+-- end the user's suspensions early, 0 rows when none is in force
+UPDATE suspensions
+SET lifted_at = NOW() -- lifted now
+WHERE user_id = $1
+  AND lifted_at IS NULL
+  AND ends_at > NOW();
+
+-- name: ListSuspensionsForUser :many
+-- every suspension a user has had, latest first
+SELECT * FROM suspensions
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: CreateShadowBan :one
+-- shadow ban a user until ends_at
+INSERT INTO shadow_bans (id, created_at, user_id, moderator_id, reason, ends_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    $1,                -- insert banned user fk
+    $2,                -- insert moderator fk
+    $3,                -- insert reason
+    $4                 -- insert end time
+)
+RETURNING *;
+
+-- name: LiftShadowBans :execrows
+-- end the user's shadow bans early, 0 rows when none is in force
+UPDATE shadow_bans
+SET lifted_at = NOW() -- lifted now
+WHERE user_id = $1
+  AND lifted_at IS NULL
+  AND ends_at > NOW();
+
+-- name: ListShadowBansForUser :many
+-- every shadow ban a user has had, latest first
+SELECT * FROM shadow_bans
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetRestrictedUserIDs :many
+-- everyone suspended or shadow banned right now, their chirps are seen by them only
+SELECT user_id FROM suspensions
+WHERE lifted_at IS NULL
+  AND ends_at > NOW()
+UNION
+SELECT user_id FROM shadow_bans
+WHERE lifted_at IS NULL
+  AND ends_at > NOW();
+
+-- name: IsUserRestricted :one
+-- whether a user is suspended or shadow banned right now
+SELECT (
+    EXISTS (SELECT 1 FROM suspensions WHERE suspensions.user_id = @user_id AND lifted_at IS NULL AND ends_at > NOW())
+    OR EXISTS (SELECT 1 FROM shadow_bans WHERE shadow_bans.user_id = @user_id AND lifted_at IS NULL AND ends_at > NOW())
+) AS restricted;
@@ -0,0 +1,30 @@
+-- mutes.sql
+
+-- name: CreateMute :exec
+-- mute a user, muting them again is a no-op
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES (
+    $1,   -- insert muter fk
+    $2,   -- insert muted fk
+    NOW() -- current time
+)
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteMute :exec
+-- unmute a user, unmuting someone not muted is a no-op
+DELETE FROM user_mutes
+WHERE muter_id = $1
+  AND muted_id = $2;
+
+-- name: ListMutes :many
+-- the users a user has muted, newest first
+SELECT user_mutes.muted_id, user_mutes.created_at, users.handle, users.avatar_url
+FROM user_mutes
+JOIN users ON users.id = user_mutes.muted_id
+WHERE user_mutes.muter_id = $1
+ORDER BY user_mutes.created_at DESC;
+
+-- name: GetMutedUserIDs :many
+-- everyone the user has muted
+SELECT muted_id FROM user_mutes
+WHERE muter_id = $1;
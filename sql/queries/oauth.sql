@@ -0,0 +1,114 @@
+-- oauth.sql
+
+-- name: CreateOAuthClient :one
+-- register "one" third party app, owner_id is fk
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, redirect_uris, scopes, secret_hash)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    NOW(),             -- current time
+    $1,                -- insert owner id fk
+    $2,                -- insert client name
+    $3,                -- insert redirect uris
+    $4,                -- insert allowed scopes
+    $5                 -- insert secret hash (nullable)
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+-- select a client by its client_id
+SELECT * FROM oauth_clients
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListOAuthClientsByOwner :many
+-- select a user's registered apps, newest first
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteOAuthClient :one
+-- remove an app and (by cascade) its codes and refresh tokens, only the owner's
+DELETE FROM oauth_clients
+WHERE id = $1
+  AND owner_id = $2
+RETURNING id;
+
+-- name: CreateOAuthCode :exec
+-- store a consented authorization code
+INSERT INTO oauth_codes (id, created_at, code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    $1,                -- insert code hash
+    $2,                -- insert client id fk
+    $3,                -- insert user id fk
+    $4,                -- insert redirect uri
+    $5,                -- insert scopes
+    $6,                -- insert pkce challenge
+    $7                 -- insert expiration time
+);
+
+-- name: GetOAuthCodeByHash :one
+-- select a code by its hash
+SELECT * FROM oauth_codes
+WHERE code_hash = $1
+LIMIT 1;
+
+-- name: MarkOAuthCodeUsed :execrows
+-- spend a code, zero rows means someone else already did
+UPDATE oauth_codes
+SET used_at = NOW()
+WHERE id = $1
+  AND used_at IS NULL;
+
+-- name: DeleteExpiredOAuthCodes :exec
+-- drop codes past their lifetime, replays of them fail as unknown anyway
+DELETE FROM oauth_codes
+WHERE expires_at <= NOW();
+
+-- name: CreateOAuthRefreshToken :exec
+-- store a refresh token for a grant
+INSERT INTO oauth_refresh_tokens (id, created_at, token_hash, grant_id, client_id, user_id, scopes, expires_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    $1,                -- insert token hash
+    $2,                -- insert grant id
+    $3,                -- insert client id fk
+    $4,                -- insert user id fk
+    $5,                -- insert scopes
+    $6                 -- insert expiration time
+);
+
+-- name: GetOAuthRefreshTokenByHash :one
+-- select a refresh token by its hash
+SELECT * FROM oauth_refresh_tokens
+WHERE token_hash = $1
+LIMIT 1;
+
+-- name: RevokeOAuthRefreshToken :execrows
+-- revoke one refresh token, zero rows means it already was
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW()
+WHERE id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrant :exec
+-- revoke every refresh token descending from a code (replay or reuse detected)
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW()
+WHERE grant_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeAllOAuthGrantsForUser :exec
+-- revoke every refresh token a user's consents produced (account deletion requested)
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: DeleteOAuthCodesForUser :exec
+-- drop a user's unexchanged codes, so none turns into a fresh grant
+DELETE FROM oauth_codes
+WHERE user_id = $1;
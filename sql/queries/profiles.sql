@@ -0,0 +1,45 @@
+-- profiles.sql
+
+-- name: UpdateUserProfile :one
+-- set the public profile fields, the handler passes every field (changed or not)
+UPDATE users
+SET
+  handle = $2,       -- "null" clears the handle
+  display_name = $3, -- shown next to the handle
+  bio = $4,          -- short public bio
+  avatar_url = $5,   -- "null" for no picture
+  updated_at = NOW() -- audit trail
+-- by user id as input
+WHERE id = $1
+RETURNING *;
+
+-- name: GetPublicProfile :one
+-- the public side of an account, never the email
+SELECT
+  users.id,
+  users.created_at,
+  users.handle,
+  users.display_name,
+  users.bio,
+  users.avatar_url,
+  (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id) AS chirp_count
+FROM users
+-- handles are unique whatever the case
+WHERE lower(users.handle) = lower($1)
+LIMIT 1;
+
+-- name: GetUserHandles :many
+-- handles for a page of chirp authors, users without one are left out
+SELECT id, handle FROM users
+WHERE id = ANY(@ids::uuid[])
+  AND handle IS NOT NULL;
+
+-- name: SetUserAvatar :exec
+-- set or clear the uploaded avatar, either way it replaces any avatar link
+UPDATE users
+SET
+  avatar_key = $2,   -- "null" falls back to the identicon
+  avatar_url = NULL, -- the upload is the picture now
+  updated_at = NOW() -- audit trail
+-- by user id as input
+WHERE id = $1;
@@ -26,4 +26,19 @@ UPDATE refresh_tokens
 SET 
   updated_at = NOW(), 
   revoked_at = NOW() 
-WHERE token = $1; -- use token string (unique as it's a pk) 
\ No newline at end of file
+WHERE token = $1; -- use token string (unique as it's a pk) 
+
+-- name: RevokeAllRefreshTokensForUser :exec
+-- sign a user out everywhere (password reset or change)
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: ListRefreshTokensForUser :many
+-- a user's sessions for their data export, never the token itself
+SELECT created_at, expires_at, revoked_at FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
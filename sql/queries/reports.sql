@@ -0,0 +1,40 @@
+-- reports.sql
+
+-- name: CreateReport :one
+-- file "one" report, the partial unique indexes refuse a second open one per target
+INSERT INTO reports (id, created_at, updated_at, kind, reporter_id, user_id, chirp_id, chirp_body, reason, details)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    NOW(),             -- current time
+    $1,                -- insert kind
+    $2,                -- insert reporter fk ("null" for the spam filter)
+    $3,                -- insert reported user fk
+    $4,                -- insert reported chirp fk ("null" for user reports)
+    $5,                -- insert the chirp as reported
+    $6,                -- insert reason code
+    $7                 -- insert details
+)
+RETURNING *;
+
+-- name: GetReport :one
+-- one report by id
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: ListReports :many
+-- the moderation queue, oldest first
+SELECT * FROM reports
+WHERE status = @status
+ORDER BY created_at ASC, id ASC
+LIMIT @page_size;
+
+-- name: ResolveReport :execrows
+-- close an open report, 0 rows when someone else got there first
+UPDATE reports
+SET
+  status = $2,         -- dismissed or actioned
+  resolved_at = NOW(), -- closed now
+  updated_at = NOW()   -- audit trail
+WHERE id = $1
+  AND status = 'open';
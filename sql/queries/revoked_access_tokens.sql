@@ -0,0 +1,24 @@
+-- revoked_access_tokens.sql
+
+-- name: RevokeAccessToken :exec
+-- add an access token id to the denylist
+INSERT INTO revoked_access_tokens (jti, created_at, user_id, expires_at, reason)
+VALUES (
+    $1,     -- insert token id
+    NOW(),  -- current time
+    $2,     -- insert user id fk (nullable)
+    $3,     -- insert token expiry
+    $4      -- insert reason
+)
+-- revoking twice is harmless
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: GetActiveRevokedAccessTokens :many
+-- select revoked token ids that haven't expired yet
+SELECT jti, expires_at FROM revoked_access_tokens
+WHERE expires_at > NOW();
+
+-- name: DeleteExpiredRevokedAccessTokens :exec
+-- expired tokens fail validation anyway, so drop them
+DELETE FROM revoked_access_tokens
+WHERE expires_at <= NOW();
@@ -0,0 +1,18 @@
+-- subscriptions.sql
+
+-- name: CreateSubscriptionEvent :exec
+-- record "one" billing event against a user
+INSERT INTO subscription_events (id, created_at, user_id, event, plan)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    $1,                -- insert user id fk
+    $2,                -- insert event name
+    $3                 -- insert plan after the event
+);
+
+-- name: ListSubscriptionEventsForUser :many
+-- a user's subscription history, oldest first
+SELECT * FROM subscription_events
+WHERE user_id = $1
+ORDER BY created_at ASC;
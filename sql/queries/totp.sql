@@ -0,0 +1,85 @@
+-- totp.sql
+
+-- name: GetUserTOTP :one
+-- select a user's authenticator, confirmed or not
+SELECT * FROM user_totp
+WHERE user_id = $1
+LIMIT 1;
+
+-- name: UpsertUserTOTP :one
+-- start (or restart) enrolment with a new secret
+-- a confirmed authenticator is left alone, so no row comes back
+INSERT INTO user_totp (user_id, created_at, updated_at, secret)
+VALUES (
+    $1,     -- insert user id fk
+    NOW(),  -- current time
+    NOW(),  -- current time
+    $2      -- insert secret
+)
+ON CONFLICT (user_id) DO UPDATE
+SET
+  updated_at = NOW(),               -- audit trail
+  secret = EXCLUDED.secret,         -- new secret
+  last_used_step = NULL             -- fresh secret, fresh steps
+WHERE user_totp.confirmed_at IS NULL
+RETURNING *;
+
+-- name: ConfirmUserTOTP :one
+-- turn 2fa on once the user proved they hold the secret
+UPDATE user_totp
+SET
+  updated_at = NOW(),       -- audit trail
+  confirmed_at = NOW(),     -- 2fa is now on
+  last_used_step = $2       -- the confirming code can't be reused
+WHERE user_id = $1
+  AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseTOTPStep :one
+-- accept a code's time step only if it is newer than the last one used
+UPDATE user_totp
+SET
+  updated_at = NOW(),       -- audit trail
+  last_used_step = $2       -- remember the step
+WHERE user_id = $1
+  AND confirmed_at IS NOT NULL
+  AND (last_used_step IS NULL OR last_used_step < $2)
+RETURNING user_id;
+
+-- name: DeleteUserTOTP :exec
+-- turn 2fa off
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: ReplaceRecoveryCodes :exec
+-- swap a user's recovery codes for a new batch in one statement
+WITH cleared AS (
+    DELETE FROM recovery_codes
+    WHERE recovery_codes.user_id = $1
+)
+INSERT INTO recovery_codes (id, created_at, user_id, code_hash)
+SELECT
+    gen_random_uuid(),  -- generate a unique id
+    NOW(),              -- current time
+    $1,                 -- insert user id fk
+    unnest($2::TEXT[]); -- one row per code hash
+
+-- name: UseRecoveryCode :one
+-- spend a recovery code, only works once
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+  AND code_hash = $2
+  AND used_at IS NULL
+RETURNING id;
+
+-- name: CountUnusedRecoveryCodes :one
+-- how many recovery codes a user has left
+SELECT COUNT(*) FROM recovery_codes
+WHERE user_id = $1
+  AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+-- forget all of a user's recovery codes (2fa turned off)
+DELETE FROM recovery_codes
+WHERE user_id = $1;
@@ -18,17 +18,18 @@ RETURNING *;
 DELETE FROM users;
 
 -- name: GetUserByEmail :one
--- select one user by email
+-- select one user by email, ignoring case
 SELECT * FROM users
 -- by user email as input
-WHERE email = $1
+WHERE lower(email) = lower($1)
 LIMIT 1;
 
 -- name: UpdateUserLogin :one 
 UPDATE users 
 SET 
   updated_at = NOW(),  -- audit trail
-  email = $2, -- user provides new email
+  email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END, -- a new address must be verified again
+  email = $2, -- user provides new email (only ever a confirmed one)
   hashed_password = $3 -- user provides new password
 WHERE id = $1 -- use userid from token get bearer (unique as it's a pk) 
 -- return only updated_at to match resp timestamp (rest are inputs from code, no need to return)
@@ -49,4 +50,107 @@ SET
   updated_at = NOW()   -- audit trail
 -- by user id as input
 WHERE id = $1
-RETURNING id, is_chirpy_red;
\ No newline at end of file
+RETURNING id, is_chirpy_red;
+
+-- name: SetUserRole :one
+-- set a user's role (user/moderator/admin)
+UPDATE users
+SET
+  role = $2,         -- new role
+  updated_at = NOW() -- audit trail
+-- by user id as input
+WHERE id = $1
+RETURNING id, role, updated_at;
+
+-- name: UpdateUserPasswordHash :exec
+-- swap in a fresh hash of the same password (algorithm or cost upgrade)
+UPDATE users
+SET
+  hashed_password = $2, -- new hash
+  updated_at = NOW()    -- audit trail
+-- by user id as input
+WHERE id = $1;
+
+-- name: RehashUserPassword :execrows
+-- swap in a fresh hash of the same password, only if the hash is still the one that was verified
+-- 0 rows when the password was changed meanwhile, the newer password wins
+UPDATE users
+SET
+  hashed_password = @hashed_password, -- new hash
+  updated_at = NOW()                  -- audit trail
+WHERE id = @id
+  AND hashed_password = @old_hashed_password;
+
+-- name: MarkUserEmailVerified :exec
+-- the user proved they read mail at their address
+UPDATE users
+SET
+  email_verified_at = COALESCE(email_verified_at, NOW()), -- keep the first time
+  updated_at = NOW()                                      -- audit trail
+-- by user id as input
+WHERE id = $1;
+
+-- name: CreateUserWithoutPassword :one
+-- add "one" user signing up through an identity provider, the password stays "unset"
+INSERT INTO users (id, created_at, updated_at, email, email_verified_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    NOW(),             -- current time
+    $1,                -- insert email
+    $2                 -- "null" unless the provider vouched for the email
+)
+RETURNING *;
+
+-- name: SetUserPendingEmail :exec
+-- park a requested new address until the user confirms it
+UPDATE users
+SET
+  pending_email = $2, -- requested address ("null" cancels)
+  updated_at = NOW()  -- audit trail
+-- by user id as input
+WHERE id = $1;
+
+-- name: ConfirmUserEmailChange :one
+-- swap in the confirmed address, it was just proven so it's verified too
+UPDATE users
+SET
+  email = pending_email,     -- the confirmed address
+  pending_email = NULL,      -- nothing awaits confirmation
+  email_verified_at = NOW(), -- the link reached the new inbox
+  updated_at = NOW()         -- audit trail
+-- by user id as input
+WHERE id = $1
+  AND pending_email IS NOT NULL
+RETURNING *;
+
+-- name: ScheduleUserDeletion :exec
+-- start the grace period, the account goes at delete_after unless cancelled
+UPDATE users
+SET
+  delete_after = $2, -- end of the grace period
+  updated_at = NOW() -- audit trail
+-- by user id as input
+WHERE id = $1;
+
+-- name: CancelUserDeletion :execrows
+-- stop a pending deletion, no rows when none was pending
+UPDATE users
+SET
+  delete_after = NULL, -- keep the account
+  updated_at = NOW()   -- audit trail
+-- by user id as input
+WHERE id = $1
+  AND delete_after IS NOT NULL;
+
+-- name: GetUsersDueForDeletion :many
+-- accounts whose grace period is over, with the avatar to clean up
+SELECT id, avatar_key FROM users
+WHERE delete_after <= NOW();
+
+-- name: DeleteUserIfDue :execrows
+-- delete an account whose grace period is over, no rows if it was cancelled meanwhile
+-- chirps, tokens and everything else go with it (on delete cascade)
+DELETE FROM users
+WHERE id = $1
+  AND delete_after <= NOW();
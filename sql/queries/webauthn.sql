@@ -0,0 +1,70 @@
+-- webauthn.sql
+
+-- name: CreateWebAuthnChallenge :one
+-- store a challenge for a ceremony in progress
+INSERT INTO webauthn_challenges (id, created_at, user_id, ceremony, challenge, expires_at)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    $1,                -- insert user id fk (nullable)
+    $2,                -- insert ceremony
+    $3,                -- insert challenge
+    $4                 -- insert expiration time
+)
+RETURNING *;
+
+-- name: ConsumeWebAuthnChallenge :one
+-- take a live challenge, each one can only be used once
+DELETE FROM webauthn_challenges
+WHERE id = $1
+  AND ceremony = $2
+  AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredWebAuthnChallenges :exec
+-- drop challenges for abandoned ceremonies
+DELETE FROM webauthn_challenges
+WHERE expires_at <= NOW();
+
+-- name: CreateWebAuthnCredential :one
+-- add "one" passkey to the DB, user_id is fk
+INSERT INTO webauthn_credentials (id, created_at, updated_at, user_id, credential_id, public_key, sign_count, name)
+VALUES (
+    gen_random_uuid(), -- generate a unique id
+    NOW(),             -- current time
+    NOW(),             -- current time
+    $1,                -- insert user id fk
+    $2,                -- insert credential id
+    $3,                -- insert public key
+    $4,                -- insert sign count
+    $5                 -- insert passkey name
+)
+RETURNING *;
+
+-- name: GetWebAuthnCredentialByCredentialID :one
+-- select a passkey by the authenticator's credential id
+SELECT * FROM webauthn_credentials
+WHERE credential_id = $1
+LIMIT 1;
+
+-- name: ListWebAuthnCredentialsByUser :many
+-- select a user's passkeys, newest first
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: UpdateWebAuthnCredentialSignCount :exec
+-- record a successful sign in
+UPDATE webauthn_credentials
+SET
+  updated_at = NOW(),       -- audit trail
+  last_used_at = NOW(),     -- last sign in
+  sign_count = $2           -- new counter
+WHERE id = $1;
+
+-- name: DeleteWebAuthnCredential :one
+-- remove a passkey, only the owner's
+DELETE FROM webauthn_credentials
+WHERE id = $1
+  AND user_id = $2
+RETURNING id;
@@ -0,0 +1,16 @@
+-- 005_revoked_access_tokens.sql
+-- +goose Up
+CREATE TABLE revoked_access_tokens (
+    jti TEXT PRIMARY KEY,          -- access token id (jwt "jti" claim)
+    created_at TIMESTAMP NOT NULL, -- for auditing
+    user_id UUID NULL,             -- token owner, if known
+    expires_at TIMESTAMP NOT NULL, -- token expiry, row is useless after this
+    reason TEXT NOT NULL DEFAULT '', -- why it was revoked
+    -- link user_id to revoked token as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan revocations
+);
+
+-- +goose Down
+DROP TABLE revoked_access_tokens;
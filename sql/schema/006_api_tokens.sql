@@ -0,0 +1,22 @@
+-- 006_api_tokens.sql
+-- +goose Up
+CREATE TABLE api_tokens (
+    id UUID PRIMARY KEY,                -- our pk
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    updated_at TIMESTAMP NOT NULL,      -- for auditing
+    user_id UUID NOT NULL,              -- token owner for fk
+    name TEXT NOT NULL,                 -- user given label
+    token_hash TEXT NOT NULL UNIQUE,    -- sha256 of the token, never the token
+    token_prefix TEXT NOT NULL,         -- first few chars, to recognise a token
+    scopes TEXT[] NOT NULL,             -- granted scopes
+    expires_at TIMESTAMP NOT NULL,      -- expiration checking
+    last_used_at TIMESTAMP NULL,        -- defaults to "null"
+    revoked_at TIMESTAMP NULL,          -- defaults to "null"
+    -- link user_id to api_token as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan api_tokens
+);
+
+-- +goose Down
+DROP TABLE api_tokens;
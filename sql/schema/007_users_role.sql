@@ -0,0 +1,12 @@
+-- 007_users_role.sql
+-- +goose Up
+ALTER TABLE users
+-- role col added, one of user/moderator/admin, default to user
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'))
+;
+
+-- +goose Down
+ALTER TABLE users
+-- drop the col to undo
+DROP COLUMN role;
@@ -0,0 +1,12 @@
+-- 008_login_attempts.sql
+-- +goose Up
+CREATE TABLE login_attempts (
+    key TEXT PRIMARY KEY,                -- what is being limited, e.g. "account:bob@x.com" or "ip:1.2.3.4"
+    updated_at TIMESTAMP NOT NULL,       -- for auditing
+    failures INTEGER NOT NULL,           -- consecutive failures
+    last_failure_at TIMESTAMP NOT NULL,  -- backoff is measured from here
+    locked_until TIMESTAMP NULL          -- defaults to "null", set when locked out
+);
+
+-- +goose Down
+DROP TABLE login_attempts;
@@ -0,0 +1,31 @@
+-- 009_totp.sql
+-- +goose Up
+CREATE TABLE user_totp (
+    user_id UUID PRIMARY KEY,           -- one authenticator per user, also the fk
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    updated_at TIMESTAMP NOT NULL,      -- for auditing
+    secret TEXT NOT NULL,               -- base32 shared secret
+    confirmed_at TIMESTAMP NULL,        -- defaults to "null", 2fa is only on once confirmed
+    last_used_step BIGINT NULL,         -- last accepted time step, stops code replay
+    -- link user_id to user_totp as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan user_totp
+);
+
+CREATE TABLE recovery_codes (
+    id UUID PRIMARY KEY,                -- our pk
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    user_id UUID NOT NULL,              -- code owner for fk
+    code_hash TEXT NOT NULL,            -- sha256 of the code, never the code
+    used_at TIMESTAMP NULL,             -- defaults to "null", set when spent
+    UNIQUE (user_id, code_hash),
+    -- link user_id to recovery_codes as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan recovery_codes
+);
+
+-- +goose Down
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
@@ -0,0 +1,35 @@
+-- 010_webauthn.sql
+-- +goose Up
+CREATE TABLE webauthn_credentials (
+    id UUID PRIMARY KEY,                -- our pk
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    updated_at TIMESTAMP NOT NULL,      -- for auditing
+    user_id UUID NOT NULL,              -- passkey owner for fk
+    credential_id BYTEA NOT NULL UNIQUE, -- id chosen by the authenticator
+    public_key BYTEA NOT NULL,          -- COSE_Key bytes
+    sign_count BIGINT NOT NULL,         -- signature counter, catches cloned authenticators
+    name TEXT NOT NULL,                 -- user given label
+    last_used_at TIMESTAMP NULL,        -- defaults to "null"
+    -- link user_id to webauthn_credentials as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan webauthn_credentials
+);
+
+CREATE TABLE webauthn_challenges (
+    id UUID PRIMARY KEY,                -- our pk, handed to the client
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    user_id UUID NULL,                  -- registering user, "null" for sign in
+    ceremony TEXT NOT NULL              -- which ceremony the challenge is for
+        CHECK (ceremony IN ('registration', 'login')),
+    challenge TEXT NOT NULL,            -- base64url random challenge
+    expires_at TIMESTAMP NOT NULL,      -- expiration checking
+    -- link user_id to webauthn_challenges as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan webauthn_challenges
+);
+
+-- +goose Down
+DROP TABLE webauthn_challenges;
+DROP TABLE webauthn_credentials;
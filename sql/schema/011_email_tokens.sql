@@ -0,0 +1,30 @@
+-- 011_email_tokens.sql
+-- +goose Up
+ALTER TABLE users
+-- verified col added, "null" until the user clicks the emailed link
+ADD COLUMN email_verified_at TIMESTAMP NULL;
+
+-- existing accounts predate verification, don't lock them out of chirping
+UPDATE users SET email_verified_at = NOW();
+
+CREATE TABLE email_tokens (
+    id UUID PRIMARY KEY,                -- our pk
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    user_id UUID NOT NULL,              -- token owner for fk
+    purpose TEXT NOT NULL               -- what the emailed link is for
+        CHECK (purpose IN ('verify_email', 'reset_password')),
+    token_hash TEXT NOT NULL UNIQUE,    -- hmac of the token, never the token
+    expires_at TIMESTAMP NOT NULL,      -- expiration checking
+    used_at TIMESTAMP NULL,             -- defaults to "null", set when spent (or superseded)
+    -- link user_id to email_tokens as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan email_tokens
+);
+
+-- +goose Down
+DROP TABLE email_tokens;
+
+ALTER TABLE users
+-- drop the col to undo
+DROP COLUMN email_verified_at;
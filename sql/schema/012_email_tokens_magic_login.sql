@@ -0,0 +1,17 @@
+-- 012_email_tokens_magic_login.sql
+-- +goose Up
+ALTER TABLE email_tokens
+-- allow magic login links alongside verify and reset
+DROP CONSTRAINT email_tokens_purpose_check,
+ADD CONSTRAINT email_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'magic_login'));
+
+-- +goose Down
+-- magic links can't survive the old check
+DELETE FROM email_tokens WHERE purpose = 'magic_login';
+
+ALTER TABLE email_tokens
+-- back to verify and reset only
+DROP CONSTRAINT email_tokens_purpose_check,
+ADD CONSTRAINT email_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password'));
@@ -0,0 +1,36 @@
+-- 013_user_identities.sql
+-- +goose Up
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY,                -- our pk
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    updated_at TIMESTAMP NOT NULL,      -- for auditing
+    user_id UUID NOT NULL,              -- linked user for fk
+    provider TEXT NOT NULL,             -- our name for the identity provider
+    subject TEXT NOT NULL,              -- the provider's stable id for the user ("sub")
+    email TEXT NOT NULL,                -- email the provider last gave, for display only
+    last_login_at TIMESTAMP NULL,       -- defaults to "null"
+    UNIQUE (provider, subject),         -- one account per external identity
+    UNIQUE (user_id, provider),         -- one identity per provider per account
+    -- link user_id to user_identities as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan user_identities
+);
+
+CREATE TABLE oidc_logins (
+    state TEXT PRIMARY KEY,             -- random state, round trips through the provider
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    provider TEXT NOT NULL,             -- provider the flow was started for
+    nonce TEXT NOT NULL,                -- must come back in the id token
+    code_verifier TEXT NOT NULL,        -- pkce verifier, never leaves the server
+    user_id UUID NULL,                  -- linking user, "null" for sign in
+    expires_at TIMESTAMP NOT NULL,      -- expiration checking
+    -- link user_id to oidc_logins as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan oidc_logins
+);
+
+-- +goose Down
+DROP TABLE oidc_logins;
+DROP TABLE user_identities;
@@ -0,0 +1,62 @@
+-- 014_oauth.sql
+-- +goose Up
+CREATE TABLE oauth_clients (
+    id UUID PRIMARY KEY,                -- our pk, doubles as the public client_id
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    updated_at TIMESTAMP NOT NULL,      -- for auditing
+    owner_id UUID NOT NULL,             -- registering user for fk
+    name TEXT NOT NULL,                 -- shown on the consent page
+    redirect_uris TEXT[] NOT NULL,      -- exact match allow list
+    scopes TEXT[] NOT NULL,             -- most the client may ask for
+    secret_hash TEXT NULL,              -- sha256 of the secret, "null" for public clients
+    -- link owner_id to oauth_clients as fk
+    FOREIGN KEY (owner_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan oauth_clients
+);
+
+CREATE TABLE oauth_codes (
+    id UUID PRIMARY KEY,                -- our pk, names the grant its tokens belong to
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    code_hash TEXT NOT NULL UNIQUE,     -- sha256 of the code, never the code
+    client_id UUID NOT NULL,            -- client the code was issued to for fk
+    user_id UUID NOT NULL,              -- consenting user for fk
+    redirect_uri TEXT NOT NULL,         -- must be repeated at the token endpoint
+    scopes TEXT[] NOT NULL,             -- consented scopes
+    code_challenge TEXT NOT NULL,       -- pkce S256 challenge
+    expires_at TIMESTAMP NOT NULL,      -- expiration checking
+    used_at TIMESTAMP NULL,             -- defaults to "null", kept until expiry to catch replays
+    -- link client_id to oauth_codes as fk
+    FOREIGN KEY (client_id) -- select fk
+        REFERENCES oauth_clients (id) -- match with id in oauth_clients
+        ON DELETE CASCADE, -- prevents orphan oauth_codes
+    -- link user_id to oauth_codes as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan oauth_codes
+);
+
+CREATE TABLE oauth_refresh_tokens (
+    id UUID PRIMARY KEY,                -- our pk
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    token_hash TEXT NOT NULL UNIQUE,    -- sha256 of the token, never the token
+    grant_id UUID NOT NULL,             -- the code it descends from, shared across rotations
+    client_id UUID NOT NULL,            -- client the token was issued to for fk
+    user_id UUID NOT NULL,              -- consenting user for fk
+    scopes TEXT[] NOT NULL,             -- consented scopes
+    expires_at TIMESTAMP NOT NULL,      -- expiration checking
+    revoked_at TIMESTAMP NULL,          -- defaults to "null", set on rotation or revocation
+    -- link client_id to oauth_refresh_tokens as fk
+    FOREIGN KEY (client_id) -- select fk
+        REFERENCES oauth_clients (id) -- match with id in oauth_clients
+        ON DELETE CASCADE, -- prevents orphan oauth_refresh_tokens
+    -- link user_id to oauth_refresh_tokens as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan oauth_refresh_tokens
+);
+
+-- +goose Down
+DROP TABLE oauth_refresh_tokens;
+DROP TABLE oauth_codes;
+DROP TABLE oauth_clients;
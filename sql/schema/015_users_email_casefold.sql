@@ -0,0 +1,31 @@
+-- 015_users_email_casefold.sql
+-- +goose Up
+-- accounts whose emails differ only by case must be merged by hand first
+-- +goose StatementBegin
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    SELECT string_agg(folded, ', ') INTO collisions
+    FROM (
+        SELECT lower(email) AS folded
+        FROM users
+        GROUP BY lower(email)
+        HAVING COUNT(*) > 1
+    ) dupes;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'emails used by more than one account when case is ignored, merge them first: %', collisions;
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+-- store every address case folded
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+
+-- Foo@x.com and foo@x.com are the same account
+CREATE UNIQUE INDEX users_email_lower_key ON users (lower(email));
+
+-- +goose Down
+DROP INDEX users_email_lower_key;
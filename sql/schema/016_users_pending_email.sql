@@ -0,0 +1,25 @@
+-- 016_users_pending_email.sql
+-- +goose Up
+ALTER TABLE users
+-- requested new address, "null" unless a change awaits confirmation
+ADD COLUMN pending_email TEXT NULL;
+
+ALTER TABLE email_tokens
+-- allow email change confirmations alongside the others
+DROP CONSTRAINT email_tokens_purpose_check,
+ADD CONSTRAINT email_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'magic_login', 'change_email'));
+
+-- +goose Down
+-- change confirmations can't survive the old check
+DELETE FROM email_tokens WHERE purpose = 'change_email';
+
+ALTER TABLE email_tokens
+-- back to verify, reset and magic login
+DROP CONSTRAINT email_tokens_purpose_check,
+ADD CONSTRAINT email_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'magic_login'));
+
+ALTER TABLE users
+-- drop the col to undo
+DROP COLUMN pending_email;
@@ -0,0 +1,25 @@
+-- 017_users_profile.sql
+-- +goose Up
+ALTER TABLE users
+-- public @handle, stored lowercase, "null" until the user picks one
+ADD COLUMN handle TEXT NULL,
+-- name shown next to the handle
+ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+-- short public bio
+ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+-- profile picture, "null" for none
+ADD COLUMN avatar_url TEXT NULL
+;
+
+-- one account per handle, whatever the case
+CREATE UNIQUE INDEX users_handle_lower_key ON users (lower(handle));
+
+-- +goose Down
+DROP INDEX users_handle_lower_key;
+
+ALTER TABLE users
+-- drop the cols to undo
+DROP COLUMN avatar_url,
+DROP COLUMN bio,
+DROP COLUMN display_name,
+DROP COLUMN handle;
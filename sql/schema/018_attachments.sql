@@ -0,0 +1,29 @@
+-- 018_attachments.sql
+-- +goose Up
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,                -- our pk, also names the blobs
+    created_at TIMESTAMP NOT NULL,      -- for auditing
+    user_id UUID NOT NULL,              -- uploader for fk
+    chirp_id UUID NULL,                 -- "null" until a chirp uses it
+    position INT NOT NULL DEFAULT 0,    -- order within the chirp
+    content_type TEXT NOT NULL,         -- sniffed, never the client's claim
+    size_bytes BIGINT NOT NULL,         -- of the stored original
+    width INT NOT NULL,                 -- pixels, after orientation
+    height INT NOT NULL,                -- pixels, after orientation
+    blob_key TEXT NOT NULL,             -- original in the blob store
+    thumbnail_key TEXT NOT NULL,        -- thumbnail in the blob store
+    -- link user_id to attachments as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE, -- prevents orphan attachments
+    -- link chirp_id to attachments as fk
+    FOREIGN KEY (chirp_id) -- select fk
+        REFERENCES chirps (id) -- match with id in chirps
+        ON DELETE CASCADE -- prevents orphan attachments
+);
+
+-- chirp pages look attachments up by chirp
+CREATE INDEX attachments_chirp_id_idx ON attachments (chirp_id);
+
+-- +goose Down
+DROP TABLE attachments;
@@ -0,0 +1,11 @@
+-- 019_users_avatar.sql
+-- +goose Up
+ALTER TABLE users
+-- uploaded avatar blob prefix, "null" shows the generated identicon
+ADD COLUMN avatar_key TEXT NULL
+;
+
+-- +goose Down
+ALTER TABLE users
+-- drop the col to undo
+DROP COLUMN avatar_key;
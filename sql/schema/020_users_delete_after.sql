@@ -0,0 +1,16 @@
+-- 020_users_delete_after.sql
+-- +goose Up
+ALTER TABLE users
+-- when a requested deletion goes through, "null" unless one is pending
+ADD COLUMN delete_after TIMESTAMP NULL
+;
+
+-- the purge only looks at pending deletions
+CREATE INDEX users_delete_after_idx ON users (delete_after) WHERE delete_after IS NOT NULL;
+
+-- +goose Down
+DROP INDEX users_delete_after_idx;
+
+ALTER TABLE users
+-- drop the col to undo
+DROP COLUMN delete_after;
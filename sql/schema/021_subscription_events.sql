@@ -0,0 +1,19 @@
+-- 021_subscription_events.sql
+-- +goose Up
+CREATE TABLE subscription_events (
+    id UUID PRIMARY KEY,           -- unique id
+    created_at TIMESTAMP NOT NULL, -- when the event arrived
+    user_id UUID NOT NULL,         -- user id for fk
+    event TEXT NOT NULL,           -- billing event name, e.g. user.upgraded
+    plan TEXT NOT NULL,            -- plan after the event
+    -- link user_id to subscription_events as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- history goes with the account
+);
+
+-- a user's history, oldest first
+CREATE INDEX subscription_events_user_id_idx ON subscription_events (user_id, created_at);
+
+-- +goose Down
+DROP TABLE subscription_events;
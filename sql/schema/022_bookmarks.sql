@@ -0,0 +1,44 @@
+-- 022_bookmarks.sql
+-- +goose Up
+CREATE TABLE bookmark_collections (
+    id UUID PRIMARY KEY,           -- unique id
+    created_at TIMESTAMP NOT NULL, -- for auditing
+    updated_at TIMESTAMP NOT NULL, -- for auditing
+    user_id UUID NOT NULL,         -- owner for fk, collections are private
+    name TEXT NOT NULL,            -- shown to the owner only
+    -- link user_id to bookmark_collections as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan bookmark_collections
+);
+
+-- one collection per name and owner, whatever the case
+CREATE UNIQUE INDEX bookmark_collections_user_name_key ON bookmark_collections (user_id, lower(name));
+
+CREATE TABLE bookmarks (
+    user_id UUID NOT NULL,         -- who saved it
+    chirp_id UUID NOT NULL,        -- what they saved
+    created_at TIMESTAMP NOT NULL, -- when, bookmarks list newest first
+    collection_id UUID NULL,       -- "null" for uncollected
+    -- a chirp is bookmarked once per user
+    PRIMARY KEY (user_id, chirp_id),
+    -- link user_id to bookmarks as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE, -- prevents orphan bookmarks
+    -- link chirp_id to bookmarks as fk
+    FOREIGN KEY (chirp_id) -- select fk
+        REFERENCES chirps (id) -- match with id in chirps
+        ON DELETE CASCADE, -- prevents orphan bookmarks
+    -- link collection_id to bookmarks as fk
+    FOREIGN KEY (collection_id) -- select fk
+        REFERENCES bookmark_collections (id) -- match with id in bookmark_collections
+        ON DELETE SET NULL -- deleting a collection keeps its bookmarks
+);
+
+-- pages of a user's bookmarks, newest first
+CREATE INDEX bookmarks_user_created_idx ON bookmarks (user_id, created_at DESC, chirp_id DESC);
+
+-- +goose Down
+DROP TABLE bookmarks;
+DROP TABLE bookmark_collections;
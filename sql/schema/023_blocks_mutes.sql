@@ -0,0 +1,44 @@
+-- 023_blocks_mutes.sql
+-- +goose Up
+CREATE TABLE user_blocks (
+    blocker_id UUID NOT NULL,      -- who blocked
+    blocked_id UUID NOT NULL,      -- who they blocked, neither sees the other
+    created_at TIMESTAMP NOT NULL, -- when, lists newest first
+    -- a user is blocked once per blocker
+    PRIMARY KEY (blocker_id, blocked_id),
+    -- nobody blocks themselves
+    CHECK (blocker_id <> blocked_id),
+    -- link blocker_id to user_blocks as fk
+    FOREIGN KEY (blocker_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE, -- prevents orphan user_blocks
+    -- link blocked_id to user_blocks as fk
+    FOREIGN KEY (blocked_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan user_blocks
+);
+
+-- blocks work both ways, so look them up from the blocked side too
+CREATE INDEX user_blocks_blocked_idx ON user_blocks (blocked_id);
+
+CREATE TABLE user_mutes (
+    muter_id UUID NOT NULL,        -- who muted
+    muted_id UUID NOT NULL,        -- who they muted, hidden from the muter's feeds only
+    created_at TIMESTAMP NOT NULL, -- when, lists newest first
+    -- a user is muted once per muter
+    PRIMARY KEY (muter_id, muted_id),
+    -- nobody mutes themselves
+    CHECK (muter_id <> muted_id),
+    -- link muter_id to user_mutes as fk
+    FOREIGN KEY (muter_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE, -- prevents orphan user_mutes
+    -- link muted_id to user_mutes as fk
+    FOREIGN KEY (muted_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE -- prevents orphan user_mutes
+);
+
+-- +goose Down
+DROP TABLE user_mutes;
+DROP TABLE user_blocks;
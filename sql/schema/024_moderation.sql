@@ -0,0 +1,88 @@
+-- 024_moderation.sql
+-- +goose Up
+ALTER TABLE chirps
+-- set when a moderator hides the chirp, only its author still sees it
+ADD COLUMN hidden_at TIMESTAMP NULL;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,                 -- unique id
+    created_at TIMESTAMP NOT NULL,       -- for auditing, the queue is oldest first
+    updated_at TIMESTAMP NOT NULL,       -- for auditing
+    kind TEXT NOT NULL,                  -- chirp or user
+    reporter_id UUID NOT NULL,           -- who reported
+    user_id UUID NOT NULL,               -- who was reported, the author for chirp reports
+    chirp_id UUID NULL,                  -- the reported chirp, "null" for user reports or once deleted
+    chirp_body TEXT NULL,                -- the chirp as reported, kept after it's deleted
+    reason TEXT NOT NULL,                -- reason code
+    details TEXT NOT NULL DEFAULT '',    -- reporter's own words
+    status TEXT NOT NULL DEFAULT 'open', -- open, dismissed or actioned
+    resolved_at TIMESTAMP NULL,          -- "null" while open
+    -- link reporter_id to reports as fk
+    FOREIGN KEY (reporter_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE, -- prevents orphan reports
+    -- link user_id to reports as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE, -- prevents orphan reports
+    -- link chirp_id to reports as fk
+    FOREIGN KEY (chirp_id) -- select fk
+        REFERENCES chirps (id) -- match with id in chirps
+        ON DELETE SET NULL -- the report outlives the chirp
+);
+
+-- the moderation queue
+CREATE INDEX reports_status_created_idx ON reports (status, created_at);
+
+-- one open report per reporter and target
+CREATE UNIQUE INDEX reports_open_chirp_key ON reports (reporter_id, chirp_id) WHERE status = 'open' AND kind = 'chirp';
+CREATE UNIQUE INDEX reports_open_user_key ON reports (reporter_id, user_id) WHERE status = 'open' AND kind = 'user';
+
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,           -- unique id
+    created_at TIMESTAMP NOT NULL, -- for auditing
+    report_id UUID NOT NULL,       -- what was acted on
+    moderator_id UUID NULL,        -- who acted, "null" once their account is gone
+    action TEXT NOT NULL,          -- dismiss, hide_chirp, delete_chirp or suspend_user
+    notes TEXT NOT NULL DEFAULT '', -- for other moderators, never shown to users
+    -- link report_id to moderation_actions as fk
+    FOREIGN KEY (report_id) -- select fk
+        REFERENCES reports (id) -- match with id in reports
+        ON DELETE CASCADE, -- prevents orphan moderation_actions
+    -- link moderator_id to moderation_actions as fk
+    FOREIGN KEY (moderator_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE SET NULL -- the audit trail outlives the moderator
+);
+
+-- a report's history
+CREATE INDEX moderation_actions_report_idx ON moderation_actions (report_id, created_at);
+
+CREATE TABLE suspensions (
+    id UUID PRIMARY KEY,           -- unique id
+    created_at TIMESTAMP NOT NULL, -- for auditing
+    user_id UUID NOT NULL,         -- who is suspended
+    moderator_id UUID NULL,        -- who suspended them, "null" once their account is gone
+    reason TEXT NOT NULL,          -- shown to the suspended user
+    ends_at TIMESTAMP NOT NULL,    -- suspended until
+    -- link user_id to suspensions as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE, -- prevents orphan suspensions
+    -- link moderator_id to suspensions as fk
+    FOREIGN KEY (moderator_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE SET NULL -- the history outlives the moderator
+);
+
+-- a user's suspensions, latest ending first
+CREATE INDEX suspensions_user_ends_idx ON suspensions (user_id, ends_at DESC);
+
+-- +goose Down
+DROP TABLE suspensions;
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+
+ALTER TABLE chirps
+-- drop the col to undo
+DROP COLUMN hidden_at;
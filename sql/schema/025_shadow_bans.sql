@@ -0,0 +1,33 @@
+-- 025_shadow_bans.sql
+-- +goose Up
+ALTER TABLE suspensions
+-- set when a moderator ends a suspension early
+ADD COLUMN lifted_at TIMESTAMP NULL;
+
+CREATE TABLE shadow_bans (
+    id UUID PRIMARY KEY,           -- unique id
+    created_at TIMESTAMP NOT NULL, -- for auditing
+    user_id UUID NOT NULL,         -- who is shadow banned, their chirps are seen by them only
+    moderator_id UUID NULL,        -- who banned them, "null" once their account is gone
+    reason TEXT NOT NULL,          -- for other moderators, never shown to the user
+    ends_at TIMESTAMP NOT NULL,    -- banned until
+    lifted_at TIMESTAMP NULL,      -- set when a moderator ends it early
+    -- link user_id to shadow_bans as fk
+    FOREIGN KEY (user_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE CASCADE, -- prevents orphan shadow_bans
+    -- link moderator_id to shadow_bans as fk
+    FOREIGN KEY (moderator_id) -- select fk
+        REFERENCES users (id) -- match with id in users
+        ON DELETE SET NULL -- the history outlives the moderator
+);
+
+-- a user's shadow bans, latest ending first
+CREATE INDEX shadow_bans_user_ends_idx ON shadow_bans (user_id, ends_at DESC);
+
+-- +goose Down
+DROP TABLE shadow_bans;
+
+ALTER TABLE suspensions
+-- drop the col to undo
+DROP COLUMN lifted_at;
@@ -0,0 +1,18 @@
+-- 026_spam_holds.sql
+-- +goose Up
+ALTER TABLE reports
+-- "null" for chirps held for review by the spam filter, nobody reported them
+ALTER COLUMN reporter_id DROP NOT NULL;
+
+-- a user's latest chirps, for the spam filter's duplicate and rate checks
+CREATE INDEX chirps_user_created_idx ON chirps (user_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX chirps_user_created_idx;
+
+-- the spam filter's reports have no reporter to keep
+DELETE FROM reports WHERE reporter_id IS NULL;
+
+ALTER TABLE reports
+-- restore the constraint to undo
+ALTER COLUMN reporter_id SET NOT NULL;
@@ -0,0 +1,11 @@
+-- 027_pending_attachments.sql
+-- +goose Up
+-- uploads no chirp has used yet, for the per user cap
+CREATE INDEX attachments_pending_user_idx ON attachments (user_id) WHERE chirp_id IS NULL;
+
+-- and for the sweep of abandoned ones
+CREATE INDEX attachments_pending_created_idx ON attachments (created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP INDEX attachments_pending_created_idx;
+DROP INDEX attachments_pending_user_idx;
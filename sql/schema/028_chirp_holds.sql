@@ -0,0 +1,21 @@
+-- 028_chirp_holds.sql
+-- +goose Up
+ALTER TABLE chirps
+-- "null" unless the spam filter holds it for review, kept apart from hidden_at so releasing a hold never undoes a moderator
+ADD COLUMN held_at TIMESTAMP NULL;
+
+-- chirps the spam filter hid while their report is still open are holds, not moderator hides
+UPDATE chirps
+SET held_at = hidden_at, hidden_at = NULL
+WHERE hidden_at IS NOT NULL
+  AND id IN (SELECT chirp_id FROM reports WHERE reporter_id IS NULL AND status = 'open');
+
+-- +goose Down
+-- fold holds back into hidden_at
+UPDATE chirps
+SET hidden_at = COALESCE(hidden_at, held_at)
+WHERE held_at IS NOT NULL;
+
+ALTER TABLE chirps
+-- drop the column to undo
+DROP COLUMN held_at;
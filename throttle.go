@@ -0,0 +1,177 @@
+// throttle.go
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/lockout"
+	"github.com/google/uuid"
+)
+
+// login throttling policies
+var (
+	// per account, tight (nobody mistypes a password ten times)
+	accountLoginPolicy = lockout.Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+		ResetAfter:       time.Hour,
+	}
+
+	// per ip, looser (offices and phones share ips)
+	ipLoginPolicy = lockout.Policy{
+		FreeAttempts:     10,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 50,
+		LockoutDuration:  15 * time.Minute,
+		ResetAfter:       time.Hour,
+	}
+
+	// per email, magic links sent (every send counts, a link is only useful once)
+	magicLinkPolicy = lockout.Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Minute,
+		MaxDelay:         15 * time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  time.Hour,
+		ResetAfter:       time.Hour,
+	}
+)
+
+// throttle key for an account, by the email it logs in with
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// throttle key for magic links sent to an email
+func magicLinkThrottleKey(email string) string {
+	return "magic:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// throttle key for password reset links sent to an email
+func passwordResetThrottleKey(email string) string {
+	return "reset:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// throttle key for a client ip
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// throttle key for second factor attempts, separate from the password counter
+func mfaThrottleKey(userID uuid.UUID) string {
+	return "mfa:" + userID.String()
+}
+
+// how long this email + ip must wait before another login attempt
+// store errors fail open (and get logged), an outage shouldn't lock everyone out
+func (apiCfg *apiConfig) loginWait(ctx context.Context, email, ip string) time.Duration {
+	// per account wait
+	accountWait, err := apiCfg.accountLimiter.Check(ctx, accountThrottleKey(email))
+	if err != nil {
+		log.Printf("Error checking account login throttle: %s", err)
+	}
+
+	// per ip wait
+	ipWait, err := apiCfg.ipLimiter.Check(ctx, ipThrottleKey(ip))
+	if err != nil {
+		log.Printf("Error checking ip login throttle: %s", err)
+	}
+
+	// longest wait wins
+	return max(accountWait, ipWait)
+}
+
+// record a failed login attempt against the email and ip
+func (apiCfg *apiConfig) loginFailed(ctx context.Context, email, ip string) {
+	// per account failure
+	_, err := apiCfg.accountLimiter.Fail(ctx, accountThrottleKey(email))
+	if err != nil {
+		log.Printf("Error recording account login failure: %s", err)
+	}
+
+	// per ip failure
+	_, err = apiCfg.ipLimiter.Fail(ctx, ipThrottleKey(ip))
+	if err != nil {
+		log.Printf("Error recording ip login failure: %s", err)
+	}
+}
+
+// clear the account's failures after a successful login
+// the ip keeps its count, a shared ip may still be hosting an attacker
+func (apiCfg *apiConfig) loginSucceeded(ctx context.Context, email string) {
+	err := apiCfg.accountLimiter.Reset(ctx, accountThrottleKey(email))
+	if err != nil {
+		log.Printf("Error resetting account login throttle: %s", err)
+	}
+}
+
+// how long this user + ip must wait before another second factor attempt
+// same fail open rules as the password step
+func (apiCfg *apiConfig) mfaWait(ctx context.Context, userID uuid.UUID, ip string) time.Duration {
+	// per account wait
+	accountWait, err := apiCfg.accountLimiter.Check(ctx, mfaThrottleKey(userID))
+	if err != nil {
+		log.Printf("Error checking account mfa throttle: %s", err)
+	}
+
+	// per ip wait
+	ipWait, err := apiCfg.ipLimiter.Check(ctx, ipThrottleKey(ip))
+	if err != nil {
+		log.Printf("Error checking ip mfa throttle: %s", err)
+	}
+
+	// longest wait wins
+	return max(accountWait, ipWait)
+}
+
+// record a failed second factor attempt against the user and ip
+func (apiCfg *apiConfig) mfaFailed(ctx context.Context, userID uuid.UUID, ip string) {
+	// per account failure
+	_, err := apiCfg.accountLimiter.Fail(ctx, mfaThrottleKey(userID))
+	if err != nil {
+		log.Printf("Error recording account mfa failure: %s", err)
+	}
+
+	// per ip failure
+	_, err = apiCfg.ipLimiter.Fail(ctx, ipThrottleKey(ip))
+	if err != nil {
+		log.Printf("Error recording ip mfa failure: %s", err)
+	}
+}
+
+// clear the user's second factor failures after a good code
+func (apiCfg *apiConfig) mfaSucceeded(ctx context.Context, userID uuid.UUID) {
+	err := apiCfg.accountLimiter.Reset(ctx, mfaThrottleKey(userID))
+	if err != nil {
+		log.Printf("Error resetting account mfa throttle: %s", err)
+	}
+}
+
+// forget quiet login attempt records on an interval, blocks so run it in a goroutine
+// every limiter shares one store, so the longest quiet period among them is used
+func (apiCfg *apiConfig) runLoginAttemptPrune(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	olderThan := max(accountLoginPolicy.ResetAfter, ipLoginPolicy.ResetAfter, magicLinkPolicy.ResetAfter)
+
+	// prune on each tick
+	for range ticker.C {
+		dropped, err := apiCfg.ipLimiter.Prune(context.Background(), olderThan)
+
+		// prune check (whatever is left goes next tick)
+		if err != nil {
+			log.Printf("Error pruning login attempts: %s", err)
+			continue
+		}
+		if dropped > 0 {
+			log.Printf("Pruned %d quiet login attempt records", dropped)
+		}
+	}
+}
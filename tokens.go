@@ -1,13 +1,82 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
 )
 
+// token lifetimes
+const (
+	accessTokenDuration  = time.Hour           // 1 hour
+	refreshTokenDuration = 60 * 24 * time.Hour // 60 days
+)
+
+// make an access token for a user, embedding plan and role so handlers skip the db
+func (apiCfg *apiConfig) makeAccessToken(user database.User) (string, error) {
+	// premium users get the red plan
+	plan := auth.PlanFree
+	if user.IsChirpyRed {
+		plan = auth.PlanChirpyRed
+	}
+
+	// sign the token with our server key
+	return auth.MakeAccessToken(user.ID, apiCfg.serverKey, accessTokenDuration, auth.TokenOptions{
+		Plan: plan,
+		Role: user.Role,
+	})
+}
+
+// make a fresh access and refresh token pair for a user that just logged in
+func (apiCfg *apiConfig) issueLoginTokens(ctx context.Context, user database.User) (JsonUserLoginResponse, error) {
+	// make JWT token
+	tokenString, err := apiCfg.makeAccessToken(user)
+
+	// check make jwt
+	if err != nil {
+		return JsonUserLoginResponse{}, fmt.Errorf("making access token: %w", err)
+	}
+
+	// generate a refresh token
+	tokenRefreshString, err := auth.MakeRefreshToken()
+
+	// check generate refresh token
+	if err != nil {
+		return JsonUserLoginResponse{}, fmt.Errorf("making refresh token: %w", err)
+	}
+
+	// set default expiration time for refresh token
+	expiresRefreshTimestamp := time.Now().UTC().Add(refreshTokenDuration) // conv to timestamp for PostgreSQL
+
+	// add refresh token to the db
+	_, err = apiCfg.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     tokenRefreshString,      // add refresh token string
+		UserID:    user.ID,                 // set to correct user
+		ExpiresAt: expiresRefreshTimestamp, // conv to postgresql time
+	})
+
+	// create refresh token check
+	if err != nil {
+		return JsonUserLoginResponse{}, fmt.Errorf("adding refresh token to database: %w", err)
+	}
+
+	// json response payload
+	return JsonUserLoginResponse{
+		ID:           user.ID,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+		Email:        user.Email,
+		Token:        tokenString,
+		RefreshToken: tokenRefreshString,
+		IsChirpyRed:  user.IsChirpyRed,
+	}, nil
+}
+
 // Refresh handler that reissues access token if refresh is valid
 func (apiCfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request) {
 	// apiConfig check
@@ -64,11 +133,19 @@ func (apiCfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request
 		return                                                      // early return
 	}
 
-	// set default expiration time for JWT
-	expiresDuration := 3600 * time.Second // 1 hour
+	// get the user for their current plan and role
+	tokenUser, err := apiCfg.db.GetUserByID(req.Context(), loginUser.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user for refresh token: %s", err) // log msg with err
+		// helper to insert error msg + 401 unauthorised req status
+		WriteJSONError(w, "Invalid token", http.StatusUnauthorized) // general error, obscure to client
+		return                                                      // early return
+	}
 
 	// make JWT token
-	tokenString, err := auth.MakeJWT(loginUser.UserID, apiCfg.serverKey, expiresDuration)
+	tokenString, err := apiCfg.makeAccessToken(tokenUser)
 
 	// check make jwt
 	if err != nil {
@@ -0,0 +1,527 @@
+// totp.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/PietPadda/chirpy/internal/totp"
+	"github.com/google/uuid"
+)
+
+// two factor settings
+const (
+	totpIssuer          = "Chirpy"        // shown in the authenticator app
+	mfaChallengeTimeout = 5 * time.Minute // how long the second step may take
+)
+
+// check if a user has confirmed 2fa
+func (apiCfg *apiConfig) totpEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	// get the user's authenticator
+	userTOTP, err := apiCfg.db.GetUserTOTP(ctx, userID)
+
+	// never enrolled
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	// get totp check
+	if err != nil {
+		return false, err
+	}
+
+	// enrolment only counts once confirmed
+	return userTOTP.ConfirmedAt.Valid, nil
+}
+
+// write the challenge a 2fa user gets instead of tokens after the password step
+func (apiCfg *apiConfig) writeMFAChallenge(w http.ResponseWriter, userID uuid.UUID) {
+	// make the short lived challenge token
+	mfaToken, err := auth.MakeMFAToken(userID, apiCfg.serverKey, mfaChallengeTimeout)
+
+	// make challenge check
+	if err != nil {
+		log.Printf("Error making mfa token: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Internal server token generation error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload
+	respChallenge := JsonMFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+		ExpiresAt:   time.Now().UTC().Add(mfaChallengeTimeout),
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respChallenge, http.StatusOK)
+}
+
+// check a second factor, a totp code or failing that a recovery code
+// both are single use, a replayed totp code or spent recovery code fails
+func (apiCfg *apiConfig) verifySecondFactor(ctx context.Context, userID uuid.UUID, code, recoveryCode string) error {
+	// recovery code path
+	if code == "" {
+		// nothing given
+		if recoveryCode == "" {
+			return errors.New("no code given")
+		}
+
+		// spend the code (no row means unknown or already used)
+		_, err := apiCfg.db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: totp.HashRecoveryCode(recoveryCode),
+		})
+		return err
+	}
+
+	// get the user's authenticator
+	userTOTP, err := apiCfg.db.GetUserTOTP(ctx, userID)
+
+	// get totp check
+	if err != nil {
+		return err
+	}
+
+	// confirmed check
+	if !userTOTP.ConfirmedAt.Valid {
+		return errors.New("totp is not confirmed")
+	}
+
+	// check the code against the current time window
+	step, err := totp.Validate(userTOTP.Secret, code, time.Now())
+
+	// code check
+	if err != nil {
+		return err
+	}
+
+	// burn the step so the same code can't be used again (no row means replay)
+	_, err = apiCfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		UserID:       userID,
+		LastUsedStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+	return err
+}
+
+// EnrolTOTP handler that starts 2fa enrolment with a new secret
+func (apiCfg *apiConfig) handlerEnrolTOTP(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// get the user, their email labels the authenticator entry
+	user, err := apiCfg.db.GetUserByID(req.Context(), caller.UserID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", caller.UserID, err) // log msg with err
+		// helper to insert error msg + 404 not found status code
+		WriteJSONError(w, "User not found", http.StatusNotFound)
+		return // early return
+	}
+
+	// generate the shared secret
+	secret, err := totp.GenerateSecret()
+
+	// generate secret check
+	if err != nil {
+		log.Printf("Error making totp secret: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Internal server secret generation error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// store the pending secret (replaces any unconfirmed one)
+	_, err = apiCfg.db.UpsertUserTOTP(req.Context(), database.UpsertUserTOTPParams{
+		UserID: caller.UserID,
+		Secret: secret,
+	})
+
+	// already enrolled check (a confirmed row is left untouched, so nothing comes back)
+	if errors.Is(err, sql.ErrNoRows) {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return // early return
+	}
+
+	// store secret check
+	if err != nil {
+		log.Printf("Error storing totp secret: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Error occurred starting two-factor enrolment", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload
+	respEnrol := JsonTOTPEnrolResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, user.Email, secret),
+	}
+
+	// helper to insert body response + 201 created status code
+	WriteJSONResponse(w, respEnrol, http.StatusCreated)
+}
+
+// ConfirmTOTP handler that turns 2fa on once a code checks out, returning recovery codes
+func (apiCfg *apiConfig) handlerConfirmTOTP(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqCode JsonTOTPCodeRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqCode)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqCode is now successfully populated
+
+	// get the pending authenticator
+	userTOTP, err := apiCfg.db.GetUserTOTP(req.Context(), caller.UserID)
+
+	// enrolment check
+	if err != nil || userTOTP.ConfirmedAt.Valid {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "No pending two-factor enrolment", http.StatusConflict)
+		return // early return
+	}
+
+	// check the code proves the app holds the secret
+	step, err := totp.Validate(userTOTP.Secret, reqCode.Code, time.Now())
+
+	// code check
+	if err != nil {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Invalid code", http.StatusBadRequest)
+		return // early return
+	}
+
+	// generate the recovery codes
+	recoveryCodes, err := totp.GenerateRecoveryCodes(totp.RecoveryCodeCount)
+
+	// generate codes check
+	if err != nil {
+		log.Printf("Error making recovery codes: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Internal server recovery code generation error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// hash the codes, only the hashes are stored
+	codeHashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		codeHashes[i] = totp.HashRecoveryCode(code)
+	}
+
+	// store the codes before turning 2fa on, so 2fa is never on without them
+	err = apiCfg.db.ReplaceRecoveryCodes(req.Context(), database.ReplaceRecoveryCodesParams{
+		UserID:  caller.UserID,
+		Column2: codeHashes,
+	})
+
+	// store codes check
+	if err != nil {
+		log.Printf("Error storing recovery codes: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Error occurred confirming two-factor enrolment", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// turn 2fa on, burning the confirming step
+	_, err = apiCfg.db.ConfirmUserTOTP(req.Context(), database.ConfirmUserTOTPParams{
+		UserID:       caller.UserID,
+		LastUsedStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+
+	// confirm check
+	if err != nil {
+		log.Printf("Error confirming totp: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Error occurred confirming two-factor enrolment", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// json response payload, the only time the codes are shown
+	respConfirm := JsonTOTPConfirmResponse{
+		RecoveryCodes: recoveryCodes,
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respConfirm, http.StatusOK)
+}
+
+// DisableTOTP handler that turns 2fa off, needs a current code or a recovery code
+func (apiCfg *apiConfig) handlerDisableTOTP(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
+
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
+		// helper to insert error msg + 401 unauthorized status code
+		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// json request from client
+	var reqCode JsonTOTPCodeRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqCode)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqCode is now successfully populated
+
+	// brute force check, shares the login step's counters so a stolen session can't guess here instead
+	ip := clientIP(req)
+	wait := apiCfg.mfaWait(req.Context(), caller.UserID, ip)
+
+	// throttled check
+	if wait > 0 {
+		log.Printf("Second factor throttled for %s from %s: retry in %s", caller.UserID, ip, wait) // log msg
+		// helper to insert error msg + 429 too many requests status code
+		WriteTooManyRequests(w, "Too many attempts, try again later", wait)
+		return // early return
+	}
+
+	// a stolen session alone can't turn 2fa off
+	err = apiCfg.verifySecondFactor(req.Context(), caller.UserID, reqCode.Code, reqCode.RecoveryCode)
+
+	// second factor check
+	if err != nil {
+		log.Printf("Error verifying second factor for %s: %s", caller.UserID, err) // log msg with err
+		apiCfg.mfaFailed(req.Context(), caller.UserID, ip)                         // count the failure
+		// helper to insert error msg + 403 forbidden status code
+		WriteJSONError(w, "Invalid code", http.StatusForbidden)
+		return // early return
+	}
+
+	// correct code, clear the failures
+	apiCfg.mfaSucceeded(req.Context(), caller.UserID)
+
+	// turn 2fa off
+	err = apiCfg.db.DeleteUserTOTP(req.Context(), caller.UserID)
+
+	// delete totp check
+	if err != nil {
+		log.Printf("Error deleting totp: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Error occurred disabling two-factor authentication", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// the recovery codes go with it (best effort, useless without totp)
+	err = apiCfg.db.DeleteRecoveryCodes(req.Context(), caller.UserID)
+
+	// delete codes check
+	if err != nil {
+		log.Printf("Error deleting recovery codes: %s", err) // log msg with err
+	}
+
+	// 204 no content status code
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LoginMFA handler, the second login step that swaps a challenge and code for tokens
+func (apiCfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, req *http.Request) {
+	// apiConfig check
+	if apiCfg == nil {
+		// handle gracefully
+		log.Printf("Internal server error: apiCfg is nil") // msg to server admin
+		// send msg to client code 500
+		WriteJSONError(w, "Internal server configuration error", http.StatusInternalServerError)
+		return // stop processing req
+	}
+
+	// json request from client
+	var reqMFA JsonMFALoginRequest
+
+	// create json req body decoder
+	decoder := json.NewDecoder(req.Body)
+
+	// close on exit to prevent mem leak
+	defer req.Body.Close()
+
+	// decode the req body
+	err := decoder.Decode(&reqMFA)
+
+	// request body missing edge case check (before general error check)
+	if err == io.EOF { // end of file
+		log.Printf("Error empty request body: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Request body is empty", http.StatusBadRequest)
+		return // early return
+	}
+
+	// decode check
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Something went wrong", http.StatusBadRequest)
+		return // early return
+	}
+
+	// reqMFA is now successfully populated
+
+	// validate the challenge from the password step
+	userID, err := auth.ValidateMFAToken(reqMFA.MFAToken, apiCfg.serverKey)
+
+	// challenge check
+	if err != nil {
+		log.Printf("Error validating mfa token: %s", err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Invalid or expired login challenge", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// brute force check, 6 digits don't take long to guess otherwise
+	ip := clientIP(req)
+	wait := apiCfg.mfaWait(req.Context(), userID, ip)
+
+	// throttled check
+	if wait > 0 {
+		log.Printf("Second factor throttled for %s from %s: retry in %s", userID, ip, wait) // log msg
+		// helper to insert error msg + 429 too many requests status code
+		WriteTooManyRequests(w, "Too many attempts, try again later", wait)
+		return // early return
+	}
+
+	// check the code (or recovery code)
+	err = apiCfg.verifySecondFactor(req.Context(), userID, reqMFA.Code, reqMFA.RecoveryCode)
+
+	// second factor check
+	if err != nil {
+		log.Printf("Error verifying second factor for %s: %s", userID, err) // log msg with err
+		apiCfg.mfaFailed(req.Context(), userID, ip)                         // count the failure
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Invalid code", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// correct code, clear the failures
+	apiCfg.mfaSucceeded(req.Context(), userID)
+
+	// get the user, for the token claims
+	loginUser, err := apiCfg.db.GetUserByID(req.Context(), userID)
+
+	// get user check
+	if err != nil {
+		log.Printf("Error getting user %s: %s", userID, err) // log msg with err
+		// helper to insert error msg + 401 unauthorised status code
+		WriteJSONError(w, "Invalid or expired login challenge", http.StatusUnauthorized)
+		return // early return
+	}
+
+	// suspended check
+	if apiCfg.refuseSuspended(w, req, loginUser.ID) {
+		return // early return
+	}
+
+	// make the access and refresh tokens
+	respLogin, err := apiCfg.issueLoginTokens(req.Context(), loginUser)
+
+	// issue tokens check
+	if err != nil {
+		log.Printf("Error issuing login tokens: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal server error status code
+		WriteJSONError(w, "Internal server token generation error", http.StatusInternalServerError)
+		return // early return
+	}
+
+	// helper to insert body response + 200 ok status code
+	WriteJSONResponse(w, respLogin, http.StatusOK)
+}
@@ -0,0 +1,72 @@
+// totp_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// test the challenge handed out after the password step
+func TestWriteMFAChallenge(t *testing.T) {
+	// test config, challenge tokens need no db
+	apiCfg := &apiConfig{serverKey: "AllYourBase"}
+	userID := uuid.New()
+
+	// write the challenge
+	rec := httptest.NewRecorder()
+	apiCfg.writeMFAChallenge(rec, userID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK) // fatal, don't continue
+	}
+
+	// decode it
+	var resp JsonMFAChallengeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding challenge failed: %v", err) // fatal, don't continue
+	}
+	if !resp.MFARequired {
+		t.Errorf("mfa_required = false, want true")
+	}
+	if resp.ExpiresAt.After(time.Now().Add(mfaChallengeTimeout + time.Minute)) {
+		t.Errorf("challenge expires too late: %s", resp.ExpiresAt)
+	}
+
+	// the challenge names the user, and is no access token
+	got, err := auth.ValidateMFAToken(resp.MFAToken, apiCfg.serverKey)
+	if err != nil || got != userID {
+		t.Errorf("ValidateMFAToken = %s, %v, want %s", got, err, userID)
+	}
+	if _, err := auth.ValidateJWT(resp.MFAToken, apiCfg.serverKey); err == nil {
+		t.Errorf("challenge token accepted as an access token")
+	}
+}
+
+// test the second login step refuses anything but a challenge token
+func TestHandlerLoginMFARejectsAccessToken(t *testing.T) {
+	// test config, rejected before any db access
+	apiCfg := &apiConfig{serverKey: "AllYourBase"}
+
+	// an access token is not a challenge
+	access, err := auth.MakeJWT(uuid.New(), apiCfg.serverKey, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err) // fatal, don't continue
+	}
+
+	// second step with it
+	body := `{"mfa_token":"` + access + `","code":"123456"}`
+	req := httptest.NewRequest("POST", "/api/login/2fa", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	apiCfg.handlerLoginMFA(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,43 @@
+// tx.go
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/PietPadda/chirpy/internal/database"
+)
+
+// what the handlers need from the db, the sqlc queries plus transactions
+type querier interface {
+	database.Querier
+
+	// run fn in a transaction, committed when it returns nil and rolled back otherwise
+	inTx(ctx context.Context, fn func(q database.Querier) error) error
+}
+
+// querier backed by postgres
+type sqlQuerier struct {
+	*database.Queries
+	conn *sql.DB // for transactions
+}
+
+// run fn in a transaction, committed when it returns nil and rolled back otherwise
+func (sq sqlQuerier) inTx(ctx context.Context, fn func(q database.Querier) error) error {
+	// start it
+	tx, err := sq.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// a no-op once committed
+	defer tx.Rollback()
+
+	// do the work
+	err = fn(sq.WithTx(tx))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
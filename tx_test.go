@@ -0,0 +1,299 @@
+// tx_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PietPadda/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// FAKES
+// stand-in for the db, answering the queries the handler tests need from memory
+// any other query panics on the nil database.Querier, so a test can't quietly skip one
+type fakeQuerier struct {
+	database.Querier
+
+	users      map[uuid.UUID]database.User
+	chirps     map[uuid.UUID]database.Chirp
+	reports    map[uuid.UUID]database.Report
+	blocked    []uuid.UUID // blocked by, or blocking, the caller
+	muted      []uuid.UUID // muted by the caller
+	restricted []uuid.UUID // suspended or shadow banned
+	calls      []string    // queries run, in order
+}
+
+// new empty fake
+func newFakeQuerier() *fakeQuerier {
+	return &fakeQuerier{
+		users:   make(map[uuid.UUID]database.User),
+		chirps:  make(map[uuid.UUID]database.Chirp),
+		reports: make(map[uuid.UUID]database.Report),
+	}
+}
+
+// whether a query ran
+func (f *fakeQuerier) called(query string) bool {
+	return slices.Contains(f.calls, query)
+}
+
+// no transactions in memory, fn runs straight on the fake
+func (f *fakeQuerier) inTx(ctx context.Context, fn func(q database.Querier) error) error {
+	return fn(f)
+}
+
+// BLOCKS, MUTES AND RESTRICTIONS
+func (f *fakeQuerier) GetBlockedUserIDs(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error) {
+	f.calls = append(f.calls, "GetBlockedUserIDs")
+	return f.blocked, nil
+}
+
+func (f *fakeQuerier) GetMutedUserIDs(ctx context.Context, muterID uuid.UUID) ([]uuid.UUID, error) {
+	f.calls = append(f.calls, "GetMutedUserIDs")
+	return f.muted, nil
+}
+
+func (f *fakeQuerier) IsBlockedEitherWay(ctx context.Context, arg database.IsBlockedEitherWayParams) (bool, error) {
+	f.calls = append(f.calls, "IsBlockedEitherWay")
+	return slices.Contains(f.blocked, arg.OtherID), nil
+}
+
+func (f *fakeQuerier) CreateMute(ctx context.Context, arg database.CreateMuteParams) error {
+	f.calls = append(f.calls, "CreateMute")
+	if _, ok := f.users[arg.MutedID]; !ok {
+		return &pq.Error{Code: "23503"} // foreign key violation, no such user
+	}
+	if !slices.Contains(f.muted, arg.MutedID) {
+		f.muted = append(f.muted, arg.MutedID)
+	}
+	return nil
+}
+
+func (f *fakeQuerier) DeleteMute(ctx context.Context, arg database.DeleteMuteParams) error {
+	f.calls = append(f.calls, "DeleteMute")
+	f.muted = slices.DeleteFunc(f.muted, func(id uuid.UUID) bool { return id == arg.MutedID })
+	return nil
+}
+
+func (f *fakeQuerier) GetRestrictedUserIDs(ctx context.Context) ([]uuid.UUID, error) {
+	f.calls = append(f.calls, "GetRestrictedUserIDs")
+	return f.restricted, nil
+}
+
+func (f *fakeQuerier) IsUserRestricted(ctx context.Context, userID uuid.UUID) (bool, error) {
+	f.calls = append(f.calls, "IsUserRestricted")
+	return slices.Contains(f.restricted, userID), nil
+}
+
+func (f *fakeQuerier) GetActiveSuspension(ctx context.Context, userID uuid.UUID) (database.Suspension, error) {
+	f.calls = append(f.calls, "GetActiveSuspension")
+	return database.Suspension{}, sql.ErrNoRows // nobody is suspended in here
+}
+
+// USERS
+func (f *fakeQuerier) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	f.calls = append(f.calls, "GetUserByID")
+	user, ok := f.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (f *fakeQuerier) GetPublicProfile(ctx context.Context, lower string) (database.GetPublicProfileRow, error) {
+	f.calls = append(f.calls, "GetPublicProfile")
+	for _, user := range f.users {
+		if !strings.EqualFold(user.Handle.String, lower) {
+			continue
+		}
+		profile := database.GetPublicProfileRow{ID: user.ID, CreatedAt: user.CreatedAt, Handle: user.Handle}
+		for _, chirp := range f.chirps {
+			if chirp.UserID == user.ID {
+				profile.ChirpCount++
+			}
+		}
+		return profile, nil
+	}
+	return database.GetPublicProfileRow{}, sql.ErrNoRows
+}
+
+// CHIRPS
+func (f *fakeQuerier) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	f.calls = append(f.calls, "GetChirp")
+	chirp, ok := f.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (f *fakeQuerier) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	f.calls = append(f.calls, "GetChirps")
+	var chirps []database.Chirp
+	for _, chirp := range f.chirps {
+		chirps = append(chirps, chirp)
+	}
+	return chirps, nil
+}
+
+func (f *fakeQuerier) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	f.calls = append(f.calls, "GetChirpsByAuthorID")
+	var chirps []database.Chirp
+	for _, chirp := range f.chirps {
+		if chirp.UserID == userID {
+			chirps = append(chirps, chirp)
+		}
+	}
+	return chirps, nil
+}
+
+func (f *fakeQuerier) GetUserHandles(ctx context.Context, ids []uuid.UUID) ([]database.GetUserHandlesRow, error) {
+	f.calls = append(f.calls, "GetUserHandles")
+	var rows []database.GetUserHandlesRow
+	for _, id := range ids {
+		if user, ok := f.users[id]; ok {
+			rows = append(rows, database.GetUserHandlesRow{ID: id, Handle: user.Handle})
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeQuerier) ListAttachmentsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.Attachment, error) {
+	f.calls = append(f.calls, "ListAttachmentsForChirps")
+	return nil, nil // no uploads in here
+}
+
+func (f *fakeQuerier) LockChirpAuthor(ctx context.Context, userID uuid.UUID) error {
+	f.calls = append(f.calls, "LockChirpAuthor")
+	return nil
+}
+
+func (f *fakeQuerier) GetRecentChirpsForUser(ctx context.Context, arg database.GetRecentChirpsForUserParams) ([]database.GetRecentChirpsForUserRow, error) {
+	f.calls = append(f.calls, "GetRecentChirpsForUser")
+	var rows []database.GetRecentChirpsForUserRow
+	for _, chirp := range f.chirps {
+		if chirp.UserID == arg.UserID && chirp.CreatedAt.After(arg.Since) {
+			rows = append(rows, database.GetRecentChirpsForUserRow{Body: chirp.Body, CreatedAt: chirp.CreatedAt})
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeQuerier) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	f.calls = append(f.calls, "CreateChirp")
+	now := time.Now().UTC()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: arg.Body, UserID: arg.UserID}
+	f.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (f *fakeQuerier) CreateImportedChirp(ctx context.Context, arg database.CreateImportedChirpParams) (database.Chirp, error) {
+	f.calls = append(f.calls, "CreateImportedChirp")
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: arg.CreatedAt, UpdatedAt: arg.CreatedAt, Body: arg.Body, UserID: arg.UserID}
+	f.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (f *fakeQuerier) HideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	f.calls = append(f.calls, "HideChirp")
+	chirp, ok := f.chirps[id]
+	if !ok || chirp.HiddenAt.Valid {
+		return 0, nil
+	}
+	chirp.HiddenAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	f.chirps[id] = chirp
+	return 1, nil
+}
+
+func (f *fakeQuerier) HoldChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	f.calls = append(f.calls, "HoldChirp")
+	chirp, ok := f.chirps[id]
+	if !ok || chirp.HeldAt.Valid {
+		return 0, nil
+	}
+	chirp.HeldAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	f.chirps[id] = chirp
+	return 1, nil
+}
+
+func (f *fakeQuerier) ReleaseChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	f.calls = append(f.calls, "ReleaseChirp")
+	chirp, ok := f.chirps[id]
+	if !ok || !chirp.HeldAt.Valid {
+		return 0, nil
+	}
+	chirp.HeldAt = sql.NullTime{}
+	f.chirps[id] = chirp
+	return 1, nil
+}
+
+// BOOKMARKS
+func (f *fakeQuerier) GetBookmarkedChirpIDs(ctx context.Context, arg database.GetBookmarkedChirpIDsParams) ([]uuid.UUID, error) {
+	f.calls = append(f.calls, "GetBookmarkedChirpIDs")
+	return nil, nil // nothing bookmarked in here
+}
+
+func (f *fakeQuerier) CreateBookmark(ctx context.Context, arg database.CreateBookmarkParams) error {
+	f.calls = append(f.calls, "CreateBookmark")
+	return nil
+}
+
+// REPORTS
+func (f *fakeQuerier) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	f.calls = append(f.calls, "CreateReport")
+	now := time.Now().UTC()
+	report := database.Report{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Kind:       arg.Kind,
+		ReporterID: arg.ReporterID,
+		UserID:     arg.UserID,
+		ChirpID:    arg.ChirpID,
+		ChirpBody:  arg.ChirpBody,
+		Reason:     arg.Reason,
+		Details:    arg.Details,
+		Status:     reportStatusOpen,
+	}
+	f.reports[report.ID] = report
+	return report, nil
+}
+
+func (f *fakeQuerier) GetReport(ctx context.Context, id uuid.UUID) (database.Report, error) {
+	f.calls = append(f.calls, "GetReport")
+	report, ok := f.reports[id]
+	if !ok {
+		return database.Report{}, sql.ErrNoRows
+	}
+	return report, nil
+}
+
+func (f *fakeQuerier) ResolveReport(ctx context.Context, arg database.ResolveReportParams) (int64, error) {
+	f.calls = append(f.calls, "ResolveReport")
+	report, ok := f.reports[arg.ID]
+	if !ok || report.Status != reportStatusOpen {
+		return 0, nil
+	}
+	report.Status = arg.Status
+	report.ResolvedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	f.reports[arg.ID] = report
+	return 1, nil
+}
+
+func (f *fakeQuerier) CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error) {
+	f.calls = append(f.calls, "CreateModerationAction")
+	return database.ModerationAction{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now().UTC(),
+		ReportID:    arg.ReportID,
+		ModeratorID: arg.ModeratorID,
+		Action:      arg.Action,
+		Notes:       arg.Notes,
+	}, nil
+}
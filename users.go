@@ -3,14 +3,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
-	"time"
 
 	// our internal package
 	// postgresql db access
-	"github.com/PietPadda/chirpy/internal/auth"
 	"github.com/PietPadda/chirpy/internal/database"
 	"github.com/lib/pq" // postgresql driver
 )
@@ -74,21 +73,22 @@ func (apiCfg *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Requ
 		return // early return
 	}
 
-	// ENSURE EMAIL IS UNIQUE (to handle error gracefully)
-	pqErr, isPQError := err.(*pq.Error)
+	// syntax check and case fold
+	email, err := normaliseEmail(reqEmail.Email)
+	if err != nil {
+		log.Printf("Error invalid email: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Email is not a valid address", http.StatusBadRequest)
+		return // early return
+	}
 
-	// handle specific error first
-	// check if url duplication occurred
-	if isPQError && pqErr.Code == "23505" {
-		// the error exists and it matches the PostgreSQL code for unique duplication
-		// graceful degradation
-		log.Printf("Error creating new user: %s", err)
-		WriteJSONError(w, "User is already registered", http.StatusBadRequest)
+	// password policy check (writes field errors on failure)
+	if !apiCfg.checkPasswordPolicy(w, reqEmail.Password, email) {
 		return // early return
 	}
 
 	// hash the password
-	hash, err := auth.HashPassword(reqEmail.Password)
+	hash, err := apiCfg.passwordHasher.Hash(reqEmail.Password)
 
 	// hash check
 	if err != nil {
@@ -100,10 +100,23 @@ func (apiCfg *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Requ
 
 	// create new user using sqlc function
 	newUser, err := apiCfg.db.CreateUser(req.Context(), database.CreateUserParams{
-		HashedPassword: hash,           // hashed password
-		Email:          reqEmail.Email, // directly  user input
+		HashedPassword: hash,  // hashed password
+		Email:          email, // normalised user input
 	})
 
+	// ENSURE EMAIL IS UNIQUE (to handle error gracefully)
+	pqErr, isPQError := err.(*pq.Error)
+
+	// handle specific error first
+	// check if url duplication occurred
+	if isPQError && pqErr.Code == "23505" {
+		// the error exists and it matches the PostgreSQL code for unique duplication
+		// graceful degradation
+		log.Printf("Error creating new user: %s", err)
+		WriteJSONError(w, "User is already registered", http.StatusBadRequest)
+		return // early return
+	}
+
 	// new user check (general)
 	if err != nil {
 		log.Printf("Error creating new user: %s", err) // log msg with err
@@ -112,17 +125,14 @@ func (apiCfg *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Requ
 		return // early return
 	}
 
-	// json response payload
-	respUser := JsonUserResponse{
-		ID:          newUser.ID,
-		CreatedAt:   newUser.CreatedAt,
-		UpdatedAt:   newUser.UpdatedAt,
-		Email:       newUser.Email,
-		IsChirpyRed: newUser.IsChirpyRed,
+	// email a verification link (best effort, the user can ask for another)
+	err = apiCfg.sendVerificationEmail(req.Context(), newUser)
+	if err != nil {
+		log.Printf("Error sending verification email: %s", err) // log msg with err
 	}
 
 	// helper to insert body response + 201 created status code
-	WriteJSONResponse(w, respUser, http.StatusCreated)
+	WriteJSONResponse(w, apiCfg.userResponse(newUser), http.StatusCreated)
 }
 
 // UpdateUser handler that updates user login details
@@ -147,27 +157,19 @@ func (apiCfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Requ
 	// json request from client
 	var reqUpdate JsonUserRequest
 
-	// authenticate before decoding request
-	token, err := auth.GetBearerToken(req.Header) // get the bearer's token
+	// get the authenticated caller (set by the auth middleware)
+	caller, ok := principalFromContext(req.Context())
 
-	// get token check
-	if err != nil {
-		log.Printf("Error getting bearer token: %s", err) // log msg with err
+	// principal check (route registered without the auth middleware)
+	if !ok {
+		log.Printf("Internal server error: no principal in request context") // msg to server admin
 		// helper to insert error msg + 401 unauthorized status code
 		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
 		return // early return
 	}
 
-	// validate the JWT token after getting bearer's token
-	uuidJWTValidated, err := auth.ValidateJWT(token, apiCfg.serverKey) // pass in tokenstring and server secret
-
-	// jwt validation check
-	if err != nil {
-		log.Printf("Error validating JWT token: %s", err) // log msg with err
-		// helper to insert error msg + 401 unauthorized status code
-		WriteJSONError(w, "Unauthorized access", http.StatusUnauthorized)
-		return // early return
-	}
+	// get the validated user id
+	uuidJWTValidated := caller.UserID
 
 	// create json req body decoder
 	decoder := json.NewDecoder(req.Body)
@@ -176,7 +178,7 @@ func (apiCfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Requ
 	defer req.Body.Close()
 
 	// decode the req body
-	err = decoder.Decode(&reqUpdate)
+	err := decoder.Decode(&reqUpdate)
 
 	// request body missing edge case check (before general error check)
 	if err == io.EOF { // end of file
@@ -197,7 +199,14 @@ func (apiCfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Requ
 		return // early return
 	}
 
-	// reqEmail is now successfully populated
+	// reqUpdate is now successfully populated
+
+	// re-authentication check, a stolen access token alone can't take over the account
+	if reqUpdate.CurrentPassword == "" {
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Current password is required", http.StatusBadRequest)
+		return // early return
+	}
 
 	// check email empty
 	if len(reqUpdate.Email) == 0 {
@@ -206,15 +215,13 @@ func (apiCfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Requ
 		return // early return
 	}
 
-	// hash the UPDATED password
-	hash, err := auth.HashPassword(reqUpdate.Password)
-
-	// hash check
+	// syntax check and case fold
+	newEmail, err := normaliseEmail(reqUpdate.Email)
 	if err != nil {
-		log.Printf("Error hashing password: %s", err) // log msg with err
-		// helper to insert error msg + 500 internal error status code
-		WriteJSONError(w, "Internal server error", http.StatusInternalServerError) // generic message
-		return                                                                     // early return
+		log.Printf("Error invalid email: %s", err) // log msg with err
+		// helper to insert error msg + 400 bad req status code
+		WriteJSONError(w, "Email is not a valid address", http.StatusBadRequest)
+		return // early return
 	}
 
 	// get user CURRENT details using VALIDATED id
@@ -227,34 +234,71 @@ func (apiCfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Requ
 		return                                                                     // early return
 	}
 
+	// current password check (writes the error on failure)
+	if !apiCfg.checkCurrentPassword(w, req, currentDetails, reqUpdate.CurrentPassword) {
+		return // early return
+	}
+
+	// is the address changing
+	emailChanging := newEmail != currentDetails.Email
+
+	// is the password changing, hashes are salted so verify rather than compare them
+	_, err = apiCfg.passwordHasher.Verify(currentDetails.HashedPassword, reqUpdate.Password)
+	passwordChanging := err != nil
+
 	// check if email OR password didn't change (at least 1 one must, should update if NOT actually updating!)
-	if reqUpdate.Email == currentDetails.Email && hash == currentDetails.HashedPassword { // if both the same
-		log.Printf("Error email or password didn't change: %s", err) // log msg with err
+	if !emailChanging && !passwordChanging { // if both the same
+		log.Printf("Error email or password didn't change for user %s", uuidJWTValidated) // log msg
 		// helper to insert error msg + 400 bad request error status code
 		WriteJSONError(w, "Email or password didn't change", http.StatusBadRequest) // generic message
 		return                                                                      // early return
 	}
 
-	// create new user using sqlc function
-	userUpdatedAt, err := apiCfg.db.UpdateUserLogin(req.Context(), database.UpdateUserLoginParams{
-		ID:             uuidJWTValidated, // from get userID from validated token
-		HashedPassword: hash,             // hashed password
-		Email:          reqUpdate.Email,  // directly  user input
-	})
+	// keep the stored hash unless there's a new password
+	hash := currentDetails.HashedPassword
+	if passwordChanging {
+		// password policy check (writes field errors on failure)
+		if !apiCfg.checkPasswordPolicy(w, reqUpdate.Password, newEmail) {
+			return // early return
+		}
 
-	// ENSURE EMAIL IS UNIQUE (to handle error gracefully)
-	pqErr, isPQError := err.(*pq.Error)
+		// hash the UPDATED password
+		hash, err = apiCfg.passwordHasher.Hash(reqUpdate.Password)
 
-	// handle specific error first
-	// check if url duplication occurred
-	if isPQError && pqErr.Code == "23505" {
-		// the error exists and it matches the PostgreSQL code for unique duplication
-		// graceful degradation
-		log.Printf("Error updating user: %s", err)
-		WriteJSONError(w, "User email is already used", http.StatusBadRequest)
+		// hash check
+		if err != nil {
+			log.Printf("Error hashing password: %s", err) // log msg with err
+			// helper to insert error msg + 500 internal error status code
+			WriteJSONError(w, "Internal server error", http.StatusInternalServerError) // generic message
+			return                                                                     // early return
+		}
+	}
+
+	// park the new address first, so a conflict doesn't leave the password half applied
+	pendingEmail, err := apiCfg.requestEmailChange(req.Context(), currentDetails, newEmail)
+
+	// taken check
+	if errors.Is(err, errEmailTaken) {
+		// helper to insert error msg + 409 conflict status code
+		WriteJSONError(w, "User email is already used", http.StatusConflict)
+		return // early return
+	}
+
+	// email change check (general)
+	if err != nil {
+		log.Printf("Error requesting email change: %s", err) // log msg with err
+		// helper to insert error msg + 500 internal error status code
+		WriteJSONError(w, "Error occurred updating user", http.StatusInternalServerError)
 		return // early return
 	}
 
+	// update the password, the email only moves once the new inbox confirms
+	userUpdatedAt, err := apiCfg.db.UpdateUserLogin(req.Context(), database.UpdateUserLoginParams{
+		ID:             uuidJWTValidated,     // from get userID from validated token
+		HashedPassword: hash,                 // hashed password
+		Email:          currentDetails.Email, // unchanged until confirmed
+	})
+
 	// update user check (general)
 	if err != nil {
 		log.Printf("Error updating user: %s", err) // log msg with err
@@ -265,10 +309,33 @@ func (apiCfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Requ
 
 	// json response payload
 	respUser := JsonUserUpdatedResponse{
-		ID:          uuidJWTValidated, // from get userID from validated token
-		UpdatedAt:   userUpdatedAt,    // from sqlc code, only return
-		Email:       reqUpdate.Email,  // from client request struct
-		IsChirpyRed: currentDetails.IsChirpyRed,
+		ID:           uuidJWTValidated,     // from get userID from validated token
+		UpdatedAt:    userUpdatedAt,        // from sqlc code, only return
+		Email:        currentDetails.Email, // stays until the change is confirmed
+		PendingEmail: pendingEmail.String,  // empty unless a change awaits confirmation
+		IsChirpyRed:  currentDetails.IsChirpyRed,
+	}
+
+	// a new password signs out everywhere, like the change password endpoint
+	if passwordChanging {
+		// revoke every refresh token (access tokens run out within the hour)
+		err = apiCfg.db.RevokeAllRefreshTokensForUser(req.Context(), uuidJWTValidated)
+		if err != nil {
+			log.Printf("Error revoking refresh tokens after password change: %s", err) // log msg with err
+		}
+
+		// then sign this session back in with a fresh pair
+		respLogin, err := apiCfg.issueLoginTokens(req.Context(), currentDetails)
+
+		// issue tokens check
+		if err != nil {
+			log.Printf("Error issuing tokens after password change: %s", err) // log msg with err
+			// helper to insert error msg + 500 internal error status code
+			WriteJSONError(w, "Password changed, please log in again", http.StatusInternalServerError)
+			return // early return
+		}
+		respUser.Token = respLogin.Token
+		respUser.RefreshToken = respLogin.RefreshToken
 	}
 
 	// helper to insert body response + 200 ok status code
@@ -324,17 +391,27 @@ func (apiCfg *apiConfig) handlerUserLogin(w http.ResponseWriter, req *http.Reque
 		return // early return
 	}
 
-	// set default expiration time for JWT
-	expiresDuration := 3600 * time.Second // 1 hour
-
 	// reqLogin is now successfully populated
 
+	// brute force check, per account and per ip
+	ip := clientIP(req)
+	wait := apiCfg.loginWait(req.Context(), reqLogin.Email, ip)
+
+	// throttled check
+	if wait > 0 {
+		log.Printf("Login throttled for %s from %s: retry in %s", reqLogin.Email, ip, wait) // log msg
+		// helper to insert error msg + 429 too many requests status code
+		WriteTooManyRequests(w, "Too many login attempts, try again later", wait)
+		return // early return
+	}
+
 	// get the user by email
 	loginUser, err := apiCfg.db.GetUserByEmail(req.Context(), reqLogin.Email)
 
 	// get user check
 	if err != nil {
-		log.Printf("Error getting user by email: %s", err) // log msg with err
+		log.Printf("Error getting user by email: %s", err)    // log msg with err
+		apiCfg.loginFailed(req.Context(), reqLogin.Email, ip) // unknown emails count too
 		// helper to insert error msg + 401 unauthorised req status code
 		WriteJSONError(w, "Incorrect email or password", http.StatusUnauthorized)
 		return // early return
@@ -343,69 +420,59 @@ func (apiCfg *apiConfig) handlerUserLogin(w http.ResponseWriter, req *http.Reque
 	// get user hashed password
 	hash := loginUser.HashedPassword
 
-	// compare hashed password with input password
-	err = auth.CheckPasswordHash(hash, reqLogin.Password)
+	// compare hashed password with input password (flags outdated hashes)
+	needsRehash, err := apiCfg.passwordHasher.Verify(hash, reqLogin.Password)
 
 	// hash check
 	if err != nil {
-		log.Printf("Error invalid password: %s", err) // log msg with err
+		log.Printf("Error invalid password: %s", err)         // log msg with err
+		apiCfg.loginFailed(req.Context(), reqLogin.Email, ip) // count the failure
 		// helper to insert error msg + 401 unauthorised error status code
 		WriteJSONError(w, "Incorrect email or password", http.StatusUnauthorized)
 		return // early return
 	}
 
-	// make JWT token
-	tokenString, err := auth.MakeJWT(loginUser.ID, apiCfg.serverKey, expiresDuration)
+	// correct password, clear the account's failures
+	apiCfg.loginSucceeded(req.Context(), reqLogin.Email)
 
-	// check make jwt
-	if err != nil {
-		log.Printf("Error making JWT token: %s", err) // log msg with err
-		// helper to insert error msg + 500 internal server error status code
-		WriteJSONError(w, "Internal server token generation error", http.StatusInternalServerError)
-		return // early return
+	// correct password but outdated hash, upgrade it while we have the plaintext
+	if needsRehash {
+		apiCfg.rehashPassword(req.Context(), loginUser.ID, hash, reqLogin.Password)
 	}
 
-	// generate a refresh token
-	tokenRefreshString, err := auth.MakeRefreshToken()
+	// look up the second factor, password alone isn't enough once 2fa is on
+	mfaEnabled, err := apiCfg.totpEnabled(req.Context(), loginUser.ID)
 
-	// check generate refresh token
+	// 2fa lookup check (fail closed)
 	if err != nil {
-		log.Printf("Error making refresh token: %s", err) // log msg with err
+		log.Printf("Error getting totp for user %s: %s", loginUser.ID, err) // log msg with err
 		// helper to insert error msg + 500 internal server error status code
-		WriteJSONError(w, "Internal server refresh token generation error", http.StatusInternalServerError)
+		WriteJSONError(w, "Error occurred during login", http.StatusInternalServerError)
 		return // early return
 	}
 
-	// set default expiration time for refresh token
-	expiresRefreshDuration := 60 * 24 * time.Hour                           // 60 days
-	expiresRefreshTimestamp := time.Now().UTC().Add(expiresRefreshDuration) // conv to timestamp for PostgreSQL
+	// suspended check
+	if apiCfg.refuseSuspended(w, req, loginUser.ID) {
+		return // early return
+	}
 
-	// add refresh token to the db
-	_, err = apiCfg.db.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
-		Token:     tokenRefreshString,      // add refresh token string
-		UserID:    loginUser.ID,            // set to correct user
-		ExpiresAt: expiresRefreshTimestamp, // conv to postgresql time
-	})
+	// 2fa on, hand back a challenge instead of tokens
+	if mfaEnabled {
+		apiCfg.writeMFAChallenge(w, loginUser.ID)
+		return // early return
+	}
 
-	// create refresh token check
+	// make the access and refresh tokens
+	respLogin, err := apiCfg.issueLoginTokens(req.Context(), loginUser)
+
+	// issue tokens check
 	if err != nil {
-		log.Printf("Error adding refresh token to database: %s", err) // log msg with err
+		log.Printf("Error issuing login tokens: %s", err) // log msg with err
 		// helper to insert error msg + 500 internal server error status code
-		WriteJSONError(w, "Internal server refresh token adding to database error", http.StatusInternalServerError)
+		WriteJSONError(w, "Internal server token generation error", http.StatusInternalServerError)
 		return // early return
 	}
 
-	// json response payload
-	respLogin := JsonUserLoginResponse{
-		ID:           loginUser.ID,
-		CreatedAt:    loginUser.CreatedAt,
-		UpdatedAt:    loginUser.UpdatedAt,
-		Email:        loginUser.Email,
-		Token:        tokenString,
-		RefreshToken: tokenRefreshString,
-		IsChirpyRed:  loginUser.IsChirpyRed,
-	}
-
 	// helper to insert body response + 200 ok  status code
 	WriteJSONResponse(w, respLogin, http.StatusOK)
 }
@@ -3,8 +3,15 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // ERROR helper to make the API much more DRY
@@ -27,6 +34,11 @@ func WriteJSONError(w http.ResponseWriter, message string, statusCode int) {
 	w.Write(dat)                                       // write the response error
 }
 
+// FIELD ERRORS helper, validation problems the client can map onto form fields
+func WriteJSONFieldErrors(w http.ResponseWriter, message string, fields []JsonFieldError, statusCode int) {
+	WriteJSONResponse(w, JsonResponseFieldErrors{Error: message, Fields: fields}, statusCode)
+}
+
 // RESPONSE helper to make the API much more DRY
 // payload to allow ANY type of struct as input
 func WriteJSONResponse(w http.ResponseWriter, payload interface{}, statusCode int) {
@@ -47,3 +59,65 @@ func WriteJSONResponse(w http.ResponseWriter, payload interface{}, statusCode in
 	w.WriteHeader(statusCode)                          // status code
 	w.Write(dat)                                       // write the response body
 }
+
+// TOO MANY REQUESTS helper, tells the client when to come back
+func WriteTooManyRequests(w http.ResponseWriter, message string, wait time.Duration) {
+	// whole seconds, rounded up so clients never retry early
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	// helper to insert error msg + 429 too many requests status code
+	WriteJSONError(w, message, http.StatusTooManyRequests)
+}
+
+// CLIENT IP helper, the address the request came from
+// forwarded headers are ignored as they can be spoofed unless a trusted proxy sets them
+func clientIP(req *http.Request) string {
+	// remote addr is host:port
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+
+	// no port check, use it as is
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// longest address that fits in an smtp path (rfc 5321)
+const maxEmailLength = 254
+
+// EMAIL helper, checks the syntax and case folds so Foo@x.com and foo@x.com are one account
+func normaliseEmail(raw string) (string, error) {
+	// surrounding spaces are a typo, not part of the address
+	raw = strings.TrimSpace(raw)
+
+	// empty check
+	if raw == "" {
+		return "", errors.New("email is empty")
+	}
+
+	// length check
+	if len(raw) > maxEmailLength {
+		return "", errors.New("email is too long")
+	}
+
+	// syntax check
+	addr, err := mail.ParseAddress(raw)
+	if err != nil {
+		return "", err
+	}
+
+	// bare address only, "Name <a@b>" would parse too
+	if addr.Address != raw || addr.Name != "" {
+		return "", errors.New("email must be a bare address")
+	}
+
+	// no dotless domains like root@localhost
+	_, domain, _ := strings.Cut(addr.Address, "@")
+	if !strings.Contains(domain, ".") {
+		return "", errors.New("email domain is incomplete")
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
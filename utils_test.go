@@ -0,0 +1,89 @@
+// utils_test.go
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing" // importing testing package for unit tests
+	"time"
+)
+
+// test the retry after header rounds up
+func TestWriteTooManyRequests(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	// 1.2 seconds must become 2, never 1
+	WriteTooManyRequests(rec, "slow down", 1200*time.Millisecond)
+
+	// status check
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	// header check
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want %q", got, "2")
+	}
+}
+
+// test client ip extraction
+func TestClientIP(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		remoteAddr string
+		expected   string
+	}{
+		{"192.0.2.1:1234", "192.0.2.1"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"192.0.2.1", "192.0.2.1"},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		req := httptest.NewRequest("POST", "/api/login", nil)
+		req.RemoteAddr = tc.remoteAddr
+
+		// check result
+		if actual := clientIP(req); actual != tc.expected {
+			t.Errorf("clientIP(%q) = %q, want %q", tc.remoteAddr, actual, tc.expected)
+		}
+	}
+}
+
+// test email syntax checks and case folding
+func TestNormaliseEmail(t *testing.T) {
+	// build test cases
+	testCases := []struct {
+		raw      string
+		expected string
+		wantErr  bool
+	}{
+		{"foo@example.com", "foo@example.com", false},
+		{"  Foo@Example.COM ", "foo@example.com", false},
+		{"", "", true},
+		{"foo", "", true},
+		{"foo@", "", true},
+		{"foo@localhost", "", true},
+		{"Foo <foo@example.com>", "", true},
+		{"a@b.c, d@e.f", "", true},
+		{strings.Repeat("a", 250) + "@x.io", "", true},
+	}
+
+	// loop through test cases
+	for _, tc := range testCases {
+		actual, err := normaliseEmail(tc.raw)
+
+		// error check
+		if (err != nil) != tc.wantErr {
+			t.Errorf("normaliseEmail(%q) err = %v, wantErr %v", tc.raw, err, tc.wantErr)
+			continue
+		}
+
+		// check result
+		if actual != tc.expected {
+			t.Errorf("normaliseEmail(%q) = %q, want %q", tc.raw, actual, tc.expected)
+		}
+	}
+}
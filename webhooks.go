@@ -9,6 +9,7 @@ import (
 	"net/http"
 
 	"github.com/PietPadda/chirpy/internal/auth"
+	"github.com/PietPadda/chirpy/internal/database"
 )
 
 // PolkaWebhook handler that sets user to premiums
@@ -103,6 +104,18 @@ func (apiCfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, req *http.Re
 		return                                           // stop processing req
 	}
 
+	// keep the history for the user's data export
+	err = apiCfg.db.CreateSubscriptionEvent(req.Context(), database.CreateSubscriptionEventParams{
+		UserID: chirpyRed.ID,
+		Event:  reqChirpyRed.Event,
+		Plan:   auth.PlanChirpyRed,
+	})
+
+	// record event check (the upgrade stands, log it)
+	if err != nil {
+		log.Printf("Error recording subscription event: %s", err) // msg to server admin
+	}
+
 	// write to server and client that user upgrade to chirpy red
 	log.Printf("User has been upgraded to chirpy red: ID = %s", chirpyRed.ID) // log msg with err
 	w.WriteHeader(http.StatusNoContent)                                       // status code 204 to client